@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"html/template"
 	"io"
 	"log"
@@ -16,6 +17,7 @@ import (
 	"poc-finance/internal/database"
 	"poc-finance/internal/handlers"
 	authmw "poc-finance/internal/middleware"
+	"poc-finance/internal/models"
 	"poc-finance/internal/services"
 )
 
@@ -78,9 +80,17 @@ func (t *TemplateRegistry) renderPartial(w io.Writer, name string, data interfac
 		return t.renderPartialFile(w, "internal/templates/partials/"+baseName+".html", data)
 	case strings.Contains(baseName, "tax"):
 		templateFile = "internal/templates/tax-report.html"
+	case strings.Contains(baseName, "period-budget"):
+		templateFile = "internal/templates/period-budgets.html"
 	case strings.Contains(baseName, "budget"):
 		templateFile = "internal/templates/budgets.html"
-	case strings.Contains(baseName, "invite"), strings.Contains(baseName, "joint-accounts"), strings.Contains(baseName, "split-members"), strings.Contains(baseName, "notification"):
+	case strings.Contains(baseName, "subscription"):
+		templateFile = "internal/templates/subscriptions.html"
+	case strings.Contains(baseName, "net-worth"):
+		templateFile = "internal/templates/net-worth.html"
+	case strings.Contains(baseName, "job"):
+		templateFile = "internal/templates/admin-jobs.html"
+	case strings.Contains(baseName, "invite"), strings.Contains(baseName, "joint-accounts"), strings.Contains(baseName, "split-members"), strings.Contains(baseName, "notification"), strings.Contains(baseName, "summary-schedule"), strings.Contains(baseName, "webhook"):
 		return t.renderPartialFile(w, "internal/templates/partials/"+baseName+".html", data)
 	default:
 		return echo.ErrNotFound
@@ -158,6 +168,10 @@ func loadTemplates() *TemplateRegistry {
 		"internal/templates/recurring.html",
 		"internal/templates/tax-report.html",
 		"internal/templates/budgets.html",
+		"internal/templates/period-budgets.html",
+		"internal/templates/subscriptions.html",
+		"internal/templates/net-worth.html",
+		"internal/templates/admin-jobs.html",
 	}
 
 	// Auth pages have their own base template embedded
@@ -195,14 +209,15 @@ func loadTemplates() *TemplateRegistry {
 	return &TemplateRegistry{templates: templates, funcMap: funcMap}
 }
 
-// startRecurringScheduler runs the recurring transaction scheduler in the background
-// It checks for due transactions daily at midnight
-func startRecurringScheduler(schedulerService *services.RecurringSchedulerService) {
-	log.Println("Starting recurring transaction scheduler...")
+// startDailyJob runs a registered job in the background: once on startup and then
+// daily at midnight. Runs are persisted and locked by the job runner, so restarts and
+// multiple instances never process the same work twice.
+func startDailyJob(jobRunner *services.JobRunnerService, name string) {
+	log.Printf("Starting daily job %s...", name)
 
 	// Run immediately on startup
-	if err := schedulerService.ProcessDueTransactions(); err != nil {
-		log.Printf("Error processing due transactions on startup: %v", err)
+	if _, err := jobRunner.RunJob(name, models.JobTriggerSchedule); err != nil {
+		log.Printf("Error running job %s on startup: %v", name, err)
 	}
 
 	// Calculate time until next midnight
@@ -218,42 +233,50 @@ func startRecurringScheduler(schedulerService *services.RecurringSchedulerServic
 	defer ticker.Stop()
 
 	for {
-		log.Println("Running scheduled check for due recurring transactions...")
-		if err := schedulerService.ProcessDueTransactions(); err != nil {
-			log.Printf("Error processing due transactions: %v", err)
+		log.Printf("Running scheduled job %s...", name)
+		if _, err := jobRunner.RunJob(name, models.JobTriggerSchedule); err != nil {
+			log.Printf("Error running job %s: %v", name, err)
 		}
 		<-ticker.C
 	}
 }
 
-// startDueDateScheduler runs the due date notification scheduler in the background
-// It checks for upcoming expense due dates daily at midnight
-func startDueDateScheduler(schedulerService *services.DueDateSchedulerService) {
-	log.Println("Starting due date notification scheduler...")
+// startHourlyJob runs a registered job in the background: once on startup and then
+// at the start of every hour
+func startHourlyJob(jobRunner *services.JobRunnerService, name string) {
+	log.Printf("Starting hourly job %s...", name)
 
-	// Run immediately on startup
-	if err := schedulerService.CheckUpcomingDueDates(); err != nil {
-		log.Printf("Error checking upcoming due dates on startup: %v", err)
+	for {
+		if _, err := jobRunner.RunJob(name, models.JobTriggerSchedule); err != nil {
+			log.Printf("Error running job %s: %v", name, err)
+		}
+
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Hour).Add(time.Hour).Sub(now))
 	}
+}
 
-	// Calculate time until next midnight
-	now := time.Now()
-	nextMidnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
-	durationUntilMidnight := nextMidnight.Sub(now)
+// startWebhookRetries retries failed webhook deliveries once their backoff has elapsed
+func startWebhookRetries(webhookService *services.WebhookService) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
 
-	// Wait until midnight
-	time.Sleep(durationUntilMidnight)
+	for range ticker.C {
+		if err := webhookService.RetryDueDeliveries(); err != nil {
+			log.Printf("Error retrying webhook deliveries: %v", err)
+		}
+	}
+}
 
-	// Then run every 24 hours
-	ticker := time.NewTicker(24 * time.Hour)
+// startNotificationDeliveries sends the queued email and webhook notifications every minute
+func startNotificationDeliveries(notificationService *services.NotificationService) {
+	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
 
-	for {
-		log.Println("Running scheduled check for upcoming expense due dates...")
-		if err := schedulerService.CheckUpcomingDueDates(); err != nil {
-			log.Printf("Error checking upcoming due dates: %v", err)
+	for range ticker.C {
+		if err := notificationService.SendQueuedDeliveries(); err != nil {
+			log.Printf("Error sending queued notifications: %v", err)
 		}
-		<-ticker.C
 	}
 }
 
@@ -263,16 +286,87 @@ func main() {
 		log.Fatalf("Erro ao inicializar banco de dados: %v", err)
 	}
 
+	// Real-time notification events reach only this instance's SSE streams unless they are
+	// relayed through Postgres, which every instance shares when DATABASE_URL is set
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		services.SetNotificationBroker(services.NewPostgresNotificationBroker(context.Background(), databaseURL))
+	}
+
 	// Initialize settings cache service
 	settingsCacheService := services.NewSettingsCacheService()
 
-	// Start recurring transaction scheduler
+	// Register background jobs
+	jobRunner := services.NewJobRunnerService()
 	schedulerService := services.NewRecurringSchedulerService()
-	go startRecurringScheduler(schedulerService)
+	jobRunner.Register(services.Job{
+		Name:        services.JobRecurringTransactions,
+		Description: "Geração de transações recorrentes",
+		Run:         schedulerService.ProcessDueTransactions,
+	})
+	dueDateSchedulerService := services.NewDueDateSchedulerService()
+	jobRunner.Register(services.Job{
+		Name:        services.JobDueDateNotifications,
+		Description: "Avisos de vencimento de despesas",
+		Run:         dueDateSchedulerService.CheckUpcomingDueDates,
+	})
+	subscriptionService := services.NewSubscriptionService()
+	jobRunner.Register(services.Job{
+		Name:        services.JobSubscriptionReminders,
+		Description: "Lembretes de cancelamento de assinaturas",
+		Run:         subscriptionService.SendCancelReminders,
+	})
+	healthScoreService := services.NewHealthScoreService()
+	jobRunner.Register(services.Job{
+		Name:        services.JobHealthScoreSnapshots,
+		Description: "Snapshot mensal do score de saúde financeira",
+		Run:         healthScoreService.TakeSnapshots,
+	})
+
+	summaryScheduleService := services.NewSummaryScheduleService()
+	jobRunner.Register(services.Job{
+		Name:        services.JobScheduledSummaries,
+		Description: "Resumos semanais e mensais agendados",
+		Run:         summaryScheduleService.SendDueSummaries,
+	})
+
+	notificationService := services.NewNotificationService()
+	jobRunner.Register(services.Job{
+		Name:        services.JobNotificationDigest,
+		Description: "Resumo diário de notificações por email e webhook",
+		Run:         notificationService.SendDigests,
+	})
+	jobRunner.Register(services.Job{
+		Name:        services.JobNotificationRetention,
+		Description: "Remoção de notificações lidas antigas",
+		Run:         notificationService.PurgeOldNotifications,
+	})
+
+	// Start recurring transaction scheduler
+	go startDailyJob(jobRunner, services.JobRecurringTransactions)
 
 	// Start due date notification scheduler
-	dueDateSchedulerService := services.NewDueDateSchedulerService()
-	go startDueDateScheduler(dueDateSchedulerService)
+	go startDailyJob(jobRunner, services.JobDueDateNotifications)
+
+	// Start subscription cancel reminder scheduler
+	go startDailyJob(jobRunner, services.JobSubscriptionReminders)
+
+	// Start health score snapshot scheduler
+	go startDailyJob(jobRunner, services.JobHealthScoreSnapshots)
+
+	// Start scheduled summaries, checked every hour since each schedule has its own time
+	go startHourlyJob(jobRunner, services.JobScheduledSummaries)
+
+	// Start notification digest delivery, hourly since each user picks their digest hour
+	go startHourlyJob(jobRunner, services.JobNotificationDigest)
+
+	// Start notification retention, purging read notifications older than NOTIFICATION_RETENTION_DAYS
+	go startDailyJob(jobRunner, services.JobNotificationRetention)
+
+	// Start queued email and webhook notifications, outside the job runner since they run every minute
+	go startNotificationDeliveries(notificationService)
+
+	// Start webhook retries, outside the job runner since they run every minute
+	go startWebhookRetries(services.NewWebhookService())
 
 	// Inicializa Echo
 	e := echo.New()
@@ -330,12 +424,17 @@ func main() {
 	accountHandler := handlers.NewAccountHandler()
 	goalHandler := handlers.NewGoalHandler()
 	notificationHandler := handlers.NewNotificationHandler()
+	webhookHandler := handlers.NewWebhookHandler()
 	recurringHandler := handlers.NewRecurringTransactionHandler()
 	healthScoreHandler := handlers.NewHealthScoreHandler()
 	analyticsHandler := handlers.NewAnalyticsHandler()
 	taxReportHandler := handlers.NewTaxReportHandler(settingsCacheService)
 	budgetHandler := handlers.NewBudgetHandler()
+	periodBudgetHandler := handlers.NewPeriodBudgetHandler()
+	subscriptionHandler := handlers.NewSubscriptionHandler()
+	netWorthHandler := handlers.NewNetWorthHandler()
 	onboardingHandler := handlers.NewOnboardingHandler()
+	jobHandler := handlers.NewJobHandler(jobRunner)
 
 	// Auth routes (public - no authentication required)
 	e.GET("/register", authHandler.RegisterPage)
@@ -391,6 +490,13 @@ func main() {
 	// Configurações
 	protected.GET("/settings", settingsHandler.Get)
 	protected.POST("/settings", settingsHandler.Update)
+	protected.POST("/settings/health-score", settingsHandler.UpdateHealthScore)
+	protected.POST("/settings/summary", groupSummaryHandler.SavePersonalSettings)
+	protected.POST("/settings/webhooks", webhookHandler.Create)
+	protected.POST("/settings/webhooks/:id/toggle", webhookHandler.Toggle)
+	protected.POST("/settings/webhooks/:id/test", webhookHandler.Test)
+	protected.GET("/settings/webhooks/:id/deliveries", webhookHandler.Deliveries)
+	protected.DELETE("/settings/webhooks/:id", webhookHandler.Delete)
 
 	// Grupos familiares
 	protected.GET("/groups", groupCrudHandler.List)
@@ -413,12 +519,15 @@ func main() {
 	// Resumo periódico do grupo
 	protected.POST("/groups/:id/summary/weekly", groupSummaryHandler.GenerateWeeklySummary)
 	protected.POST("/groups/:id/summary/monthly", groupSummaryHandler.GenerateMonthlySummary)
+	protected.POST("/groups/:id/summary/settings", groupSummaryHandler.SaveGroupSettings)
 
 	// Metas do grupo
 	protected.GET("/groups/:id/goals", goalHandler.GoalsPage)
 	protected.POST("/groups/:id/goals", goalHandler.Create)
 	protected.DELETE("/goals/:goalId", goalHandler.Delete)
 	protected.POST("/goals/:goalId/contribution", goalHandler.AddContribution)
+	protected.POST("/goals/:goalId/auto-contribution", goalHandler.CreateAutoContribution)
+	protected.DELETE("/goals/:goalId/auto-contribution", goalHandler.CancelAutoContribution)
 
 	// Health Score do grupo
 	protected.GET("/groups/:id/health-score", healthScoreHandler.GroupScorePage)
@@ -428,8 +537,11 @@ func main() {
 	protected.GET("/notifications", notificationHandler.List)
 	protected.GET("/notifications/badge", notificationHandler.GetBadge)
 	protected.GET("/notifications/dropdown", notificationHandler.GetDropdown)
+	protected.GET("/notifications/stream", notificationHandler.Stream)
 	protected.POST("/notifications/:id/read", notificationHandler.MarkAsRead)
 	protected.POST("/notifications/mark-all-read", notificationHandler.MarkAllAsRead)
+	protected.POST("/notifications/bulk", notificationHandler.Bulk)
+	protected.POST("/notifications/preferences", notificationHandler.SavePreferences)
 	protected.DELETE("/notifications/:id", notificationHandler.Delete)
 
 	// Recurring Transactions
@@ -438,14 +550,34 @@ func main() {
 	protected.POST("/recurring/:id", recurringHandler.Update)
 	protected.DELETE("/recurring/:id", recurringHandler.Delete)
 	protected.POST("/recurring/:id/toggle", recurringHandler.Toggle)
+	protected.GET("/recurring/occurrences", recurringHandler.Occurrences)
+	protected.POST("/recurring/:id/occurrences/:date", recurringHandler.UpdateOccurrence)
+	protected.POST("/recurring/:id/occurrences/:date/skip", recurringHandler.SkipOccurrence)
+	protected.DELETE("/recurring/:id/occurrences/:date", recurringHandler.RestoreOccurrence)
+
+	// Subscriptions
+	protected.GET("/subscriptions", subscriptionHandler.Page)
+	protected.POST("/subscriptions/status", subscriptionHandler.SetStatus)
+
+	// Net worth (personal, or of a group with ?group_id=)
+	protected.GET("/net-worth", netWorthHandler.Page)
+	protected.GET("/net-worth/history", netWorthHandler.History)
+	protected.POST("/net-worth/items", netWorthHandler.CreateItem)
+	protected.POST("/net-worth/items/:id/valuations", netWorthHandler.AddValuation)
+	protected.DELETE("/net-worth/items/:id", netWorthHandler.DeleteItem)
 
 	// Health Score
 	protected.GET("/health-score", healthScoreHandler.Index)
 	protected.GET("/health-score/current", healthScoreHandler.GetUserScore)
 	protected.GET("/health-score/history", healthScoreHandler.GetScoreHistory)
+	protected.POST("/health-score/recommendations/:action", healthScoreHandler.UpdateRecommendation)
 
 	// Analytics API
 	protected.GET("/analytics/trends", analyticsHandler.GetTrends)
+	protected.GET("/analytics/cash-flow", analyticsHandler.GetCashFlow)
+	protected.GET("/analytics/query", analyticsHandler.Query)
+	protected.GET("/analytics/year-over-year", analyticsHandler.GetYearOverYear)
+	protected.GET("/analytics/seasonality", analyticsHandler.GetSeasonality)
 
 	// Tax Reports
 	protected.GET("/tax-report", taxReportHandler.TaxReportPage)
@@ -459,7 +591,23 @@ func main() {
 	protected.POST("/budgets/:id/categories", budgetHandler.AddCategory)
 	protected.POST("/budgets/:id/categories/:catId", budgetHandler.UpdateCategory)
 	protected.DELETE("/budgets/:id/categories/:catId", budgetHandler.DeleteCategory)
+	protected.GET("/budgets/:id/alerts", budgetHandler.Alerts)
+	protected.POST("/budgets/:id/alerts", budgetHandler.UpdateAlertThresholds)
+	protected.GET("/budgets/:id/envelope", budgetHandler.Envelope)
+	protected.POST("/budgets/:id/rollover", budgetHandler.SetRollover)
+	protected.POST("/budgets/:id/moves", budgetHandler.MoveMoney)
 	protected.POST("/budgets/copy", budgetHandler.CopyFromPreviousMonth)
+	protected.GET("/budgets/variance", budgetHandler.Variance)
+	protected.GET("/budgets/suggestions", budgetHandler.Suggestions)
+	protected.POST("/budgets/suggestions", budgetHandler.CreateFromSuggestion)
+	protected.GET("/budgets/variance/export", exportHandler.ExportBudgetVariance)
+
+	// Budgets over quarters, years or custom periods
+	protected.GET("/budgets/periods", periodBudgetHandler.Page)
+	protected.POST("/budgets/periods", periodBudgetHandler.Create)
+	protected.DELETE("/budgets/periods/:id", periodBudgetHandler.Delete)
+	protected.POST("/budgets/periods/:id/categories", periodBudgetHandler.AddCategory)
+	protected.DELETE("/budgets/periods/:id/categories/:catId", periodBudgetHandler.DeleteCategory)
 
 	// Group Budgets
 	protected.GET("/groups/:id/budgets", budgetHandler.GroupBudgetsPage)
@@ -472,6 +620,13 @@ func main() {
 	protected.POST("/onboarding/complete", onboardingHandler.Complete)
 	protected.POST("/onboarding/skip", onboardingHandler.Skip)
 
+	// Admin: background jobs
+	admin := protected.Group("/admin", authmw.RequireAdmin())
+	admin.GET("/jobs", jobHandler.JobsPage)
+	admin.GET("/jobs/dashboard", jobHandler.Dashboard)
+	admin.POST("/jobs/:name/run", jobHandler.RunNow)
+	admin.POST("/jobs/runs/:id/retry", jobHandler.Retry)
+
 	// Inicia servidor
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -77,14 +77,37 @@ func Init() error {
 		&models.GoalContribution{},
 		&models.Notification{},
 		&models.RecurringTransaction{},
+		&models.RecurringOccurrenceOverride{},
 		&models.HealthScore{},
 		&models.Budget{},
 		&models.BudgetCategory{},
+		&models.BudgetAlert{},
+		&models.BudgetMove{},
+		&models.PeriodBudget{},
+		&models.PeriodBudgetCategory{},
+		&models.Subscription{},
+		&models.NetWorthItem{},
+		&models.NetWorthValuation{},
+		&models.RecommendationAction{},
+		&models.SummarySchedule{},
+		&models.SummaryPreference{},
+		&models.NotificationPreference{},
+		&models.NotificationSettings{},
+		&models.NotificationDelivery{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.JobRun{},
+		&models.JobLock{},
+		&models.JobIdempotencyKey{},
 	)
 	if err != nil {
 		return err
 	}
 
+	if err := migrateLegacyBudgetAlerts(DB); err != nil {
+		return err
+	}
+
 	// Inicializa configurações padrão se não existirem
 	initDefaultSettings()
 
@@ -108,6 +131,44 @@ func initDefaultSettings() {
 	}
 }
 
+// legacyBudgetAlertColumns maps the notification columns budget categories had before the
+// alert log to the threshold each one recorded
+var legacyBudgetAlertColumns = []struct {
+	column    string
+	threshold float64
+}{
+	{"notified_at_80", 80},
+	{"notified_at_100", 100},
+}
+
+// migrateLegacyBudgetAlerts turns the old notified_at_80/notified_at_100 timestamps into
+// budget_alerts rows, so categories already past a threshold aren't alerted again, then
+// drops the columns. It does nothing once the columns are gone.
+func migrateLegacyBudgetAlerts(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, legacy := range legacyBudgetAlertColumns {
+			if !tx.Migrator().HasColumn(&models.BudgetCategory{}, legacy.column) {
+				continue
+			}
+			err := tx.Exec(`INSERT INTO budget_alerts
+				(created_at, updated_at, budget_id, budget_category_id, category, threshold, spent, "limit", notified)
+				SELECT `+legacy.column+`, `+legacy.column+`, budget_id, id, category, ?, spent, "limit", ?
+				FROM budget_categories c
+				WHERE `+legacy.column+` IS NOT NULL AND deleted_at IS NULL
+				AND NOT EXISTS (SELECT 1 FROM budget_alerts a WHERE a.budget_category_id = c.id AND a.threshold = ?)`,
+				legacy.threshold, true, legacy.threshold).Error
+			if err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropColumn(&models.BudgetCategory{}, legacy.column); err != nil {
+				return err
+			}
+			log.Printf("Alertas de orçamento migrados da coluna %s", legacy.column)
+		}
+		return nil
+	})
+}
+
 func GetDB() *gorm.DB {
 	return DB
 }
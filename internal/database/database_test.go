@@ -0,0 +1,65 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestMigrateLegacyBudgetAlerts(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	// Recreate the columns budget categories had before the alert log
+	for _, legacy := range legacyBudgetAlertColumns {
+		if err := db.Exec("ALTER TABLE budget_categories ADD COLUMN `" + legacy.column + "` datetime").Error; err != nil {
+			t.Fatalf("adding %s: %v", legacy.column, err)
+		}
+	}
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	budget := models.Budget{UserID: user.ID, Year: 2030, Month: 3, Name: "Mensal"}
+	db.Create(&budget)
+	over := models.BudgetCategory{BudgetID: budget.ID, Category: "Lazer", Limit: 100, Spent: 120}
+	near := models.BudgetCategory{BudgetID: budget.ID, Category: "Mercado", Limit: 100, Spent: 85}
+	under := models.BudgetCategory{BudgetID: budget.ID, Category: "Saúde", Limit: 100, Spent: 10}
+	for _, category := range []*models.BudgetCategory{&over, &near, &under} {
+		db.Create(category)
+	}
+	notifiedAt := time.Date(2030, 3, 10, 12, 0, 0, 0, time.UTC)
+	db.Exec("UPDATE budget_categories SET notified_at_80 = ?, notified_at_100 = ? WHERE id = ?", notifiedAt, notifiedAt, over.ID)
+	db.Exec("UPDATE budget_categories SET notified_at_80 = ? WHERE id = ?", notifiedAt, near.ID)
+
+	if err := migrateLegacyBudgetAlerts(db); err != nil {
+		t.Fatalf("migrateLegacyBudgetAlerts() error = %v", err)
+	}
+
+	var alerts []models.BudgetAlert
+	db.Order("budget_category_id, threshold").Find(&alerts)
+	want := []struct {
+		categoryID uint
+		threshold  float64
+	}{{over.ID, 80}, {over.ID, 100}, {near.ID, 80}}
+	if len(alerts) != len(want) {
+		t.Fatalf("got %d alerts, want %d", len(alerts), len(want))
+	}
+	for i, alert := range alerts {
+		if alert.BudgetCategoryID != want[i].categoryID || alert.Threshold != want[i].threshold || !alert.Notified || !alert.IsActive() {
+			t.Errorf("alert %d = %+v, want an active notified alert at %.0f%% for category %d", i, alert, want[i].threshold, want[i].categoryID)
+		}
+		if alert.Spent == 0 || alert.Limit != 100 {
+			t.Errorf("alert %d spent/limit = %.2f/%.2f, want the category values", i, alert.Spent, alert.Limit)
+		}
+	}
+
+	for _, legacy := range legacyBudgetAlertColumns {
+		if db.Migrator().HasColumn(&models.BudgetCategory{}, legacy.column) {
+			t.Errorf("column %s wasn't dropped", legacy.column)
+		}
+	}
+	// Running again is a no-op
+	if err := migrateLegacyBudgetAlerts(db); err != nil {
+		t.Fatalf("second migrateLegacyBudgetAlerts() error = %v", err)
+	}
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"encoding/csv"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -14,12 +17,14 @@ import (
 )
 
 type AnalyticsHandler struct {
-	accountService *services.AccountService
+	accountService  *services.AccountService
+	cashFlowService *services.CashFlowService
 }
 
 func NewAnalyticsHandler() *AnalyticsHandler {
 	return &AnalyticsHandler{
-		accountService: services.NewAccountService(),
+		accountService:  services.NewAccountService(),
+		cashFlowService: services.NewCashFlowService(),
 	}
 }
 
@@ -31,26 +36,7 @@ func (h *AnalyticsHandler) GetTrends(c echo.Context) error {
 	allAccountIDs, _ := h.accountService.GetUserAccountIDs(userID)
 
 	// Handle account filter from query parameter
-	var accountIDs []uint
-	accountIDParam := c.QueryParam("account_id")
-
-	if accountIDParam != "" && accountIDParam != "all" {
-		parsedID, err := strconv.ParseUint(accountIDParam, 10, 32)
-		if err == nil {
-			selectedAccountID := uint(parsedID)
-			// Validate user has access to this account
-			if h.accountService.CanUserAccessAccount(userID, selectedAccountID) {
-				accountIDs = []uint{selectedAccountID}
-			} else {
-				// Fallback to all accounts if invalid
-				accountIDs = allAccountIDs
-			}
-		} else {
-			accountIDs = allAccountIDs
-		}
-	} else {
-		accountIDs = allAccountIDs
-	}
+	accountIDs := h.filterAccountIDs(c, userID, allAccountIDs)
 
 	// Parse months parameter (default to 6)
 	months := 6
@@ -83,3 +69,198 @@ func (h *AnalyticsHandler) GetTrends(c echo.Context) error {
 	log.Println("[Analytics] Trends data loaded successfully - returning JSON")
 	return c.JSON(http.StatusOK, response)
 }
+
+// filterAccountIDs narrows the user's accounts to the one selected by the account_id query
+// parameter, falling back to all accounts when it is missing or not accessible
+func (h *AnalyticsHandler) filterAccountIDs(c echo.Context, userID uint, allAccountIDs []uint) []uint {
+	accountIDParam := c.QueryParam("account_id")
+	if accountIDParam == "" || accountIDParam == "all" {
+		return allAccountIDs
+	}
+
+	parsedID, err := strconv.ParseUint(accountIDParam, 10, 32)
+	if err != nil || !h.accountService.CanUserAccessAccount(userID, uint(parsedID)) {
+		return allAccountIDs
+	}
+	return []uint{uint(parsedID)}
+}
+
+// GetCashFlow returns the projected cash flow of the next months as JSON
+func (h *AnalyticsHandler) GetCashFlow(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	allAccountIDs, _ := h.accountService.GetUserAccountIDs(userID)
+	accountIDs := h.filterAccountIDs(c, userID, allAccountIDs)
+
+	months := services.DefaultCashFlowMonths
+	if monthsParam := c.QueryParam("months"); monthsParam != "" {
+		parsed, err := strconv.Atoi(monthsParam)
+		if err != nil || parsed < services.MinCashFlowMonths || parsed > services.MaxCashFlowMonths {
+			return c.String(http.StatusBadRequest, "Período deve ser entre 3 e 12 meses")
+		}
+		months = parsed
+	}
+
+	// The forecast starts from the current account balances unless the user overrides it
+	var startingBalance *float64
+	if balanceParam := c.QueryParam("starting_balance"); balanceParam != "" {
+		parsed, err := strconv.ParseFloat(balanceParam, 64)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "Saldo inicial inválido")
+		}
+		startingBalance = &parsed
+	}
+
+	forecast, err := h.cashFlowService.Forecast(accountIDs, months, startingBalance, time.Now())
+	if err != nil {
+		log.Printf("[Analytics] Error projecting cash flow: %v", err)
+		return c.String(http.StatusInternalServerError, "Erro ao projetar fluxo de caixa")
+	}
+
+	return c.JSON(http.StatusOK, forecast)
+}
+
+// GetYearOverYear compares a month and the year to date with the previous year as JSON.
+// Query params: year and month (default the current month) and account_id.
+func (h *AnalyticsHandler) GetYearOverYear(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	allAccountIDs, _ := h.accountService.GetUserAccountIDs(userID)
+	accountIDs := h.filterAccountIDs(c, userID, allAccountIDs)
+
+	now := time.Now()
+	year, month := now.Year(), int(now.Month())
+	if yearParam := c.QueryParam("year"); yearParam != "" {
+		parsed, err := strconv.Atoi(yearParam)
+		if err != nil || parsed < 2000 || parsed > 2100 {
+			return c.String(http.StatusBadRequest, "Ano inválido")
+		}
+		year = parsed
+	}
+	if monthParam := c.QueryParam("month"); monthParam != "" {
+		parsed, err := strconv.Atoi(monthParam)
+		if err != nil || parsed < 1 || parsed > 12 {
+			return c.String(http.StatusBadRequest, "Mês inválido")
+		}
+		month = parsed
+	}
+
+	return c.JSON(http.StatusOK, services.GetYearOverYearComparison(database.DB, year, month, accountIDs))
+}
+
+// GetSeasonality returns which months historically spend above average as JSON.
+// Query params: years of history (1 to 5, default 3) and account_id.
+func (h *AnalyticsHandler) GetSeasonality(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	allAccountIDs, _ := h.accountService.GetUserAccountIDs(userID)
+	accountIDs := h.filterAccountIDs(c, userID, allAccountIDs)
+
+	years := services.DefaultSeasonalityYears
+	if yearsParam := c.QueryParam("years"); yearsParam != "" {
+		parsed, err := strconv.Atoi(yearsParam)
+		if err != nil || parsed < 1 || parsed > services.MaxSeasonalityYears {
+			return c.String(http.StatusBadRequest, "Histórico deve ser entre 1 e 5 anos")
+		}
+		years = parsed
+	}
+
+	return c.JSON(http.StatusOK, services.GetSeasonality(database.DB, accountIDs, years, time.Now()))
+}
+
+// analyticsMeasureHeaders are the CSV column titles of each measure
+var analyticsMeasureHeaders = map[services.AnalyticsMeasure]string{
+	services.AnalyticsMeasureIncome:  "Receitas",
+	services.AnalyticsMeasureExpense: "Despesas",
+	services.AnalyticsMeasureNet:     "Saldo",
+}
+
+// Query runs an analytics query and returns it as JSON or CSV.
+// Query params: start and end (AAAA-MM-DD, default the last 6 months), group_by (day, week,
+// month, category, account, member or merchant), account_id, category and measure (income,
+// expense or net; each repeatable or comma-separated) and format (json or csv).
+func (h *AnalyticsHandler) Query(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	now := time.Now()
+	query := services.AnalyticsQuery{
+		Start:   time.Date(now.Year(), now.Month()-5, 1, 0, 0, 0, 0, time.Local),
+		End:     now,
+		GroupBy: services.AnalyticsGroupBy(c.QueryParam("group_by")),
+	}
+	for param, date := range map[string]*time.Time{"start": &query.Start, "end": &query.End} {
+		if value := c.QueryParam(param); value != "" {
+			parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+			if err != nil {
+				return c.String(http.StatusBadRequest, "Data inválida (use AAAA-MM-DD)")
+			}
+			*date = parsed
+		}
+	}
+
+	accountParams := queryParamList(c, "account_id")
+	if len(accountParams) == 0 {
+		query.AccountIDs, _ = h.accountService.GetUserAccountIDs(userID)
+	}
+	for _, value := range accountParams {
+		accountID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "ID da conta inválido")
+		}
+		if !h.accountService.CanUserAccessAccount(userID, uint(accountID)) {
+			return c.String(http.StatusForbidden, "Acesso negado à conta")
+		}
+		query.AccountIDs = append(query.AccountIDs, uint(accountID))
+	}
+
+	query.Categories = queryParamList(c, "category")
+	for _, measure := range queryParamList(c, "measure") {
+		query.Measures = append(query.Measures, services.AnalyticsMeasure(measure))
+	}
+
+	result, err := services.QueryAnalytics(database.DB, query)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if c.QueryParam("format") != "csv" {
+		return c.JSON(http.StatusOK, result)
+	}
+
+	filename := fmt.Sprintf("analise_%s_%s_%s.csv", result.Query.GroupBy,
+		result.Query.Start.Format("2006-01-02"), result.Query.End.Format("2006-01-02"))
+	c.Response().Header().Set("Content-Type", "text/csv")
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+
+	writer := csv.NewWriter(c.Response().Writer)
+	defer writer.Flush()
+
+	header := []string{"Grupo"}
+	for _, measure := range result.Query.Measures {
+		header = append(header, analyticsMeasureHeaders[measure])
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, row := range append(result.Rows, result.Total) {
+		record := []string{row.Label}
+		for _, measure := range result.Query.Measures {
+			record = append(record, fmt.Sprintf("%.2f", row.Values[measure]))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryParamList returns the values of a repeatable query parameter, also accepting
+// comma-separated values
+func queryParamList(c echo.Context, name string) []string {
+	var values []string
+	for _, param := range c.QueryParams()[name] {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
@@ -25,20 +25,23 @@ func NewBudgetHandler() *BudgetHandler {
 }
 
 type CreateBudgetRequest struct {
-	Name    string `form:"name"`
-	Year    int    `form:"year"`
-	Month   int    `form:"month"`
-	GroupID *uint  `form:"group_id"`
+	Name            string `form:"name"`
+	Year            int    `form:"year"`
+	Month           int    `form:"month"`
+	GroupID         *uint  `form:"group_id"`
+	AlertThresholds string `form:"alert_thresholds"`
 }
 
 type AddCategoryRequest struct {
-	Category string  `form:"category"`
-	Limit    float64 `form:"limit"`
+	Category        string  `form:"category"`
+	Limit           float64 `form:"limit"`
+	AlertThresholds string  `form:"alert_thresholds"`
 }
 
 type UpdateCategoryRequest struct {
-	Category string  `form:"category"`
-	Limit    float64 `form:"limit"`
+	Category        string  `form:"category"`
+	Limit           float64 `form:"limit"`
+	AlertThresholds string  `form:"alert_thresholds"`
 }
 
 // BudgetsPage returns the budgets page for individual user
@@ -161,13 +164,23 @@ func (h *BudgetHandler) Create(c echo.Context) error {
 		req.Month = int(time.Now().Month())
 	}
 
+	if _, err := services.ParseAlertThresholds(req.AlertThresholds); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
 	// Create empty budget (categories will be added separately)
 	var categories []struct {
 		Category string
 		Limit    float64
 	}
 
-	_, err := h.budgetService.CreateBudget(userID, req.GroupID, req.Year, req.Month, req.Name, categories)
+	budget, err := h.budgetService.CreateBudget(userID, req.GroupID, req.Year, req.Month, req.Name, categories)
+	if err == nil && req.AlertThresholds != "" {
+		err = h.budgetService.SetBudgetAlertThresholds(budget.ID, userID, req.AlertThresholds)
+	}
+	if rollover := c.FormValue("rollover"); err == nil && (rollover == "on" || rollover == "true") {
+		err = h.budgetService.SetRollover(budget.ID, userID, true)
+	}
 	if err != nil {
 		if err == services.ErrUnauthorized {
 			return c.String(http.StatusForbidden, "Você não tem permissão para criar orçamento para este grupo")
@@ -249,8 +262,14 @@ func (h *BudgetHandler) AddCategory(c echo.Context) error {
 	if req.Category == "" || req.Limit <= 0 {
 		return c.String(http.StatusBadRequest, "Categoria e limite são obrigatórios")
 	}
+	if _, err := services.ParseAlertThresholds(req.AlertThresholds); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
 
-	_, err = h.budgetService.AddCategory(uint(budgetID), userID, req.Category, req.Limit)
+	category, err := h.budgetService.AddCategory(uint(budgetID), userID, req.Category, req.Limit)
+	if err == nil && req.AlertThresholds != "" {
+		err = h.budgetService.SetCategoryAlertThresholds(category.ID, userID, req.AlertThresholds)
+	}
 	if err != nil {
 		if err == services.ErrUnauthorized {
 			return c.String(http.StatusForbidden, "Você não tem permissão para modificar este orçamento")
@@ -291,8 +310,15 @@ func (h *BudgetHandler) UpdateCategory(c echo.Context) error {
 	if req.Category == "" || req.Limit <= 0 {
 		return c.String(http.StatusBadRequest, "Categoria e limite são obrigatórios")
 	}
+	if _, err := services.ParseAlertThresholds(req.AlertThresholds); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
 
 	err = h.budgetService.UpdateCategory(uint(categoryID), userID, req.Category, req.Limit)
+	// Thresholds are only changed when the field is submitted (empty uses the budget's)
+	if _, submitted := c.Request().Form["alert_thresholds"]; err == nil && submitted {
+		err = h.budgetService.SetCategoryAlertThresholds(uint(categoryID), userID, req.AlertThresholds)
+	}
 	if err != nil {
 		if err == services.ErrUnauthorized {
 			return c.String(http.StatusForbidden, "Você não tem permissão para modificar este orçamento")
@@ -398,3 +424,249 @@ func (h *BudgetHandler) CopyFromPreviousMonth(c echo.Context) error {
 		"userID":  userID,
 	})
 }
+
+// UpdateAlertThresholds changes the alert thresholds of a budget
+func (h *BudgetHandler) UpdateAlertThresholds(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	budgetID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do orçamento inválido")
+	}
+
+	err = h.budgetService.SetBudgetAlertThresholds(uint(budgetID), userID, c.FormValue("alert_thresholds"))
+	if err != nil {
+		return budgetError(c, err)
+	}
+
+	return h.Alerts(c)
+}
+
+// Alerts returns the alert log of a budget (HTMX partial)
+func (h *BudgetHandler) Alerts(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	budgetID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do orçamento inválido")
+	}
+
+	budget, err := h.budgetService.GetBudgetByID(uint(budgetID), userID)
+	if err != nil {
+		if err == services.ErrBudgetNotFound {
+			return c.String(http.StatusNotFound, "Orçamento não encontrado")
+		}
+		return c.String(http.StatusForbidden, "Você não tem permissão para acessar este orçamento")
+	}
+
+	alerts, err := h.budgetService.GetBudgetAlerts(budget.ID, userID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao buscar alertas")
+	}
+
+	return c.Render(http.StatusOK, "partials/budget-alerts.html", map[string]interface{}{
+		"budget": budget,
+		"alerts": alerts,
+	})
+}
+
+// Envelope returns the envelope view of a budget: ready-to-assign pool, balances and moves (HTMX partial)
+func (h *BudgetHandler) Envelope(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	budgetID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do orçamento inválido")
+	}
+
+	summary, err := h.budgetService.GetEnvelopeSummary(uint(budgetID), userID)
+	if err != nil {
+		return budgetError(c, err)
+	}
+
+	return c.Render(http.StatusOK, "partials/budget-envelope.html", map[string]interface{}{
+		"envelope": summary,
+	})
+}
+
+// SetRollover turns envelope rollover on or off
+func (h *BudgetHandler) SetRollover(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	budgetID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do orçamento inválido")
+	}
+
+	enabled := c.FormValue("enabled") == "on" || c.FormValue("enabled") == "true"
+	if err := h.budgetService.SetRollover(uint(budgetID), userID, enabled); err != nil {
+		return budgetError(c, err)
+	}
+
+	return h.Envelope(c)
+}
+
+// MoveMoney moves money between categories or from/to the ready-to-assign pool
+func (h *BudgetHandler) MoveMoney(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	budgetID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do orçamento inválido")
+	}
+
+	amount, err := strconv.ParseFloat(c.FormValue("amount"), 64)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Valor inválido")
+	}
+
+	// An empty category means the ready-to-assign pool
+	parseCategory := func(field string) (*uint, error) {
+		value := c.FormValue(field)
+		if value == "" {
+			return nil, nil
+		}
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		categoryID := uint(id)
+		return &categoryID, nil
+	}
+	fromCategoryID, err := parseCategory("from_category_id")
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID da categoria inválido")
+	}
+	toCategoryID, err := parseCategory("to_category_id")
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID da categoria inválido")
+	}
+
+	err = h.budgetService.MoveBudgetMoney(uint(budgetID), userID, fromCategoryID, toCategoryID, amount, c.FormValue("note"))
+	if err != nil {
+		return budgetError(c, err)
+	}
+
+	return h.Envelope(c)
+}
+
+// Variance returns the budget-vs-actual report over a range of months (HTMX partial).
+// Query params: from and to (YYYY-MM, default the last 6 months) and optional group_id.
+func (h *BudgetHandler) Variance(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	groupID, err := parseOptionalGroupID(c.QueryParam("group_id"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do grupo inválido")
+	}
+	fromYear, fromMonth, toYear, toMonth, err := parseVarianceRange(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Mês inválido (use AAAA-MM)")
+	}
+
+	report, err := h.budgetService.GetVarianceReport(userID, groupID, fromYear, fromMonth, toYear, toMonth)
+	if err != nil {
+		return budgetError(c, err)
+	}
+
+	return c.Render(http.StatusOK, "partials/budget-variance.html", map[string]interface{}{
+		"report":  report,
+		"groupID": groupID,
+		"from":    c.QueryParam("from"),
+		"to":      c.QueryParam("to"),
+	})
+}
+
+// Suggestions returns category limits suggested from the spending history (HTMX partial).
+// Query params: months (default 6), percentile (default 50, the median) and optional group_id.
+func (h *BudgetHandler) Suggestions(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	groupID, err := parseOptionalGroupID(c.QueryParam("group_id"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do grupo inválido")
+	}
+	months, _ := strconv.Atoi(c.QueryParam("months"))
+	percentile, _ := strconv.ParseFloat(c.QueryParam("percentile"), 64)
+
+	suggestion, err := h.budgetService.SuggestBudget(userID, groupID, months, percentile, time.Now())
+	if err != nil {
+		return budgetError(c, err)
+	}
+
+	now := time.Now()
+	return c.Render(http.StatusOK, "partials/budget-suggestions.html", map[string]interface{}{
+		"suggestion":   suggestion,
+		"groupID":      groupID,
+		"currentYear":  now.Year(),
+		"currentMonth": int(now.Month()),
+	})
+}
+
+// CreateFromSuggestion creates a budget with the limits suggested from the spending history
+func (h *BudgetHandler) CreateFromSuggestion(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	var req CreateBudgetRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Dados inválidos")
+	}
+	if req.Name == "" {
+		req.Name = "Orçamento sugerido"
+	}
+	if req.Year == 0 {
+		req.Year = time.Now().Year()
+	}
+	if req.Month == 0 {
+		req.Month = int(time.Now().Month())
+	}
+	months, _ := strconv.Atoi(c.FormValue("months"))
+	percentile, _ := strconv.ParseFloat(c.FormValue("percentile"), 64)
+
+	_, err := h.budgetService.CreateBudgetFromSuggestion(userID, req.GroupID, req.Year, req.Month, req.Name, months, percentile)
+	if err != nil {
+		return budgetError(c, err)
+	}
+
+	// Return updated list
+	var budgets interface{}
+	if req.GroupID != nil {
+		budgets, _ = h.budgetService.GetGroupBudgets(userID, *req.GroupID, 0, 0)
+	} else {
+		budgets, _ = h.budgetService.GetUserBudgets(userID, 0, 0)
+	}
+
+	return c.Render(http.StatusOK, "partials/budget-list.html", map[string]interface{}{
+		"budgets": budgets,
+		"userID":  userID,
+	})
+}
+
+// parseVarianceRange reads the from/to months (YYYY-MM) of a variance report.
+// Missing values default to the last 6 months up to the current one.
+func parseVarianceRange(c echo.Context) (fromYear, fromMonth, toYear, toMonth int, err error) {
+	to := time.Now()
+	if value := c.QueryParam("to"); value != "" {
+		if to, err = time.Parse("2006-01", value); err != nil {
+			return
+		}
+	}
+	from := time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -5, 0)
+	if value := c.QueryParam("from"); value != "" {
+		if from, err = time.Parse("2006-01", value); err != nil {
+			return
+		}
+	}
+	return from.Year(), int(from.Month()), to.Year(), int(to.Month()), nil
+}
+
+// budgetError maps budget service errors to responses
+func budgetError(c echo.Context, err error) error {
+	switch err {
+	case services.ErrInvalidAlertThresholds, services.ErrInvalidMoveAmount, services.ErrInsufficientFunds, services.ErrSameMoveCategory,
+		services.ErrInvalidReportRange, services.ErrNoSpendingHistory, services.ErrInvalidBudgetMonth, services.ErrInvalidBudgetYear:
+		return c.String(http.StatusBadRequest, err.Error())
+	case services.ErrBudgetNotFound:
+		return c.String(http.StatusNotFound, "Orçamento não encontrado")
+	case services.ErrCategoryNotFound:
+		return c.String(http.StatusNotFound, "Categoria não encontrada")
+	case services.ErrUnauthorized:
+		return c.String(http.StatusForbidden, "Você não tem permissão para modificar este orçamento")
+	}
+	return c.String(http.StatusInternalServerError, "Erro ao atualizar orçamento")
+}
@@ -15,11 +15,15 @@ import (
 
 type CreditCardHandler struct {
 	accountService *services.AccountService
+	budgetService  *services.BudgetService
+	anomalyService *services.AnomalyService
 }
 
 func NewCreditCardHandler() *CreditCardHandler {
 	return &CreditCardHandler{
 		accountService: services.NewAccountService(),
+		budgetService:  services.NewBudgetService(),
+		anomalyService: services.NewAnomalyService(),
 	}
 }
 
@@ -136,9 +140,16 @@ func (h *CreditCardHandler) DeleteCard(c echo.Context) error {
 	}
 
 	// Deleta parcelas associadas
+	var installments []models.Installment
+	database.DB.Where("credit_card_id = ?", id).Find(&installments)
 	database.DB.Where("credit_card_id = ?", id).Delete(&models.Installment{})
 	database.DB.Delete(&card)
 
+	// Installments no longer count against budgets
+	for i := range installments {
+		h.refreshInstallmentBudgets(card.AccountID, &installments[i])
+	}
+
 	var cards []models.CreditCard
 	database.DB.Where("account_id IN ?", accountIDs).Find(&cards)
 
@@ -184,9 +195,23 @@ func (h *CreditCardHandler) CreateInstallment(c echo.Context) error {
 		return c.String(http.StatusInternalServerError, "Erro ao criar parcelamento")
 	}
 
+	// Each installment counts against the budget of the month it is due
+	h.refreshInstallmentBudgets(card.AccountID, &installment)
+
+	// Flag unusual or duplicate purchases
+	h.anomalyService.CheckInstallment(card.AccountID, &installment)
+
 	return h.renderInstallmentList(c)
 }
 
+// refreshInstallmentBudgets recalculates the budgets of every month an installment is due
+func (h *CreditCardHandler) refreshInstallmentBudgets(accountID uint, installment *models.Installment) {
+	if installment.Category == "" {
+		return
+	}
+	h.budgetService.RefreshInstallmentSpending(accountID, installment)
+}
+
 func (h *CreditCardHandler) DeleteInstallment(c echo.Context) error {
 	userID := middleware.GetUserID(c)
 	accountIDs, _ := h.accountService.GetUserAccountIDs(userID)
@@ -212,6 +237,7 @@ func (h *CreditCardHandler) DeleteInstallment(c echo.Context) error {
 	}
 
 	database.DB.Delete(&installment)
+	h.refreshInstallmentBudgets(installment.CreditCard.AccountID, &installment)
 	return h.renderInstallmentList(c)
 }
 
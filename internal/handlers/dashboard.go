@@ -209,6 +209,8 @@ func (h *DashboardHandler) Index(c echo.Context) error {
 	log.Println("[Dashboard] Fetching analytics data")
 	monthOverMonthComparison := services.GetMonthOverMonthComparison(database.DB, year, month, accountIDs)
 	categoryBreakdownWithPercentages := services.GetCategoryBreakdownWithPercentages(database.DB, year, month, accountIDs)
+	yearOverYear := services.GetYearOverYearComparison(database.DB, year, month, accountIDs)
+	seasonality := services.GetSeasonality(database.DB, accountIDs, services.DefaultSeasonalityYears, now)
 
 	// Generate trend from monthSummaries (already filtered by start date)
 	incomeVsExpenseTrend := make([]services.IncomeVsExpenseTrendPoint, 0, len(monthSummaries))
@@ -247,6 +249,8 @@ func (h *DashboardHandler) Index(c echo.Context) error {
 		"lastUpdated":                      lastUpdated,
 		"scoreTrend":                       scoreTrend,
 		"monthOverMonthComparison":         monthOverMonthComparison,
+		"yearOverYear":                     yearOverYear,
+		"seasonality":                      seasonality,
 		"categoryBreakdownWithPercentages": categoryBreakdownWithPercentages,
 		"incomeVsExpenseTrend":             incomeVsExpenseTrend,
 		"bracketWarning":                   bracketWarning,
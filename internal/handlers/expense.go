@@ -19,6 +19,8 @@ type ExpenseHandler struct {
 	notificationService  *services.NotificationService
 	settingsCacheService *services.SettingsCacheService
 	budgetService        *services.BudgetService
+	anomalyService       *services.AnomalyService
+	webhookService       *services.WebhookService
 }
 
 func NewExpenseHandler(settingsCacheService *services.SettingsCacheService) *ExpenseHandler {
@@ -27,6 +29,8 @@ func NewExpenseHandler(settingsCacheService *services.SettingsCacheService) *Exp
 		notificationService:  services.NewNotificationService(),
 		settingsCacheService: settingsCacheService,
 		budgetService:        services.NewBudgetService(),
+		anomalyService:       services.NewAnomalyService(),
+		webhookService:       services.NewWebhookService(),
 	}
 }
 
@@ -216,12 +220,41 @@ func (h *ExpenseHandler) Create(c echo.Context) error {
 	// Notify group members if this is a joint account expense
 	h.notifyPartnerExpense(userID, accountID, &expense)
 
+	// Variable expenses count against budgets in the month they are created
+	h.refreshVariableExpenseBudgets(&expense)
+
 	// Check budget limit and notify if exceeded
 	h.checkBudgetLimit(accountID)
 
+	// Flag unusual, duplicate or new recurring charges
+	h.anomalyService.CheckExpense(&expense)
+
+	// Send the new expense to the account users' webhooks
+	if account, err := h.accountService.GetAccountByID(accountID); err == nil {
+		h.webhookService.EmitForAccount(account, models.WebhookEventExpenseCreated, map[string]interface{}{
+			"expense_id": expense.ID,
+			"account_id": expense.AccountID,
+			"group_id":   account.GroupID,
+			"name":       expense.Name,
+			"amount":     expense.Amount,
+			"type":       expense.Type,
+			"category":   expense.Category,
+			"due_day":    expense.DueDay,
+		})
+	}
+
 	return h.renderExpenseList(c, string(expenseType))
 }
 
+// refreshVariableExpenseBudgets recalculates the budgets a variable expense counts against
+func (h *ExpenseHandler) refreshVariableExpenseBudgets(expense *models.Expense) {
+	if expense.Type != models.ExpenseTypeVariable || expense.Category == "" {
+		return
+	}
+	h.budgetService.RefreshAccountSpending(expense.AccountID, expense.Category,
+		expense.CreatedAt.Year(), int(expense.CreatedAt.Month()))
+}
+
 // notifyPartnerExpense sends notifications to group members when an expense is added to a joint account
 func (h *ExpenseHandler) notifyPartnerExpense(creatorID uint, accountID uint, expense *models.Expense) {
 	// Get the account to check if it's a joint account
@@ -285,6 +318,14 @@ func (h *ExpenseHandler) checkBudgetLimit(accountID uint) {
 		Percentage:    percentage,
 	}
 	h.notificationService.NotifyBudgetLimitReached(alertData, members)
+	h.webhookService.EmitForUsers(members, models.WebhookEventBudgetThreshold, map[string]interface{}{
+		"account_id":     balance.Account.ID,
+		"account_name":   balance.Account.Name,
+		"group_id":       balance.Account.GroupID,
+		"total_expenses": balance.TotalExpenses,
+		"limit":          budgetLimit,
+		"percentage":     percentage,
+	})
 }
 
 func (h *ExpenseHandler) Toggle(c echo.Context) error {
@@ -301,6 +342,9 @@ func (h *ExpenseHandler) Toggle(c echo.Context) error {
 	expense.Active = !expense.Active
 	database.DB.Save(&expense)
 
+	// Inactive variable expenses no longer count against budgets
+	h.refreshVariableExpenseBudgets(&expense)
+
 	return h.renderExpenseList(c, string(expense.Type))
 }
 
@@ -340,9 +384,10 @@ func (h *ExpenseHandler) Delete(c echo.Context) error {
 		for periodKey := range affectedPeriods {
 			var year, month int
 			fmt.Sscanf(periodKey, "%d-%d", &year, &month)
-			h.budgetService.UpdateCategorySpent(userID, category, year, month)
+			h.budgetService.RefreshAccountSpending(expense.AccountID, category, year, month)
 		}
 	}
+	h.refreshVariableExpenseBudgets(&expense)
 
 	return h.renderExpenseList(c, expenseType)
 }
@@ -379,7 +424,7 @@ func (h *ExpenseHandler) MarkPaid(c echo.Context) error {
 
 		// Update budget tracking in real-time
 		if expense.Category != "" {
-			h.budgetService.UpdateCategorySpent(userID, expense.Category, year, month)
+			h.budgetService.RefreshAccountSpending(expense.AccountID, expense.Category, year, month)
 		}
 	}
 
@@ -406,7 +451,7 @@ func (h *ExpenseHandler) MarkUnpaid(c echo.Context) error {
 
 	// Update budget tracking in real-time
 	if expense.Category != "" {
-		h.budgetService.UpdateCategorySpent(userID, expense.Category, year, month)
+		h.budgetService.RefreshAccountSpending(expense.AccountID, expense.Category, year, month)
 	}
 
 	return h.renderExpenseList(c, string(expense.Type))
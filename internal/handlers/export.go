@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/csv"
 	"fmt"
+	"net/http"
 	"strconv"
 	"time"
 
@@ -11,14 +12,19 @@ import (
 
 	"poc-finance/internal/database"
 	"poc-finance/internal/i18n"
+	"poc-finance/internal/middleware"
 	"poc-finance/internal/models"
 	"poc-finance/internal/services"
 )
 
-type ExportHandler struct{}
+type ExportHandler struct {
+	budgetService *services.BudgetService
+}
 
 func NewExportHandler() *ExportHandler {
-	return &ExportHandler{}
+	return &ExportHandler{
+		budgetService: services.NewBudgetService(),
+	}
 }
 
 func (h *ExportHandler) ExportYear(c echo.Context) error {
@@ -410,3 +416,127 @@ func (h *ExportHandler) writeInstallmentsCSV(writer *csv.Writer) error {
 
 	return nil
 }
+
+// ExportBudgetVariance exports the budget-vs-actual report of a range of months.
+// Query params: from and to (YYYY-MM), optional group_id and format (xlsx or csv).
+func (h *ExportHandler) ExportBudgetVariance(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	groupID, err := parseOptionalGroupID(c.QueryParam("group_id"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do grupo inválido")
+	}
+	fromYear, fromMonth, toYear, toMonth, err := parseVarianceRange(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Mês inválido (use AAAA-MM)")
+	}
+
+	report, err := h.budgetService.GetVarianceReport(userID, groupID, fromYear, fromMonth, toYear, toMonth)
+	if err != nil {
+		return budgetError(c, err)
+	}
+
+	filename := fmt.Sprintf("orcamento_variacao_%d-%02d_%d-%02d", fromYear, fromMonth, toYear, toMonth)
+
+	if c.QueryParam("format") == "csv" {
+		c.Response().Header().Set("Content-Type", "text/csv")
+		c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", filename))
+
+		writer := csv.NewWriter(c.Response().Writer)
+		defer writer.Flush()
+		return h.writeVarianceCSV(writer, report)
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	h.createVarianceSheet(f, report)
+	f.DeleteSheet("Sheet1")
+
+	c.Response().Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.xlsx", filename))
+
+	return f.Write(c.Response().Writer)
+}
+
+// varianceRow is a line of the exported variance report with the month it refers to
+type varianceRow struct {
+	Period string
+	services.VarianceLine
+}
+
+// varianceRows flattens a variance report: one row per category and month,
+// then the totals of each category over the range and the overall total
+func varianceRows(report *services.VarianceReport) []varianceRow {
+	var rows []varianceRow
+	for _, month := range report.Months {
+		period := fmt.Sprintf("%02d/%d", month.Month, month.Year)
+		for _, line := range month.Lines {
+			rows = append(rows, varianceRow{period, line})
+		}
+	}
+	for _, line := range report.Categories {
+		rows = append(rows, varianceRow{"Total", line})
+	}
+	total := report.Total
+	total.Category = "Todas"
+	return append(rows, varianceRow{"Total", total})
+}
+
+var varianceHeaders = []string{"Mês", "Categoria", "Planejado", "Realizado", "Variação", "Variação %"}
+
+func (h *ExportHandler) createVarianceSheet(f *excelize.File, report *services.VarianceReport) {
+	sheet := "Planejado x Realizado"
+	f.NewSheet(sheet)
+
+	for i, h := range varianceHeaders {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheet, cell, h)
+	}
+
+	style, _ := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#4472C4"}, Pattern: 1},
+	})
+	f.SetCellStyle(sheet, "A1", "F1", style)
+
+	for i, r := range varianceRows(report) {
+		row := i + 2
+		f.SetCellValue(sheet, fmt.Sprintf("A%d", row), r.Period)
+		f.SetCellValue(sheet, fmt.Sprintf("B%d", row), r.Category)
+		f.SetCellValue(sheet, fmt.Sprintf("C%d", row), r.Planned)
+		f.SetCellValue(sheet, fmt.Sprintf("D%d", row), r.Actual)
+		f.SetCellValue(sheet, fmt.Sprintf("E%d", row), r.Variance)
+		f.SetCellValue(sheet, fmt.Sprintf("F%d", row), r.VariancePercentage)
+	}
+
+	for i := 1; i <= 6; i++ {
+		col, _ := excelize.ColumnNumberToName(i)
+		f.SetColWidth(sheet, col, col, 15)
+	}
+}
+
+func (h *ExportHandler) writeVarianceCSV(writer *csv.Writer, report *services.VarianceReport) error {
+	// Cabeçalho da seção
+	writer.Write([]string{"PLANEJADO X REALIZADO"})
+
+	if err := writer.Write(varianceHeaders); err != nil {
+		return err
+	}
+
+	for _, r := range varianceRows(report) {
+		row := []string{
+			r.Period,
+			r.Category,
+			fmt.Sprintf("%.2f", r.Planned),
+			fmt.Sprintf("%.2f", r.Actual),
+			fmt.Sprintf("%.2f", r.Variance),
+			fmt.Sprintf("%.2f", r.VariancePercentage),
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
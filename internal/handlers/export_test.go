@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"encoding/csv"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,6 +13,7 @@ import (
 	"github.com/xuri/excelize/v2"
 
 	"poc-finance/internal/database"
+	"poc-finance/internal/middleware"
 	"poc-finance/internal/models"
 	"poc-finance/internal/testutil"
 )
@@ -819,6 +822,91 @@ func TestExportHandler_ExportYear_SpecialCharactersInData(t *testing.T) {
 	}
 }
 
+func TestExportHandler_ExportBudgetVariance(t *testing.T) {
+	handler, e, _ := setupExportTestHandler()
+
+	user := testutil.CreateTestUser(database.DB, "variance@example.com", "Variance User", "hash")
+	database.DB.Create(&models.Budget{
+		UserID: user.ID, Year: 2030, Month: 1, Name: "Janeiro", Status: models.BudgetStatusActive,
+		Categories: []models.BudgetCategory{{Category: "Alimentação", Limit: 1000, Spent: 1200}},
+	})
+
+	tests := []struct {
+		name        string
+		format      string
+		contentType string
+	}{
+		{"xlsx", "xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+		{"csv", "csv", "text/csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/budgets/variance/export?from=2030-01&to=2030-03&format="+tt.format, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.Set(middleware.UserIDKey, user.ID)
+
+			if err := handler.ExportBudgetVariance(c); err != nil {
+				t.Fatalf("ExportBudgetVariance() returned error: %v", err)
+			}
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %s, want %s", got, tt.contentType)
+			}
+			wantDisposition := "attachment; filename=orcamento_variacao_2030-01_2030-03." + tt.format
+			if got := rec.Header().Get("Content-Disposition"); got != wantDisposition {
+				t.Errorf("Content-Disposition = %s, want %s", got, wantDisposition)
+			}
+
+			var rows [][]string
+			if tt.format == "csv" {
+				reader := csv.NewReader(strings.NewReader(rec.Body.String()))
+				reader.FieldsPerRecord = -1 // Section title has a single field
+				all, err := reader.ReadAll()
+				if err != nil || len(all) == 0 {
+					t.Fatalf("Failed to parse CSV: %v", err)
+				}
+				rows = all[1:]
+			} else {
+				f, err := excelize.OpenReader(rec.Body)
+				if err != nil {
+					t.Fatalf("Failed to parse Excel file: %v", err)
+				}
+				defer f.Close()
+				rows, _ = f.GetRows("Planejado x Realizado")
+			}
+
+			// Header, January line, category total and overall total
+			if len(rows) != 4 {
+				t.Fatalf("rows = %d, want 4", len(rows))
+			}
+			if rows[1][0] != "01/2030" || rows[1][1] != "Alimentação" {
+				t.Errorf("first row = %v, want month 01/2030 and Alimentação", rows[1])
+			}
+			if !strings.HasPrefix(rows[1][4], "-200") {
+				t.Errorf("variance = %s, want -200", rows[1][4])
+			}
+		})
+	}
+}
+
+func TestExportHandler_ExportBudgetVariance_InvalidRange(t *testing.T) {
+	handler, e, _ := setupExportTestHandler()
+
+	req := httptest.NewRequest(http.MethodGet, "/budgets/variance/export?from=2030-05&to=2030-01", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set(middleware.UserIDKey, uint(1))
+
+	handler.ExportBudgetVariance(c)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
 // Helper function to check if a slice contains a string
 func contains(slice []string, str string) bool {
 	for _, s := range slice {
@@ -61,12 +61,18 @@ func (h *GoalHandler) GoalsPage(c echo.Context) error {
 	// Get joint accounts for the dropdown
 	accounts, _ := h.accountService.GetGroupJointAccounts(uint(groupID))
 
+	projections, err := h.goalService.GetGoalProjections(goals)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao buscar metas")
+	}
+
 	return c.Render(http.StatusOK, "goals.html", map[string]interface{}{
-		"group":    group,
-		"goals":    goals,
-		"accounts": accounts,
-		"groupID":  groupID,
-		"userID":   userID,
+		"group":       group,
+		"goals":       goals,
+		"projections": projections,
+		"accounts":    accounts,
+		"groupID":     groupID,
+		"userID":      userID,
 	})
 }
 
@@ -86,10 +92,16 @@ func (h *GoalHandler) List(c echo.Context) error {
 		return c.String(http.StatusInternalServerError, "Erro ao buscar metas")
 	}
 
+	projections, err := h.goalService.GetGoalProjections(goals)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao buscar metas")
+	}
+
 	return c.Render(http.StatusOK, "partials/goal-list.html", map[string]interface{}{
-		"goals":   goals,
-		"groupID": groupID,
-		"userID":  userID,
+		"goals":       goals,
+		"projections": projections,
+		"groupID":     groupID,
+		"userID":      userID,
 	})
 }
 
@@ -133,10 +145,16 @@ func (h *GoalHandler) Create(c echo.Context) error {
 	// Return the full list updated
 	goals, _ := h.goalService.GetGroupGoals(uint(groupID), userID)
 
+	projections, err := h.goalService.GetGoalProjections(goals)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao buscar metas")
+	}
+
 	return c.Render(http.StatusOK, "partials/goal-list.html", map[string]interface{}{
-		"goals":   goals,
-		"groupID": groupID,
-		"userID":  userID,
+		"goals":       goals,
+		"projections": projections,
+		"groupID":     groupID,
+		"userID":      userID,
 	})
 }
 
@@ -169,10 +187,16 @@ func (h *GoalHandler) Delete(c echo.Context) error {
 	// Return updated list
 	goals, _ := h.goalService.GetGroupGoals(groupID, userID)
 
+	projections, err := h.goalService.GetGoalProjections(goals)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao buscar metas")
+	}
+
 	return c.Render(http.StatusOK, "partials/goal-list.html", map[string]interface{}{
-		"goals":   goals,
-		"groupID": groupID,
-		"userID":  userID,
+		"goals":       goals,
+		"projections": projections,
+		"groupID":     groupID,
+		"userID":      userID,
 	})
 }
 
@@ -209,9 +233,85 @@ func (h *GoalHandler) AddContribution(c echo.Context) error {
 	// Return updated list
 	goals, _ := h.goalService.GetGroupGoals(goal.GroupID, userID)
 
+	projections, err := h.goalService.GetGoalProjections(goals)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao buscar metas")
+	}
+
+	return c.Render(http.StatusOK, "partials/goal-list.html", map[string]interface{}{
+		"goals":       goals,
+		"projections": projections,
+		"groupID":     goal.GroupID,
+		"userID":      userID,
+	})
+}
+
+// CreateAutoContribution schedules a monthly automatic contribution to a goal
+func (h *GoalHandler) CreateAutoContribution(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	goalID, err := strconv.ParseUint(c.Param("goalId"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID da meta inválido")
+	}
+
+	amount, err := strconv.ParseFloat(c.FormValue("amount"), 64)
+	if err != nil || amount <= 0 {
+		return c.String(http.StatusBadRequest, "Valor inválido")
+	}
+
+	_, err = h.goalService.CreateAutoContribution(uint(goalID), userID, amount)
+	if err != nil {
+		if err == services.ErrGoalNotFound {
+			return c.String(http.StatusNotFound, "Meta não encontrada")
+		}
+		if err == services.ErrGoalCompleted {
+			return c.String(http.StatusBadRequest, "Meta já foi concluída")
+		}
+		if err == services.ErrAutoContributionExists {
+			return c.String(http.StatusConflict, "Você já possui um aporte automático para esta meta")
+		}
+		if err == services.ErrUnauthorized {
+			return c.String(http.StatusForbidden, "Você não é membro deste grupo")
+		}
+		return c.String(http.StatusInternalServerError, "Erro ao criar aporte automático")
+	}
+
+	return h.renderGoalList(c, uint(goalID), userID)
+}
+
+// CancelAutoContribution stops the user's automatic contribution to a goal
+func (h *GoalHandler) CancelAutoContribution(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	goalID, err := strconv.ParseUint(c.Param("goalId"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID da meta inválido")
+	}
+
+	if err := h.goalService.CancelAutoContribution(uint(goalID), userID); err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao cancelar aporte automático")
+	}
+
+	return h.renderGoalList(c, uint(goalID), userID)
+}
+
+// renderGoalList renders the goal list of the group that owns the given goal
+func (h *GoalHandler) renderGoalList(c echo.Context, goalID, userID uint) error {
+	goal, err := h.goalService.GetGoalByID(goalID)
+	if err != nil {
+		return c.String(http.StatusNotFound, "Meta não encontrada")
+	}
+
+	goals, _ := h.goalService.GetGroupGoals(goal.GroupID, userID)
+
+	projections, err := h.goalService.GetGoalProjections(goals)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao buscar metas")
+	}
+
 	return c.Render(http.StatusOK, "partials/goal-list.html", map[string]interface{}{
-		"goals":   goals,
-		"groupID": goal.GroupID,
-		"userID":  userID,
+		"goals":       goals,
+		"projections": projections,
+		"groupID":     goal.GroupID,
+		"userID":      userID,
 	})
 }
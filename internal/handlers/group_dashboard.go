@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -17,6 +18,7 @@ type GroupDashboardHandler struct {
 	groupService       *services.GroupService
 	accountService     *services.AccountService
 	healthScoreService *services.HealthScoreService
+	summaryService     *services.SummaryScheduleService
 }
 
 func NewGroupDashboardHandler() *GroupDashboardHandler {
@@ -24,6 +26,7 @@ func NewGroupDashboardHandler() *GroupDashboardHandler {
 		groupService:       services.NewGroupService(),
 		accountService:     services.NewAccountService(),
 		healthScoreService: services.NewHealthScoreService(),
+		summaryService:     services.NewSummaryScheduleService(),
 	}
 }
 
@@ -176,5 +179,12 @@ func (h *GroupDashboardHandler) Dashboard(c echo.Context) error {
 		"scoreTrend":   scoreTrend,
 	}
 
+	// Summary schedule and the user's delivery preference
+	if schedule, err := h.summaryService.GetGroupSchedule(uint(groupID), userID); err == nil {
+		groupIDValue := uint(groupID)
+		addSummaryScheduleData(data, schedule, h.summaryService.GetPreference(userID, &groupIDValue),
+			h.groupService.IsGroupAdmin(uint(groupID), userID), fmt.Sprintf("/groups/%d/summary/settings", groupID))
+	}
+
 	return c.Render(http.StatusOK, "group-dashboard.html", data)
 }
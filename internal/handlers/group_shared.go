@@ -1,20 +0,0 @@
-package handlers
-
-import (
-	"time"
-)
-
-var monthNames = map[time.Month]string{
-	time.January:   "Janeiro",
-	time.February:  "Fevereiro",
-	time.March:     "Março",
-	time.April:     "Abril",
-	time.May:       "Maio",
-	time.June:      "Junho",
-	time.July:      "Julho",
-	time.August:    "Agosto",
-	time.September: "Setembro",
-	time.October:   "Outubro",
-	time.November:  "Novembro",
-	time.December:  "Dezembro",
-}
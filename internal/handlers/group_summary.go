@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -8,28 +9,34 @@ import (
 
 	"github.com/labstack/echo/v4"
 
-	"poc-finance/internal/database"
 	"poc-finance/internal/middleware"
 	"poc-finance/internal/models"
 	"poc-finance/internal/services"
 )
 
 type GroupSummaryHandler struct {
-	groupService        *services.GroupService
-	accountService      *services.AccountService
-	notificationService *services.NotificationService
+	groupService   *services.GroupService
+	summaryService *services.SummaryScheduleService
 }
 
 func NewGroupSummaryHandler() *GroupSummaryHandler {
 	return &GroupSummaryHandler{
-		groupService:        services.NewGroupService(),
-		accountService:      services.NewAccountService(),
-		notificationService: services.NewNotificationService(),
+		groupService:   services.NewGroupService(),
+		summaryService: services.NewSummaryScheduleService(),
 	}
 }
 
-// GenerateWeeklySummary generates a weekly summary notification for a group
+// GenerateWeeklySummary sends the summary of the last 7 days to the group members right away
 func (h *GroupSummaryHandler) GenerateWeeklySummary(c echo.Context) error {
+	return h.generateSummary(c, models.SummaryFrequencyWeekly, "Resumo semanal enviado com sucesso!")
+}
+
+// GenerateMonthlySummary sends the summary of the current month to the group members right away
+func (h *GroupSummaryHandler) GenerateMonthlySummary(c echo.Context) error {
+	return h.generateSummary(c, models.SummaryFrequencyMonthly, "Resumo mensal enviado com sucesso!")
+}
+
+func (h *GroupSummaryHandler) generateSummary(c echo.Context, frequency models.SummaryFrequency, message string) error {
 	userID := middleware.GetUserID(c)
 	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -41,118 +48,95 @@ func (h *GroupSummaryHandler) GenerateWeeklySummary(c echo.Context) error {
 		return c.String(http.StatusForbidden, "Você não é membro deste grupo")
 	}
 
-	// Get group info
-	group, err := h.groupService.GetGroupByID(uint(groupID))
-	if err != nil {
-		return c.String(http.StatusNotFound, "Grupo não encontrado")
+	// Members who opted out of summaries are skipped, as in the scheduled ones
+	if err := h.summaryService.SendGroupSummary(uint(groupID), frequency, time.Now(), false); err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao criar notificação de resumo")
 	}
 
-	// Get joint account IDs for this group
-	accountIDs, _ := h.accountService.GetGroupJointAccountIDs(uint(groupID))
-
-	// Calculate weekly summary (last 7 days)
-	now := time.Now()
-	weekStart := now.AddDate(0, 0, -7)
-
-	// Get income for the period
-	var totalIncome float64
-	var incomeCount int64
-	database.DB.Model(&models.Income{}).
-		Where("account_id IN ? AND date >= ? AND date <= ?", accountIDs, weekStart, now).
-		Select("COALESCE(SUM(net_amount), 0)").
-		Scan(&totalIncome)
-	database.DB.Model(&models.Income{}).
-		Where("account_id IN ? AND date >= ? AND date <= ?", accountIDs, weekStart, now).
-		Count(&incomeCount)
-
-	// Get expenses for the period
-	var totalExpenses float64
-	var expenseCount int64
-	database.DB.Model(&models.Expense{}).
-		Where("account_id IN ? AND created_at >= ? AND created_at <= ? AND active = ?", accountIDs, weekStart, now, true).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&totalExpenses)
-	database.DB.Model(&models.Expense{}).
-		Where("account_id IN ? AND created_at >= ? AND created_at <= ? AND active = ?", accountIDs, weekStart, now, true).
-		Count(&expenseCount)
-
-	// Build summary data
-	summaryData := services.GroupSummaryData{
-		GroupName:     group.Name,
-		GroupID:       group.ID,
-		PeriodType:    "weekly",
-		PeriodLabel:   fmt.Sprintf("Semana de %s a %s", weekStart.Format("02/01"), now.Format("02/01")),
-		TotalIncome:   totalIncome,
-		TotalExpenses: totalExpenses,
-		Balance:       totalIncome - totalExpenses,
-		ExpenseCount:  int(expenseCount),
-		IncomeCount:   int(incomeCount),
-	}
+	return c.String(http.StatusOK, message)
+}
 
-	// Get group members
-	groupMembers, err := h.groupService.GetGroupMembers(uint(groupID))
+// SaveGroupSettings saves the user's delivery preference for the group's summaries and,
+// for admins, when the summaries are sent automatically
+func (h *GroupSummaryHandler) SaveGroupSettings(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		return c.String(http.StatusInternalServerError, "Erro ao buscar membros do grupo")
+		return c.String(http.StatusBadRequest, "ID do grupo inválido")
 	}
+	groupID := uint(id)
 
-	// Send weekly summary notification
-	if err := h.notificationService.NotifyWeeklySummary(summaryData, groupMembers); err != nil {
-		return c.String(http.StatusInternalServerError, "Erro ao criar notificação de resumo")
+	preference, err := h.summaryService.SavePreference(userID, &groupID, c.FormValue("in_app") == "true", c.FormValue("email") == "true")
+	if err != nil {
+		if errors.Is(err, services.ErrUnauthorized) {
+			return c.String(http.StatusForbidden, "Você não é membro deste grupo")
+		}
+		return c.String(http.StatusInternalServerError, "Erro ao salvar preferências de resumo")
 	}
 
-	return c.String(http.StatusOK, "Resumo semanal enviado com sucesso!")
-}
+	canEdit := h.groupService.IsGroupAdmin(groupID, userID)
+	data := map[string]interface{}{}
+	if canEdit {
+		if _, err := h.summaryService.SaveGroupSchedule(groupID, userID, summaryScheduleFromForm(c)); err != nil {
+			if !errors.Is(err, services.ErrInvalidSummarySchedule) {
+				return c.String(http.StatusInternalServerError, "Erro ao salvar agendamento de resumo")
+			}
+			data["summaryError"] = err.Error()
+		}
+	}
 
-// GenerateMonthlySummary generates a monthly summary notification for a group
-func (h *GroupSummaryHandler) GenerateMonthlySummary(c echo.Context) error {
-	userID := middleware.GetUserID(c)
-	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	schedule, err := h.summaryService.GetGroupSchedule(groupID, userID)
 	if err != nil {
-		return c.String(http.StatusBadRequest, "ID do grupo inválido")
+		return c.String(http.StatusInternalServerError, "Erro ao carregar agendamento de resumo")
 	}
+	addSummaryScheduleData(data, schedule, *preference, canEdit, fmt.Sprintf("/groups/%d/summary/settings", groupID))
+	data["summarySaved"] = data["summaryError"] == nil
+	return c.Render(http.StatusOK, "partials/summary-schedule.html", data)
+}
 
-	// Verify user is a member of the group
-	if !h.groupService.IsGroupMember(uint(groupID), userID) {
-		return c.String(http.StatusForbidden, "Você não é membro deste grupo")
-	}
+// SavePersonalSettings saves when the user's personal summaries are sent and how
+func (h *GroupSummaryHandler) SavePersonalSettings(c echo.Context) error {
+	userID := middleware.GetUserID(c)
 
-	// Get group info
-	group, err := h.groupService.GetGroupByID(uint(groupID))
+	preference, err := h.summaryService.SavePreference(userID, nil, c.FormValue("in_app") == "true", c.FormValue("email") == "true")
 	if err != nil {
-		return c.String(http.StatusNotFound, "Grupo não encontrado")
+		return c.String(http.StatusInternalServerError, "Erro ao salvar preferências de resumo")
 	}
 
-	// Get joint account IDs for this group
-	accountIDs, _ := h.accountService.GetGroupJointAccountIDs(uint(groupID))
-
-	now := time.Now()
-	year := now.Year()
-	month := int(now.Month())
-
-	// Get monthly summary
-	monthlySummary := services.GetMonthlySummaryForAccounts(database.DB, year, month, accountIDs)
-
-	// Build summary data
-	summaryData := services.GroupSummaryData{
-		GroupName:     group.Name,
-		GroupID:       group.ID,
-		PeriodType:    "monthly",
-		PeriodLabel:   monthNames[now.Month()],
-		TotalIncome:   monthlySummary.TotalIncomeNet,
-		TotalExpenses: monthlySummary.TotalExpenses,
-		Balance:       monthlySummary.Balance,
+	data := map[string]interface{}{}
+	if _, err := h.summaryService.SavePersonalSchedule(userID, summaryScheduleFromForm(c)); err != nil {
+		if !errors.Is(err, services.ErrInvalidSummarySchedule) {
+			return c.String(http.StatusInternalServerError, "Erro ao salvar agendamento de resumo")
+		}
+		data["summaryError"] = err.Error()
 	}
 
-	// Get group members
-	groupMembers, err := h.groupService.GetGroupMembers(uint(groupID))
+	schedule, err := h.summaryService.GetPersonalSchedule(userID)
 	if err != nil {
-		return c.String(http.StatusInternalServerError, "Erro ao buscar membros do grupo")
+		return c.String(http.StatusInternalServerError, "Erro ao carregar agendamento de resumo")
 	}
+	addSummaryScheduleData(data, schedule, *preference, true, "/settings/summary")
+	data["summarySaved"] = data["summaryError"] == nil
+	return c.Render(http.StatusOK, "partials/summary-schedule.html", data)
+}
 
-	// Send monthly summary notification
-	if err := h.notificationService.NotifyMonthlySummary(summaryData, groupMembers); err != nil {
-		return c.String(http.StatusInternalServerError, "Erro ao criar notificação de resumo")
+func summaryScheduleFromForm(c echo.Context) models.SummarySchedule {
+	weeklyDay, _ := strconv.Atoi(c.FormValue("weekly_day"))
+	monthlyDay, _ := strconv.Atoi(c.FormValue("monthly_day"))
+	hour, _ := strconv.Atoi(c.FormValue("hour"))
+	return models.SummarySchedule{
+		WeeklyEnabled:  c.FormValue("weekly_enabled") == "true",
+		WeeklyDay:      weeklyDay,
+		MonthlyEnabled: c.FormValue("monthly_enabled") == "true",
+		MonthlyDay:     monthlyDay,
+		Hour:           hour,
 	}
+}
 
-	return c.String(http.StatusOK, "Resumo mensal enviado com sucesso!")
+// addSummaryScheduleData fills the data used by the summary-schedule partial
+func addSummaryScheduleData(data map[string]interface{}, schedule *models.SummarySchedule, preference models.SummaryPreference, canEdit bool, url string) {
+	data["summarySchedule"] = schedule
+	data["summaryPreference"] = preference
+	data["summaryCanEdit"] = canEdit
+	data["summaryURL"] = url
 }
@@ -3,10 +3,12 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
 	"poc-finance/internal/middleware"
+	"poc-finance/internal/models"
 	"poc-finance/internal/services"
 )
 
@@ -14,6 +16,7 @@ type HealthScoreHandler struct {
 	healthScoreService *services.HealthScoreService
 	accountService     *services.AccountService
 	groupService       *services.GroupService
+	netWorthService    *services.NetWorthService
 }
 
 func NewHealthScoreHandler() *HealthScoreHandler {
@@ -21,6 +24,7 @@ func NewHealthScoreHandler() *HealthScoreHandler {
 		healthScoreService: services.NewHealthScoreService(),
 		accountService:     services.NewAccountService(),
 		groupService:       services.NewGroupService(),
+		netWorthService:    services.NewNetWorthService(),
 	}
 }
 
@@ -52,8 +56,11 @@ func (h *HealthScoreHandler) Index(c echo.Context) error {
 		return c.String(http.StatusInternalServerError, "Erro ao gerar recomendações")
 	}
 
+	completed, _ := h.healthScoreService.GetCompletedRecommendations(userID, nil)
+
 	// Get actual health metrics
 	metrics := h.healthScoreService.GetHealthMetrics(userID, accountIDs)
+	netWorth, _ := h.netWorthService.GetSnapshot(userID, nil, time.Now())
 
 	// Calculate score offset for SVG circle (440 is the circumference for r=70)
 	scoreOffset := 440 - (440 * score.Score / 100)
@@ -79,19 +86,25 @@ func (h *HealthScoreHandler) Index(c echo.Context) error {
 		"lastUpdated": score.CalculatedAt.Format("02/01/2006"),
 		"scoreTrend":  scoreTrend,
 		"components": map[string]float64{
-			"savingsScore": score.SavingsScore,
-			"budgetScore":  score.BudgetScore,
-			"debtScore":    score.DebtScore,
-			"goalScore":    score.GoalScore,
+			"savingsScore":         score.SavingsScore,
+			"budgetScore":          score.BudgetScore,
+			"debtScore":            score.DebtScore,
+			"goalScore":            score.GoalScore,
+			"netWorthScore":        score.NetWorthScore,
+			"emergencyFundScore":   score.EmergencyFundScore,
+			"incomeStabilityScore": score.IncomeStabilityScore,
 		},
-		"savingsRate":     metrics.SavingsRate,
-		"budgetAdherence": metrics.BudgetAdherence,
-		"debtRatio":       metrics.DebtRatio,
-		"goalProgress":    metrics.GoalProgress,
-		"activeGoals":     metrics.ActiveGoals,
-		"scoreHistory":    scoreHistory,
-		"recommendations": recommendations,
-		"userID":          userID,
+		"inputs":                   services.ScoreInputs(score),
+		"savingsRate":              metrics.SavingsRate,
+		"budgetAdherence":          metrics.BudgetAdherence,
+		"debtRatio":                metrics.DebtRatio,
+		"goalProgress":             metrics.GoalProgress,
+		"activeGoals":              metrics.ActiveGoals,
+		"netWorth":                 netWorth,
+		"scoreHistory":             scoreHistory,
+		"recommendations":          recommendations,
+		"completedRecommendations": completed,
+		"userID":                   userID,
 	})
 }
 
@@ -183,9 +196,13 @@ func (h *HealthScoreHandler) GroupScorePage(c echo.Context) error {
 		return c.String(http.StatusInternalServerError, "Erro ao gerar recomendações")
 	}
 
+	gid := uint(groupID)
+	completed, _ := h.healthScoreService.GetCompletedRecommendations(userID, &gid)
+
 	// Get group account IDs for metrics calculation
 	groupAccountIDs, _ := h.accountService.GetAllGroupAccountIDs(uint(groupID))
 	metrics := h.healthScoreService.GetHealthMetrics(userID, groupAccountIDs)
+	netWorth, _ := h.netWorthService.GetSnapshot(userID, &gid, time.Now())
 
 	// Calculate score offset for SVG circle (440 is the circumference for r=70)
 	scoreOffset := 440 - (440 * score.Score / 100)
@@ -211,21 +228,27 @@ func (h *HealthScoreHandler) GroupScorePage(c echo.Context) error {
 		"lastUpdated": score.CalculatedAt.Format("02/01/2006"),
 		"scoreTrend":  scoreTrend,
 		"components": map[string]float64{
-			"savingsScore": score.SavingsScore,
-			"budgetScore":  score.BudgetScore,
-			"debtScore":    score.DebtScore,
-			"goalScore":    score.GoalScore,
+			"savingsScore":         score.SavingsScore,
+			"budgetScore":          score.BudgetScore,
+			"debtScore":            score.DebtScore,
+			"goalScore":            score.GoalScore,
+			"netWorthScore":        score.NetWorthScore,
+			"emergencyFundScore":   score.EmergencyFundScore,
+			"incomeStabilityScore": score.IncomeStabilityScore,
 		},
-		"savingsRate":     metrics.SavingsRate,
-		"budgetAdherence": metrics.BudgetAdherence,
-		"debtRatio":       metrics.DebtRatio,
-		"goalProgress":    metrics.GoalProgress,
-		"activeGoals":     metrics.ActiveGoals,
-		"scoreHistory":    scoreHistory,
-		"recommendations": recommendations,
-		"group":           group,
-		"groupID":         groupID,
-		"userID":          userID,
+		"inputs":                   services.ScoreInputs(score),
+		"savingsRate":              metrics.SavingsRate,
+		"budgetAdherence":          metrics.BudgetAdherence,
+		"debtRatio":                metrics.DebtRatio,
+		"goalProgress":             metrics.GoalProgress,
+		"activeGoals":              metrics.ActiveGoals,
+		"netWorth":                 netWorth,
+		"scoreHistory":             scoreHistory,
+		"recommendations":          recommendations,
+		"completedRecommendations": completed,
+		"group":                    group,
+		"groupID":                  groupID,
+		"userID":                   userID,
 	})
 }
 
@@ -265,3 +288,71 @@ func (h *HealthScoreHandler) GetGroupScoreHistory(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, history)
 }
+
+// UpdateRecommendation dismisses, snoozes or completes a recommendation and
+// re-renders the recommendation list (personal or group)
+func (h *HealthScoreHandler) UpdateRecommendation(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	groupID, err := parseOptionalGroupID(c.FormValue("group_id"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do grupo inválido")
+	}
+
+	impact, _ := strconv.ParseFloat(c.FormValue("score_impact"), 64)
+	rec := services.Recommendation{
+		Key:         c.FormValue("key"),
+		Title:       c.FormValue("title"),
+		ScoreImpact: impact,
+	}
+
+	switch c.Param("action") {
+	case "dismiss":
+		err = h.healthScoreService.DismissRecommendation(userID, groupID, rec)
+	case "snooze":
+		err = h.healthScoreService.SnoozeRecommendation(userID, groupID, rec, models.DefaultRecommendationSnoozeDays)
+	case "complete":
+		err = h.healthScoreService.CompleteRecommendation(userID, groupID, rec)
+	default:
+		return c.String(http.StatusBadRequest, "Ação inválida")
+	}
+	if err != nil {
+		switch err {
+		case services.ErrInvalidRecommendationAction:
+			return c.String(http.StatusBadRequest, err.Error())
+		case services.ErrUnauthorized:
+			return c.String(http.StatusForbidden, "Você não é membro deste grupo")
+		}
+		return c.String(http.StatusInternalServerError, "Erro ao atualizar recomendação")
+	}
+
+	// Recalculate so the list reflects the action
+	var score *models.HealthScore
+	if groupID != nil {
+		score, err = h.healthScoreService.CalculateGroupScore(*groupID)
+	} else {
+		accountIDs, accErr := h.accountService.GetUserAccountIDs(userID)
+		if accErr != nil {
+			return c.String(http.StatusInternalServerError, "Erro ao buscar contas")
+		}
+		score, err = h.healthScoreService.CalculateUserScore(userID, accountIDs)
+	}
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao calcular score de saúde")
+	}
+
+	recommendations, err := h.healthScoreService.GetRecommendations(score)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao gerar recomendações")
+	}
+	completed, _ := h.healthScoreService.GetCompletedRecommendations(userID, groupID)
+
+	data := map[string]interface{}{
+		"recommendations":          recommendations,
+		"completedRecommendations": completed,
+	}
+	if groupID != nil {
+		data["groupID"] = *groupID
+	}
+	return c.Render(http.StatusOK, "partials/health-recommendations.html", data)
+}
@@ -17,12 +17,14 @@ import (
 type IncomeHandler struct {
 	accountService *services.AccountService
 	cacheService   *services.SettingsCacheService
+	webhookService *services.WebhookService
 }
 
 func NewIncomeHandler(cacheService *services.SettingsCacheService) *IncomeHandler {
 	return &IncomeHandler{
 		accountService: services.NewAccountService(),
 		cacheService:   cacheService,
+		webhookService: services.NewWebhookService(),
 	}
 }
 
@@ -116,6 +118,21 @@ func (h *IncomeHandler) Create(c echo.Context) error {
 		return c.String(http.StatusInternalServerError, "Erro ao criar recebimento")
 	}
 
+	// Envia o novo recebimento aos webhooks dos usuários da conta
+	if account, err := h.accountService.GetAccountByID(accountID); err == nil {
+		h.webhookService.EmitForAccount(account, models.WebhookEventIncomeCreated, map[string]interface{}{
+			"income_id":   income.ID,
+			"account_id":  income.AccountID,
+			"group_id":    account.GroupID,
+			"date":        income.Date.Format("2006-01-02"),
+			"description": income.Description,
+			"amount_usd":  income.AmountUSD,
+			"amount_brl":  income.AmountBRL,
+			"tax_amount":  income.TaxAmount,
+			"net_amount":  income.NetAmount,
+		})
+	}
+
 	// Retorna a lista atualizada (para HTMX)
 	var incomes []models.Income
 	database.DB.Where("account_id IN ?", accountIDs).Order("date DESC").Find(&incomes)
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"poc-finance/internal/models"
+	"poc-finance/internal/services"
+)
+
+// jobRunsPageSize is how many runs the admin page lists
+const jobRunsPageSize = 50
+
+type JobHandler struct {
+	jobRunner *services.JobRunnerService
+}
+
+func NewJobHandler(jobRunner *services.JobRunnerService) *JobHandler {
+	return &JobHandler{
+		jobRunner: jobRunner,
+	}
+}
+
+// JobsPage renders the admin page with job statuses and the run history
+func (h *JobHandler) JobsPage(c echo.Context) error {
+	data, err := h.dashboardData(c.QueryParam("job"), models.JobRunStatus(c.QueryParam("status")))
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao buscar execuções")
+	}
+	return c.Render(http.StatusOK, "admin-jobs.html", data)
+}
+
+// Dashboard returns the job statuses and the latest runs (HTMX partial). The page polls it
+// while a job is running.
+func (h *JobHandler) Dashboard(c echo.Context) error {
+	return h.renderAfterRun(c, nil)
+}
+
+// RunNow starts a job in the background and returns the updated dashboard (HTMX partial)
+func (h *JobHandler) RunNow(c echo.Context) error {
+	err := h.jobRunner.StartJob(c.Param("name"), models.JobTriggerManual)
+	return h.renderAfterRun(c, err)
+}
+
+// Retry starts a new run of the job of a previous run in the background and returns the
+// updated dashboard (HTMX partial)
+func (h *JobHandler) Retry(c echo.Context) error {
+	runID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID inválido")
+	}
+
+	err = h.jobRunner.RetryRun(uint(runID))
+	return h.renderAfterRun(c, err)
+}
+
+// renderAfterRun maps job runner errors to responses or renders the dashboard partial
+func (h *JobHandler) renderAfterRun(c echo.Context, runErr error) error {
+	switch {
+	case errors.Is(runErr, services.ErrJobNotFound):
+		return c.String(http.StatusNotFound, runErr.Error())
+	case errors.Is(runErr, services.ErrJobLocked):
+		return c.String(http.StatusConflict, runErr.Error())
+	case runErr != nil:
+		return c.String(http.StatusInternalServerError, "Erro ao executar tarefa")
+	}
+
+	data, err := h.dashboardData("", "")
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao buscar execuções")
+	}
+	return c.Render(http.StatusOK, "partials/job-dashboard.html", data)
+}
+
+func (h *JobHandler) dashboardData(jobName string, status models.JobRunStatus) (map[string]interface{}, error) {
+	runs, err := h.jobRunner.GetRecentRuns(jobName, status, jobRunsPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := h.jobRunner.GetJobStatuses()
+	running := false
+	for _, job := range jobs {
+		running = running || job.Running
+	}
+
+	return map[string]interface{}{
+		"jobs":         jobs,
+		"running":      running,
+		"runs":         runs,
+		"filterJob":    jobName,
+		"filterStatus": string(status),
+	}, nil
+}
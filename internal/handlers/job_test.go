@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/services"
+	"poc-finance/internal/testutil"
+)
+
+func setupJobTestHandler() (*JobHandler, *echo.Echo, *services.JobRunnerService) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	runner := services.NewJobRunnerService()
+	runner.Register(services.Job{Name: "ok", Description: "OK", Run: func() error { return nil }})
+	runner.Register(services.Job{Name: "fails", Description: "Fails", MaxAttempts: 1, Run: func() error {
+		return errors.New("failure")
+	}})
+
+	e := echo.New()
+	e.Renderer = &testutil.MockRenderer{}
+	return NewJobHandler(runner), e, runner
+}
+
+// waitForJobs waits for the runs started in the background to finish
+func waitForJobs(t *testing.T, runner *services.JobRunnerService) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		running := false
+		for _, status := range runner.GetJobStatuses() {
+			running = running || status.Running
+		}
+		if !running {
+			return
+		}
+	}
+	t.Fatal("background job runs didn't finish")
+}
+
+func TestJobHandler_JobsPage(t *testing.T) {
+	handler, e, _ := setupJobTestHandler()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/jobs?status=failed", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.JobsPage(c); err != nil {
+		t.Fatalf("JobsPage() returned error: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("Status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestJobHandler_RunNow(t *testing.T) {
+	tests := []struct {
+		name       string
+		job        string
+		wantStatus int
+	}{
+		{name: "existing job", job: "ok", wantStatus: http.StatusOK},
+		{name: "unknown job", job: "missing", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, e, runner := setupJobTestHandler()
+			defer waitForJobs(t, runner)
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/jobs/"+tt.job+"/run", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("name")
+			c.SetParamValues(tt.job)
+
+			if err := handler.RunNow(c); err != nil {
+				t.Fatalf("RunNow() returned error: %v", err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestJobHandler_Retry(t *testing.T) {
+	handler, e, runner := setupJobTestHandler()
+
+	failed, _ := runner.RunJob("fails", models.JobTriggerSchedule)
+
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/admin/jobs/runs/%d/retry", failed.ID), nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(fmt.Sprint(failed.ID))
+
+	if err := handler.Retry(c); err != nil {
+		t.Fatalf("Retry() returned error: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("Status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// The retry runs in the background
+	waitForJobs(t, runner)
+	runs, _ := runner.GetRecentRuns("fails", "", 0)
+	if len(runs) != 2 {
+		t.Errorf("Expected 2 runs, got %d", len(runs))
+	}
+	if len(runs) > 0 && runs[0].ID == failed.ID {
+		t.Error("Retry() should create a new run")
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"poc-finance/internal/middleware"
+	"poc-finance/internal/models"
+	"poc-finance/internal/services"
+)
+
+type NetWorthHandler struct {
+	netWorthService *services.NetWorthService
+	groupService    *services.GroupService
+}
+
+func NewNetWorthHandler() *NetWorthHandler {
+	return &NetWorthHandler{
+		netWorthService: services.NewNetWorthService(),
+		groupService:    services.NewGroupService(),
+	}
+}
+
+type CreateNetWorthItemRequest struct {
+	Name     string  `form:"name"`
+	Category string  `form:"category"`
+	Value    float64 `form:"value"`
+	Date     string  `form:"date"`
+	GroupID  *uint   `form:"group_id"`
+}
+
+type AddValuationRequest struct {
+	Value float64 `form:"value"`
+	Date  string  `form:"date"`
+}
+
+// Page returns the net worth page (personal, or of a group with ?group_id=)
+func (h *NetWorthHandler) Page(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	groupID, err := parseOptionalGroupID(c.QueryParam("group_id"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do grupo inválido")
+	}
+
+	snapshot, err := h.netWorthService.GetSnapshot(userID, groupID, time.Now())
+	if err != nil {
+		return netWorthError(c, err)
+	}
+
+	groups, _ := h.groupService.GetUserGroups(userID)
+
+	var selectedGroupID uint
+	if groupID != nil {
+		selectedGroupID = *groupID
+	}
+
+	return c.Render(http.StatusOK, "net-worth.html", map[string]interface{}{
+		"snapshot":        snapshot,
+		"groups":          groups,
+		"selectedGroupID": selectedGroupID,
+		"today":           time.Now().Format("2006-01-02"),
+	})
+}
+
+// History returns the monthly net worth history as JSON for the chart
+func (h *NetWorthHandler) History(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	groupID, err := parseOptionalGroupID(c.QueryParam("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "ID do grupo inválido"})
+	}
+
+	months := services.DefaultNetWorthMonths
+	if monthsParam := c.QueryParam("months"); monthsParam != "" {
+		if m, err := strconv.Atoi(monthsParam); err == nil && m > 0 && m <= services.MaxNetWorthMonths {
+			months = m
+		}
+	}
+
+	history, err := h.netWorthService.GetHistory(userID, groupID, months, time.Now())
+	if err != nil {
+		if err == services.ErrUnauthorized {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "Você não é membro deste grupo"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Erro ao calcular patrimônio"})
+	}
+
+	return c.JSON(http.StatusOK, history)
+}
+
+// CreateItem registers an asset or liability with its current value
+func (h *NetWorthHandler) CreateItem(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	var req CreateNetWorthItemRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Dados inválidos")
+	}
+
+	date, err := parseValuationDate(req.Date)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Data inválida")
+	}
+
+	_, err = h.netWorthService.CreateItem(userID, req.GroupID, req.Name, models.NetWorthCategory(req.Category), req.Value, date)
+	if err != nil {
+		return netWorthError(c, err)
+	}
+
+	return h.renderList(c, userID, req.GroupID)
+}
+
+// AddValuation records a new value of an item
+func (h *NetWorthHandler) AddValuation(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do item inválido")
+	}
+
+	var req AddValuationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Dados inválidos")
+	}
+
+	date, err := parseValuationDate(req.Date)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Data inválida")
+	}
+
+	if _, err := h.netWorthService.AddValuation(uint(itemID), userID, req.Value, date); err != nil {
+		return netWorthError(c, err)
+	}
+
+	item, err := h.netWorthService.GetItemByID(uint(itemID), userID)
+	if err != nil {
+		return netWorthError(c, err)
+	}
+	return h.renderList(c, userID, item.GroupID)
+}
+
+// DeleteItem deletes an item and its valuations
+func (h *NetWorthHandler) DeleteItem(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do item inválido")
+	}
+
+	item, err := h.netWorthService.GetItemByID(uint(itemID), userID)
+	if err != nil {
+		return netWorthError(c, err)
+	}
+
+	if err := h.netWorthService.DeleteItem(item.ID, userID); err != nil {
+		return netWorthError(c, err)
+	}
+
+	return h.renderList(c, userID, item.GroupID)
+}
+
+func (h *NetWorthHandler) renderList(c echo.Context, userID uint, groupID *uint) error {
+	snapshot, err := h.netWorthService.GetSnapshot(userID, groupID, time.Now())
+	if err != nil {
+		return netWorthError(c, err)
+	}
+
+	return c.Render(http.StatusOK, "partials/net-worth-list.html", map[string]interface{}{
+		"snapshot": snapshot,
+		"today":    time.Now().Format("2006-01-02"),
+	})
+}
+
+// parseValuationDate parses the date of a valuation, defaulting to today
+func parseValuationDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+// netWorthError maps net worth service errors to responses
+func netWorthError(c echo.Context, err error) error {
+	switch err {
+	case services.ErrInvalidNetWorthItem, services.ErrInvalidValuation:
+		return c.String(http.StatusBadRequest, err.Error())
+	case services.ErrNetWorthItemNotFound:
+		return c.String(http.StatusNotFound, "Item não encontrado")
+	case services.ErrUnauthorized:
+		return c.String(http.StatusForbidden, "Você não tem permissão para acessar este patrimônio")
+	}
+	return c.String(http.StatusInternalServerError, "Erro ao processar patrimônio")
+}
@@ -1,42 +1,170 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
 	"poc-finance/internal/middleware"
+	"poc-finance/internal/models"
 	"poc-finance/internal/services"
 )
 
 type NotificationHandler struct {
 	notificationService *services.NotificationService
+	groupService        *services.GroupService
 }
 
 func NewNotificationHandler() *NotificationHandler {
 	return &NotificationHandler{
 		notificationService: services.NewNotificationService(),
+		groupService:        services.NewGroupService(),
 	}
 }
 
-// List returns all notifications for the current user
+// notificationFilterFromRequest reads the type, group_id and status filters from the
+// query string or form
+func notificationFilterFromRequest(c echo.Context) services.NotificationFilter {
+	filter := services.NotificationFilter{
+		Type:       models.NotificationType(c.FormValue("type")),
+		UnreadOnly: c.FormValue("status") == "unread",
+	}
+	if groupID, err := strconv.ParseUint(c.FormValue("group_id"), 10, 32); err == nil {
+		id := uint(groupID)
+		filter.GroupID = &id
+	}
+	return filter
+}
+
+// notificationFilterQuery encodes a filter back into /notifications query parameters
+func notificationFilterQuery(filter services.NotificationFilter) string {
+	values := url.Values{}
+	if filter.Type != "" {
+		values.Set("type", string(filter.Type))
+	}
+	if filter.GroupID != nil {
+		values.Set("group_id", strconv.FormatUint(uint64(*filter.GroupID), 10))
+	}
+	if filter.UnreadOnly {
+		values.Set("status", "unread")
+	}
+	return values.Encode()
+}
+
+// List returns the current user's notifications, optionally filtered by type, group and
+// read status
 func (h *NotificationHandler) List(c echo.Context) error {
 	userID := middleware.GetUserID(c)
+	filter := notificationFilterFromRequest(c)
 
-	notifications, err := h.notificationService.GetUserNotifications(userID, 0)
+	notifications, err := h.notificationService.FindNotifications(userID, filter, 0)
 	if err != nil {
 		return c.String(http.StatusInternalServerError, "Erro ao buscar notificações")
 	}
 
 	unreadCount, _ := h.notificationService.GetUnreadCount(userID)
+	groups, _ := h.groupService.GetUserGroups(userID)
+
+	filterGroupID := uint(0)
+	if filter.GroupID != nil {
+		filterGroupID = *filter.GroupID
+	}
 
 	return c.Render(http.StatusOK, "notifications.html", map[string]interface{}{
-		"notifications": notifications,
-		"unreadCount":   unreadCount,
+		"notifications":        notifications,
+		"unreadCount":          unreadCount,
+		"groups":               groups,
+		"typeOptions":          services.NotificationTypeOptions,
+		"filter":               filter,
+		"filterGroupID":        filterGroupID,
+		"filterQuery":          notificationFilterQuery(filter),
+		"preferenceRows":       h.notificationService.GetPreferenceRows(userID),
+		"notificationSettings": h.notificationService.GetSettings(userID),
 	})
 }
 
+// Bulk marks as read or deletes every notification matching the filter in the form,
+// then reloads the list with the same filter
+func (h *NotificationHandler) Bulk(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	filter := notificationFilterFromRequest(c)
+
+	switch c.FormValue("action") {
+	case "read":
+		if _, err := h.notificationService.MarkAsReadByFilter(userID, filter); err != nil {
+			return c.String(http.StatusInternalServerError, "Erro ao marcar como lidas")
+		}
+	case "delete":
+		if _, err := h.notificationService.DeleteByFilter(userID, filter); err != nil {
+			return c.String(http.StatusInternalServerError, "Erro ao excluir notificações")
+		}
+	default:
+		return c.String(http.StatusBadRequest, "Ação inválida")
+	}
+
+	redirect := "/notifications"
+	if query := notificationFilterQuery(filter); query != "" {
+		redirect += "?" + query
+	}
+	c.Response().Header().Set("HX-Redirect", redirect)
+	return c.NoContent(http.StatusOK)
+}
+
+// SavePreferences saves the user's channels for each notification type, quiet hours,
+// digest time and webhook URL
+func (h *NotificationHandler) SavePreferences(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	preferences := make([]models.NotificationPreference, len(services.NotificationTypeOptions))
+	for i, option := range services.NotificationTypeOptions {
+		field := func(channel string) bool {
+			return c.FormValue(string(option.Type)+"_"+channel) == "true"
+		}
+		preferences[i] = models.NotificationPreference{
+			Type:    option.Type,
+			InApp:   field("in_app"),
+			Email:   field("email"),
+			Webhook: field("webhook"),
+			Digest:  field("digest"),
+		}
+	}
+
+	quietStart, _ := strconv.Atoi(c.FormValue("quiet_start"))
+	quietEnd, _ := strconv.Atoi(c.FormValue("quiet_end"))
+	digestHour, _ := strconv.Atoi(c.FormValue("digest_hour"))
+	settings := models.NotificationSettings{
+		QuietHoursEnabled: c.FormValue("quiet_hours_enabled") == "true",
+		QuietStart:        quietStart,
+		QuietEnd:          quietEnd,
+		DigestHour:        digestHour,
+	}
+
+	data := map[string]interface{}{}
+	if _, err := h.notificationService.SaveSettings(userID, settings); err != nil {
+		if err != services.ErrInvalidNotificationSettings && err != services.ErrDigestInQuietHours {
+			return c.String(http.StatusInternalServerError, "Erro ao salvar preferências de notificação")
+		}
+		data["preferencesError"] = err.Error()
+	}
+	if err := h.notificationService.SavePreferences(userID, preferences); err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao salvar preferências de notificação")
+	}
+
+	data["preferenceRows"] = h.notificationService.GetPreferenceRows(userID)
+	if data["preferencesError"] != nil {
+		data["notificationSettings"] = settings
+	} else {
+		data["notificationSettings"] = h.notificationService.GetSettings(userID)
+		data["preferencesSaved"] = true
+	}
+	return c.Render(http.StatusOK, "partials/notification-preferences.html", data)
+}
+
 // GetDropdown returns the notification dropdown content (HTMX partial)
 func (h *NotificationHandler) GetDropdown(c echo.Context) error {
 	userID := middleware.GetUserID(c)
@@ -107,3 +235,53 @@ func (h *NotificationHandler) Delete(c echo.Context) error {
 
 	return c.String(http.StatusOK, "")
 }
+
+// notificationStreamHeartbeat keeps idle SSE connections open through proxies
+const notificationStreamHeartbeat = 25 * time.Second
+
+// Stream pushes the user's notification events as Server-Sent Events, so the badge and
+// dropdown refresh as soon as something changes instead of waiting for the next poll
+func (h *NotificationHandler) Stream(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	events, unsubscribe := services.GetNotificationBroker().Subscribe(userID)
+	defer unsubscribe()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering (nginx)
+	res.WriteHeader(http.StatusOK)
+	// Ask the browser to wait a few seconds before reconnecting
+	fmt.Fprint(res, "retry: 5000\n\n")
+	res.Flush()
+
+	heartbeat := time.NewTicker(notificationStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	ctx := c.Request().Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": ping\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(res, "event: notification\ndata: %s\n\n", data); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
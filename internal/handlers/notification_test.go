@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -602,3 +605,111 @@ func TestNotificationHandler_MultipleNotificationTypes(t *testing.T) {
 		t.Errorf("Unread count = %d, want %d", unreadCount, 3)
 	}
 }
+
+func TestNotificationHandler_Stream(t *testing.T) {
+	handler, e, userID, group := setupNotificationTestHandler()
+	e.GET("/notifications/stream", handler.Stream, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(middleware.UserIDKey, userID)
+			return next(c)
+		}
+	})
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/notifications/stream")
+	if err != nil {
+		t.Fatalf("GET /notifications/stream error = %v", err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get(echo.HeaderContentType); got != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	next := func() string {
+		t.Helper()
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the stream")
+			return ""
+		}
+	}
+
+	// The retry hint is written once the stream is subscribed
+	if line := next(); line != "retry: 5000" {
+		t.Fatalf("first line = %q, want the retry hint", line)
+	}
+	next()
+
+	// Notifications of other users are not streamed
+	services.NewNotificationService().Create(&models.Notification{UserID: userID + 1, Type: models.NotificationTypeExpense, Title: "Outro", Message: "x"})
+	services.NewNotificationService().Create(&models.Notification{UserID: userID, Type: models.NotificationTypeExpense, Title: "Nova despesa", Message: "x", GroupID: &group.ID})
+
+	if line := next(); line != "event: notification" {
+		t.Fatalf("event line = %q, want a notification event", line)
+	}
+	var event services.NotificationEvent
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(next(), "data: ")), &event); err != nil {
+		t.Fatalf("event data error = %v", err)
+	}
+	if event.UserID != userID || event.Kind != services.NotificationEventCreated || event.Title != "Nova despesa" {
+		t.Errorf("event = %+v, want the new notification of the user", event)
+	}
+}
+
+func TestNotificationHandler_Bulk(t *testing.T) {
+	handler, e, userID, group := setupNotificationTestHandler()
+
+	createTestNotification(userID, &group.ID, models.NotificationTypeDueDate, false)
+	createTestNotification(userID, &group.ID, models.NotificationTypeDueDate, false)
+	createTestNotification(userID, nil, models.NotificationTypeBudgetAlert, false)
+
+	bulk := func(action, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/notifications/bulk?"+query, strings.NewReader("action="+action))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set(middleware.UserIDKey, userID)
+		if err := handler.Bulk(c); err != nil {
+			t.Fatalf("Bulk(%s) returned error: %v", action, err)
+		}
+		return rec
+	}
+	unread := func() int64 {
+		var count int64
+		database.DB.Model(&models.Notification{}).Where("user_id = ? AND read = ?", userID, false).Count(&count)
+		return count
+	}
+
+	rec := bulk("read", fmt.Sprintf("type=due_date&group_id=%d", group.ID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if want := fmt.Sprintf("/notifications?group_id=%d&type=due_date", group.ID); rec.Header().Get("HX-Redirect") != want {
+		t.Errorf("HX-Redirect = %q, want %q", rec.Header().Get("HX-Redirect"), want)
+	}
+	if unread() != 1 {
+		t.Errorf("Unread count = %d, want only the budget alert left", unread())
+	}
+
+	bulk("delete", "status=unread")
+	var remaining int64
+	database.DB.Model(&models.Notification{}).Where("user_id = ?", userID).Count(&remaining)
+	if remaining != 2 {
+		t.Errorf("Remaining notifications = %d, want the 2 read ones", remaining)
+	}
+
+	if rec := bulk("archive", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Status for an unknown action = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
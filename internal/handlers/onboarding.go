@@ -109,8 +109,12 @@ func (h *OnboardingHandler) ShowWizard(c echo.Context) error {
 		step = currentStep
 	}
 
-	// Get category templates for step 3
+	// Get category templates for step 3, plus a suggestion when there is spending history
 	templates := h.onboardingService.GetCategoryTemplates()
+	var suggestion *services.BudgetSuggestion
+	if step == 3 {
+		suggestion = h.onboardingService.GetHistorySuggestion(userID)
+	}
 
 	// Get user's account for step 4
 	var account *models.Account
@@ -132,6 +136,7 @@ func (h *OnboardingHandler) ShowWizard(c echo.Context) error {
 		"step":        step,
 		"currentStep": currentStep,
 		"templates":   templates,
+		"suggestion":  suggestion,
 		"account":     account,
 		"categories":  categories,
 	}
@@ -187,14 +192,20 @@ func (h *OnboardingHandler) SelectCategories(c echo.Context) error {
 		})
 	}
 
-	// Create budget with categories from template
-	_, err := h.onboardingService.CreateDefaultBudget(userID, templateName)
+	// Create budget with categories from template, or from the spending history
+	var err error
+	if templateName == services.HistoryTemplateName {
+		_, err = h.onboardingService.CreateBudgetFromHistory(userID)
+	} else {
+		_, err = h.onboardingService.CreateDefaultBudget(userID, templateName)
+	}
 	if err != nil {
 		templates := h.onboardingService.GetCategoryTemplates()
 		return c.Render(http.StatusOK, "onboarding.html", map[string]interface{}{
-			"step":      3,
-			"templates": templates,
-			"error":     "Erro ao criar categorias. Tente novamente.",
+			"step":       3,
+			"templates":  templates,
+			"suggestion": h.onboardingService.GetHistorySuggestion(userID),
+			"error":      "Erro ao criar categorias. Tente novamente.",
 		})
 	}
 
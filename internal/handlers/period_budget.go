@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"poc-finance/internal/middleware"
+	"poc-finance/internal/models"
+	"poc-finance/internal/services"
+)
+
+type PeriodBudgetHandler struct {
+	periodBudgetService *services.PeriodBudgetService
+	groupService        *services.GroupService
+}
+
+func NewPeriodBudgetHandler() *PeriodBudgetHandler {
+	return &PeriodBudgetHandler{
+		periodBudgetService: services.NewPeriodBudgetService(),
+		groupService:        services.NewGroupService(),
+	}
+}
+
+type CreatePeriodBudgetRequest struct {
+	Name       string `form:"name"`
+	PeriodType string `form:"period_type"`
+	StartDate  string `form:"start_date"`
+	EndDate    string `form:"end_date"`
+	GroupID    *uint  `form:"group_id"`
+}
+
+// Page returns the period budgets page (personal, or of a group with ?group_id=)
+func (h *PeriodBudgetHandler) Page(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	groupID, err := parseOptionalGroupID(c.QueryParam("group_id"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do grupo inválido")
+	}
+
+	progress, err := h.listProgress(userID, groupID)
+	if err != nil {
+		return periodBudgetError(c, err)
+	}
+
+	groups, _ := h.groupService.GetUserGroups(userID)
+
+	return c.Render(http.StatusOK, "period-budgets.html", map[string]interface{}{
+		"budgets":    progress,
+		"groups":     groups,
+		"groupID":    groupID,
+		"categories": getExpenseCategories(),
+		"today":      time.Now().Format("2006-01-02"),
+	})
+}
+
+// Create creates a period budget
+func (h *PeriodBudgetHandler) Create(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	var req CreatePeriodBudgetRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Dados inválidos")
+	}
+
+	if req.Name == "" {
+		return c.String(http.StatusBadRequest, "Nome é obrigatório")
+	}
+
+	startDate, err := time.ParseInLocation("2006-01-02", req.StartDate, time.Local)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Data inicial inválida")
+	}
+	var endDate time.Time
+	if req.EndDate != "" {
+		endDate, err = time.ParseInLocation("2006-01-02", req.EndDate, time.Local)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "Data final inválida")
+		}
+	}
+
+	_, err = h.periodBudgetService.CreatePeriodBudget(userID, req.GroupID, req.Name,
+		models.BudgetPeriodType(req.PeriodType), startDate, endDate)
+	if err != nil {
+		return periodBudgetError(c, err)
+	}
+
+	return h.renderList(c, userID, req.GroupID)
+}
+
+// Delete deletes a period budget
+func (h *PeriodBudgetHandler) Delete(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	budgetID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do orçamento inválido")
+	}
+
+	budget, err := h.periodBudgetService.GetPeriodBudgetByID(uint(budgetID), userID)
+	if err != nil {
+		return periodBudgetError(c, err)
+	}
+
+	if err := h.periodBudgetService.DeletePeriodBudget(budget.ID, userID); err != nil {
+		return periodBudgetError(c, err)
+	}
+
+	return h.renderList(c, userID, budget.GroupID)
+}
+
+// AddCategory adds a category to a period budget
+func (h *PeriodBudgetHandler) AddCategory(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	budgetID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do orçamento inválido")
+	}
+
+	var req AddCategoryRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Dados inválidos")
+	}
+
+	if req.Category == "" || req.Limit <= 0 {
+		return c.String(http.StatusBadRequest, "Categoria e limite são obrigatórios")
+	}
+
+	if _, err := h.periodBudgetService.AddCategory(uint(budgetID), userID, req.Category, req.Limit); err != nil {
+		return periodBudgetError(c, err)
+	}
+
+	return h.renderBudget(c, userID, uint(budgetID))
+}
+
+// DeleteCategory removes a category from a period budget
+func (h *PeriodBudgetHandler) DeleteCategory(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	budgetID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do orçamento inválido")
+	}
+	categoryID, err := strconv.ParseUint(c.Param("catId"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID da categoria inválido")
+	}
+
+	if err := h.periodBudgetService.DeleteCategory(uint(budgetID), uint(categoryID), userID); err != nil {
+		return periodBudgetError(c, err)
+	}
+
+	return h.renderBudget(c, userID, uint(budgetID))
+}
+
+func (h *PeriodBudgetHandler) listProgress(userID uint, groupID *uint) ([]*services.PeriodBudgetProgress, error) {
+	budgets, err := h.periodBudgetService.GetPeriodBudgets(userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	progress := make([]*services.PeriodBudgetProgress, len(budgets))
+	for i := range budgets {
+		progress[i] = h.periodBudgetService.GetProgress(&budgets[i], now)
+	}
+	return progress, nil
+}
+
+func (h *PeriodBudgetHandler) renderList(c echo.Context, userID uint, groupID *uint) error {
+	progress, err := h.listProgress(userID, groupID)
+	if err != nil {
+		return periodBudgetError(c, err)
+	}
+
+	return c.Render(http.StatusOK, "partials/period-budget-list.html", map[string]interface{}{
+		"budgets":    progress,
+		"categories": getExpenseCategories(),
+	})
+}
+
+func (h *PeriodBudgetHandler) renderBudget(c echo.Context, userID, budgetID uint) error {
+	budget, err := h.periodBudgetService.GetPeriodBudgetByID(budgetID, userID)
+	if err != nil {
+		return periodBudgetError(c, err)
+	}
+
+	return c.Render(http.StatusOK, "partials/period-budget-item.html", map[string]interface{}{
+		"progress":   h.periodBudgetService.GetProgress(budget, time.Now()),
+		"categories": getExpenseCategories(),
+	})
+}
+
+// parseOptionalGroupID parses a group ID query parameter, returning nil when empty
+func parseOptionalGroupID(value string) (*uint, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	groupID := uint(id)
+	return &groupID, nil
+}
+
+// periodBudgetError maps period budget service errors to responses
+func periodBudgetError(c echo.Context, err error) error {
+	switch err {
+	case services.ErrInvalidBudgetPeriod:
+		return c.String(http.StatusBadRequest, err.Error())
+	case services.ErrPeriodBudgetNotFound:
+		return c.String(http.StatusNotFound, "Orçamento não encontrado")
+	case services.ErrCategoryNotFound:
+		return c.String(http.StatusNotFound, "Categoria não encontrada")
+	case services.ErrUnauthorized:
+		return c.String(http.StatusForbidden, "Você não tem permissão para acessar este orçamento")
+	}
+	return c.String(http.StatusInternalServerError, "Erro ao processar orçamento")
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,13 +14,18 @@ import (
 	"poc-finance/internal/services"
 )
 
+// defaultOccurrenceMonths is the preview window when none is requested
+const defaultOccurrenceMonths = 3
+
 type RecurringTransactionHandler struct {
-	accountService *services.AccountService
+	accountService    *services.AccountService
+	occurrenceService *services.RecurringOccurrenceService
 }
 
 func NewRecurringTransactionHandler() *RecurringTransactionHandler {
 	return &RecurringTransactionHandler{
-		accountService: services.NewAccountService(),
+		accountService:    services.NewAccountService(),
+		occurrenceService: services.NewRecurringOccurrenceService(),
 	}
 }
 
@@ -32,6 +38,46 @@ type CreateRecurringTransactionRequest struct {
 	StartDate       string  `form:"start_date"`
 	EndDate         string  `form:"end_date"`
 	Category        string  `form:"category"`
+	Currency        string  `form:"currency"`
+	ExchangeRate    float64 `form:"exchange_rate"`
+
+	// Schedule options
+	Interval              int    `form:"interval"`
+	ScheduleRule          string `form:"schedule_rule"`
+	DayOfMonth            int    `form:"day_of_month"`
+	WeekOfMonth           int    `form:"week_of_month"`
+	Weekday               int    `form:"weekday"`
+	BusinessDayAdjustment string `form:"business_day_adjustment"`
+}
+
+// applySchedule copies the schedule options from the request into the recurring transaction
+func (req *CreateRecurringTransactionRequest) applySchedule(rt *models.RecurringTransaction) error {
+	rt.Interval = req.Interval
+	rt.ScheduleRule = models.ScheduleRule(req.ScheduleRule)
+	rt.DayOfMonth = req.DayOfMonth
+	rt.WeekOfMonth = req.WeekOfMonth
+	rt.Weekday = req.Weekday
+	rt.BusinessDayAdjustment = models.BusinessDayAdjustment(req.BusinessDayAdjustment)
+	return services.ValidateSchedule(rt)
+}
+
+// applyCurrency copies the currency options into the recurring transaction.
+// Only incomes may be in USD; the exchange rate is a fallback used when no quote is available.
+func (req *CreateRecurringTransactionRequest) applyCurrency(rt *models.RecurringTransaction) bool {
+	switch req.Currency {
+	case "", models.CurrencyBRL:
+		rt.Currency = models.CurrencyBRL
+		rt.ExchangeRate = 0
+	case models.CurrencyUSD:
+		if rt.TransactionType != models.TransactionTypeIncome || req.ExchangeRate < 0 {
+			return false
+		}
+		rt.Currency = models.CurrencyUSD
+		rt.ExchangeRate = req.ExchangeRate
+	default:
+		return false
+	}
+	return true
 }
 
 func (h *RecurringTransactionHandler) List(c echo.Context) error {
@@ -51,6 +97,8 @@ func (h *RecurringTransactionHandler) List(c echo.Context) error {
 		"accounts":                    accounts,
 		"transactionTypes":            []string{"expense", "income"},
 		"frequencies":                 []string{"daily", "weekly", "monthly", "yearly"},
+		"scheduleRules":               []string{"day_of_month", "last_business_day", "nth_weekday"},
+		"businessDayAdjustments":      []string{"none", "following", "preceding"},
 	}
 
 	return c.Render(http.StatusOK, "recurring.html", data)
@@ -111,9 +159,6 @@ func (h *RecurringTransactionHandler) Create(c echo.Context) error {
 		return c.String(http.StatusBadRequest, "Valor deve ser maior que zero")
 	}
 
-	// Set next run date to start date
-	nextRunDate := startDate
-
 	recurringTransaction := models.RecurringTransaction{
 		AccountID:       accountID,
 		TransactionType: transactionType,
@@ -122,11 +167,21 @@ func (h *RecurringTransactionHandler) Create(c echo.Context) error {
 		Description:     req.Description,
 		StartDate:       startDate,
 		EndDate:         endDate,
-		NextRunDate:     nextRunDate,
 		Active:          true,
 		Category:        req.Category,
 	}
 
+	if err := req.applySchedule(&recurringTransaction); err != nil {
+		return c.String(http.StatusBadRequest, "Agendamento inválido")
+	}
+
+	if !req.applyCurrency(&recurringTransaction) {
+		return c.String(http.StatusBadRequest, "Moeda inválida")
+	}
+
+	// Set next run date to the first scheduled occurrence
+	recurringTransaction.NextRunDate = services.FirstOccurrence(&recurringTransaction)
+
 	if err := database.DB.Create(&recurringTransaction).Error; err != nil {
 		return c.String(http.StatusInternalServerError, "Erro ao criar transação recorrente")
 	}
@@ -199,6 +254,13 @@ func (h *RecurringTransactionHandler) Update(c echo.Context) error {
 		recurringTransaction.Category = req.Category
 	}
 
+	// Update currency
+	if req.Currency != "" {
+		if !req.applyCurrency(&recurringTransaction) {
+			return c.String(http.StatusBadRequest, "Moeda inválida")
+		}
+	}
+
 	// Update start date
 	if req.StartDate != "" {
 		startDate, err := time.Parse("2006-01-02", req.StartDate)
@@ -217,6 +279,20 @@ func (h *RecurringTransactionHandler) Update(c echo.Context) error {
 		recurringTransaction.EndDate = &parsedEndDate
 	}
 
+	// Update schedule options and realign the next run date with the new schedule
+	if req.ScheduleRule != "" || req.StartDate != "" || req.Frequency != "" {
+		if req.ScheduleRule != "" {
+			if err := req.applySchedule(&recurringTransaction); err != nil {
+				return c.String(http.StatusBadRequest, "Agendamento inválido")
+			}
+		}
+		recurringTransaction.NextRunDate = nextRunDateFrom(&recurringTransaction, time.Now())
+
+		// Overrides refer to dates of the previous schedule
+		database.DB.Unscoped().Where("recurring_transaction_id = ?", recurringTransaction.ID).
+			Delete(&models.RecurringOccurrenceOverride{})
+	}
+
 	if err := database.DB.Save(&recurringTransaction).Error; err != nil {
 		return c.String(http.StatusInternalServerError, "Erro ao atualizar transação recorrente")
 	}
@@ -267,6 +343,11 @@ func (h *RecurringTransactionHandler) Toggle(c echo.Context) error {
 	}
 
 	recurringTransaction.Active = !recurringTransaction.Active
+
+	// Resuming skips the occurrences missed while paused instead of catching them up
+	if recurringTransaction.Active {
+		recurringTransaction.NextRunDate = nextRunDateFrom(&recurringTransaction, time.Now())
+	}
 	database.DB.Save(&recurringTransaction)
 
 	// Return updated active list
@@ -277,3 +358,133 @@ func (h *RecurringTransactionHandler) Toggle(c echo.Context) error {
 		"items": activeRecurringTransactions,
 	})
 }
+
+// nextRunDateFrom returns the first scheduled occurrence from today onwards,
+// or the first occurrence of the schedule if it has not started yet
+func nextRunDateFrom(rt *models.RecurringTransaction, now time.Time) time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if rt.StartDate.After(today) {
+		return services.FirstOccurrence(rt)
+	}
+	return services.NextOccurrence(rt, today.Add(-time.Nanosecond))
+}
+
+// Occurrences returns the upcoming occurrences of the user's recurring transactions (HTMX partial)
+func (h *RecurringTransactionHandler) Occurrences(c echo.Context) error {
+	return h.renderOccurrences(c)
+}
+
+// SkipOccurrence skips a single upcoming occurrence
+func (h *RecurringTransactionHandler) SkipOccurrence(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	accountIDs, _ := h.accountService.GetUserAccountIDs(userID)
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := h.occurrenceService.SkipOccurrence(uint(id), accountIDs, c.Param("date")); err != nil {
+		return occurrenceError(c, err)
+	}
+
+	return h.renderOccurrences(c)
+}
+
+// UpdateOccurrence changes the amount and/or date of a single upcoming occurrence
+func (h *RecurringTransactionHandler) UpdateOccurrence(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	accountIDs, _ := h.accountService.GetUserAccountIDs(userID)
+
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var amount *float64
+	if value := c.FormValue("amount"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "Valor inválido")
+		}
+		amount = &parsed
+	}
+
+	var date *time.Time
+	if value := c.FormValue("date"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "Data inválida")
+		}
+		date = &parsed
+	}
+
+	if amount == nil && date == nil {
+		return c.String(http.StatusBadRequest, "Informe um novo valor ou uma nova data")
+	}
+
+	if err := h.occurrenceService.OverrideOccurrence(uint(id), accountIDs, c.Param("date"), amount, date); err != nil {
+		return occurrenceError(c, err)
+	}
+
+	return h.renderOccurrences(c)
+}
+
+// RestoreOccurrence removes the changes made to a single upcoming occurrence
+func (h *RecurringTransactionHandler) RestoreOccurrence(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	accountIDs, _ := h.accountService.GetUserAccountIDs(userID)
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := h.occurrenceService.RestoreOccurrence(uint(id), accountIDs, c.Param("date")); err != nil {
+		return occurrenceError(c, err)
+	}
+
+	return h.renderOccurrences(c)
+}
+
+// renderOccurrences renders the occurrence preview for the window given by the "months" parameter
+func (h *RecurringTransactionHandler) renderOccurrences(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	accountIDs, _ := h.accountService.GetUserAccountIDs(userID)
+
+	months, _ := strconv.Atoi(c.QueryParam("months"))
+	if months < 1 || months > 24 {
+		months = defaultOccurrenceMonths
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	to := from.AddDate(0, months, 0)
+
+	occurrences, err := h.occurrenceService.PreviewOccurrences(accountIDs, from, to)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao buscar ocorrências")
+	}
+
+	var totalIncome, totalExpense float64
+	for _, occurrence := range occurrences {
+		// USD amounts are only converted when generated, so they are left out of the totals
+		if occurrence.Skipped || occurrence.Currency == models.CurrencyUSD {
+			continue
+		}
+		if occurrence.TransactionType == models.TransactionTypeIncome {
+			totalIncome += occurrence.Amount
+		} else {
+			totalExpense += occurrence.Amount
+		}
+	}
+
+	return c.Render(http.StatusOK, "partials/recurring-occurrences.html", map[string]interface{}{
+		"occurrences":  occurrences,
+		"months":       months,
+		"totalIncome":  totalIncome,
+		"totalExpense": totalExpense,
+	})
+}
+
+// occurrenceError maps occurrence service errors to HTTP responses
+func occurrenceError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, services.ErrOccurrenceNotFound):
+		return c.String(http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidOccurrenceDate), errors.Is(err, services.ErrOccurrenceTooEarly),
+		errors.Is(err, services.ErrInvalidOccurrenceAmount):
+		return c.String(http.StatusBadRequest, err.Error())
+	default:
+		return c.String(http.StatusInternalServerError, "Erro ao atualizar ocorrência")
+	}
+}
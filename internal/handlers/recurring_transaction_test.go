@@ -762,3 +762,70 @@ func TestRecurringTransactionHandler_Toggle_UnauthorizedAccount(t *testing.T) {
 		t.Errorf("Other user's transaction was toggled when it shouldn't be")
 	}
 }
+
+func TestRecurringTransactionHandler_Occurrences(t *testing.T) {
+	handler, e, userID, accountID := setupRecurringTransactionTestHandler()
+	e.Renderer = &testutil.MockRenderer{}
+
+	start := time.Now().AddDate(0, 0, 1)
+	rt := models.RecurringTransaction{
+		AccountID:       accountID,
+		TransactionType: models.TransactionTypeExpense,
+		Frequency:       models.FrequencyMonthly,
+		Amount:          50,
+		Description:     "Streaming",
+		StartDate:       start,
+		NextRunDate:     start,
+		Active:          true,
+	}
+	database.DB.Create(&rt)
+	day := start.Format("2006-01-02")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		form       url.Values
+		handle     func(c echo.Context) error
+		wantStatus int
+	}{
+		{name: "preview", method: http.MethodGet, path: "/recurring/occurrences?months=6", handle: handler.Occurrences, wantStatus: http.StatusOK},
+		{name: "skip", method: http.MethodPost, path: "/recurring/occurrences/skip", handle: handler.SkipOccurrence, wantStatus: http.StatusOK},
+		{name: "update amount", method: http.MethodPost, path: "/recurring/occurrences", form: url.Values{"amount": {"75"}}, handle: handler.UpdateOccurrence, wantStatus: http.StatusOK},
+		{name: "update without changes", method: http.MethodPost, path: "/recurring/occurrences", form: url.Values{}, handle: handler.UpdateOccurrence, wantStatus: http.StatusBadRequest},
+		{name: "restore", method: http.MethodDelete, path: "/recurring/occurrences", handle: handler.RestoreOccurrence, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.form.Encode()))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.Set(middleware.UserIDKey, userID)
+			c.SetParamNames("id", "date")
+			c.SetParamValues(fmt.Sprintf("%d", rt.ID), day)
+
+			if err := tt.handle(c); err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+
+	// Unknown occurrence
+	req := httptest.NewRequest(http.MethodPost, "/recurring/occurrences/skip", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set(middleware.UserIDKey, userID)
+	c.SetParamNames("id", "date")
+	c.SetParamValues(fmt.Sprintf("%d", rt.ID), "1999-01-01")
+	if err := handler.SkipOccurrence(c); err != nil {
+		t.Fatalf("SkipOccurrence() returned error: %v", err)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
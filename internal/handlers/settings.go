@@ -7,17 +7,22 @@ import (
 	"github.com/labstack/echo/v4"
 
 	"poc-finance/internal/database"
+	"poc-finance/internal/middleware"
 	"poc-finance/internal/models"
 	"poc-finance/internal/services"
 )
 
 type SettingsHandler struct{
-	cacheService *services.SettingsCacheService
+	cacheService   *services.SettingsCacheService
+	summaryService *services.SummaryScheduleService
+	webhookService *services.WebhookService
 }
 
 func NewSettingsHandler(cacheService *services.SettingsCacheService) *SettingsHandler {
 	return &SettingsHandler{
-		cacheService: cacheService,
+		cacheService:   cacheService,
+		summaryService: services.NewSummaryScheduleService(),
+		webhookService: services.NewWebhookService(),
 	}
 }
 
@@ -48,8 +53,66 @@ func (h *SettingsHandler) Get(c echo.Context) error {
 		RecordStartDate: startDateStr,
 		ManualBracket:   cachedData.ManualBracket,
 	}
-	return c.Render(http.StatusOK, "settings.html", map[string]interface{}{
-		"settings": data,
+	pageData := map[string]interface{}{
+		"settings":          data,
+		"healthScoreConfig": services.GetHealthScoreConfig(),
+	}
+
+	// Personal summary schedule
+	userID := middleware.GetUserID(c)
+	if schedule, err := h.summaryService.GetPersonalSchedule(userID); err == nil {
+		addSummaryScheduleData(pageData, schedule, h.summaryService.GetPreference(userID, nil), true, "/settings/summary")
+	}
+
+	// Outgoing webhooks
+	addWebhookData(pageData, h.webhookService, userID)
+
+	return c.Render(http.StatusOK, "settings.html", pageData)
+}
+
+// UpdateHealthScore saves the health score weights and thresholds (entered as percentages,
+// except the emergency fund months)
+func (h *SettingsHandler) UpdateHealthScore(c echo.Context) error {
+	percent := func(name string) float64 {
+		value, _ := strconv.ParseFloat(c.FormValue(name), 64)
+		return value / 100
+	}
+	emergencyFundMonths, _ := strconv.ParseFloat(c.FormValue("emergency_fund_months"), 64)
+
+	config := services.HealthScoreConfig{
+		Weights: services.HealthScoreWeights{
+			Savings:         percent("weight_savings"),
+			Debt:            percent("weight_debt"),
+			EmergencyFund:   percent("weight_emergency_fund"),
+			IncomeStability: percent("weight_income_stability"),
+			Goals:           percent("weight_goals"),
+			Budget:          percent("weight_budget"),
+			NetWorth:        percent("weight_net_worth"),
+		},
+		Thresholds: services.HealthScoreThresholds{
+			SavingsRate:         percent("savings_rate"),
+			ObligationRatio:     percent("obligation_ratio"),
+			DebtToIncome:        percent("debt_to_income"),
+			EmergencyFundMonths: emergencyFundMonths,
+			IncomeVariation:     percent("income_variation"),
+			BudgetVariation:     percent("budget_variation"),
+			NetWorthDebtRatio:   percent("net_worth_debt_ratio"),
+		},
+	}
+
+	if err := services.SaveHealthScoreConfig(config); err != nil {
+		if err != services.ErrInvalidHealthScoreConfig {
+			return c.String(http.StatusInternalServerError, "Erro ao salvar configuracoes do score")
+		}
+		return c.Render(http.StatusOK, "partials/health-score-settings.html", map[string]interface{}{
+			"healthScoreConfig": config,
+			"healthScoreError":  err.Error(),
+		})
+	}
+
+	return c.Render(http.StatusOK, "partials/health-score-settings.html", map[string]interface{}{
+		"healthScoreConfig": services.GetHealthScoreConfig(),
+		"saved":             true,
 	})
 }
 
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"poc-finance/internal/middleware"
+	"poc-finance/internal/models"
+	"poc-finance/internal/services"
+)
+
+type SubscriptionHandler struct {
+	accountService      *services.AccountService
+	subscriptionService *services.SubscriptionService
+}
+
+func NewSubscriptionHandler() *SubscriptionHandler {
+	return &SubscriptionHandler{
+		accountService:      services.NewAccountService(),
+		subscriptionService: services.NewSubscriptionService(),
+	}
+}
+
+type SetSubscriptionStatusRequest struct {
+	AccountID    uint   `form:"account_id"`
+	Name         string `form:"name"`
+	Status       string `form:"status"`
+	ReminderDays int    `form:"reminder_days"`
+}
+
+// Page returns the subscriptions detected in the user's accounts
+func (h *SubscriptionHandler) Page(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	accountIDs, _ := h.accountService.GetUserAccountIDs(userID)
+
+	return c.Render(http.StatusOK, "subscriptions.html", map[string]interface{}{
+		"overview": h.subscriptionService.GetOverview(accountIDs, time.Now()),
+	})
+}
+
+// SetStatus marks a subscription to cancel (with a reminder before the next charge),
+// as cancelled, ignored or active again
+func (h *SubscriptionHandler) SetStatus(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	var req SetSubscriptionStatusRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Dados inválidos")
+	}
+
+	_, err := h.subscriptionService.SetStatus(userID, req.AccountID, req.Name, models.SubscriptionStatus(req.Status), req.ReminderDays)
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+
+	accountIDs, _ := h.accountService.GetUserAccountIDs(userID)
+	return c.Render(http.StatusOK, "partials/subscription-list.html", map[string]interface{}{
+		"overview": h.subscriptionService.GetOverview(accountIDs, time.Now()),
+	})
+}
+
+func subscriptionError(c echo.Context, err error) error {
+	switch err {
+	case services.ErrInvalidSubscription, services.ErrInvalidSubscriptionStatus:
+		return c.String(http.StatusBadRequest, err.Error())
+	case services.ErrUnauthorized:
+		return c.String(http.StatusForbidden, "Você não tem permissão para acessar esta conta")
+	}
+	return c.String(http.StatusInternalServerError, "Erro ao atualizar assinatura")
+}
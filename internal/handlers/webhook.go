@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"poc-finance/internal/middleware"
+	"poc-finance/internal/models"
+	"poc-finance/internal/services"
+)
+
+// webhookDeliveryLogSize is how many deliveries the log shows per webhook
+const webhookDeliveryLogSize = 20
+
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+}
+
+func NewWebhookHandler() *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: services.NewWebhookService(),
+	}
+}
+
+// addWebhookData adds the user's webhooks and the subscribable events to page data
+func addWebhookData(data map[string]interface{}, webhookService *services.WebhookService, userID uint) {
+	webhooks, _ := webhookService.ListWebhooks(userID)
+	data["webhooks"] = webhooks
+	data["webhookEventOptions"] = services.WebhookEventOptions
+}
+
+func (h *WebhookHandler) renderWebhooks(c echo.Context, data map[string]interface{}) error {
+	addWebhookData(data, h.webhookService, middleware.GetUserID(c))
+	return c.Render(http.StatusOK, "partials/webhooks.html", data)
+}
+
+// Create registers a webhook for the selected events
+func (h *WebhookHandler) Create(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+
+	form, err := c.FormParams()
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Dados inválidos")
+	}
+	var events []models.WebhookEvent
+	for _, event := range form["events"] {
+		events = append(events, models.WebhookEvent(event))
+	}
+
+	data := map[string]interface{}{}
+	webhook, err := h.webhookService.CreateWebhook(userID, c.FormValue("url"), c.FormValue("description"), events)
+	switch err {
+	case nil:
+		// The secret is shown in full once, right after creation
+		data["createdWebhook"] = webhook
+	case services.ErrInvalidWebhookURL, services.ErrWebhookNoEvents:
+		data["webhookError"] = err.Error()
+	default:
+		return c.String(http.StatusInternalServerError, "Erro ao criar webhook")
+	}
+	return h.renderWebhooks(c, data)
+}
+
+// Toggle pauses or resumes a webhook
+func (h *WebhookHandler) Toggle(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	webhookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do webhook inválido")
+	}
+
+	webhook, err := h.webhookService.GetWebhook(uint(webhookID), userID)
+	if err != nil {
+		return c.String(http.StatusNotFound, err.Error())
+	}
+	if err := h.webhookService.SetActive(webhook.ID, userID, !webhook.Active); err != nil {
+		return c.String(http.StatusInternalServerError, "Erro ao atualizar webhook")
+	}
+	return h.renderWebhooks(c, map[string]interface{}{})
+}
+
+// Delete removes a webhook
+func (h *WebhookHandler) Delete(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	webhookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do webhook inválido")
+	}
+
+	if err := h.webhookService.DeleteWebhook(uint(webhookID), userID); err != nil {
+		if err == services.ErrWebhookNotFound {
+			return c.String(http.StatusNotFound, err.Error())
+		}
+		return c.String(http.StatusInternalServerError, "Erro ao excluir webhook")
+	}
+	return h.renderWebhooks(c, map[string]interface{}{})
+}
+
+// Test sends a test event and returns the delivery log with its outcome
+func (h *WebhookHandler) Test(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	webhookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do webhook inválido")
+	}
+
+	if _, err := h.webhookService.SendTest(uint(webhookID), userID); err != nil {
+		if err == services.ErrWebhookNotFound {
+			return c.String(http.StatusNotFound, err.Error())
+		}
+		return c.String(http.StatusInternalServerError, "Erro ao enviar teste")
+	}
+	return h.Deliveries(c)
+}
+
+// Deliveries returns the latest deliveries of a webhook (HTMX partial)
+func (h *WebhookHandler) Deliveries(c echo.Context) error {
+	userID := middleware.GetUserID(c)
+	webhookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "ID do webhook inválido")
+	}
+
+	deliveries, err := h.webhookService.GetDeliveries(uint(webhookID), userID, webhookDeliveryLogSize)
+	if err != nil {
+		if err == services.ErrWebhookNotFound {
+			return c.String(http.StatusNotFound, err.Error())
+		}
+		return c.String(http.StatusInternalServerError, "Erro ao buscar entregas")
+	}
+	return c.Render(http.StatusOK, "partials/webhook-deliveries.html", map[string]interface{}{
+		"webhookID":  webhookID,
+		"deliveries": deliveries,
+	})
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/middleware"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func setupWebhookTestHandler() (*WebhookHandler, *echo.Echo, uint) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "test@example.com", "Test User", "hash")
+
+	e := echo.New()
+	e.Renderer = &testutil.MockRenderer{}
+	return NewWebhookHandler(), e, user.ID
+}
+
+func webhookRequest(e *echo.Echo, method, target string, form url.Values, userID uint, id uint) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set(middleware.UserIDKey, userID)
+	if id != 0 {
+		c.SetParamNames("id")
+		c.SetParamValues(fmt.Sprintf("%d", id))
+	}
+	return c, rec
+}
+
+func TestWebhookHandler_Create(t *testing.T) {
+	handler, e, userID := setupWebhookTestHandler()
+
+	form := url.Values{"url": {"https://203.0.113.10/hook"}, "events": {"expense.created", "goal.reached"}}
+	c, rec := webhookRequest(e, http.MethodPost, "/settings/webhooks", form, userID, 0)
+	if err := handler.Create(c); err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("Status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var webhook models.Webhook
+	if err := database.DB.Where("user_id = ?", userID).First(&webhook).Error; err != nil {
+		t.Fatalf("webhook wasn't created: %v", err)
+	}
+	if webhook.Events != "expense.created,goal.reached" {
+		t.Errorf("Events = %q, want both selected events", webhook.Events)
+	}
+
+	// Invalid forms re-render the partial without creating anything
+	c, rec = webhookRequest(e, http.MethodPost, "/settings/webhooks", url.Values{"url": {"https://203.0.113.10/other"}}, userID, 0)
+	if err := handler.Create(c); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("Create() without events = %d, %v", rec.Code, err)
+	}
+	var count int64
+	database.DB.Model(&models.Webhook{}).Count(&count)
+	if count != 1 {
+		t.Errorf("Webhook count = %d, want 1", count)
+	}
+}
+
+func TestWebhookHandler_TestAndDeliveries(t *testing.T) {
+	handler, e, userID := setupWebhookTestHandler()
+
+	received := 0
+	t.Setenv("WEBHOOK_ALLOW_INTERNAL", "true") // The test server listens on loopback
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+	}))
+	defer server.Close()
+
+	webhook, err := handler.webhookService.CreateWebhook(userID, server.URL, "", []models.WebhookEvent{models.WebhookEventGoalReached})
+	if err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+
+	c, rec := webhookRequest(e, http.MethodPost, "/settings/webhooks/1/test", nil, userID, webhook.ID)
+	if err := handler.Test(c); err != nil {
+		t.Fatalf("Test() returned error: %v", err)
+	}
+	if rec.Code != http.StatusOK || received != 1 {
+		t.Errorf("Status = %d with %d requests received, want 200 and the test event", rec.Code, received)
+	}
+
+	var delivery models.WebhookDelivery
+	database.DB.Where("webhook_id = ?", webhook.ID).First(&delivery)
+	if delivery.Event != models.WebhookEventTest || delivery.Status != models.WebhookDeliverySuccess {
+		t.Errorf("delivery = %+v, want a successful test delivery", delivery)
+	}
+}
+
+func TestWebhookHandler_OtherUserWebhook(t *testing.T) {
+	handler, e, userID := setupWebhookTestHandler()
+
+	other := testutil.CreateTestUser(database.DB, "other@example.com", "Other", "hash")
+	webhook, err := handler.webhookService.CreateWebhook(other.ID, "https://203.0.113.10/hook", "", []models.WebhookEvent{models.WebhookEventGoalReached})
+	if err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+
+	c, rec := webhookRequest(e, http.MethodDelete, "/settings/webhooks/1", nil, userID, webhook.ID)
+	if err := handler.Delete(c); err != nil {
+		t.Fatalf("Delete() returned error: %v", err)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	c, rec = webhookRequest(e, http.MethodPost, "/settings/webhooks/1/toggle", nil, userID, webhook.ID)
+	if err := handler.Toggle(c); err != nil || rec.Code != http.StatusNotFound {
+		t.Errorf("Toggle() = %d, %v, want %d", rec.Code, err, http.StatusNotFound)
+	}
+
+	var count int64
+	database.DB.Model(&models.Webhook{}).Where("active = ?", true).Count(&count)
+	if count != 1 {
+		t.Error("another user's webhook was changed")
+	}
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 
@@ -111,6 +112,32 @@ func clearAuthCookies(c echo.Context) {
 	})
 }
 
+// IsAdminEmail reports whether the email is listed in the ADMIN_EMAILS environment
+// variable (comma-separated, case-insensitive)
+func IsAdminEmail(email string) bool {
+	if email == "" {
+		return false
+	}
+	for _, admin := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if strings.EqualFold(strings.TrimSpace(admin), email) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireAdmin restricts routes to administrators. It must run after AuthMiddleware.
+func RequireAdmin() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !IsAdminEmail(GetUserEmail(c)) {
+				return c.String(http.StatusForbidden, "Acesso restrito a administradores")
+			}
+			return next(c)
+		}
+	}
+}
+
 // redirectToLogin redirects the user to the login page
 func redirectToLogin(c echo.Context) error {
 	// For HTMX requests, return a special header to trigger full page redirect
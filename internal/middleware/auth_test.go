@@ -450,3 +450,41 @@ func TestRedirectToLogin_HTMX(t *testing.T) {
 		t.Errorf("HX-Redirect = %s, want /login", hxRedirect)
 	}
 }
+
+func TestRequireAdmin(t *testing.T) {
+	t.Setenv("ADMIN_EMAILS", "admin@example.com, Ops@Example.com")
+
+	tests := []struct {
+		name       string
+		email      string
+		wantStatus int
+	}{
+		{name: "admin", email: "admin@example.com", wantStatus: http.StatusOK},
+		{name: "admin case-insensitive", email: "ops@example.com", wantStatus: http.StatusOK},
+		{name: "regular user", email: "user@example.com", wantStatus: http.StatusForbidden},
+		{name: "no email", email: "", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/admin/jobs", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			if tt.email != "" {
+				c.Set(UserEmailKey, tt.email)
+			}
+
+			handler := RequireAdmin()(func(c echo.Context) error {
+				return c.String(http.StatusOK, "ok")
+			})
+			if err := handler(c); err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
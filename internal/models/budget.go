@@ -42,6 +42,9 @@ type Budget struct {
 	Name       string            `json:"name" gorm:"not null"`
 	Status     BudgetStatus      `json:"status" gorm:"default:active"`
 	Categories []BudgetCategory  `json:"categories" gorm:"foreignKey:BudgetID"`
+
+	AlertThresholds string `json:"alert_thresholds"`              // Comma-separated percentages (e.g. "50,75,90,100,120"); empty uses the defaults
+	Rollover        bool   `json:"rollover" gorm:"default:false"` // Envelope budget: category balances carry into the next month
 }
 
 func (b *Budget) TableName() string {
@@ -81,10 +84,19 @@ func (b *Budget) OverallProgressPercentage() float64 {
 	return (b.TotalSpent() / totalLimit) * 100
 }
 
-// RemainingAmount returns the total amount still available across all categories.
-// Returns 0 if the budget has been exceeded.
+// TotalCarriedOver calculates the sum of the balances carried from the previous month
+func (b *Budget) TotalCarriedOver() float64 {
+	var total float64
+	for _, cat := range b.Categories {
+		total += cat.CarriedOver
+	}
+	return total
+}
+
+// RemainingAmount returns the total amount still available across all categories,
+// including balances carried from the previous month. Returns 0 if the budget has been exceeded.
 func (b *Budget) RemainingAmount() float64 {
-	remaining := b.TotalLimit() - b.TotalSpent()
+	remaining := b.TotalLimit() + b.TotalCarriedOver() - b.TotalSpent()
 	if remaining < 0 {
 		return 0
 	}
@@ -108,13 +120,14 @@ func (b *Budget) GetOverallStatus() CategoryStatus {
 // and visual status (green/yellow/red) based on spending thresholds.
 type BudgetCategory struct {
 	gorm.Model
-	BudgetID      uint      `json:"budget_id" gorm:"not null;index"`
-	Budget        Budget    `json:"-" gorm:"foreignKey:BudgetID"`
-	Category      string    `json:"category" gorm:"not null"`
-	Limit         float64   `json:"limit" gorm:"not null"`
-	Spent         float64   `json:"spent" gorm:"default:0"`
-	NotifiedAt80  *time.Time `json:"notified_at_80"`  // Timestamp when 80% notification was sent
-	NotifiedAt100 *time.Time `json:"notified_at_100"` // Timestamp when 100% notification was sent
+	BudgetID uint    `json:"budget_id" gorm:"not null;index"`
+	Budget   Budget  `json:"-" gorm:"foreignKey:BudgetID"`
+	Category string  `json:"category" gorm:"not null"`
+	Limit    float64 `json:"limit" gorm:"not null"`
+	Spent    float64 `json:"spent" gorm:"default:0"`
+
+	AlertThresholds string  `json:"alert_thresholds"`              // Overrides the budget thresholds when set
+	CarriedOver     float64 `json:"carried_over" gorm:"default:0"` // Balance carried from the previous month (negative when overspent)
 }
 
 func (c *BudgetCategory) TableName() string {
@@ -130,16 +143,36 @@ func (c *BudgetCategory) ProgressPercentage() float64 {
 	return (c.Spent / c.Limit) * 100
 }
 
+// EnvelopePercentage calculates the percentage of the envelope funds (the limit plus the
+// balance carried from the previous month) spent (0-100+). An envelope left without funds by
+// an overspent previous month counts as fully spent.
+func (c *BudgetCategory) EnvelopePercentage() float64 {
+	funds := c.Limit + c.CarriedOver
+	if funds <= 0 {
+		if c.Limit == 0 && c.CarriedOver == 0 {
+			return 0
+		}
+		return 100
+	}
+	return (c.Spent / funds) * 100
+}
+
 // RemainingAmount returns the amount still available in this category.
 // Returns 0 if the category limit has been exceeded.
 func (c *BudgetCategory) RemainingAmount() float64 {
-	remaining := c.Limit - c.Spent
+	remaining := c.Available()
 	if remaining < 0 {
 		return 0
 	}
 	return remaining
 }
 
+// Available returns the envelope balance of the category: its limit plus the balance
+// carried from the previous month minus spending. Negative when overspent.
+func (c *BudgetCategory) Available() float64 {
+	return c.Limit + c.CarriedOver - c.Spent
+}
+
 // IsExceeded returns true if spending has exceeded the category limit
 func (c *BudgetCategory) IsExceeded() bool {
 	return c.Spent > c.Limit
@@ -158,12 +191,46 @@ func (c *BudgetCategory) GetStatus() CategoryStatus {
 	return CategoryStatusGood
 }
 
-// ShouldNotifyAt80 returns true if the category has reached 80% threshold and hasn't been notified yet
-func (c *BudgetCategory) ShouldNotifyAt80() bool {
-	return c.ProgressPercentage() >= 80 && c.NotifiedAt80 == nil
+// BudgetAlert logs a threshold alert sent for a budget category. The alert stays active
+// while spending remains at or above the threshold; once spending drops below it the alert
+// is re-armed (ReArmedAt set) and crossing the threshold again logs a new alert.
+type BudgetAlert struct {
+	gorm.Model
+	BudgetID         uint       `json:"budget_id" gorm:"not null;index"`
+	BudgetCategoryID uint       `json:"budget_category_id" gorm:"not null;index"`
+	Category         string     `json:"category"`
+	Threshold        float64    `json:"threshold"`   // Percentage of the limit that was crossed
+	Spent            float64    `json:"spent"`       // Spending when the alert was triggered
+	Limit            float64    `json:"limit"`       // Category limit when the alert was triggered
+	Notified         bool       `json:"notified"`    // False when a higher threshold was crossed at the same time
+	ReArmedAt        *time.Time `json:"re_armed_at"` // When spending dropped back below the threshold
 }
 
-// ShouldNotifyAt100 returns true if the category has reached 100% threshold and hasn't been notified yet
-func (c *BudgetCategory) ShouldNotifyAt100() bool {
-	return c.ProgressPercentage() >= 100 && c.NotifiedAt100 == nil
+func (a *BudgetAlert) TableName() string {
+	return "budget_alerts"
+}
+
+// IsActive returns true while spending has not dropped below the threshold since the alert
+func (a *BudgetAlert) IsActive() bool {
+	return a.ReArmedAt == nil
+}
+
+// BudgetMove records money moved between the categories of a budget, or between a
+// category and the ready-to-assign pool (nil category ID). Category names are kept so
+// the history survives deleted categories.
+type BudgetMove struct {
+	gorm.Model
+	BudgetID       uint    `json:"budget_id" gorm:"not null;index"`
+	UserID         uint    `json:"user_id" gorm:"not null"`
+	User           User    `json:"-" gorm:"foreignKey:UserID"`
+	FromCategoryID *uint   `json:"from_category_id"`
+	FromCategory   string  `json:"from_category"`
+	ToCategoryID   *uint   `json:"to_category_id"`
+	ToCategory     string  `json:"to_category"`
+	Amount         float64 `json:"amount" gorm:"not null"`
+	Note           string  `json:"note"`
+}
+
+func (m *BudgetMove) TableName() string {
+	return "budget_moves"
 }
@@ -8,21 +8,26 @@ import (
 
 // HealthScore represents a calculated financial health score for a user or group.
 // Scores range from 0-100 and are based on multiple financial factors including
-// savings rate, debt levels, goal progress, and budget adherence. Historical scores
-// enable tracking of financial health trends over time.
+// savings rate, debt levels, emergency fund, income stability, goal progress, budget
+// adherence and net worth. Historical scores enable tracking of financial health trends over
+// time: a daily job keeps one snapshot per user or group and month, identified by Period.
 type HealthScore struct {
 	gorm.Model
-	UserID       *uint         `json:"user_id" gorm:"index"`
+	UserID       *uint         `json:"user_id" gorm:"index;uniqueIndex:idx_health_scores_user_period,where:group_id IS NULL AND deleted_at IS NULL AND period <> ''"`
 	User         *User         `json:"-" gorm:"foreignKey:UserID"`
-	GroupID      *uint         `json:"group_id" gorm:"index"`
+	GroupID      *uint         `json:"group_id" gorm:"index;uniqueIndex:idx_health_scores_group_period,where:user_id IS NULL AND deleted_at IS NULL AND period <> ''"`
 	Group        *FamilyGroup  `json:"-" gorm:"foreignKey:GroupID"`
 	Score        float64       `json:"score" gorm:"not null"`
 	SavingsScore float64       `json:"savings_score" gorm:"not null"`
 	DebtScore    float64       `json:"debt_score" gorm:"not null"`
 	GoalScore    float64       `json:"goal_score" gorm:"not null"`
 	BudgetScore  float64       `json:"budget_score" gorm:"not null"`
+	NetWorthScore float64      `json:"net_worth_score" gorm:"not null;default:0"`
+	EmergencyFundScore   float64 `json:"emergency_fund_score" gorm:"not null;default:0"`
+	IncomeStabilityScore float64 `json:"income_stability_score" gorm:"not null;default:0"`
 	CalculatedAt time.Time     `json:"calculated_at" gorm:"not null;index"`
-	Metadata     string        `json:"metadata" gorm:"type:text"`
+	Period       string        `json:"period" gorm:"index;uniqueIndex:idx_health_scores_user_period;uniqueIndex:idx_health_scores_group_period"` // Month of the snapshot (YYYY-MM), one per owner
+	Metadata     string        `json:"metadata" gorm:"type:text"` // Inputs of each component (JSON)
 }
 
 func (h *HealthScore) TableName() string {
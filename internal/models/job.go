@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JobRunStatus represents the outcome of a background job execution
+type JobRunStatus string
+
+const (
+	// JobRunRunning indicates the job is still executing (or the process died mid-run)
+	JobRunRunning JobRunStatus = "running"
+	// JobRunSucceeded indicates the job finished without errors
+	JobRunSucceeded JobRunStatus = "succeeded"
+	// JobRunFailed indicates the job returned an error or panicked
+	JobRunFailed JobRunStatus = "failed"
+)
+
+// JobTrigger describes what started a job run
+type JobTrigger string
+
+const (
+	// JobTriggerSchedule is used for runs started by the daily scheduler
+	JobTriggerSchedule JobTrigger = "schedule"
+	// JobTriggerManual is used for runs started from the admin page
+	JobTriggerManual JobTrigger = "manual"
+	// JobTriggerRetry is used for automatic retries after a failed attempt
+	JobTriggerRetry JobTrigger = "retry"
+)
+
+// JobRun records a single attempt of a background job, so restarts and failures are visible
+type JobRun struct {
+	gorm.Model
+	JobName    string       `json:"job_name" gorm:"index;not null"`
+	Status     JobRunStatus `json:"status" gorm:"index;not null"`
+	Trigger    JobTrigger   `json:"trigger"`
+	Attempt    int          `json:"attempt" gorm:"default:1"`
+	InstanceID string       `json:"instance_id"` // Server instance that executed the run
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	Error      string       `json:"error" gorm:"type:text"`
+}
+
+// Duration returns how long the run took (zero while it is still running)
+func (r *JobRun) Duration() time.Duration {
+	if r.FinishedAt == nil {
+		return 0
+	}
+	return r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond)
+}
+
+// JobLock is a lease that allows only one server instance to run a job at a time
+type JobLock struct {
+	Name        string    `json:"name" gorm:"primaryKey"`
+	Owner       string    `json:"owner"`
+	LockedUntil time.Time `json:"locked_until"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// JobIdempotencyKey marks a unit of work (e.g. one occurrence of a recurring transaction)
+// as already processed, so reruns after a restart or crash never repeat it
+type JobIdempotencyKey struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Key       string    `json:"key" gorm:"uniqueIndex;not null"`
+	JobName   string    `json:"job_name" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NetWorthItemKind tells whether an item adds to or subtracts from the net worth
+type NetWorthItemKind string
+
+const (
+	NetWorthItemAsset     NetWorthItemKind = "asset"
+	NetWorthItemLiability NetWorthItemKind = "liability"
+)
+
+// NetWorthCategory groups assets and liabilities in the net worth breakdown
+type NetWorthCategory string
+
+const (
+	// Assets
+	NetWorthCategoryCash       NetWorthCategory = "cash" // Bank balances and emergency reserve
+	NetWorthCategoryRealEstate NetWorthCategory = "real_estate"
+	NetWorthCategoryVehicle    NetWorthCategory = "vehicle"
+	NetWorthCategoryInvestment NetWorthCategory = "investment"
+	NetWorthCategoryFGTS       NetWorthCategory = "fgts"
+	NetWorthCategoryOtherAsset NetWorthCategory = "other_asset"
+
+	// Liabilities
+	NetWorthCategoryLoan           NetWorthCategory = "loan"
+	NetWorthCategoryFinancing      NetWorthCategory = "financing"
+	NetWorthCategoryOtherLiability NetWorthCategory = "other_liability"
+)
+
+// Kind returns whether the category is an asset or a liability category
+func (c NetWorthCategory) Kind() (NetWorthItemKind, bool) {
+	switch c {
+	case NetWorthCategoryCash, NetWorthCategoryRealEstate, NetWorthCategoryVehicle, NetWorthCategoryInvestment,
+		NetWorthCategoryFGTS, NetWorthCategoryOtherAsset:
+		return NetWorthItemAsset, true
+	case NetWorthCategoryLoan, NetWorthCategoryFinancing, NetWorthCategoryOtherLiability:
+		return NetWorthItemLiability, true
+	}
+	return "", false
+}
+
+// IsLiquid reports whether assets of the category can be used as an emergency fund
+func (c NetWorthCategory) IsLiquid() bool {
+	return c == NetWorthCategoryCash || c == NetWorthCategoryInvestment
+}
+
+// NetWorthItem is an asset or liability registered manually (a property, a car, an
+// investment, the FGTS balance, a loan or a financing). Its value changes over time and
+// is recorded as dated valuations. Like budgets it can be personal or belong to a group.
+type NetWorthItem struct {
+	gorm.Model
+	GroupID    *uint               `json:"group_id" gorm:"index"`
+	Group      *FamilyGroup        `json:"group,omitempty" gorm:"foreignKey:GroupID"`
+	UserID     uint                `json:"user_id" gorm:"not null;index"`
+	User       User                `json:"-" gorm:"foreignKey:UserID"`
+	Name       string              `json:"name" gorm:"not null"`
+	Kind       NetWorthItemKind    `json:"kind" gorm:"not null"`
+	Category   NetWorthCategory    `json:"category" gorm:"not null"`
+	Valuations []NetWorthValuation `json:"valuations" gorm:"foreignKey:ItemID"`
+}
+
+func (i *NetWorthItem) TableName() string {
+	return "net_worth_items"
+}
+
+// ValuationAt returns the most recent valuation on or before date, nil when there is none
+func (i *NetWorthItem) ValuationAt(date time.Time) *NetWorthValuation {
+	var latest *NetWorthValuation
+	for j := range i.Valuations {
+		v := &i.Valuations[j]
+		if v.Date.After(date) {
+			continue
+		}
+		if latest == nil || v.Date.After(latest.Date) || v.Date.Equal(latest.Date) && v.ID > latest.ID {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// NetWorthValuation is the value of an asset (market value) or liability (outstanding
+// balance) on a date
+type NetWorthValuation struct {
+	gorm.Model
+	ItemID uint      `json:"item_id" gorm:"not null;index"`
+	Date   time.Time `json:"date" gorm:"not null;index"`
+	Value  float64   `json:"value" gorm:"not null"`
+}
+
+func (v *NetWorthValuation) TableName() string {
+	return "net_worth_valuations"
+}
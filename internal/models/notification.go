@@ -22,6 +22,10 @@ const (
 	NotificationTypeSummary NotificationType = "summary"
 	// NotificationTypeDueDate represents notifications for upcoming expense due dates
 	NotificationTypeDueDate NotificationType = "due_date"
+	// NotificationTypeUnusualCharge represents notifications about unusual, duplicate or new recurring charges
+	NotificationTypeUnusualCharge NotificationType = "unusual_charge"
+	// NotificationTypeSubscription represents reminders to cancel a subscription before its next charge
+	NotificationTypeSubscription NotificationType = "subscription"
 )
 
 // Notification represents an in-app notification sent to a user.
@@ -42,6 +46,11 @@ type Notification struct {
 	Group     *FamilyGroup     `json:"group" gorm:"foreignKey:GroupID"`
 	InviteID  *uint            `json:"invite_id"`
 	Invite    *GroupInvite     `json:"invite" gorm:"foreignKey:InviteID"`
+
+	// Similar unread notifications of the same day share a CollapseKey and are merged into
+	// one row; Count tells how many were merged
+	CollapseKey string `json:"-" gorm:"index"`
+	Count       int    `json:"count" gorm:"default:1"`
 }
 
 func (n *Notification) TableName() string {
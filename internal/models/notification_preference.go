@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NotificationChannel identifies how a notification reaches the user
+type NotificationChannel string
+
+const (
+	// NotificationChannelInApp stores the notification in the notifications table
+	NotificationChannelInApp NotificationChannel = "in_app"
+	// NotificationChannelEmail sends the notification by email
+	NotificationChannelEmail NotificationChannel = "email"
+	// NotificationChannelWebhook sends the notification to the user's webhooks subscribed to
+	// notification.created
+	NotificationChannelWebhook NotificationChannel = "webhook"
+)
+
+// NotificationPreference stores which channels a user wants for one notification type.
+// Without a record, notifications are delivered in-app only.
+type NotificationPreference struct {
+	gorm.Model
+	UserID  uint             `json:"user_id" gorm:"not null;uniqueIndex:idx_notification_preferences_user_type"`
+	User    User             `json:"-" gorm:"foreignKey:UserID"`
+	Type    NotificationType `json:"type" gorm:"not null;uniqueIndex:idx_notification_preferences_user_type"`
+	InApp   bool             `json:"in_app"`
+	Email   bool             `json:"email"`
+	Webhook bool             `json:"webhook"`
+	Digest  bool             `json:"digest"` // Email and webhook deliveries wait for the daily digest
+}
+
+func (p *NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
+
+// Enabled reports whether the preference delivers through the channel
+func (p *NotificationPreference) Enabled(channel NotificationChannel) bool {
+	switch channel {
+	case NotificationChannelInApp:
+		return p.InApp
+	case NotificationChannelEmail:
+		return p.Email
+	case NotificationChannelWebhook:
+		return p.Webhook
+	}
+	return false
+}
+
+// Muted reports whether the type is delivered through no channel at all
+func (p *NotificationPreference) Muted() bool {
+	return !p.InApp && !p.Email && !p.Webhook
+}
+
+// NotificationSettings holds a user's delivery settings shared by every notification type
+type NotificationSettings struct {
+	gorm.Model
+	UserID            uint `json:"user_id" gorm:"not null;uniqueIndex"`
+	User              User `json:"-" gorm:"foreignKey:UserID"`
+	QuietHoursEnabled bool `json:"quiet_hours_enabled"`
+	QuietStart        int  `json:"quiet_start"` // Hour (0-23) quiet hours begin
+	QuietEnd          int  `json:"quiet_end"`   // Hour (0-23) quiet hours end; may be on the next day
+	DigestHour        int  `json:"digest_hour"` // Hour (0-23) the daily digest is sent
+}
+
+func (s *NotificationSettings) TableName() string {
+	return "notification_settings"
+}
+
+// InQuietHours reports whether email and webhook deliveries should be held at t.
+// In-app notifications are silent and never held.
+func (s *NotificationSettings) InQuietHours(t time.Time) bool {
+	if !s.QuietHoursEnabled || s.QuietStart == s.QuietEnd {
+		return false
+	}
+	hour := t.Hour()
+	if s.QuietStart < s.QuietEnd {
+		return hour >= s.QuietStart && hour < s.QuietEnd
+	}
+	return hour >= s.QuietStart || hour < s.QuietEnd
+}
+
+// NotificationDelivery is a queued email or webhook delivery. It goes out with the next run
+// of the delivery worker, or with the daily digest, and waits for quiet hours to end.
+// SentAt is set once it goes out, FailedAt once it gave up after its last attempt.
+type NotificationDelivery struct {
+	gorm.Model
+	UserID   uint                `json:"user_id" gorm:"not null;index"`
+	User     User                `json:"-" gorm:"foreignKey:UserID"`
+	Channel  NotificationChannel `json:"channel" gorm:"not null"`
+	Type     NotificationType    `json:"type" gorm:"not null"`
+	Title    string              `json:"title"`
+	Message  string              `json:"message"`
+	Link     string              `json:"link"`
+	Digest   bool                `json:"digest"` // Waits for the digest rather than the next worker run
+	Attempts int                 `json:"attempts"`
+	SentAt   *time.Time          `json:"sent_at" gorm:"index"`
+	FailedAt *time.Time          `json:"failed_at"`
+}
+
+func (d *NotificationDelivery) TableName() string {
+	return "notification_deliveries"
+}
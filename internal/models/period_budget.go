@@ -0,0 +1,67 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BudgetPeriodType represents how the date range of a period budget is defined
+type BudgetPeriodType string
+
+const (
+	// BudgetPeriodQuarter covers three months from the start date
+	BudgetPeriodQuarter BudgetPeriodType = "quarter"
+	// BudgetPeriodYear covers twelve months from the start date
+	BudgetPeriodYear BudgetPeriodType = "year"
+	// BudgetPeriodCustom covers an arbitrary date range
+	BudgetPeriodCustom BudgetPeriodType = "custom"
+)
+
+// PeriodBudget is a budget over an arbitrary date range (quarter, year or custom), used for
+// costs that don't fit a single month such as IPVA, IPTU, school fees or Christmas.
+// Like monthly budgets it can be individual or shared with a family group.
+type PeriodBudget struct {
+	gorm.Model
+	GroupID    *uint                  `json:"group_id" gorm:"index"`
+	Group      *FamilyGroup           `json:"group,omitempty" gorm:"foreignKey:GroupID"`
+	UserID     uint                   `json:"user_id" gorm:"not null;index"`
+	User       User                   `json:"user" gorm:"foreignKey:UserID"`
+	Name       string                 `json:"name" gorm:"not null"`
+	PeriodType BudgetPeriodType       `json:"period_type" gorm:"not null"`
+	StartDate  time.Time              `json:"start_date" gorm:"not null"` // First day of the period
+	EndDate    time.Time              `json:"end_date" gorm:"not null"`   // Last day of the period (inclusive)
+	Status     BudgetStatus           `json:"status" gorm:"default:active"`
+	Categories []PeriodBudgetCategory `json:"categories" gorm:"foreignKey:PeriodBudgetID"`
+}
+
+func (b *PeriodBudget) TableName() string {
+	return "period_budgets"
+}
+
+// TotalLimit calculates the sum of all category limits in this budget
+func (b *PeriodBudget) TotalLimit() float64 {
+	var total float64
+	for _, cat := range b.Categories {
+		total += cat.Limit
+	}
+	return total
+}
+
+// Days returns the number of days covered by the period
+func (b *PeriodBudget) Days() int {
+	return int(math.Round(b.EndDate.Sub(b.StartDate).Hours()/24)) + 1
+}
+
+// PeriodBudgetCategory is a spending category of a period budget with its limit for the whole period
+type PeriodBudgetCategory struct {
+	gorm.Model
+	PeriodBudgetID uint    `json:"period_budget_id" gorm:"not null;index"`
+	Category       string  `json:"category" gorm:"not null"`
+	Limit          float64 `json:"limit" gorm:"not null"`
+}
+
+func (c *PeriodBudgetCategory) TableName() string {
+	return "period_budget_categories"
+}
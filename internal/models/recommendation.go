@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecommendationActionStatus represents what the user did with a health score recommendation
+type RecommendationActionStatus string
+
+const (
+	// RecommendationActionDismissed is advice the user doesn't want to see again
+	RecommendationActionDismissed RecommendationActionStatus = "dismissed"
+	// RecommendationActionSnoozed is advice hidden until SnoozedUntil
+	RecommendationActionSnoozed RecommendationActionStatus = "snoozed"
+	// RecommendationActionCompleted is advice the user followed
+	RecommendationActionCompleted RecommendationActionStatus = "completed"
+)
+
+// DefaultRecommendationSnoozeDays is how long a snoozed recommendation stays hidden by default
+const DefaultRecommendationSnoozeDays = 30
+
+// RecommendationAction stores what a user did with a recommendation, identified by its key.
+// Recommendations themselves are computed from the user's data on every view; a record only
+// exists once the user dismisses, snoozes or completes one. Like health scores, actions
+// belong to a user (personal recommendations) or to a group.
+type RecommendationAction struct {
+	gorm.Model
+	UserID       uint                       `json:"user_id" gorm:"not null;index"` // User who took the action
+	User         User                       `json:"-" gorm:"foreignKey:UserID"`
+	GroupID      *uint                      `json:"group_id" gorm:"index"`
+	Group        *FamilyGroup               `json:"-" gorm:"foreignKey:GroupID"`
+	Key          string                     `json:"key" gorm:"not null;index"` // Identifies the advice (kind and subject)
+	Title        string                     `json:"title"`
+	Status       RecommendationActionStatus `json:"status" gorm:"not null"`
+	SnoozedUntil *time.Time                 `json:"snoozed_until"`
+	ScoreImpact  float64                    `json:"score_impact"` // Estimated score impact when the action was taken
+	ActedAt      time.Time                  `json:"acted_at" gorm:"not null"`
+}
+
+func (a *RecommendationAction) TableName() string {
+	return "recommendation_actions"
+}
+
+// Hides reports whether the recommendation should be left out on the given date
+func (a *RecommendationAction) Hides(now time.Time) bool {
+	if a.Status == RecommendationActionSnoozed {
+		return a.SnoozedUntil != nil && now.Before(*a.SnoozedUntil)
+	}
+	return true
+}
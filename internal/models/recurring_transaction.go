@@ -30,11 +30,45 @@ const (
 	FrequencyYearly  Frequency = "yearly"
 )
 
+// Currency codes supported by recurring transactions
+const (
+	// CurrencyBRL is the default currency (Brazilian Real)
+	CurrencyBRL = "BRL"
+	// CurrencyUSD is used for foreign-currency incomes converted at generation time
+	CurrencyUSD = "USD"
+)
+
+// ScheduleRule selects how monthly occurrences choose their day of the month
+type ScheduleRule string
+
+const (
+	// ScheduleRuleDayOfMonth repeats on a fixed day, clamped to the last day of shorter months
+	ScheduleRuleDayOfMonth ScheduleRule = "day_of_month"
+	// ScheduleRuleLastBusinessDay repeats on the last business day of the month
+	ScheduleRuleLastBusinessDay ScheduleRule = "last_business_day"
+	// ScheduleRuleNthWeekday repeats on a specific weekday of the month (e.g. second Tuesday)
+	ScheduleRuleNthWeekday ScheduleRule = "nth_weekday"
+)
+
+// BusinessDayAdjustment controls what happens when an occurrence falls on a weekend or holiday
+type BusinessDayAdjustment string
+
+const (
+	// BusinessDayNone keeps the occurrence on its scheduled date
+	BusinessDayNone BusinessDayAdjustment = "none"
+	// BusinessDayFollowing moves the occurrence to the next business day
+	BusinessDayFollowing BusinessDayAdjustment = "following"
+	// BusinessDayPreceding moves the occurrence to the previous business day
+	BusinessDayPreceding BusinessDayAdjustment = "preceding"
+)
+
 // RecurringTransaction represents an automated transaction that repeats on a regular schedule.
 // Recurring transactions are used to track predictable income (salary, rental income) and
 // expenses (subscriptions, bills, loan payments) that occur at fixed intervals. The system
 // uses NextRunDate to automatically generate transactions when they become due.
 // Transactions can be configured with optional end dates and can be activated or deactivated.
+// Schedules repeat every Interval units of Frequency; monthly and yearly schedules are anchored
+// to StartDate so that day-of-month clamping (Jan 31 -> Feb 28 -> Mar 31) never drifts.
 type RecurringTransaction struct {
 	gorm.Model
 	AccountID       uint            `json:"account_id" gorm:"not null;index"`
@@ -48,8 +82,35 @@ type RecurringTransaction struct {
 	NextRunDate     time.Time       `json:"next_run_date" gorm:"not null"`
 	Active          bool            `json:"active" gorm:"default:true"`
 	Category        string          `json:"category"`
+	GoalID          *uint           `json:"goal_id" gorm:"index"`        // Set when the transaction is an automatic goal contribution
+	Currency        string          `json:"currency" gorm:"default:BRL"` // Currency of Amount (BRL or USD)
+	ExchangeRate    float64         `json:"exchange_rate"`               // Fallback USD/BRL rate when no quote is available at generation time
+
+	// Schedule options
+	Interval              int                   `json:"interval" gorm:"default:1"`                   // Repeat every N frequency units
+	ScheduleRule          ScheduleRule          `json:"schedule_rule" gorm:"default:day_of_month"`   // Monthly day selection rule
+	DayOfMonth            int                   `json:"day_of_month"`                                // 1-31, 0 = day of StartDate
+	WeekOfMonth           int                   `json:"week_of_month"`                               // 1-4, or -1 for the last week (nth_weekday rule)
+	Weekday               int                   `json:"weekday"`                                     // 0 = Sunday ... 6 = Saturday (nth_weekday rule)
+	BusinessDayAdjustment BusinessDayAdjustment `json:"business_day_adjustment" gorm:"default:none"` // Weekend/holiday handling
 }
 
 func (rt *RecurringTransaction) TableName() string {
 	return "recurring_transactions"
 }
+
+// RecurringOccurrenceOverride changes a single occurrence of a recurring transaction:
+// it can be skipped, or generated with a different amount and/or date.
+// OccurrenceDate is the originally scheduled date and identifies the occurrence.
+type RecurringOccurrenceOverride struct {
+	gorm.Model
+	RecurringTransactionID uint       `json:"recurring_transaction_id" gorm:"not null;index"`
+	OccurrenceDate         time.Time  `json:"occurrence_date" gorm:"not null"`
+	Skip                   bool       `json:"skip" gorm:"default:false"`
+	Amount                 *float64   `json:"amount"` // Replaces the recurring amount when set
+	Date                   *time.Time `json:"date"`   // Moves the occurrence when set
+}
+
+func (o *RecurringOccurrenceOverride) TableName() string {
+	return "recurring_occurrence_overrides"
+}
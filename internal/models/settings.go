@@ -29,4 +29,6 @@ const (
 	SettingRecordStartDate = "record_start_date"
 	// SettingManualBracket represents the manually selected tax bracket (1-6, or 0 for automatic calculation)
 	SettingManualBracket = "manual_bracket"
+	// SettingHealthScoreConfig represents the weights and thresholds of the health score (JSON)
+	SettingHealthScoreConfig = "health_score_config"
 )
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SubscriptionStatus represents what the user decided about a detected subscription
+type SubscriptionStatus string
+
+const (
+	// SubscriptionStatusActive is a subscription the user keeps
+	SubscriptionStatusActive SubscriptionStatus = "active"
+	// SubscriptionStatusToCancel is a subscription the user wants to cancel before the next charge
+	SubscriptionStatusToCancel SubscriptionStatus = "to_cancel"
+	// SubscriptionStatusCancelled is a subscription the user already cancelled
+	SubscriptionStatusCancelled SubscriptionStatus = "cancelled"
+	// SubscriptionStatusIgnored is a recurring merchant that is not a subscription
+	SubscriptionStatusIgnored SubscriptionStatus = "ignored"
+)
+
+// DefaultSubscriptionReminderDays is how many days before the next charge a cancel reminder is sent
+const DefaultSubscriptionReminderDays = 3
+
+// Subscription stores the user's decision about a subscription detected from the recurring
+// charges of an account. Subscriptions themselves are detected from the expense history;
+// a record only exists once the user marks one (to cancel, cancelled or ignored).
+type Subscription struct {
+	gorm.Model
+	AccountID    uint               `json:"account_id" gorm:"not null;uniqueIndex:idx_subscription_account_merchant"`
+	Account      Account            `json:"-" gorm:"foreignKey:AccountID"`
+	Merchant     string             `json:"merchant" gorm:"not null;uniqueIndex:idx_subscription_account_merchant"` // Normalized name of the recurring charge
+	Name         string             `json:"name" gorm:"not null"`
+	Status       SubscriptionStatus `json:"status" gorm:"not null;default:active"`
+	ReminderDays int                `json:"reminder_days" gorm:"default:3"` // Days before the next charge to remind a pending cancellation
+	CancelledAt  *time.Time         `json:"cancelled_at"`
+}
+
+func (s *Subscription) TableName() string {
+	return "subscriptions"
+}
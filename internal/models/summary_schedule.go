@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SummaryFrequency identifies the period covered by a scheduled summary
+type SummaryFrequency string
+
+const (
+	// SummaryFrequencyWeekly covers the seven days before the scheduled time
+	SummaryFrequencyWeekly SummaryFrequency = "weekly"
+	// SummaryFrequencyMonthly covers the calendar month before the scheduled time
+	SummaryFrequencyMonthly SummaryFrequency = "monthly"
+)
+
+// SummarySchedule configures when periodic summaries are generated automatically.
+// A schedule with a GroupID belongs to a family group and is edited by its admins;
+// a schedule without one is the personal summary of UserID. Summaries are only sent
+// for slots after the schedule was created, so enabling a schedule never back-fills.
+type SummarySchedule struct {
+	gorm.Model
+	UserID         uint         `json:"user_id" gorm:"not null;index"` // Owner of a personal schedule, or the admin who last changed a group one
+	User           User         `json:"-" gorm:"foreignKey:UserID"`
+	GroupID        *uint        `json:"group_id" gorm:"index"`
+	Group          *FamilyGroup `json:"-" gorm:"foreignKey:GroupID"`
+	WeeklyEnabled  bool         `json:"weekly_enabled"`
+	WeeklyDay      int          `json:"weekly_day"` // 0 (Sunday) to 6 (Saturday)
+	MonthlyEnabled bool         `json:"monthly_enabled"`
+	MonthlyDay     int          `json:"monthly_day"` // 1 to 28, so every month has the day
+	Hour           int          `json:"hour"`        // 0 to 23, server local time
+}
+
+func (s *SummarySchedule) TableName() string {
+	return "summary_schedules"
+}
+
+// Enabled reports whether the schedule sends summaries of the given frequency
+func (s *SummarySchedule) Enabled(frequency SummaryFrequency) bool {
+	switch frequency {
+	case SummaryFrequencyWeekly:
+		return s.WeeklyEnabled
+	case SummaryFrequencyMonthly:
+		return s.MonthlyEnabled
+	}
+	return false
+}
+
+// LastSlot returns the most recent scheduled time of the given frequency at or before now
+func (s *SummarySchedule) LastSlot(frequency SummaryFrequency, now time.Time) time.Time {
+	if frequency == SummaryFrequencyMonthly {
+		slot := time.Date(now.Year(), now.Month(), s.MonthlyDay, s.Hour, 0, 0, 0, now.Location())
+		if slot.After(now) {
+			slot = slot.AddDate(0, -1, 0)
+		}
+		return slot
+	}
+
+	slot := time.Date(now.Year(), now.Month(), now.Day(), s.Hour, 0, 0, 0, now.Location())
+	slot = slot.AddDate(0, 0, -((int(slot.Weekday()) - s.WeeklyDay + 7) % 7))
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -7)
+	}
+	return slot
+}
+
+// SummaryPreference stores how a user wants to receive scheduled summaries of a group,
+// or their personal summaries when GroupID is nil. Without a record, members get
+// group summaries in-app only.
+type SummaryPreference struct {
+	gorm.Model
+	UserID  uint         `json:"user_id" gorm:"not null;index"`
+	User    User         `json:"-" gorm:"foreignKey:UserID"`
+	GroupID *uint        `json:"group_id" gorm:"index"`
+	Group   *FamilyGroup `json:"-" gorm:"foreignKey:GroupID"`
+	InApp   bool         `json:"in_app"`
+	Email   bool         `json:"email"`
+}
+
+func (p *SummaryPreference) TableName() string {
+	return "summary_preferences"
+}
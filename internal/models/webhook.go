@@ -0,0 +1,91 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WebhookEvent is a financial event users can subscribe outgoing webhooks to
+type WebhookEvent string
+
+const (
+	WebhookEventExpenseCreated     WebhookEvent = "expense.created"
+	WebhookEventIncomeCreated      WebhookEvent = "income.created"
+	WebhookEventBudgetThreshold    WebhookEvent = "budget.threshold_crossed"
+	WebhookEventGoalReached        WebhookEvent = "goal.reached"
+	WebhookEventDueDateUpcoming    WebhookEvent = "due_date.upcoming"
+	WebhookEventRecurringGenerated WebhookEvent = "recurring.generated"
+	// WebhookEventNotification carries the notifications the user chose to receive by webhook
+	WebhookEventNotification WebhookEvent = "notification.created"
+	// WebhookEventTest is sent by the test button, whatever the subscribed events
+	WebhookEventTest WebhookEvent = "webhook.test"
+)
+
+// Webhook is a user-configured URL that receives signed event payloads
+type Webhook struct {
+	gorm.Model
+	UserID      uint   `json:"user_id" gorm:"not null;index"`
+	User        User   `json:"-" gorm:"foreignKey:UserID"`
+	URL         string `json:"url" gorm:"not null"`
+	Description string `json:"description"`
+	Secret      string `json:"-" gorm:"not null"` // HMAC key for the signature header
+	Events      string `json:"events"`            // Comma-separated WebhookEvent values
+	Active      bool   `json:"active"`
+}
+
+func (w *Webhook) TableName() string {
+	return "webhooks"
+}
+
+// EventList returns the events the webhook is subscribed to
+func (w Webhook) EventList() []WebhookEvent {
+	var events []WebhookEvent
+	for _, event := range strings.Split(w.Events, ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			events = append(events, WebhookEvent(event))
+		}
+	}
+	return events
+}
+
+// Subscribes reports whether the webhook receives the event
+func (w Webhook) Subscribes(event WebhookEvent) bool {
+	if event == WebhookEventTest {
+		return true
+	}
+	for _, subscribed := range w.EventList() {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDeliveryStatus tracks a delivery through its retries
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending WebhookDeliveryStatus = "pending" // Waiting for its first attempt or a retry
+	WebhookDeliverySuccess WebhookDeliveryStatus = "success"
+	WebhookDeliveryFailed  WebhookDeliveryStatus = "failed" // Gave up after the last retry
+)
+
+// WebhookDelivery logs one event sent to one webhook, with the outcome of its last attempt
+type WebhookDelivery struct {
+	gorm.Model
+	WebhookID      uint                  `json:"webhook_id" gorm:"not null;index"`
+	Event          WebhookEvent          `json:"event" gorm:"not null"`
+	Payload        string                `json:"payload" gorm:"type:text"`
+	Status         WebhookDeliveryStatus `json:"status" gorm:"not null;index"`
+	Attempts       int                   `json:"attempts"`
+	ResponseStatus int                   `json:"response_status"`
+	Error          string                `json:"error"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at" gorm:"index"`
+	DeliveredAt    *time.Time            `json:"delivered_at"`
+}
+
+func (d *WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/i18n"
+	"poc-finance/internal/models"
+)
+
+// MaxAnalyticsQueryDays bounds the range of an analytics query (about two years)
+const MaxAnalyticsQueryDays = 731
+
+var (
+	ErrInvalidAnalyticsRange   = errors.New("período inválido (máximo de 2 anos)")
+	ErrInvalidAnalyticsGroupBy = errors.New("agrupamento inválido")
+	ErrInvalidAnalyticsMeasure = errors.New("medida inválida")
+)
+
+// AnalyticsGroupBy is the dimension the rows of an analytics query are grouped by
+type AnalyticsGroupBy string
+
+const (
+	AnalyticsGroupByDay      AnalyticsGroupBy = "day"
+	AnalyticsGroupByWeek     AnalyticsGroupBy = "week" // Weeks start on Monday
+	AnalyticsGroupByMonth    AnalyticsGroupBy = "month"
+	AnalyticsGroupByCategory AnalyticsGroupBy = "category"
+	AnalyticsGroupByAccount  AnalyticsGroupBy = "account"
+	AnalyticsGroupByMember   AnalyticsGroupBy = "member"   // Account owner, or each member's share of split expenses
+	AnalyticsGroupByMerchant AnalyticsGroupBy = "merchant" // Normalized expense, bill or income name
+)
+
+// IsTime reports whether the grouping is a time bucket, whose empty buckets are kept
+func (g AnalyticsGroupBy) IsTime() bool {
+	return g == AnalyticsGroupByDay || g == AnalyticsGroupByWeek || g == AnalyticsGroupByMonth
+}
+
+// AnalyticsMeasure is a value computed for each row of an analytics query
+type AnalyticsMeasure string
+
+const (
+	AnalyticsMeasureIncome  AnalyticsMeasure = "income"  // Net income
+	AnalyticsMeasureExpense AnalyticsMeasure = "expense" // Fixed and variable expenses, installments and bills
+	AnalyticsMeasureNet     AnalyticsMeasure = "net"     // Income - expense
+)
+
+// AllAnalyticsMeasures is used when a query asks for no measure in particular
+var AllAnalyticsMeasures = []AnalyticsMeasure{AnalyticsMeasureIncome, AnalyticsMeasureExpense, AnalyticsMeasureNet}
+
+// AnalyticsQuery selects the incomes and expenses of some accounts between two dates
+// (both inclusive) and how to aggregate them. Incomes have no category, so a category
+// filter leaves them out.
+type AnalyticsQuery struct {
+	Start      time.Time          `json:"start"`
+	End        time.Time          `json:"end"`
+	GroupBy    AnalyticsGroupBy   `json:"group_by"`
+	AccountIDs []uint             `json:"account_ids"`
+	Categories []string           `json:"categories,omitempty"`
+	Measures   []AnalyticsMeasure `json:"measures"`
+}
+
+// AnalyticsRow is the aggregation of a group, with the requested measures
+type AnalyticsRow struct {
+	Key    string                       `json:"key"`
+	Label  string                       `json:"label"`
+	Values map[AnalyticsMeasure]float64 `json:"values"`
+}
+
+// AnalyticsResult holds the rows of a query in display order and their total
+type AnalyticsResult struct {
+	Query AnalyticsQuery `json:"query"`
+	Rows  []AnalyticsRow `json:"rows"`
+	Total AnalyticsRow   `json:"total"`
+}
+
+// Validate normalizes the query dates to whole days and checks the grouping and measures
+func (q *AnalyticsQuery) Validate() error {
+	q.Start = time.Date(q.Start.Year(), q.Start.Month(), q.Start.Day(), 0, 0, 0, 0, time.Local)
+	q.End = time.Date(q.End.Year(), q.End.Month(), q.End.Day(), 0, 0, 0, 0, time.Local)
+	if q.End.Before(q.Start) || q.End.Sub(q.Start) > MaxAnalyticsQueryDays*24*time.Hour {
+		return ErrInvalidAnalyticsRange
+	}
+
+	switch q.GroupBy {
+	case "":
+		q.GroupBy = AnalyticsGroupByMonth
+	case AnalyticsGroupByDay, AnalyticsGroupByWeek, AnalyticsGroupByMonth, AnalyticsGroupByCategory,
+		AnalyticsGroupByAccount, AnalyticsGroupByMember, AnalyticsGroupByMerchant:
+	default:
+		return ErrInvalidAnalyticsGroupBy
+	}
+
+	if len(q.Measures) == 0 {
+		q.Measures = AllAnalyticsMeasures
+	}
+	for _, m := range q.Measures {
+		if m != AnalyticsMeasureIncome && m != AnalyticsMeasureExpense && m != AnalyticsMeasureNet {
+			return ErrInvalidAnalyticsMeasure
+		}
+	}
+	return nil
+}
+
+// analyticsAmount is an income (positive) or expense (negative) attributed to a group
+type analyticsAmount struct {
+	key    string
+	label  string
+	amount float64
+}
+
+// analyticsBucket accumulates the amounts of a group
+type analyticsBucket struct {
+	label   string
+	income  float64
+	expense float64
+}
+
+// QueryAnalytics runs an analytics query over the accounts in the query. The data is
+// loaded with the batch queries of GetBatchMonthlySummariesForAccounts (one query per
+// source for the whole range) and aggregated in memory, plus one query for accounts
+// and one for expense splits when grouping by account or member.
+func QueryAnalytics(db *gorm.DB, query AnalyticsQuery) (*AnalyticsResult, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	entries := loadSummaryEntries(db, query.Start, query.End.AddDate(0, 0, 1), query.AccountIDs)
+
+	if len(query.Categories) > 0 {
+		allowed := make(map[string]bool, len(query.Categories))
+		for _, category := range query.Categories {
+			allowed[category] = true
+		}
+		filtered := entries[:0]
+		for _, entry := range entries {
+			if entry.Kind != summaryEntryIncome && allowed[entry.Category] {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+
+	attribute := analyticsAttribution(db, query, entries)
+
+	buckets := make(map[string]*analyticsBucket)
+	var keys []string
+	addBucket := func(key, label string) *analyticsBucket {
+		bucket, ok := buckets[key]
+		if !ok {
+			bucket = &analyticsBucket{label: label}
+			buckets[key] = bucket
+			keys = append(keys, key)
+		}
+		return bucket
+	}
+
+	// Time groupings keep their empty buckets so charts have a continuous axis
+	if query.GroupBy.IsTime() {
+		for day := query.Start; !day.After(query.End); day = day.AddDate(0, 0, 1) {
+			key, label := analyticsTimeBucket(query.GroupBy, day)
+			addBucket(key, label)
+		}
+	}
+
+	for _, entry := range entries {
+		for _, a := range attribute(entry) {
+			bucket := addBucket(a.key, a.label)
+			if a.amount >= 0 {
+				bucket.income += a.amount
+			} else {
+				bucket.expense -= a.amount
+			}
+		}
+	}
+
+	if query.GroupBy.IsTime() {
+		sort.Strings(keys)
+	} else {
+		// Largest expenses first, then largest incomes
+		sort.SliceStable(keys, func(i, j int) bool {
+			a, b := buckets[keys[i]], buckets[keys[j]]
+			if a.expense != b.expense {
+				return a.expense > b.expense
+			}
+			if a.income != b.income {
+				return a.income > b.income
+			}
+			return a.label < b.label
+		})
+	}
+
+	result := &AnalyticsResult{Query: query, Rows: make([]AnalyticsRow, 0, len(keys))}
+	total := &analyticsBucket{label: "Total"}
+	for _, key := range keys {
+		bucket := buckets[key]
+		total.income += bucket.income
+		total.expense += bucket.expense
+		result.Rows = append(result.Rows, bucket.row(key, query.Measures))
+	}
+	result.Total = total.row("total", query.Measures)
+
+	return result, nil
+}
+
+func (b *analyticsBucket) row(key string, measures []AnalyticsMeasure) AnalyticsRow {
+	row := AnalyticsRow{Key: key, Label: b.label, Values: make(map[AnalyticsMeasure]float64, len(measures))}
+	for _, m := range measures {
+		switch m {
+		case AnalyticsMeasureIncome:
+			row.Values[m] = math.Round(b.income*100) / 100
+		case AnalyticsMeasureExpense:
+			row.Values[m] = math.Round(b.expense*100) / 100
+		case AnalyticsMeasureNet:
+			row.Values[m] = math.Round((b.income-b.expense)*100) / 100
+		}
+	}
+	return row
+}
+
+// analyticsAttribution returns how an entry is split into the groups of the query
+func analyticsAttribution(db *gorm.DB, query AnalyticsQuery, entries []summaryEntry) func(summaryEntry) []analyticsAmount {
+	signed := func(entry summaryEntry) float64 {
+		if entry.Kind == summaryEntryIncome {
+			return entry.Amount
+		}
+		return -entry.Amount
+	}
+
+	switch query.GroupBy {
+	case AnalyticsGroupByCategory:
+		return func(entry summaryEntry) []analyticsAmount {
+			if entry.Kind == summaryEntryIncome {
+				return []analyticsAmount{{"income", "Receitas", entry.Amount}}
+			}
+			label := entry.Category
+			if label == "" {
+				label = "Sem categoria"
+			}
+			return []analyticsAmount{{"category:" + entry.Category, label, -entry.Amount}}
+		}
+
+	case AnalyticsGroupByMerchant:
+		return func(entry summaryEntry) []analyticsAmount {
+			merchant := normalizeMerchant(entry.Name)
+			label := strings.TrimSpace(entry.Name)
+			if merchant == "" {
+				label = "Sem descrição"
+			}
+			return []analyticsAmount{{"merchant:" + merchant, label, signed(entry)}}
+		}
+
+	case AnalyticsGroupByAccount, AnalyticsGroupByMember:
+		var accounts []models.Account
+		db.Preload("User").Where("id IN ?", query.AccountIDs).Find(&accounts)
+		accountByID := make(map[uint]models.Account, len(accounts))
+		for _, account := range accounts {
+			accountByID[account.ID] = account
+		}
+
+		if query.GroupBy == AnalyticsGroupByAccount {
+			return func(entry summaryEntry) []analyticsAmount {
+				account := accountByID[entry.AccountID]
+				return []analyticsAmount{{fmt.Sprintf("account:%d", entry.AccountID), account.Name, signed(entry)}}
+			}
+		}
+
+		splits := analyticsExpenseSplits(db, entries)
+		member := func(user models.User, amount float64) analyticsAmount {
+			return analyticsAmount{fmt.Sprintf("member:%d", user.ID), user.Name, amount}
+		}
+		return func(entry summaryEntry) []analyticsAmount {
+			if expenseSplits := splits[entry.ExpenseID]; entry.ExpenseID != 0 && len(expenseSplits) > 0 {
+				amounts := make([]analyticsAmount, 0, len(expenseSplits))
+				for _, split := range expenseSplits {
+					amounts = append(amounts, member(split.User, -entry.Amount*split.Percentage/100))
+				}
+				return amounts
+			}
+			return []analyticsAmount{member(accountByID[entry.AccountID].User, signed(entry))}
+		}
+	}
+
+	return func(entry summaryEntry) []analyticsAmount {
+		key, label := analyticsTimeBucket(query.GroupBy, entry.Date)
+		return []analyticsAmount{{key, label, signed(entry)}}
+	}
+}
+
+// analyticsExpenseSplits loads the member splits of the split expenses among the entries
+func analyticsExpenseSplits(db *gorm.DB, entries []summaryEntry) map[uint][]models.ExpenseSplit {
+	var expenseIDs []uint
+	seen := make(map[uint]bool)
+	for _, entry := range entries {
+		if entry.ExpenseID != 0 && !seen[entry.ExpenseID] {
+			seen[entry.ExpenseID] = true
+			expenseIDs = append(expenseIDs, entry.ExpenseID)
+		}
+	}
+
+	result := make(map[uint][]models.ExpenseSplit)
+	if len(expenseIDs) == 0 {
+		return result
+	}
+
+	var splits []models.ExpenseSplit
+	db.Preload("User").
+		Joins("JOIN expenses ON expenses.id = expense_splits.expense_id").
+		Where("expense_splits.expense_id IN ? AND expenses.is_split = ?", expenseIDs, true).
+		Find(&splits)
+	for _, split := range splits {
+		result[split.ExpenseID] = append(result[split.ExpenseID], split)
+	}
+	return result
+}
+
+// analyticsTimeBucket returns the key (sortable) and label of the day, week or month of a date
+func analyticsTimeBucket(groupBy AnalyticsGroupBy, date time.Time) (string, string) {
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+	switch groupBy {
+	case AnalyticsGroupByDay:
+		return date.Format("2006-01-02"), date.Format("02/01/2006")
+	case AnalyticsGroupByWeek:
+		monday := date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+		return monday.Format("2006-01-02"), "Semana de " + monday.Format("02/01/2006")
+	default:
+		return date.Format("2006-01"), fmt.Sprintf("%s %d", i18n.MonthNames[date.Month()], date.Year())
+	}
+}
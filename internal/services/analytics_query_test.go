@@ -0,0 +1,167 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestQueryAnalytics(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2030, month, day, 0, 0, 0, 0, time.Local)
+	}
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Ana", "hash")
+	partner := testutil.CreateTestUser(db, "partner@example.com", "Bruno", "hash")
+	account := testutil.CreateTestAccount(db, "Pessoal", models.AccountTypeIndividual, user.ID, nil)
+	partnerAccount := testutil.CreateTestAccount(db, "Conta do Bruno", models.AccountTypeIndividual, partner.ID, nil)
+	accountIDs := []uint{account.ID, partnerAccount.ID}
+
+	db.Create(&models.Income{AccountID: account.ID, Date: date(1, 5), Description: "Salário", GrossAmount: 5000, NetAmount: 5000})
+	db.Create(&models.Expense{AccountID: account.ID, Name: "Aluguel", Amount: 1000, Type: models.ExpenseTypeFixed, DueDay: 10, Category: "Moradia", Active: true})
+	db.Create(&models.Expense{AccountID: account.ID, Name: "Uber", Amount: 50, Type: models.ExpenseTypeVariable, Category: "Transporte", Active: true,
+		Model: gorm.Model{CreatedAt: date(1, 12)}})
+	db.Create(&models.Expense{AccountID: partnerAccount.ID, Name: " uber", Amount: 30, Type: models.ExpenseTypeVariable, Category: "Transporte", Active: true,
+		Model: gorm.Model{CreatedAt: date(2, 3)}})
+	// Dinner split between both members
+	dinner := models.Expense{AccountID: account.ID, Name: "Jantar", Amount: 200, Type: models.ExpenseTypeVariable, Category: "Alimentação", Active: true,
+		IsSplit: true, Model: gorm.Model{CreatedAt: date(1, 15)}}
+	db.Create(&dinner)
+	db.Create(&models.ExpenseSplit{ExpenseID: dinner.ID, UserID: user.ID, Percentage: 50, Amount: 100})
+	db.Create(&models.ExpenseSplit{ExpenseID: dinner.ID, UserID: partner.ID, Percentage: 50, Amount: 100})
+	db.Create(&models.Bill{AccountID: partnerAccount.ID, Name: "Luz", Amount: 300, DueDate: date(2, 5), Category: "Moradia"})
+	card := models.CreditCard{AccountID: account.ID, Name: "Cartão", ClosingDay: 1, DueDay: 10}
+	db.Create(&card)
+	db.Create(&models.Installment{CreditCardID: card.ID, Description: "Notebook", TotalAmount: 200, InstallmentAmount: 100,
+		TotalInstallments: 2, StartDate: date(1, 20), Category: "Eletrônicos"})
+
+	run := func(query AnalyticsQuery) *AnalyticsResult {
+		t.Helper()
+		query.AccountIDs = accountIDs
+		result, err := QueryAnalytics(db, query)
+		if err != nil {
+			t.Fatalf("QueryAnalytics(%+v) error = %v", query, err)
+		}
+		return result
+	}
+
+	t.Run("monthly rows match the batch monthly summaries", func(t *testing.T) {
+		result := run(AnalyticsQuery{Start: date(1, 1), End: date(2, 28), GroupBy: AnalyticsGroupByMonth})
+		summaries := GetBatchMonthlySummariesForAccounts(db, 2030, 1, 2030, 2, accountIDs)
+		if len(result.Rows) != 2 {
+			t.Fatalf("got %d rows, want 2", len(result.Rows))
+		}
+		for i, row := range result.Rows {
+			if row.Values[AnalyticsMeasureIncome] != summaries[i].TotalIncomeNet ||
+				row.Values[AnalyticsMeasureExpense] != summaries[i].TotalExpenses ||
+				row.Values[AnalyticsMeasureNet] != summaries[i].Balance {
+				t.Errorf("row %s = %v, want the summary %+v", row.Key, row.Values, summaries[i])
+			}
+		}
+		// January: rent, Uber, dinner and the first installment
+		if got := result.Rows[0].Values[AnalyticsMeasureExpense]; got != 1350 {
+			t.Errorf("January expense = %.2f, want 1350.00", got)
+		}
+		if got := result.Total.Values[AnalyticsMeasureNet]; got != 5000-1350-1430 {
+			t.Errorf("total net = %.2f, want %.2f", got, float64(5000-1350-1430))
+		}
+	})
+
+	t.Run("days keep empty buckets and partial months count due dates only", func(t *testing.T) {
+		result := run(AnalyticsQuery{Start: date(1, 9), End: date(1, 12), GroupBy: AnalyticsGroupByDay,
+			Measures: []AnalyticsMeasure{AnalyticsMeasureExpense}})
+		want := map[string]float64{"2030-01-09": 0, "2030-01-10": 1000, "2030-01-11": 0, "2030-01-12": 50}
+		if len(result.Rows) != len(want) {
+			t.Fatalf("got %d rows, want %d", len(result.Rows), len(want))
+		}
+		for _, row := range result.Rows {
+			if row.Values[AnalyticsMeasureExpense] != want[row.Key] {
+				t.Errorf("day %s expense = %.2f, want %.2f", row.Key, row.Values[AnalyticsMeasureExpense], want[row.Key])
+			}
+			if _, ok := row.Values[AnalyticsMeasureIncome]; ok {
+				t.Errorf("day %s has the income measure, which was not requested", row.Key)
+			}
+		}
+	})
+
+	t.Run("weeks start on Monday", func(t *testing.T) {
+		// January 1st, 2030 is a Tuesday
+		result := run(AnalyticsQuery{Start: date(1, 1), End: date(1, 13), GroupBy: AnalyticsGroupByWeek})
+		if len(result.Rows) != 2 || result.Rows[0].Key != "2029-12-31" || result.Rows[1].Key != "2030-01-07" {
+			t.Fatalf("rows = %+v, want the weeks of 2029-12-31 and 2030-01-07", result.Rows)
+		}
+		if got := result.Rows[1].Values[AnalyticsMeasureExpense]; got != 1050 {
+			t.Errorf("second week expense = %.2f, want 1050.00", got)
+		}
+	})
+
+	t.Run("category filter leaves incomes out", func(t *testing.T) {
+		result := run(AnalyticsQuery{Start: date(1, 1), End: date(2, 28), GroupBy: AnalyticsGroupByCategory,
+			Categories: []string{"Moradia", "Transporte"}})
+		if len(result.Rows) != 2 || result.Rows[0].Label != "Moradia" || result.Rows[1].Label != "Transporte" {
+			t.Fatalf("rows = %+v, want Moradia then Transporte", result.Rows)
+		}
+		if got := result.Rows[0].Values[AnalyticsMeasureExpense]; got != 2300 {
+			t.Errorf("Moradia expense = %.2f, want 2300.00", got)
+		}
+		if got := result.Total.Values[AnalyticsMeasureIncome]; got != 0 {
+			t.Errorf("total income = %.2f, want 0", got)
+		}
+	})
+
+	t.Run("members get their share of split expenses", func(t *testing.T) {
+		result := run(AnalyticsQuery{Start: date(1, 1), End: date(1, 31), GroupBy: AnalyticsGroupByMember})
+		byLabel := make(map[string]AnalyticsRow)
+		for _, row := range result.Rows {
+			byLabel[row.Label] = row
+		}
+		if got := byLabel["Ana"].Values[AnalyticsMeasureExpense]; got != 1250 {
+			t.Errorf("Ana expense = %.2f, want 1250.00", got)
+		}
+		if got := byLabel["Bruno"].Values[AnalyticsMeasureExpense]; got != 100 {
+			t.Errorf("Bruno expense = %.2f, want 100.00", got)
+		}
+		if got := byLabel["Ana"].Values[AnalyticsMeasureIncome]; got != 5000 {
+			t.Errorf("Ana income = %.2f, want 5000.00", got)
+		}
+	})
+
+	t.Run("accounts and merchants", func(t *testing.T) {
+		result := run(AnalyticsQuery{Start: date(1, 1), End: date(2, 28), GroupBy: AnalyticsGroupByAccount})
+		if len(result.Rows) != 2 || result.Rows[0].Label != "Pessoal" || result.Rows[0].Values[AnalyticsMeasureExpense] != 2450 {
+			t.Errorf("account rows = %+v, want Pessoal first with 2450.00 of expenses", result.Rows)
+		}
+
+		result = run(AnalyticsQuery{Start: date(1, 1), End: date(2, 28), GroupBy: AnalyticsGroupByMerchant})
+		for _, row := range result.Rows {
+			if row.Key == "merchant:uber" && row.Values[AnalyticsMeasureExpense] != 80 {
+				t.Errorf("Uber expense = %.2f, want 80.00 from both spellings", row.Values[AnalyticsMeasureExpense])
+			}
+		}
+	})
+
+	t.Run("invalid queries", func(t *testing.T) {
+		invalid := []struct {
+			query AnalyticsQuery
+			want  error
+		}{
+			{AnalyticsQuery{Start: date(2, 1), End: date(1, 1)}, ErrInvalidAnalyticsRange},
+			{AnalyticsQuery{Start: date(1, 1), End: date(1, 1).AddDate(3, 0, 0)}, ErrInvalidAnalyticsRange},
+			{AnalyticsQuery{Start: date(1, 1), End: date(2, 1), GroupBy: "year"}, ErrInvalidAnalyticsGroupBy},
+			{AnalyticsQuery{Start: date(1, 1), End: date(2, 1), Measures: []AnalyticsMeasure{"profit"}}, ErrInvalidAnalyticsMeasure},
+		}
+		for _, tc := range invalid {
+			if _, err := QueryAnalytics(db, tc.query); err != tc.want {
+				t.Errorf("QueryAnalytics(%+v) error = %v, want %v", tc.query, err, tc.want)
+			}
+		}
+	})
+}
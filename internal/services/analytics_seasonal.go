@@ -0,0 +1,406 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/i18n"
+	"poc-finance/internal/models"
+)
+
+const (
+	// DefaultSeasonalityYears is how much history the seasonality view looks at
+	DefaultSeasonalityYears = 3
+	// MaxSeasonalityYears bounds the history of the seasonality view
+	MaxSeasonalityYears = 5
+	// SeasonalSpikeIndex is how far above the monthly average a month must be to count as a spike
+	SeasonalSpikeIndex = 1.15
+	// maxSeasonalCategories is how many spiking categories are listed per month
+	maxSeasonalCategories = 3
+)
+
+// YearOverYearValue compares an amount with the same period of the previous year
+type YearOverYearValue struct {
+	Current       float64 `json:"current"`
+	Previous      float64 `json:"previous"`
+	Change        float64 `json:"change"`         // Current - Previous
+	ChangePercent float64 `json:"change_percent"` // Same conventions as the month-over-month comparison
+}
+
+// YearOverYearCategory compares the spending of a category
+type YearOverYearCategory struct {
+	Category string            `json:"category"`
+	Expense  YearOverYearValue `json:"expense"`
+}
+
+// YearOverYearAccount compares the incomes and expenses of an account
+type YearOverYearAccount struct {
+	AccountID   uint              `json:"account_id"`
+	AccountName string            `json:"account_name"`
+	Income      YearOverYearValue `json:"income"`
+	Expense     YearOverYearValue `json:"expense"`
+	Net         YearOverYearValue `json:"net"`
+}
+
+// YearOverYearPeriod compares a period (a month or the year to date) with the same period
+// of the previous year. Start and End are the first and last day of the current period.
+type YearOverYearPeriod struct {
+	Label         string                 `json:"label"`
+	Start         time.Time              `json:"start"`
+	End           time.Time              `json:"end"`
+	PreviousStart time.Time              `json:"previous_start"`
+	PreviousEnd   time.Time              `json:"previous_end"`
+	Income        YearOverYearValue      `json:"income"`
+	Expense       YearOverYearValue      `json:"expense"`
+	Net           YearOverYearValue      `json:"net"`
+	Categories    []YearOverYearCategory `json:"categories"` // Largest current spending first
+	Accounts      []YearOverYearAccount  `json:"accounts"`
+}
+
+// YearOverYearComparison compares a month and the year up to that month with the previous year
+type YearOverYearComparison struct {
+	Year            int                `json:"year"`
+	Month           int                `json:"month"`
+	HasPreviousYear bool               `json:"has_previous_year"` // Whether the previous year is after the record start date
+	ThisMonth       YearOverYearPeriod `json:"this_month"`
+	YearToDate      YearOverYearPeriod `json:"year_to_date"`
+}
+
+// yoyTotals accumulates the amounts of one side of a comparison
+type yoyTotals struct {
+	income     float64
+	expense    float64
+	categories map[string]float64
+	accounts   map[uint]*[2]float64 // Income and expense
+}
+
+func newYoYTotals() *yoyTotals {
+	return &yoyTotals{categories: make(map[string]float64), accounts: make(map[uint]*[2]float64)}
+}
+
+func (t *yoyTotals) add(entry summaryEntry) {
+	account, ok := t.accounts[entry.AccountID]
+	if !ok {
+		account = &[2]float64{}
+		t.accounts[entry.AccountID] = account
+	}
+	if entry.Kind == summaryEntryIncome {
+		t.income += entry.Amount
+		account[0] += entry.Amount
+		return
+	}
+	t.expense += entry.Amount
+	t.categories[entry.Category] += entry.Amount
+	account[1] += entry.Amount
+}
+
+// GetYearOverYearComparison compara um mês e o acumulado do ano até esse mês com os mesmos
+// períodos do ano anterior, no total, por categoria e por conta. Os períodos são meses
+// inteiros, como no resumo mensal (despesas fixas contam em todos os meses).
+//
+// Os dois anos são carregados com as mesmas queries batch de GetBatchMonthlySummariesForAccounts
+// (uma query por tipo para todo o intervalo) e comparados em memória.
+func GetYearOverYearComparison(db *gorm.DB, year int, month int, accountIDs []uint) YearOverYearComparison {
+	monthStart := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local)
+	monthEnd := monthStart.AddDate(0, 1, 0) // Exclusive
+	yearStart := time.Date(year, 1, 1, 0, 0, 0, 0, time.Local)
+	previousMonthStart := monthStart.AddDate(-1, 0, 0)
+	previousMonthEnd := monthEnd.AddDate(-1, 0, 0)
+	previousYearStart := yearStart.AddDate(-1, 0, 0)
+
+	recordStartDate := getRecordStartDateSetting()
+	comparison := YearOverYearComparison{
+		Year:            year,
+		Month:           month,
+		HasPreviousYear: recordStartDate.IsZero() || !previousMonthStart.Before(recordStartDate),
+	}
+
+	thisMonth, thisYear := newYoYTotals(), newYoYTotals()
+	lastMonth, lastYear := newYoYTotals(), newYoYTotals()
+	for _, entry := range loadSummaryEntries(db, previousYearStart, monthEnd, accountIDs) {
+		switch {
+		case !entry.Date.Before(yearStart):
+			thisYear.add(entry)
+			if !entry.Date.Before(monthStart) {
+				thisMonth.add(entry)
+			}
+		case entry.Date.Before(previousMonthEnd):
+			lastYear.add(entry)
+			if !entry.Date.Before(previousMonthStart) {
+				lastMonth.add(entry)
+			}
+		}
+	}
+
+	accountNames := make(map[uint]string)
+	if len(accountIDs) > 0 {
+		var accounts []models.Account
+		db.Where("id IN ?", accountIDs).Find(&accounts)
+		for _, account := range accounts {
+			accountNames[account.ID] = account.Name
+		}
+	}
+
+	comparison.ThisMonth = buildYearOverYearPeriod(i18n.MonthNames[time.Month(month)], thisMonth, lastMonth, accountNames)
+	comparison.ThisMonth.Start, comparison.ThisMonth.End = monthStart, monthEnd.AddDate(0, 0, -1)
+	comparison.ThisMonth.PreviousStart, comparison.ThisMonth.PreviousEnd = previousMonthStart, previousMonthEnd.AddDate(0, 0, -1)
+
+	comparison.YearToDate = buildYearOverYearPeriod("Acumulado do ano", thisYear, lastYear, accountNames)
+	comparison.YearToDate.Start, comparison.YearToDate.End = yearStart, monthEnd.AddDate(0, 0, -1)
+	comparison.YearToDate.PreviousStart, comparison.YearToDate.PreviousEnd = previousYearStart, previousMonthEnd.AddDate(0, 0, -1)
+
+	return comparison
+}
+
+func buildYearOverYearPeriod(label string, current, previous *yoyTotals, accountNames map[uint]string) YearOverYearPeriod {
+	period := YearOverYearPeriod{
+		Label:      label,
+		Income:     newYearOverYearValue(current.income, previous.income),
+		Expense:    newYearOverYearValue(current.expense, previous.expense),
+		Net:        newYearOverYearValue(current.income-current.expense, previous.income-previous.expense),
+		Categories: []YearOverYearCategory{},
+		Accounts:   []YearOverYearAccount{},
+	}
+
+	categories := make(map[string]bool)
+	for category := range current.categories {
+		categories[category] = true
+	}
+	for category := range previous.categories {
+		categories[category] = true
+	}
+	for category := range categories {
+		label := category
+		if label == "" {
+			label = "Sem categoria"
+		}
+		period.Categories = append(period.Categories, YearOverYearCategory{
+			Category: label,
+			Expense:  newYearOverYearValue(current.categories[category], previous.categories[category]),
+		})
+	}
+	sort.Slice(period.Categories, func(i, j int) bool {
+		a, b := period.Categories[i].Expense, period.Categories[j].Expense
+		if a.Current != b.Current {
+			return a.Current > b.Current
+		}
+		if a.Previous != b.Previous {
+			return a.Previous > b.Previous
+		}
+		return period.Categories[i].Category < period.Categories[j].Category
+	})
+
+	accounts := make(map[uint]bool)
+	for id := range current.accounts {
+		accounts[id] = true
+	}
+	for id := range previous.accounts {
+		accounts[id] = true
+	}
+	for id := range accounts {
+		cur, prev := [2]float64{}, [2]float64{}
+		if totals, ok := current.accounts[id]; ok {
+			cur = *totals
+		}
+		if totals, ok := previous.accounts[id]; ok {
+			prev = *totals
+		}
+		period.Accounts = append(period.Accounts, YearOverYearAccount{
+			AccountID:   id,
+			AccountName: accountNames[id],
+			Income:      newYearOverYearValue(cur[0], prev[0]),
+			Expense:     newYearOverYearValue(cur[1], prev[1]),
+			Net:         newYearOverYearValue(cur[0]-cur[1], prev[0]-prev[1]),
+		})
+	}
+	sort.Slice(period.Accounts, func(i, j int) bool {
+		return period.Accounts[i].AccountName < period.Accounts[j].AccountName
+	})
+
+	return period
+}
+
+// newYearOverYearValue computes the change between two amounts. A negative previous
+// amount is compared by its absolute value; from zero, any increase counts as 100%.
+func newYearOverYearValue(current, previous float64) YearOverYearValue {
+	value := YearOverYearValue{Current: current, Previous: previous, Change: current - previous}
+	switch {
+	case previous > 0:
+		value.ChangePercent = value.Change / previous * 100
+	case previous < 0:
+		value.ChangePercent = value.Change / -previous * 100
+	case current > 0:
+		value.ChangePercent = 100
+	}
+	return value
+}
+
+// SeasonalCategory is a category that historically spikes in a month
+type SeasonalCategory struct {
+	Category       string  `json:"category"`
+	AverageExpense float64 `json:"average_expense"` // Average spending in the calendar month
+	ExtraExpense   float64 `json:"extra_expense"`   // Spending above the category's monthly average
+	Index          float64 `json:"index"`           // AverageExpense / the category's monthly average
+}
+
+// SeasonalMonth summarizes a calendar month over the years of history
+type SeasonalMonth struct {
+	Month          int                `json:"month"`
+	MonthName      string             `json:"month_name"`
+	Samples        int                `json:"samples"` // How many times the month occurs in the history
+	AverageIncome  float64            `json:"average_income"`
+	AverageExpense float64            `json:"average_expense"`
+	IncomeIndex    float64            `json:"income_index"`   // AverageIncome / the monthly average (1 = typical)
+	ExpenseIndex   float64            `json:"expense_index"`  // AverageExpense / the monthly average (1 = typical)
+	ExtraExpense   float64            `json:"extra_expense"`  // Spending above the monthly average, when positive
+	VariableExtra  float64            `json:"variable_extra"` // Variable spending above its monthly average, negative below
+	Spike          bool               `json:"spike"`          // ExpenseIndex at or above SeasonalSpikeIndex
+	Categories     []SeasonalCategory `json:"categories"`     // Categories that spike in the month, largest extra first
+}
+
+// Seasonality shows which calendar months historically spend (or earn) above the average
+type Seasonality struct {
+	From                  time.Time       `json:"from"`
+	To                    time.Time       `json:"to"` // Exclusive
+	MonthsAnalyzed        int             `json:"months_analyzed"`
+	AverageMonthlyIncome  float64         `json:"average_monthly_income"`
+	AverageMonthlyExpense float64         `json:"average_monthly_expense"`
+	AverageVariable       float64         `json:"average_variable"` // Monthly average of the variable expenses alone
+	Months                []SeasonalMonth `json:"months"`           // January to December
+}
+
+// Month returns the seasonal summary of a calendar month
+func (s *Seasonality) Month(month time.Month) SeasonalMonth {
+	return s.Months[month-1]
+}
+
+// GetSeasonality analisa os meses completos dos últimos anos (até o mês anterior a now) e
+// calcula, para cada mês do calendário, a média de receitas e despesas comparada à média
+// mensal, destacando os meses e as categorias que historicamente têm picos de gastos.
+//
+// O histórico começa no primeiro mês com movimentação (receitas, despesas variáveis, contas
+// ou parcelas) e respeita a data de início dos registros. Os dados são carregados com as
+// queries batch de GetBatchMonthlySummariesForAccounts.
+func GetSeasonality(db *gorm.DB, accountIDs []uint, years int, now time.Time) Seasonality {
+	if years <= 0 {
+		years = DefaultSeasonalityYears
+	}
+	if years > MaxSeasonalityYears {
+		years = MaxSeasonalityYears
+	}
+
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	from := to.AddDate(-years, 0, 0)
+	if recordStartDate := getRecordStartDateSetting(); !recordStartDate.IsZero() {
+		if start := time.Date(recordStartDate.Year(), recordStartDate.Month(), 1, 0, 0, 0, 0, time.Local); start.After(from) {
+			from = start
+		}
+	}
+
+	seasonality := Seasonality{From: from, To: to, Months: make([]SeasonalMonth, 12)}
+	for i := range seasonality.Months {
+		seasonality.Months[i] = SeasonalMonth{Month: i + 1, MonthName: i18n.MonthNames[time.Month(i+1)], Categories: []SeasonalCategory{}}
+	}
+
+	entries := loadSummaryEntries(db, from, to, accountIDs)
+
+	// Fixed expenses are counted in every month, so they don't mark the start of the history
+	firstActivity := to
+	for _, entry := range entries {
+		if entry.Kind != summaryEntryFixed && entry.Date.Before(firstActivity) {
+			firstActivity = entry.Date
+		}
+	}
+	if !firstActivity.Before(to) {
+		seasonality.From = to
+		return seasonality
+	}
+	seasonality.From = time.Date(firstActivity.Year(), firstActivity.Month(), 1, 0, 0, 0, 0, time.Local)
+
+	incomes := make([]float64, 12)
+	expenses := make([]float64, 12)
+	variables := make([]float64, 12)
+	categoryByMonth := make([]map[string]float64, 12)
+	categoryTotals := make(map[string]float64)
+	for i := range categoryByMonth {
+		categoryByMonth[i] = make(map[string]float64)
+	}
+	var totalIncome, totalExpense, totalVariable float64
+	for _, entry := range entries {
+		if entry.Date.Before(seasonality.From) {
+			continue
+		}
+		m := int(entry.Date.Month()) - 1
+		if entry.Kind == summaryEntryIncome {
+			incomes[m] += entry.Amount
+			totalIncome += entry.Amount
+			continue
+		}
+		expenses[m] += entry.Amount
+		totalExpense += entry.Amount
+		if entry.Kind == summaryEntryVariable {
+			variables[m] += entry.Amount
+			totalVariable += entry.Amount
+		}
+		categoryByMonth[m][entry.Category] += entry.Amount
+		categoryTotals[entry.Category] += entry.Amount
+	}
+
+	for month := seasonality.From; month.Before(to); month = month.AddDate(0, 1, 0) {
+		seasonality.Months[month.Month()-1].Samples++
+		seasonality.MonthsAnalyzed++
+	}
+	seasonality.AverageMonthlyIncome = totalIncome / float64(seasonality.MonthsAnalyzed)
+	seasonality.AverageMonthlyExpense = totalExpense / float64(seasonality.MonthsAnalyzed)
+	seasonality.AverageVariable = totalVariable / float64(seasonality.MonthsAnalyzed)
+
+	for i := range seasonality.Months {
+		month := &seasonality.Months[i]
+		if month.Samples == 0 {
+			continue
+		}
+		samples := float64(month.Samples)
+		month.AverageIncome = incomes[i] / samples
+		month.AverageExpense = expenses[i] / samples
+		if seasonality.AverageMonthlyIncome > 0 {
+			month.IncomeIndex = month.AverageIncome / seasonality.AverageMonthlyIncome
+		}
+		if seasonality.AverageMonthlyExpense > 0 {
+			month.ExpenseIndex = month.AverageExpense / seasonality.AverageMonthlyExpense
+			month.ExtraExpense = month.AverageExpense - seasonality.AverageMonthlyExpense
+			if month.ExtraExpense < 0 {
+				month.ExtraExpense = 0
+			}
+		}
+		month.VariableExtra = variables[i]/samples - seasonality.AverageVariable
+		month.Spike = month.ExpenseIndex >= SeasonalSpikeIndex
+
+		for category, total := range categoryByMonth[i] {
+			categoryAverage := categoryTotals[category] / float64(seasonality.MonthsAnalyzed)
+			average := total / samples
+			if categoryAverage <= 0 || average/categoryAverage < SeasonalSpikeIndex {
+				continue
+			}
+			label := category
+			if label == "" {
+				label = "Sem categoria"
+			}
+			month.Categories = append(month.Categories, SeasonalCategory{
+				Category:       label,
+				AverageExpense: average,
+				ExtraExpense:   average - categoryAverage,
+				Index:          average / categoryAverage,
+			})
+		}
+		sort.Slice(month.Categories, func(a, b int) bool {
+			return month.Categories[a].ExtraExpense > month.Categories[b].ExtraExpense
+		})
+		if len(month.Categories) > maxSeasonalCategories {
+			month.Categories = month.Categories[:maxSeasonalCategories]
+		}
+	}
+
+	return seasonality
+}
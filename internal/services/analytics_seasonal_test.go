@@ -0,0 +1,135 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestGetYearOverYearComparison(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	}
+
+	user := testutil.CreateTestUser(db, "yoy@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Pessoal", models.AccountTypeIndividual, user.ID, nil)
+	savings := testutil.CreateTestAccount(db, "Reserva", models.AccountTypeIndividual, user.ID, nil)
+
+	db.Create(&models.Income{AccountID: account.ID, Date: date(2029, 1, 10), GrossAmount: 500, NetAmount: 500})
+	db.Create(&models.Income{AccountID: account.ID, Date: date(2029, 3, 10), GrossAmount: 3000, NetAmount: 3000})
+	db.Create(&models.Income{AccountID: account.ID, Date: date(2029, 4, 10), GrossAmount: 9000, NetAmount: 9000}) // After March: outside the previous YTD
+	db.Create(&models.Income{AccountID: account.ID, Date: date(2030, 1, 10), GrossAmount: 1000, NetAmount: 1000})
+	db.Create(&models.Income{AccountID: savings.ID, Date: date(2030, 3, 10), GrossAmount: 4000, NetAmount: 4000})
+
+	db.Create(&models.Expense{Model: gorm.Model{CreatedAt: date(2029, 3, 5)}, AccountID: account.ID, Name: "Mercado", Amount: 400,
+		Type: models.ExpenseTypeVariable, Category: "Alimentação", Active: true})
+	db.Create(&models.Expense{Model: gorm.Model{CreatedAt: date(2030, 3, 5)}, AccountID: account.ID, Name: "Mercado", Amount: 600,
+		Type: models.ExpenseTypeVariable, Category: "Alimentação", Active: true})
+	db.Create(&models.Expense{Model: gorm.Model{CreatedAt: date(2030, 2, 5)}, AccountID: account.ID, Name: "Uber", Amount: 100,
+		Type: models.ExpenseTypeVariable, Category: "Transporte", Active: true})
+
+	comparison := GetYearOverYearComparison(db, 2030, 3, []uint{account.ID, savings.ID})
+	if !comparison.HasPreviousYear {
+		t.Error("HasPreviousYear = false, want true without a record start date")
+	}
+
+	month := comparison.ThisMonth
+	if month.Label != "Março" || !month.PreviousStart.Equal(date(2029, 3, 1)) || !month.End.Equal(date(2030, 3, 31)) {
+		t.Errorf("month period = %s %v..%v (previous from %v), want Março 2030 vs 2029", month.Label, month.Start, month.End, month.PreviousStart)
+	}
+	if month.Income.Current != 4000 || month.Income.Previous != 3000 || math.Abs(month.Income.ChangePercent-33.33) > 0.01 {
+		t.Errorf("month income = %+v, want 4000 vs 3000 (+33.33%%)", month.Income)
+	}
+	if month.Expense.Current != 600 || month.Expense.Previous != 400 || month.Expense.ChangePercent != 50 {
+		t.Errorf("month expense = %+v, want 600 vs 400 (+50%%)", month.Expense)
+	}
+
+	ytd := comparison.YearToDate
+	if ytd.Income.Current != 5000 || ytd.Income.Previous != 3500 {
+		t.Errorf("YTD income = %+v, want 5000 vs 3500", ytd.Income)
+	}
+	if ytd.Expense.Current != 700 || ytd.Expense.Previous != 400 {
+		t.Errorf("YTD expense = %+v, want 700 vs 400", ytd.Expense)
+	}
+	if len(ytd.Categories) != 2 || ytd.Categories[0].Category != "Alimentação" || ytd.Categories[1].Category != "Transporte" {
+		t.Fatalf("YTD categories = %+v, want Alimentação then Transporte", ytd.Categories)
+	}
+	if transport := ytd.Categories[1].Expense; transport.Previous != 0 || transport.ChangePercent != 100 {
+		t.Errorf("Transporte = %+v, want a new category (+100%%)", transport)
+	}
+
+	if len(ytd.Accounts) != 2 || ytd.Accounts[0].AccountName != "Pessoal" || ytd.Accounts[1].AccountName != "Reserva" {
+		t.Fatalf("YTD accounts = %+v, want Pessoal and Reserva", ytd.Accounts)
+	}
+	if reserve := ytd.Accounts[1]; reserve.Income.Current != 4000 || reserve.Net.Previous != 0 {
+		t.Errorf("Reserva = %+v, want 4000 of income this year and nothing last year", reserve)
+	}
+}
+
+func TestGetSeasonality(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "season@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Pessoal", models.AccountTypeIndividual, user.ID, nil)
+
+	// Three years of groceries every month and gifts every December
+	for month := time.Date(2027, 1, 10, 0, 0, 0, 0, time.Local); month.Year() < 2030; month = month.AddDate(0, 1, 0) {
+		db.Create(&models.Expense{Model: gorm.Model{CreatedAt: month}, AccountID: account.ID, Name: "Mercado", Amount: 100,
+			Type: models.ExpenseTypeVariable, Category: "Alimentação", Active: true})
+		if month.Month() == time.December {
+			db.Create(&models.Expense{Model: gorm.Model{CreatedAt: month}, AccountID: account.ID, Name: "Presentes", Amount: 500,
+				Type: models.ExpenseTypeVariable, Category: "Presentes", Active: true})
+		}
+	}
+	// The current month is not part of the history
+	db.Create(&models.Expense{Model: gorm.Model{CreatedAt: time.Date(2030, 1, 5, 0, 0, 0, 0, time.Local)}, AccountID: account.ID,
+		Name: "Viagem", Amount: 5000, Type: models.ExpenseTypeVariable, Category: "Lazer", Active: true})
+
+	now := time.Date(2030, 1, 15, 0, 0, 0, 0, time.Local)
+	seasonality := GetSeasonality(db, []uint{account.ID}, 3, now)
+
+	if seasonality.MonthsAnalyzed != 36 {
+		t.Fatalf("MonthsAnalyzed = %d, want 36", seasonality.MonthsAnalyzed)
+	}
+	if math.Abs(seasonality.AverageMonthlyExpense-(3600.0+1500.0)/36) > 0.01 {
+		t.Errorf("AverageMonthlyExpense = %.2f, want %.2f", seasonality.AverageMonthlyExpense, (3600.0+1500.0)/36)
+	}
+
+	december := seasonality.Month(time.December)
+	if !december.Spike || december.Samples != 3 || december.AverageExpense != 600 {
+		t.Errorf("December = %+v, want a spike averaging 600 over 3 years", december)
+	}
+	if len(december.Categories) != 1 || december.Categories[0].Category != "Presentes" {
+		t.Errorf("December categories = %+v, want only Presentes", december.Categories)
+	}
+	if june := seasonality.Month(time.June); june.Spike || june.ExtraExpense != 0 || june.ExpenseIndex >= 1 {
+		t.Errorf("June = %+v, want a below-average month", june)
+	}
+
+	// The cash-flow forecast reserves for the December spike
+	forecast, err := NewCashFlowService().Forecast([]uint{account.ID}, 12, nil, now)
+	if err != nil {
+		t.Fatalf("Forecast() error = %v", err)
+	}
+	last := forecast.Overall[len(forecast.Overall)-1]
+	if last.Month != 12 || math.Abs(last.SeasonalExtra-december.VariableExtra) > 0.01 {
+		t.Errorf("December forecast = %+v, want a seasonal extra of %.2f", last, december.VariableExtra)
+	}
+	if len(forecast.SeasonalSpikes) != 1 || forecast.SeasonalSpikes[0].Month != 12 {
+		t.Errorf("SeasonalSpikes = %+v, want December", forecast.SeasonalSpikes)
+	}
+
+	if empty := GetSeasonality(db, nil, 3, now); empty.MonthsAnalyzed != 0 || len(empty.Months) != 12 {
+		t.Errorf("GetSeasonality() without accounts = %+v, want no history", empty)
+	}
+}
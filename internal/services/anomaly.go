@@ -0,0 +1,257 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+const (
+	// anomalyLookbackMonths is how far back the history of a charge is analyzed
+	anomalyLookbackMonths = 12
+	// minAnomalyHistory is the minimum number of previous charges needed to judge an amount
+	minAnomalyHistory = 4
+	// anomalyScoreThreshold is the robust z-score (median absolute deviation) above which an amount is unusual
+	anomalyScoreThreshold = 3.5
+	// anomalyFlatRatio flags amounts this many times the median when the history has no variation
+	anomalyFlatRatio = 2.0
+	// duplicateChargeWindow is the interval in which an identical charge is considered a duplicate
+	duplicateChargeWindow = 48 * time.Hour
+	// subscriptionAmountTolerance is the relative difference accepted between two subscription charges
+	subscriptionAmountTolerance = 0.1
+)
+
+// AnomalyKind identifies why a charge looked unusual
+type AnomalyKind string
+
+const (
+	AnomalyKindUnusualAmount   AnomalyKind = "unusual_amount"
+	AnomalyKindDuplicate       AnomalyKind = "duplicate"
+	AnomalyKindNewSubscription AnomalyKind = "new_subscription"
+)
+
+// Charge is an expense or installment purchase analyzed by the anomaly detector
+type Charge struct {
+	AccountID uint
+	Name      string
+	Category  string
+	Amount    float64
+	Date      time.Time
+}
+
+// Anomaly is a charge flagged by the detector with the explanation shown to the user
+type Anomaly struct {
+	Kind   AnomalyKind `json:"kind"`
+	Charge Charge      `json:"charge"`
+	Reason string      `json:"reason"`
+	Score  float64     `json:"score"` // Robust z-score for unusual amounts
+}
+
+type AnomalyService struct {
+	accountService      *AccountService
+	notificationService *NotificationService
+}
+
+func NewAnomalyService() *AnomalyService {
+	return &AnomalyService{
+		accountService:      NewAccountService(),
+		notificationService: NewNotificationService(),
+	}
+}
+
+// CheckExpense analyzes a newly created variable expense and notifies the account members
+// about any anomaly found. Fixed expenses are planned and are not analyzed.
+func (s *AnomalyService) CheckExpense(expense *models.Expense) []Anomaly {
+	if expense.Type != models.ExpenseTypeVariable {
+		return nil
+	}
+	charge := Charge{
+		AccountID: expense.AccountID,
+		Name:      expense.Name,
+		Category:  expense.Category,
+		Amount:    expense.Amount,
+		Date:      expense.CreatedAt,
+	}
+	history := chargeHistory(expense.AccountID, charge.Date.AddDate(0, -anomalyLookbackMonths, 0), charge.Date, expense.ID, 0)
+	anomalies := DetectAnomalies(charge, history, hasRecurringCharge(expense.AccountID, expense.Name))
+	s.notify(expense.AccountID, anomalies, "/expenses")
+	return anomalies
+}
+
+// CheckInstallment analyzes a newly created installment purchase by its total amount
+func (s *AnomalyService) CheckInstallment(accountID uint, installment *models.Installment) []Anomaly {
+	charge := Charge{
+		AccountID: accountID,
+		Name:      installment.Description,
+		Category:  installment.Category,
+		Amount:    installment.TotalAmount,
+		Date:      installment.CreatedAt,
+	}
+	history := chargeHistory(accountID, charge.Date.AddDate(0, -anomalyLookbackMonths, 0), charge.Date, 0, installment.ID)
+	// Installment purchases are one-off, so a repeated one is never a subscription
+	anomalies := DetectAnomalies(charge, history, true)
+	s.notify(accountID, anomalies, "/cards")
+	return anomalies
+}
+
+func (s *AnomalyService) notify(accountID uint, anomalies []Anomaly, link string) {
+	if len(anomalies) == 0 {
+		return
+	}
+	account, err := s.accountService.GetAccountByID(accountID)
+	if err != nil {
+		return
+	}
+	members, err := s.accountService.GetAccountMembers(accountID)
+	if err != nil {
+		return
+	}
+	for _, anomaly := range anomalies {
+		s.notificationService.NotifyUnusualCharge(anomaly, account, members, link)
+	}
+}
+
+// DetectAnomalies compares a charge with the previous charges of its account. It flags
+// identical charges within duplicateChargeWindow, amounts far above the history of the same
+// merchant (or, lacking it, of the category) and a second monthly charge of a new merchant,
+// which looks like a new subscription unless knownRecurring is set.
+func DetectAnomalies(charge Charge, history []Charge, knownRecurring bool) []Anomaly {
+	var anomalies []Anomaly
+	merchant := normalizeMerchant(charge.Name)
+
+	var merchantAmounts, categoryAmounts []float64
+	var merchantHistory []Charge
+	for _, previous := range history {
+		sameMerchant := merchant != "" && normalizeMerchant(previous.Name) == merchant
+		if sameMerchant && math.Abs(previous.Amount-charge.Amount) < 0.01 && absDuration(charge.Date.Sub(previous.Date)) <= duplicateChargeWindow {
+			anomalies = append(anomalies, Anomaly{
+				Kind:   AnomalyKindDuplicate,
+				Charge: charge,
+				Reason: fmt.Sprintf("Já existe uma cobrança idêntica de R$ %.2f em \"%s\" registrada em %s",
+					previous.Amount, previous.Name, previous.Date.Format("02/01/2006 15:04")),
+			})
+			continue
+		}
+		if sameMerchant {
+			merchantAmounts = append(merchantAmounts, previous.Amount)
+			merchantHistory = append(merchantHistory, previous)
+		}
+		if charge.Category != "" && previous.Category == charge.Category {
+			categoryAmounts = append(categoryAmounts, previous.Amount)
+		}
+	}
+
+	if score, median, ok := unusualAmount(charge.Amount, merchantAmounts); ok {
+		anomalies = append(anomalies, Anomaly{
+			Kind:   AnomalyKindUnusualAmount,
+			Charge: charge,
+			Score:  score,
+			Reason: fmt.Sprintf("R$ %.2f é %.1fx a mediana de R$ %.2f das últimas %d cobranças em \"%s\"",
+				charge.Amount, charge.Amount/median, median, len(merchantAmounts), charge.Name),
+		})
+	} else if score, median, ok := unusualAmount(charge.Amount, categoryAmounts); ok && len(merchantAmounts) < minAnomalyHistory {
+		anomalies = append(anomalies, Anomaly{
+			Kind:   AnomalyKindUnusualAmount,
+			Charge: charge,
+			Score:  score,
+			Reason: fmt.Sprintf("R$ %.2f é %.1fx a mediana de R$ %.2f dos últimos %d gastos em %s",
+				charge.Amount, charge.Amount/median, median, len(categoryAmounts), charge.Category),
+		})
+	}
+
+	if !knownRecurring && len(merchantHistory) == 1 && looksLikeSubscription(charge, merchantHistory[0]) {
+		anomalies = append(anomalies, Anomaly{
+			Kind:   AnomalyKindNewSubscription,
+			Charge: charge,
+			Reason: fmt.Sprintf("Segunda cobrança de R$ %.2f em \"%s\" um mês após a primeira (%s): parece uma nova assinatura",
+				charge.Amount, charge.Name, merchantHistory[0].Date.Format("02/01/2006")),
+		})
+	}
+
+	return anomalies
+}
+
+// unusualAmount returns the robust z-score of amount over the history and whether it is
+// unusually high. Histories without variation flag amounts anomalyFlatRatio times the median.
+func unusualAmount(amount float64, history []float64) (float64, float64, bool) {
+	if len(history) < minAnomalyHistory {
+		return 0, 0, false
+	}
+	median := percentileOf(history, 50)
+	if median <= 0 || amount <= median {
+		return 0, median, false
+	}
+
+	deviations := make([]float64, len(history))
+	for i, v := range history {
+		deviations[i] = math.Abs(v - median)
+	}
+	mad := percentileOf(deviations, 50)
+	if mad == 0 {
+		return 0, median, amount >= median*anomalyFlatRatio
+	}
+
+	score := 0.6745 * (amount - median) / mad
+	return score, median, score > anomalyScoreThreshold
+}
+
+// looksLikeSubscription reports whether a charge repeats a previous one about a month later
+func looksLikeSubscription(charge, previous Charge) bool {
+	days := charge.Date.Sub(previous.Date).Hours() / 24
+	if days < 25 || days > 35 || previous.Amount <= 0 {
+		return false
+	}
+	return math.Abs(charge.Amount-previous.Amount)/previous.Amount <= subscriptionAmountTolerance
+}
+
+// chargeHistory returns the variable expenses and installment purchases of an account
+// created between since and date, excluding the given expense and installment (0 for none)
+func chargeHistory(accountID uint, since, date time.Time, excludeExpenseID, excludeInstallmentID uint) []Charge {
+	var expenses []models.Expense
+	database.DB.Where("account_id = ? AND type = ? AND created_at >= ? AND created_at <= ? AND id <> ?",
+		accountID, models.ExpenseTypeVariable, since, date, excludeExpenseID).Find(&expenses)
+
+	var installments []models.Installment
+	database.DB.Joins("JOIN credit_cards ON credit_cards.id = installments.credit_card_id").
+		Where("credit_cards.account_id = ? AND installments.created_at >= ? AND installments.created_at <= ? AND installments.id <> ?",
+			accountID, since, date, excludeInstallmentID).
+		Find(&installments)
+
+	history := make([]Charge, 0, len(expenses)+len(installments))
+	for _, e := range expenses {
+		history = append(history, Charge{AccountID: accountID, Name: e.Name, Category: e.Category, Amount: e.Amount, Date: e.CreatedAt})
+	}
+	for _, i := range installments {
+		history = append(history, Charge{AccountID: accountID, Name: i.Description, Category: i.Category, Amount: i.TotalAmount, Date: i.CreatedAt})
+	}
+	return history
+}
+
+// hasRecurringCharge reports whether the account already has a recurring expense for the merchant
+func hasRecurringCharge(accountID uint, name string) bool {
+	var recurring []models.RecurringTransaction
+	database.DB.Where("account_id = ? AND transaction_type = ?", accountID, models.TransactionTypeExpense).Find(&recurring)
+	merchant := normalizeMerchant(name)
+	for _, rt := range recurring {
+		if normalizeMerchant(rt.Description) == merchant {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeMerchant makes charge names comparable regardless of case and spacing
+func normalizeMerchant(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
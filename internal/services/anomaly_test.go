@@ -0,0 +1,106 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestDetectAnomalies(t *testing.T) {
+	now := time.Date(2030, 3, 15, 12, 0, 0, 0, time.Local)
+	charge := func(name, category string, amount float64, daysAgo int) Charge {
+		return Charge{Name: name, Category: category, Amount: amount, Date: now.AddDate(0, 0, -daysAgo)}
+	}
+	groceries := []Charge{
+		charge("Mercado", "Alimentação", 100, 10),
+		charge("Mercado", "Alimentação", 110, 40),
+		charge("Mercado", "Alimentação", 90, 70),
+		charge("mercado ", "Alimentação", 105, 100),
+		charge("Mercado", "Alimentação", 95, 130),
+	}
+
+	tests := []struct {
+		name           string
+		charge         Charge
+		history        []Charge
+		knownRecurring bool
+		want           []AnomalyKind
+	}{
+		{"amount far above merchant history", charge("Mercado", "Alimentação", 400, 0), groceries, false, []AnomalyKind{AnomalyKindUnusualAmount}},
+		{"amount within merchant history", charge("Mercado", "Alimentação", 115, 0), groceries, false, nil},
+		{"amount far above category history", charge("Restaurante", "Alimentação", 600, 0), groceries, false, []AnomalyKind{AnomalyKindUnusualAmount}},
+		{"not enough history", charge("Mercado", "Alimentação", 400, 0), groceries[:3], false, nil},
+		{"duplicate charge", charge("Uber", "Transporte", 32.5, 0), []Charge{{Name: "UBER", Category: "Transporte", Amount: 32.5, Date: now.Add(-time.Hour)}}, false, []AnomalyKind{AnomalyKindDuplicate}},
+		{"same amount days apart", charge("Uber", "Transporte", 32.5, 0), []Charge{charge("Uber", "Transporte", 32.5, 5)}, false, nil},
+		{"new subscription", charge("Streaming", "Lazer", 21.9, 0), []Charge{charge("Streaming", "Lazer", 21.9, 30)}, false, []AnomalyKind{AnomalyKindNewSubscription}},
+		{"known recurring charge", charge("Streaming", "Lazer", 21.9, 0), []Charge{charge("Streaming", "Lazer", 21.9, 30)}, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anomalies := DetectAnomalies(tt.charge, tt.history, tt.knownRecurring)
+			if len(anomalies) != len(tt.want) {
+				t.Fatalf("got %d anomalies (%+v), want %v", len(anomalies), anomalies, tt.want)
+			}
+			for i, kind := range tt.want {
+				if anomalies[i].Kind != kind {
+					t.Errorf("anomaly %d kind = %s, want %s", i, anomalies[i].Kind, kind)
+				}
+				if anomalies[i].Reason == "" {
+					t.Errorf("anomaly %d has no reason", i)
+				}
+			}
+		})
+	}
+}
+
+func TestAnomalyService_CheckExpense(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Pessoal", models.AccountTypeIndividual, user.ID, nil)
+	now := time.Now()
+
+	for i, amount := range []float64{80, 90, 100, 110, 120} {
+		db.Create(&models.Expense{
+			Model:     gorm.Model{CreatedAt: now.AddDate(0, -i-1, 0)},
+			AccountID: account.ID,
+			Name:      "Farmácia",
+			Amount:    amount,
+			Type:      models.ExpenseTypeVariable,
+			Category:  "Saúde",
+			Active:    true,
+		})
+	}
+
+	service := NewAnomalyService()
+
+	fixed := models.Expense{AccountID: account.ID, Name: "Farmácia", Amount: 900, Type: models.ExpenseTypeFixed, Category: "Saúde", Active: true}
+	db.Create(&fixed)
+	if anomalies := service.CheckExpense(&fixed); len(anomalies) != 0 {
+		t.Errorf("fixed expenses should not be analyzed, got %+v", anomalies)
+	}
+
+	expense := models.Expense{AccountID: account.ID, Name: "Farmácia", Amount: 900, Type: models.ExpenseTypeVariable, Category: "Saúde", Active: true}
+	db.Create(&expense)
+	anomalies := service.CheckExpense(&expense)
+	if len(anomalies) != 1 || anomalies[0].Kind != AnomalyKindUnusualAmount {
+		t.Fatalf("expected an unusual amount, got %+v", anomalies)
+	}
+
+	var notifications []models.Notification
+	db.Where("user_id = ? AND type = ?", user.ID, models.NotificationTypeUnusualCharge).Find(&notifications)
+	if len(notifications) != 1 {
+		t.Fatalf("expected 1 notification, got %d", len(notifications))
+	}
+	if !strings.Contains(notifications[0].Message, "mediana de R$ 100.00") {
+		t.Errorf("notification message should explain the anomaly, got %q", notifications[0].Message)
+	}
+}
@@ -3,7 +3,6 @@ package services
 import (
 	"errors"
 	"fmt"
-	"time"
 
 	"poc-finance/internal/database"
 	"poc-finance/internal/models"
@@ -19,12 +18,14 @@ var (
 type BudgetService struct {
 	groupService        *GroupService
 	notificationService *NotificationService
+	webhookService      *WebhookService
 }
 
 func NewBudgetService() *BudgetService {
 	return &BudgetService{
 		groupService:        NewGroupService(),
 		notificationService: NewNotificationService(),
+		webhookService:      NewWebhookService(),
 	}
 }
 
@@ -75,6 +76,9 @@ func (s *BudgetService) CreateBudget(userID uint, groupID *uint, year, month int
 		}
 	}
 
+	// Count spending already registered in the period
+	s.RecalculateBudgetSpent(budget.ID)
+
 	// Reload with associations
 	database.DB.Preload("User").Preload("Group").Preload("Categories").First(budget, budget.ID)
 	return budget, nil
@@ -203,10 +207,18 @@ func (s *BudgetService) DeleteBudget(budgetID, userID uint) error {
 		return ErrUnauthorized
 	}
 
-	// Delete categories first
+	// Delete alerts, moves and categories first
+	database.DB.Where("budget_id = ?", budgetID).Delete(&models.BudgetAlert{})
+	database.DB.Where("budget_id = ?", budgetID).Delete(&models.BudgetMove{})
 	database.DB.Where("budget_id = ?", budgetID).Delete(&models.BudgetCategory{})
 
-	return database.DB.Delete(budget).Error
+	if err := database.DB.Delete(budget).Error; err != nil {
+		return err
+	}
+
+	// The next month no longer has balances to carry from this one
+	s.refreshNextRollover(budget)
+	return nil
 }
 
 // ArchiveBudget archives a budget
@@ -236,11 +248,15 @@ func (s *BudgetService) AddCategory(budgetID, userID uint, categoryName string,
 		return nil, ErrUnauthorized
 	}
 
+	// Count spending already registered in the period
+	spending := GetCategorySpending(database.DB, budgetAccountIDs(database.DB, budget), budget.Year, budget.Month)
+	spent := categoryTotal(spending, categoryName)
+
 	category := &models.BudgetCategory{
 		BudgetID: budgetID,
 		Category: categoryName,
 		Limit:    limit,
-		Spent:    0,
+		Spent:    spent,
 	}
 
 	if err := database.DB.Create(category).Error; err != nil {
@@ -268,10 +284,18 @@ func (s *BudgetService) UpdateCategory(categoryID, userID uint, categoryName str
 		return ErrUnauthorized
 	}
 
-	return database.DB.Model(&category).Updates(map[string]interface{}{
+	err = database.DB.Model(&category).Updates(map[string]interface{}{
 		"category": categoryName,
 		"limit":    limit,
 	}).Error
+	if err != nil {
+		return err
+	}
+
+	// A new limit changes the progress against the alert thresholds and the envelope balance
+	s.evaluateCategoryAlerts(budget, &category)
+	s.refreshNextRollover(budget)
+	return nil
 }
 
 // DeleteCategory deletes a budget category
@@ -292,6 +316,7 @@ func (s *BudgetService) DeleteCategory(categoryID, userID uint) error {
 		return ErrUnauthorized
 	}
 
+	database.DB.Where("budget_category_id = ?", category.ID).Delete(&models.BudgetAlert{})
 	return database.DB.Delete(&category).Error
 }
 
@@ -302,27 +327,20 @@ func (s *BudgetService) UpdateCategorySpending(budgetID uint, categoryName strin
 		return ErrCategoryNotFound
 	}
 
-	category.Spent = spent
-
-	// Check if we need to send notifications
-	shouldNotify80 := category.ShouldNotifyAt80()
-	shouldNotify100 := category.ShouldNotifyAt100()
-
-	if shouldNotify80 {
-		now := time.Now()
-		category.NotifiedAt80 = &now
-		// Send 80% notification
-		s.sendCategoryNotification(&category, 80)
+	var budget models.Budget
+	if err := database.DB.First(&budget, budgetID).Error; err != nil {
+		return ErrBudgetNotFound
 	}
 
-	if shouldNotify100 {
-		now := time.Now()
-		category.NotifiedAt100 = &now
-		// Send 100% notification
-		s.sendCategoryNotification(&category, 100)
+	category.Spent = spent
+	if err := database.DB.Save(&category).Error; err != nil {
+		return err
 	}
 
-	return database.DB.Save(&category).Error
+	// Check if we need to send notifications
+	s.evaluateCategoryAlerts(&budget, &category)
+	s.refreshNextRollover(&budget)
+	return nil
 }
 
 // CopyFromPreviousMonth copies a budget from the previous month
@@ -357,7 +375,30 @@ func (s *BudgetService) CopyFromPreviousMonth(userID uint, groupID *uint, target
 	}
 
 	// Create new budget
-	return s.CreateBudget(userID, groupID, targetYear, targetMonth, prevBudget.Name, categories)
+	budget, err := s.CreateBudget(userID, groupID, targetYear, targetMonth, prevBudget.Name, categories)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the alert thresholds and the envelope mode of the previous month
+	database.DB.Model(budget).Updates(map[string]interface{}{
+		"alert_thresholds": prevBudget.AlertThresholds,
+		"rollover":         prevBudget.Rollover,
+	})
+	for _, prevCategory := range prevBudget.Categories {
+		if prevCategory.AlertThresholds == "" {
+			continue
+		}
+		database.DB.Model(&models.BudgetCategory{}).
+			Where("budget_id = ? AND category = ?", budget.ID, prevCategory.Category).
+			Update("alert_thresholds", prevCategory.AlertThresholds)
+	}
+
+	database.DB.Preload("User").Preload("Group").Preload("Categories").First(budget, budget.ID)
+	if budget.Rollover {
+		s.applyRollover(budget)
+	}
+	return budget, nil
 }
 
 // Helper: canAccessBudget checks if user can view a budget
@@ -383,7 +424,7 @@ func (s *BudgetService) canModifyBudget(budget *models.Budget, userID uint) bool
 }
 
 // Helper: sendCategoryNotification sends a notification when category reaches threshold
-func (s *BudgetService) sendCategoryNotification(category *models.BudgetCategory, percentage int) {
+func (s *BudgetService) sendCategoryNotification(category *models.BudgetCategory, threshold float64) {
 	// Load budget with associations
 	var budget models.Budget
 	if err := database.DB.Preload("User").Preload("Group").First(&budget, category.BudgetID).Error; err != nil {
@@ -405,21 +446,21 @@ func (s *BudgetService) sendCategoryNotification(category *models.BudgetCategory
 	// Create notification for each member
 	for _, member := range members {
 		var title, message string
-		var notifType models.NotificationType
-
-		if percentage == 80 {
+		switch {
+		case threshold < 100:
 			title = "Alerta de orçamento"
-			message = formatBudgetMessage(&budget, category, "próximo do limite", percentage)
-			notifType = models.NotificationTypeBudgetAlert
-		} else {
+			message = formatBudgetMessage(&budget, category, "próximo do limite", threshold)
+		case threshold == 100:
 			title = "Orçamento atingido"
-			message = formatBudgetMessage(&budget, category, "atingiu o limite", percentage)
-			notifType = models.NotificationTypeBudgetAlert
+			message = formatBudgetMessage(&budget, category, "atingiu o limite", threshold)
+		default:
+			title = "Orçamento ultrapassado"
+			message = formatBudgetMessage(&budget, category, "ultrapassou o limite", threshold)
 		}
 
 		notification := &models.Notification{
 			UserID:  member.ID,
-			Type:    notifType,
+			Type:    models.NotificationTypeBudgetAlert,
 			Title:   title,
 			Message: message,
 			Link:    "/budgets",
@@ -427,16 +468,31 @@ func (s *BudgetService) sendCategoryNotification(category *models.BudgetCategory
 		}
 		s.notificationService.Create(notification)
 	}
+
+	s.webhookService.EmitForUsers(members, models.WebhookEventBudgetThreshold, map[string]interface{}{
+		"budget_id":   budget.ID,
+		"budget_name": budget.Name,
+		"group_id":    budget.GroupID,
+		"category":    category.Category,
+		"threshold":   threshold,
+		"spent":       category.Spent,
+		"limit":       category.Limit,
+	})
 }
 
 // Helper: formatBudgetMessage formats the notification message
-func formatBudgetMessage(budget *models.Budget, category *models.BudgetCategory, status string, percentage int) string {
+func formatBudgetMessage(budget *models.Budget, category *models.BudgetCategory, status string, percentage float64) string {
+	// Envelope budgets can spend the balance carried in too
+	funds := category.Limit
+	if budget.Rollover {
+		funds += category.CarriedOver
+	}
 	if budget.GroupID != nil {
-		return formatMessage("A categoria \"%s\" do orçamento \"%s\" do grupo %s (R$ %.2f / R$ %.2f - %d%%)",
-			category.Category, budget.Name, status, category.Spent, category.Limit, percentage)
+		return formatMessage("A categoria \"%s\" do orçamento \"%s\" do grupo %s (R$ %.2f / R$ %.2f - %.0f%%)",
+			category.Category, budget.Name, status, category.Spent, funds, percentage)
 	}
-	return formatMessage("A categoria \"%s\" do seu orçamento \"%s\" %s (R$ %.2f / R$ %.2f - %d%%)",
-		category.Category, budget.Name, status, category.Spent, category.Limit, percentage)
+	return formatMessage("A categoria \"%s\" do seu orçamento \"%s\" %s (R$ %.2f / R$ %.2f - %.0f%%)",
+		category.Category, budget.Name, status, category.Spent, funds, percentage)
 }
 
 // Helper: formatMessage is a simple wrapper for fmt.Sprintf
@@ -444,80 +500,84 @@ func formatMessage(format string, args ...interface{}) string {
 	return fmt.Sprintf(format, args...)
 }
 
-// UpdateCategorySpent recalculates spending from expense payments for a specific category/month/year
-// This method is called when expense payments are created, updated, or deleted
+// UpdateCategorySpent recalculates the actual spending of a category in every active budget
+// a user created for a month, personal and group ones. Spending changes refresh only the
+// affected budgets through RefreshAccountSpending instead.
 func (s *BudgetService) UpdateCategorySpent(userID uint, category string, year, month int) error {
-	// Find all active budgets for this user and period (individual and group budgets)
 	var budgets []models.Budget
-	database.DB.Where("user_id = ? AND year = ? AND month = ? AND status = ?",
+	if err := database.DB.Where("user_id = ? AND year = ? AND month = ? AND status = ?",
 		userID, year, month, models.BudgetStatusActive).
-		Preload("Group").
-		Preload("Categories").
-		Find(&budgets)
+		Find(&budgets).Error; err != nil {
+		return err
+	}
 
-	for _, budget := range budgets {
-		// Find the category in this budget
-		var budgetCategory models.BudgetCategory
-		if err := database.DB.Where("budget_id = ? AND category = ?", budget.ID, category).
-			First(&budgetCategory).Error; err != nil {
-			continue // Category doesn't exist in this budget, skip
-		}
+	for i := range budgets {
+		s.updateBudgetCategorySpent(&budgets[i], category)
+	}
 
-		// Recalculate spent amount from expense payments
-		var totalSpent float64
-		paymentQuery := database.DB.Table("expense_payments").
-			Joins("JOIN expenses ON expenses.id = expense_payments.expense_id").
-			Where("expenses.category = ? AND expense_payments.year = ? AND expense_payments.month = ?", category, year, month)
-
-		if budget.GroupID != nil {
-			// For group budgets, sum all payments from group members' accounts
-			paymentQuery = paymentQuery.
-				Joins("JOIN accounts ON accounts.id = expenses.account_id").
-				Where("accounts.group_id = ?", *budget.GroupID)
-		} else {
-			// For individual budgets, only count user's account expenses
-			paymentQuery = paymentQuery.
-				Joins("JOIN accounts ON accounts.id = expenses.account_id").
-				Where("accounts.user_id = ? AND (accounts.group_id IS NULL OR accounts.type = ?)", userID, models.AccountTypeIndividual)
-		}
+	return nil
+}
 
-		paymentQuery.Select("COALESCE(SUM(expense_payments.amount), 0)").Row().Scan(&totalSpent)
+// RefreshAccountSpending recalculates the budgets affected by a spending change in an account:
+// the group's budgets for joint accounts, the owner's personal budgets otherwise
+func (s *BudgetService) RefreshAccountSpending(accountID uint, category string, year, month int) error {
+	var account models.Account
+	if err := database.DB.First(&account, accountID).Error; err != nil {
+		return err
+	}
 
-		// Store old spent value to check if thresholds crossed
-		oldSpent := budgetCategory.Spent
+	query := database.DB.Where("year = ? AND month = ? AND status = ?", year, month, models.BudgetStatusActive)
+	if account.IsJoint() && account.GroupID != nil {
+		query = query.Where("group_id = ?", *account.GroupID)
+	} else {
+		query = query.Where("user_id = ? AND group_id IS NULL", account.UserID)
+	}
 
-		// Update spent amount
-		database.DB.Model(&budgetCategory).Update("spent", totalSpent)
+	var budgets []models.Budget
+	query.Find(&budgets)
 
-		// Reload to get updated values
-		database.DB.First(&budgetCategory, budgetCategory.ID)
+	for i := range budgets {
+		s.updateBudgetCategorySpent(&budgets[i], category)
+	}
 
-		// Check if thresholds were crossed and send notifications
-		oldPercentage := 0.0
-		if budgetCategory.Limit > 0 {
-			oldPercentage = (oldSpent / budgetCategory.Limit) * 100
-		}
-		newPercentage := budgetCategory.ProgressPercentage()
+	return nil
+}
 
-		// Send 80% notification if crossed from below to above
-		if oldPercentage < 80 && newPercentage >= 80 && budgetCategory.NotifiedAt80 == nil {
-			s.sendCategoryNotification(&budgetCategory, 80)
-			now := time.Now()
-			database.DB.Model(&budgetCategory).Update("notified_at_80", now)
+// RefreshInstallmentSpending recalculates the budgets of every month an installment is due
+func (s *BudgetService) RefreshInstallmentSpending(accountID uint, installment *models.Installment) error {
+	for _, ym := range installmentMonths(installment) {
+		if err := s.RefreshAccountSpending(accountID, installment.Category, ym[0], ym[1]); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		// Send 100% notification if crossed from below to above
-		if oldPercentage < 100 && newPercentage >= 100 && budgetCategory.NotifiedAt100 == nil {
-			s.sendCategoryNotification(&budgetCategory, 100)
-			now := time.Now()
-			database.DB.Model(&budgetCategory).Update("notified_at_100", now)
-		}
+// updateBudgetCategorySpent stores the actual spending of a category in a budget
+// and evaluates its alert thresholds
+func (s *BudgetService) updateBudgetCategorySpent(budget *models.Budget, category string) {
+	// Find the category in this budget
+	var budgetCategory models.BudgetCategory
+	if err := database.DB.Where("budget_id = ? AND category = ?", budget.ID, category).
+		First(&budgetCategory).Error; err != nil {
+		return // Category doesn't exist in this budget, skip
 	}
 
-	return nil
+	spending := GetCategorySpending(database.DB, budgetAccountIDs(database.DB, budget), budget.Year, budget.Month)
+	totalSpent := categoryTotal(spending, category)
+
+	// Update spent amount
+	database.DB.Model(&budgetCategory).Update("spent", totalSpent)
+	budgetCategory.Spent = totalSpent
+
+	// Notify crossed thresholds and re-arm the ones spending dropped below
+	s.evaluateCategoryAlerts(budget, &budgetCategory)
+
+	// Envelope balances carry into the next month
+	s.refreshNextRollover(budget)
 }
 
-// RecalculateBudgetSpent recalculates all category spending for a budget from expense payments
+// RecalculateBudgetSpent recalculates all category spending for a budget from every spending source
 func (s *BudgetService) RecalculateBudgetSpent(budgetID uint) error {
 	// Get budget with categories
 	var budget models.Budget
@@ -525,31 +585,18 @@ func (s *BudgetService) RecalculateBudgetSpent(budgetID uint) error {
 		return ErrBudgetNotFound
 	}
 
+	spending := GetCategorySpending(database.DB, budgetAccountIDs(database.DB, &budget), budget.Year, budget.Month)
+
 	// Recalculate each category
 	for _, category := range budget.Categories {
-		var totalSpent float64
-		paymentQuery := database.DB.Table("expense_payments").
-			Joins("JOIN expenses ON expenses.id = expense_payments.expense_id").
-			Where("expenses.category = ? AND expense_payments.year = ? AND expense_payments.month = ?",
-				category.Category, budget.Year, budget.Month)
-
-		if budget.GroupID != nil {
-			// For group budgets, sum all payments from group members' accounts
-			paymentQuery = paymentQuery.
-				Joins("JOIN accounts ON accounts.id = expenses.account_id").
-				Where("accounts.group_id = ?", *budget.GroupID)
-		} else {
-			// For individual budgets, only count user's account expenses
-			paymentQuery = paymentQuery.
-				Joins("JOIN accounts ON accounts.id = expenses.account_id").
-				Where("accounts.user_id = ? AND (accounts.group_id IS NULL OR accounts.type = ?)", budget.UserID, models.AccountTypeIndividual)
-		}
-
-		paymentQuery.Select("COALESCE(SUM(expense_payments.amount), 0)").Row().Scan(&totalSpent)
+		totalSpent := categoryTotal(spending, category.Category)
 
 		// Update spent amount
 		database.DB.Model(&category).Update("spent", totalSpent)
 	}
 
+	// Envelope balances carry into the next month
+	s.refreshNextRollover(&budget)
+
 	return nil
 }
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+var ErrInvalidAlertThresholds = errors.New("limites de alerta inválidos (use percentuais entre 1 e 1000 separados por vírgula)")
+
+// DefaultBudgetAlertThresholds are used when neither the budget nor the category define thresholds
+var DefaultBudgetAlertThresholds = []float64{80, 100}
+
+// maxAlertThreshold caps thresholds so typos like "1000000" are rejected
+const maxAlertThreshold = 1000
+
+// ParseAlertThresholds parses a comma-separated list of percentages (e.g. "50, 75, 100"),
+// returning them sorted and without duplicates. An empty value returns nil.
+func ParseAlertThresholds(value string) ([]float64, error) {
+	var thresholds []float64
+	seen := make(map[float64]bool)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSuffix(strings.TrimSpace(part), "%")
+		if part == "" {
+			continue
+		}
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || threshold <= 0 || threshold > maxAlertThreshold {
+			return nil, ErrInvalidAlertThresholds
+		}
+		if !seen[threshold] {
+			seen[threshold] = true
+			thresholds = append(thresholds, threshold)
+		}
+	}
+	sort.Float64s(thresholds)
+	return thresholds, nil
+}
+
+// FormatAlertThresholds formats thresholds the way they are stored ("50,75,100")
+func FormatAlertThresholds(thresholds []float64) string {
+	parts := make([]string, len(thresholds))
+	for i, threshold := range thresholds {
+		parts[i] = strconv.FormatFloat(threshold, 'f', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// normalizeAlertThresholds validates a thresholds list and returns its stored form
+func normalizeAlertThresholds(value string) (string, error) {
+	thresholds, err := ParseAlertThresholds(value)
+	if err != nil {
+		return "", err
+	}
+	return FormatAlertThresholds(thresholds), nil
+}
+
+// defaultAlertThresholds returns the default thresholds, including the global
+// budget warning threshold configured in the settings
+func defaultAlertThresholds() []float64 {
+	thresholds := append([]float64{}, DefaultBudgetAlertThresholds...)
+	if warning := getSettingFloat(models.SettingBudgetWarningThreshold); warning > 0 && warning <= maxAlertThreshold {
+		thresholds = append(thresholds, warning)
+	}
+	normalized, _ := ParseAlertThresholds(FormatAlertThresholds(thresholds))
+	return normalized
+}
+
+// AlertThresholdsFor resolves the thresholds of a category: its own list, else the
+// budget's list, else the defaults
+func AlertThresholdsFor(budget *models.Budget, category *models.BudgetCategory) []float64 {
+	for _, value := range []string{category.AlertThresholds, budget.AlertThresholds} {
+		if thresholds, err := ParseAlertThresholds(value); err == nil && len(thresholds) > 0 {
+			return thresholds
+		}
+	}
+	return defaultAlertThresholds()
+}
+
+// categoryAlertPercentage is the spending percentage alert thresholds are compared with.
+// With rollover a category can spend its limit plus the balance it carried in, so the
+// percentage is measured against both.
+func categoryAlertPercentage(budget *models.Budget, category *models.BudgetCategory) float64 {
+	if budget.Rollover {
+		return category.EnvelopePercentage()
+	}
+	return category.ProgressPercentage()
+}
+
+// evaluateCategoryAlerts compares the spending of a category with its thresholds.
+// Newly crossed thresholds are logged and only the highest of them is notified, so a
+// single large expense doesn't trigger a burst of alerts. Thresholds the spending dropped
+// below are re-armed.
+func (s *BudgetService) evaluateCategoryAlerts(budget *models.Budget, category *models.BudgetCategory) {
+	var active []models.BudgetAlert
+	database.DB.Where("budget_category_id = ? AND re_armed_at IS NULL", category.ID).Find(&active)
+
+	activeByThreshold := make(map[float64]*models.BudgetAlert)
+	for i := range active {
+		activeByThreshold[active[i].Threshold] = &active[i]
+	}
+
+	percentage := categoryAlertPercentage(budget, category)
+	now := time.Now()
+
+	// Re-arm alerts whose threshold the spending dropped below, including thresholds
+	// that were removed from the list
+	thresholds := AlertThresholdsFor(budget, category)
+	configured := make(map[float64]bool)
+	for _, threshold := range thresholds {
+		configured[threshold] = true
+	}
+	for _, alert := range activeByThreshold {
+		if percentage < alert.Threshold || !configured[alert.Threshold] {
+			database.DB.Model(alert).Update("re_armed_at", now)
+		}
+	}
+
+	var crossed []float64
+	for _, threshold := range thresholds {
+		if percentage >= threshold && activeByThreshold[threshold] == nil {
+			crossed = append(crossed, threshold)
+		}
+	}
+	if len(crossed) == 0 {
+		return
+	}
+
+	highest := crossed[len(crossed)-1]
+	for _, threshold := range crossed {
+		database.DB.Create(&models.BudgetAlert{
+			BudgetID:         budget.ID,
+			BudgetCategoryID: category.ID,
+			Category:         category.Category,
+			Threshold:        threshold,
+			Spent:            category.Spent,
+			Limit:            category.Limit,
+			Notified:         threshold == highest,
+		})
+	}
+	s.sendCategoryNotification(category, highest)
+}
+
+// SetBudgetAlertThresholds changes the thresholds of a budget (empty restores the defaults)
+func (s *BudgetService) SetBudgetAlertThresholds(budgetID, userID uint, value string) error {
+	normalized, err := normalizeAlertThresholds(value)
+	if err != nil {
+		return err
+	}
+
+	budget, err := s.GetBudgetByID(budgetID, userID)
+	if err != nil {
+		return err
+	}
+	if !s.canModifyBudget(budget, userID) {
+		return ErrUnauthorized
+	}
+
+	if err := database.DB.Model(budget).Update("alert_thresholds", normalized).Error; err != nil {
+		return err
+	}
+
+	for i := range budget.Categories {
+		s.evaluateCategoryAlerts(budget, &budget.Categories[i])
+	}
+	return nil
+}
+
+// SetCategoryAlertThresholds changes the thresholds of a category (empty uses the budget's)
+func (s *BudgetService) SetCategoryAlertThresholds(categoryID, userID uint, value string) error {
+	normalized, err := normalizeAlertThresholds(value)
+	if err != nil {
+		return err
+	}
+
+	var category models.BudgetCategory
+	if err := database.DB.First(&category, categoryID).Error; err != nil {
+		return ErrCategoryNotFound
+	}
+
+	budget, err := s.GetBudgetByID(category.BudgetID, userID)
+	if err != nil {
+		return err
+	}
+	if !s.canModifyBudget(budget, userID) {
+		return ErrUnauthorized
+	}
+
+	if err := database.DB.Model(&category).Update("alert_thresholds", normalized).Error; err != nil {
+		return err
+	}
+
+	s.evaluateCategoryAlerts(budget, &category)
+	return nil
+}
+
+// GetBudgetAlerts returns the alert log of a budget, most recent first
+func (s *BudgetService) GetBudgetAlerts(budgetID, userID uint) ([]models.BudgetAlert, error) {
+	if _, err := s.GetBudgetByID(budgetID, userID); err != nil {
+		return nil, err
+	}
+
+	var alerts []models.BudgetAlert
+	err := database.DB.Where("budget_id = ?", budgetID).
+		Order("created_at DESC, threshold DESC").
+		Find(&alerts).Error
+	return alerts, err
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestParseAlertThresholds(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []float64
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: nil},
+		{name: "sorted and deduplicated", value: "100, 50,75%, 50", want: []float64{50, 75, 100}},
+		{name: "above limit", value: "50,120", want: []float64{50, 120}},
+		{name: "not a number", value: "50,abc", wantErr: true},
+		{name: "zero", value: "0", wantErr: true},
+		{name: "too high", value: "5000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAlertThresholds(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAlertThresholds() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAlertThresholds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertThresholdsFor(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	tests := []struct {
+		name     string
+		budget   string
+		category string
+		want     []float64
+	}{
+		{name: "defaults", want: DefaultBudgetAlertThresholds},
+		{name: "budget thresholds", budget: "50,100", want: []float64{50, 100}},
+		{name: "category overrides budget", budget: "50,100", category: "90,120", want: []float64{90, 120}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AlertThresholdsFor(&models.Budget{AlertThresholds: tt.budget}, &models.BudgetCategory{AlertThresholds: tt.category})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AlertThresholdsFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBudgetService_UpdateCategorySpending_Alerts(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	service := NewBudgetService()
+
+	budget, err := service.CreateBudget(user.ID, nil, 2030, 3, "Mensal", []struct {
+		Category string
+		Limit    float64
+	}{{Category: "Lazer", Limit: 1000}})
+	if err != nil {
+		t.Fatalf("CreateBudget() error = %v", err)
+	}
+	if err := service.SetBudgetAlertThresholds(budget.ID, user.ID, "50,75,100,120"); err != nil {
+		t.Fatalf("SetBudgetAlertThresholds() error = %v", err)
+	}
+
+	notifications := func() int64 {
+		var count int64
+		db.Model(&models.Notification{}).Where("user_id = ?", user.ID).Count(&count)
+		return count
+	}
+
+	steps := []struct {
+		name              string
+		spent             float64
+		wantNotifications int64
+		wantActive        int64
+	}{
+		{name: "below every threshold", spent: 400, wantNotifications: 0, wantActive: 0},
+		{name: "jump over two thresholds notifies once", spent: 800, wantNotifications: 1, wantActive: 2},
+		{name: "same level does not notify again", spent: 850, wantNotifications: 1, wantActive: 2},
+		{name: "drop re-arms the 75% alert", spent: 600, wantNotifications: 1, wantActive: 1},
+		{name: "rising again notifies again", spent: 760, wantNotifications: 2, wantActive: 2},
+		{name: "over the limit", spent: 1300, wantNotifications: 3, wantActive: 4},
+	}
+
+	for _, step := range steps {
+		if err := service.UpdateCategorySpending(budget.ID, "Lazer", step.spent); err != nil {
+			t.Fatalf("%s: UpdateCategorySpending() error = %v", step.name, err)
+		}
+		if got := notifications(); got != step.wantNotifications {
+			t.Errorf("%s: notifications = %d, want %d", step.name, got, step.wantNotifications)
+		}
+		var active int64
+		db.Model(&models.BudgetAlert{}).Where("budget_id = ? AND re_armed_at IS NULL", budget.ID).Count(&active)
+		if active != step.wantActive {
+			t.Errorf("%s: active alerts = %d, want %d", step.name, active, step.wantActive)
+		}
+	}
+
+	alerts, err := service.GetBudgetAlerts(budget.ID, user.ID)
+	if err != nil {
+		t.Fatalf("GetBudgetAlerts() error = %v", err)
+	}
+	if len(alerts) != 5 {
+		t.Errorf("Expected 5 logged alerts, got %d", len(alerts))
+	}
+}
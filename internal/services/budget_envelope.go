@@ -0,0 +1,240 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+var (
+	ErrInvalidMoveAmount = errors.New("valor deve ser maior que zero")
+	ErrInsufficientFunds = errors.New("saldo insuficiente na origem")
+	ErrSameMoveCategory  = errors.New("origem e destino devem ser diferentes")
+)
+
+// EnvelopeSummary is the envelope view of a budget: the month's income, how much of it
+// is assigned to categories and what is still ready to assign
+type EnvelopeSummary struct {
+	Budget        *models.Budget      `json:"budget"`
+	Income        float64             `json:"income"`          // Net income of the budget's accounts in the month
+	Assigned      float64             `json:"assigned"`        // Sum of the category limits
+	ReadyToAssign float64             `json:"ready_to_assign"` // Income not assigned yet (negative when over-assigned)
+	Moves         []models.BudgetMove `json:"moves"`
+}
+
+// budgetMonthIncome returns the net income of the budget's accounts in its month
+func budgetMonthIncome(budget *models.Budget) float64 {
+	accountIDs := budgetAccountIDs(database.DB, budget)
+	if len(accountIDs) == 0 {
+		return 0
+	}
+
+	startOfMonth := time.Date(budget.Year, time.Month(budget.Month), 1, 0, 0, 0, 0, time.Local)
+	endOfMonth := startOfMonth.AddDate(0, 1, 0)
+
+	var total float64
+	database.DB.Model(&models.Income{}).
+		Where("account_id IN ? AND date >= ? AND date < ?", accountIDs, startOfMonth, endOfMonth).
+		Select("COALESCE(SUM(net_amount), 0)").
+		Row().Scan(&total)
+	return total
+}
+
+// GetEnvelopeSummary returns the ready-to-assign pool and the move history of a budget
+func (s *BudgetService) GetEnvelopeSummary(budgetID, userID uint) (*EnvelopeSummary, error) {
+	budget, err := s.GetBudgetByID(budgetID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &EnvelopeSummary{
+		Budget:   budget,
+		Income:   budgetMonthIncome(budget),
+		Assigned: budget.TotalLimit(),
+	}
+	summary.ReadyToAssign = summary.Income - summary.Assigned
+
+	database.DB.Where("budget_id = ?", budget.ID).
+		Preload("User").
+		Order("created_at DESC").
+		Find(&summary.Moves)
+
+	return summary, nil
+}
+
+// MoveBudgetMoney moves an amount between two categories of a budget. A nil source takes
+// the amount from the ready-to-assign pool and a nil destination returns it to the pool.
+// The source can only give what it still has available.
+func (s *BudgetService) MoveBudgetMoney(budgetID, userID uint, fromCategoryID, toCategoryID *uint, amount float64, note string) error {
+	if amount <= 0 {
+		return ErrInvalidMoveAmount
+	}
+	if fromCategoryID == nil && toCategoryID == nil ||
+		fromCategoryID != nil && toCategoryID != nil && *fromCategoryID == *toCategoryID {
+		return ErrSameMoveCategory
+	}
+
+	budget, err := s.GetBudgetByID(budgetID, userID)
+	if err != nil {
+		return err
+	}
+	if !s.canModifyBudget(budget, userID) {
+		return ErrUnauthorized
+	}
+
+	findCategory := func(id *uint) (*models.BudgetCategory, error) {
+		if id == nil {
+			return nil, nil
+		}
+		for i := range budget.Categories {
+			if budget.Categories[i].ID == *id {
+				return &budget.Categories[i], nil
+			}
+		}
+		return nil, ErrCategoryNotFound
+	}
+
+	from, err := findCategory(fromCategoryID)
+	if err != nil {
+		return err
+	}
+	to, err := findCategory(toCategoryID)
+	if err != nil {
+		return err
+	}
+
+	move := models.BudgetMove{
+		BudgetID: budget.ID,
+		UserID:   userID,
+		Amount:   amount,
+		Note:     note,
+	}
+
+	if from == nil {
+		if budgetMonthIncome(budget)-budget.TotalLimit() < amount {
+			return ErrInsufficientFunds
+		}
+	} else {
+		if amount > from.Limit || amount > from.Available() {
+			return ErrInsufficientFunds
+		}
+		move.FromCategoryID = &from.ID
+		move.FromCategory = from.Category
+	}
+	if to != nil {
+		move.ToCategoryID = &to.ID
+		move.ToCategory = to.Category
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if from != nil {
+			if err := tx.Model(from).Update("limit", from.Limit-amount).Error; err != nil {
+				return err
+			}
+			from.Limit -= amount
+		}
+		if to != nil {
+			if err := tx.Model(to).Update("limit", to.Limit+amount).Error; err != nil {
+				return err
+			}
+			to.Limit += amount
+		}
+		return tx.Create(&move).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// New limits change the progress against the alert thresholds and the next month's balances
+	for _, category := range []*models.BudgetCategory{from, to} {
+		if category != nil {
+			s.evaluateCategoryAlerts(budget, category)
+		}
+	}
+	s.refreshNextRollover(budget)
+	return nil
+}
+
+// SetRollover turns envelope rollover on or off for a budget. When on, each category
+// starts with the balance left (or overspent) in the previous month.
+func (s *BudgetService) SetRollover(budgetID, userID uint, enabled bool) error {
+	budget, err := s.GetBudgetByID(budgetID, userID)
+	if err != nil {
+		return err
+	}
+	if !s.canModifyBudget(budget, userID) {
+		return ErrUnauthorized
+	}
+
+	if err := database.DB.Model(budget).Update("rollover", enabled).Error; err != nil {
+		return err
+	}
+	budget.Rollover = enabled
+
+	s.applyRollover(budget)
+	return nil
+}
+
+// adjacentBudget returns the budget with the same owner (user or group) offset months
+// away from the given one, or nil when there is none
+func (s *BudgetService) adjacentBudget(budget *models.Budget, offset int) *models.Budget {
+	date := time.Date(budget.Year, time.Month(budget.Month), 1, 0, 0, 0, 0, time.UTC).AddDate(0, offset, 0)
+
+	query := database.DB.Where("year = ? AND month = ?", date.Year(), int(date.Month()))
+	if budget.GroupID != nil {
+		query = query.Where("group_id = ?", *budget.GroupID)
+	} else {
+		query = query.Where("user_id = ? AND group_id IS NULL", budget.UserID)
+	}
+
+	var adjacent models.Budget
+	if err := query.Preload("Categories").First(&adjacent).Error; err != nil {
+		return nil
+	}
+	return &adjacent
+}
+
+// applyRollover stores in each category the balance carried from the previous month.
+// Budgets without rollover carry nothing. When a carried balance changed, the change then
+// flows to the following months; otherwise they are left alone.
+func (s *BudgetService) applyRollover(budget *models.Budget) {
+	carried := make(map[string]float64)
+	if budget.Rollover {
+		if previous := s.adjacentBudget(budget, -1); previous != nil {
+			for _, category := range previous.Categories {
+				carried[category.Category] = category.Available()
+			}
+		}
+	}
+
+	changed := false
+	for i := range budget.Categories {
+		category := &budget.Categories[i]
+		if category.CarriedOver == carried[category.Category] {
+			continue
+		}
+		database.DB.Model(category).Update("carried_over", carried[category.Category])
+		category.CarriedOver = carried[category.Category]
+		changed = true
+		// The carried balance changes what the category can spend, and so its alerts
+		s.evaluateCategoryAlerts(budget, category)
+	}
+
+	if changed {
+		s.refreshNextRollover(budget)
+	}
+}
+
+// refreshNextRollover recalculates the carried balances of the next month's budget
+// after the balances of a budget changed
+func (s *BudgetService) refreshNextRollover(budget *models.Budget) {
+	next := s.adjacentBudget(budget, 1)
+	if next == nil || !next.Rollover {
+		return
+	}
+	s.applyRollover(next)
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+// budgetCategories builds the categories argument of CreateBudget
+func budgetCategories(limits map[string]float64) []struct {
+	Category string
+	Limit    float64
+} {
+	var categories []struct {
+		Category string
+		Limit    float64
+	}
+	for name, limit := range limits {
+		categories = append(categories, struct {
+			Category string
+			Limit    float64
+		}{Category: name, Limit: limit})
+	}
+	return categories
+}
+
+// categoryByName returns the category of a budget with the given name
+func categoryByName(t *testing.T, budgetID uint, name string) models.BudgetCategory {
+	t.Helper()
+	var category models.BudgetCategory
+	if err := database.DB.Where("budget_id = ? AND category = ?", budgetID, name).First(&category).Error; err != nil {
+		t.Fatalf("category %s not found: %v", name, err)
+	}
+	return category
+}
+
+func TestBudgetService_MoveBudgetMoney(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+	db.Create(&models.Income{AccountID: account.ID, Date: time.Date(2030, 3, 5, 0, 0, 0, 0, time.Local), NetAmount: 3000})
+
+	service := NewBudgetService()
+	budget, err := service.CreateBudget(user.ID, nil, 2030, 3, "Mensal", budgetCategories(map[string]float64{
+		"Mercado": 1000,
+		"Lazer":   500,
+	}))
+	if err != nil {
+		t.Fatalf("CreateBudget() error = %v", err)
+	}
+	service.UpdateCategorySpending(budget.ID, "Lazer", 450)
+
+	mercado := categoryByName(t, budget.ID, "Mercado")
+	lazer := categoryByName(t, budget.ID, "Lazer")
+
+	tests := []struct {
+		name    string
+		from    *uint
+		to      *uint
+		amount  float64
+		wantErr error
+	}{
+		{name: "from ready to assign", to: &mercado.ID, amount: 1200},
+		{name: "more than ready to assign", to: &lazer.ID, amount: 400, wantErr: ErrInsufficientFunds},
+		{name: "between categories", from: &mercado.ID, to: &lazer.ID, amount: 200},
+		{name: "more than available", from: &lazer.ID, to: &mercado.ID, amount: 300, wantErr: ErrInsufficientFunds},
+		{name: "back to ready to assign", from: &lazer.ID, amount: 100},
+		{name: "same category", from: &mercado.ID, to: &mercado.ID, amount: 10, wantErr: ErrSameMoveCategory},
+		{name: "invalid amount", from: &mercado.ID, to: &lazer.ID, amount: 0, wantErr: ErrInvalidMoveAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.MoveBudgetMoney(budget.ID, user.ID, tt.from, tt.to, tt.amount, "")
+			if err != tt.wantErr {
+				t.Errorf("MoveBudgetMoney() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	summary, err := service.GetEnvelopeSummary(budget.ID, user.ID)
+	if err != nil {
+		t.Fatalf("GetEnvelopeSummary() error = %v", err)
+	}
+
+	want := map[string]float64{"Mercado": 2000, "Lazer": 600}
+	for _, category := range summary.Budget.Categories {
+		if math.Abs(category.Limit-want[category.Category]) > 0.01 {
+			t.Errorf("%s limit = %.2f, want %.2f", category.Category, category.Limit, want[category.Category])
+		}
+	}
+	if math.Abs(summary.ReadyToAssign-400) > 0.01 {
+		t.Errorf("ReadyToAssign = %.2f, want 400.00", summary.ReadyToAssign)
+	}
+	if len(summary.Moves) != 3 {
+		t.Errorf("Expected 3 moves in history, got %d", len(summary.Moves))
+	}
+}
+
+func TestBudgetService_Rollover(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	service := NewBudgetService()
+
+	march, err := service.CreateBudget(user.ID, nil, 2030, 3, "Mensal", budgetCategories(map[string]float64{
+		"Mercado": 1000,
+		"Lazer":   300,
+	}))
+	if err != nil {
+		t.Fatalf("CreateBudget() error = %v", err)
+	}
+	if err := service.SetRollover(march.ID, user.ID, true); err != nil {
+		t.Fatalf("SetRollover() error = %v", err)
+	}
+	service.UpdateCategorySpending(march.ID, "Mercado", 700)
+	service.UpdateCategorySpending(march.ID, "Lazer", 450)
+
+	april, err := service.CopyFromPreviousMonth(user.ID, nil, 2030, 4)
+	if err != nil {
+		t.Fatalf("CopyFromPreviousMonth() error = %v", err)
+	}
+	if !april.Rollover {
+		t.Error("Rollover should be copied from the previous month")
+	}
+
+	tests := []struct {
+		category string
+		want     float64
+	}{
+		{"Mercado", 300},
+		{"Lazer", -150},
+	}
+	for _, tt := range tests {
+		if got := categoryByName(t, april.ID, tt.category).CarriedOver; math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("%s CarriedOver = %.2f, want %.2f", tt.category, got, tt.want)
+		}
+	}
+
+	// Late spending in March flows into April's balance
+	service.UpdateCategorySpending(march.ID, "Mercado", 900)
+	if got := categoryByName(t, april.ID, "Mercado").CarriedOver; math.Abs(got-100) > 0.01 {
+		t.Errorf("Mercado CarriedOver after update = %.2f, want 100.00", got)
+	}
+
+	// A change flows through every following month whose carried balance changes
+	may, err := service.CopyFromPreviousMonth(user.ID, nil, 2030, 5)
+	if err != nil {
+		t.Fatalf("CopyFromPreviousMonth() error = %v", err)
+	}
+	if got := categoryByName(t, may.ID, "Mercado").CarriedOver; math.Abs(got-1100) > 0.01 {
+		t.Errorf("May Mercado CarriedOver = %.2f, want 1100.00", got)
+	}
+	service.UpdateCategorySpending(march.ID, "Mercado", 950)
+	if got := categoryByName(t, may.ID, "Mercado").CarriedOver; math.Abs(got-1050) > 0.01 {
+		t.Errorf("May Mercado CarriedOver after March update = %.2f, want 1050.00", got)
+	}
+
+	// Turning rollover off clears the carried balances
+	if err := service.SetRollover(april.ID, user.ID, false); err != nil {
+		t.Fatalf("SetRollover() error = %v", err)
+	}
+	if got := categoryByName(t, april.ID, "Lazer").CarriedOver; got != 0 {
+		t.Errorf("Lazer CarriedOver after disabling = %.2f, want 0", got)
+	}
+}
+
+func TestBudgetService_RolloverAlerts(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	service := NewBudgetService()
+
+	march, err := service.CreateBudget(user.ID, nil, 2030, 3, "Mensal", budgetCategories(map[string]float64{
+		"Mercado": 1000,
+		"Lazer":   300,
+	}))
+	if err != nil {
+		t.Fatalf("CreateBudget() error = %v", err)
+	}
+	if err := service.SetRollover(march.ID, user.ID, true); err != nil {
+		t.Fatalf("SetRollover() error = %v", err)
+	}
+	service.UpdateCategorySpending(march.ID, "Mercado", 500)
+	service.UpdateCategorySpending(march.ID, "Lazer", 450)
+
+	april, err := service.CopyFromPreviousMonth(user.ID, nil, 2030, 4)
+	if err != nil {
+		t.Fatalf("CopyFromPreviousMonth() error = %v", err)
+	}
+
+	// Mercado carried 500 in: 900 of 1500 is 60%, though it's 90% of the limit
+	service.UpdateCategorySpending(april.ID, "Mercado", 900)
+	// Lazer carried 150 of overspending: 130 of the 150 left is 87%, though it's 43% of the limit
+	service.UpdateCategorySpending(april.ID, "Lazer", 130)
+
+	alerts := func(category string) []float64 {
+		var thresholds []float64
+		db.Model(&models.BudgetAlert{}).Where("budget_id = ? AND category = ?", april.ID, category).
+			Order("threshold").Pluck("threshold", &thresholds)
+		return thresholds
+	}
+	if got := alerts("Mercado"); len(got) != 0 {
+		t.Errorf("Mercado alerts = %v, want none while the carried balance covers the spending", got)
+	}
+	if got := alerts("Lazer"); len(got) != 1 || got[0] != 80 {
+		t.Errorf("Lazer alerts = %v, want the 80%% alert of the envelope", got)
+	}
+
+	// Overspending March further leaves April's Lazer envelope empty
+	service.UpdateCategorySpending(march.ID, "Lazer", 600)
+	if got := alerts("Lazer"); len(got) != 2 || got[1] != 100 {
+		t.Errorf("Lazer alerts after the carry-in grew = %v, want the 100%% alert too", got)
+	}
+}
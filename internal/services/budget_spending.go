@@ -0,0 +1,174 @@
+package services
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/models"
+)
+
+// SpendingSource identifies where an amount counted against a budget comes from
+type SpendingSource string
+
+const (
+	SpendingSourceFixedPayment SpendingSource = "fixed_payment"    // Fixed expenses paid in the month
+	SpendingSourceVariable     SpendingSource = "variable_expense" // Active variable expenses created in the month
+	SpendingSourceInstallment  SpendingSource = "installment"      // Credit card installments due in the month
+	SpendingSourceBill         SpendingSource = "bill"             // Bills due in the month
+)
+
+// CategorySpending is the actual spending of a category in a month, split by source
+type CategorySpending struct {
+	Category string                     `json:"category"`
+	Total    float64                    `json:"total"`
+	BySource map[SpendingSource]float64 `json:"by_source"`
+}
+
+func (cs *CategorySpending) add(source SpendingSource, amount float64) {
+	cs.Total += amount
+	cs.BySource[source] += amount
+}
+
+// categoryAmount is a row of the per-category aggregations
+type categoryAmount struct {
+	Category string
+	Total    float64
+}
+
+// GetCategorySpending aggregates every spending source of the given accounts in a month,
+// keyed by category. This is the single source of truth for budget actuals.
+func GetCategorySpending(db *gorm.DB, accountIDs []uint, year, month int) map[string]*CategorySpending {
+	startOfMonth := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local)
+	return GetCategorySpendingBetween(db, accountIDs, startOfMonth, startOfMonth.AddDate(0, 1, 0))
+}
+
+// GetCategorySpendingBetween aggregates every spending source of the given accounts in
+// [from, to), keyed by category. Fixed payments and installments refer to a month rather
+// than a date: they count when the first day of their month is inside the range, so
+// adjacent ranges never count them twice.
+func GetCategorySpendingBetween(db *gorm.DB, accountIDs []uint, from, to time.Time) map[string]*CategorySpending {
+	result := make(map[string]*CategorySpending)
+	if len(accountIDs) == 0 {
+		return result
+	}
+
+	add := func(source SpendingSource, category string, amount float64) {
+		if amount == 0 {
+			return
+		}
+		spending, ok := result[category]
+		if !ok {
+			spending = &CategorySpending{Category: category, BySource: make(map[SpendingSource]float64)}
+			result[category] = spending
+		}
+		spending.add(source, amount)
+	}
+
+	// Months whose first day is inside the range, as year*100+month
+	firstMonth, lastMonth := monthKeysBetween(from, to)
+
+	// Fixed expenses count when paid
+	var rows []categoryAmount
+	db.Table("expense_payments").
+		Select("expenses.category AS category, COALESCE(SUM(expense_payments.amount), 0) AS total").
+		Joins("JOIN expenses ON expenses.id = expense_payments.expense_id").
+		Where("expenses.account_id IN ? AND expenses.type = ? AND expense_payments.year * 100 + expense_payments.month BETWEEN ? AND ?",
+			accountIDs, models.ExpenseTypeFixed, firstMonth, lastMonth).
+		Group("expenses.category").
+		Scan(&rows)
+	for _, row := range rows {
+		add(SpendingSourceFixedPayment, row.Category, row.Total)
+	}
+
+	// Variable expenses count in the month they happened
+	rows = nil
+	db.Model(&models.Expense{}).
+		Select("category, COALESCE(SUM(amount), 0) AS total").
+		Where("account_id IN ? AND type = ? AND active = ? AND created_at >= ? AND created_at < ?",
+			accountIDs, models.ExpenseTypeVariable, true, from, to).
+		Group("category").
+		Scan(&rows)
+	for _, row := range rows {
+		add(SpendingSourceVariable, row.Category, row.Total)
+	}
+
+	// Installments count in each month they are due
+	var installments []models.Installment
+	db.Joins("JOIN credit_cards ON credit_cards.id = installments.credit_card_id").
+		Where("credit_cards.account_id IN ?", accountIDs).
+		Find(&installments)
+	for _, inst := range installments {
+		for _, ym := range installmentMonths(&inst) {
+			if key := ym[0]*100 + ym[1]; key >= firstMonth && key <= lastMonth {
+				add(SpendingSourceInstallment, inst.Category, inst.InstallmentAmount)
+			}
+		}
+	}
+
+	// Bills count in the month they are due
+	rows = nil
+	db.Model(&models.Bill{}).
+		Select("category, COALESCE(SUM(amount), 0) AS total").
+		Where("account_id IN ? AND due_date >= ? AND due_date < ?", accountIDs, from, to).
+		Group("category").
+		Scan(&rows)
+	for _, row := range rows {
+		add(SpendingSourceBill, row.Category, row.Total)
+	}
+
+	return result
+}
+
+// categoryTotal returns the total spending of a category, zero when nothing was spent
+func categoryTotal(spending map[string]*CategorySpending, category string) float64 {
+	if categorySpending, ok := spending[category]; ok {
+		return categorySpending.Total
+	}
+	return 0
+}
+
+// monthKeysBetween returns the first and last months (year*100+month) whose first day is
+// in [from, to). When there is none, first is greater than last.
+func monthKeysBetween(from, to time.Time) (int, int) {
+	first := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location())
+	if first.Before(from) {
+		first = first.AddDate(0, 1, 0)
+	}
+	last := time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, to.Location())
+	if !last.Before(to) {
+		last = last.AddDate(0, -1, 0)
+	}
+	return first.Year()*100 + int(first.Month()), last.Year()*100 + int(last.Month())
+}
+
+// installmentMonths returns the (year, month) pairs in which an installment is due
+func installmentMonths(inst *models.Installment) [][2]int {
+	months := make([][2]int, 0, inst.TotalInstallments)
+	start := time.Date(inst.StartDate.Year(), inst.StartDate.Month(), 1, 0, 0, 0, 0, time.Local)
+	for i := 0; i < inst.TotalInstallments; i++ {
+		date := start.AddDate(0, i, 0)
+		months = append(months, [2]int{date.Year(), int(date.Month())})
+	}
+	return months
+}
+
+// budgetAccountIDs returns the accounts whose spending counts against a budget
+func budgetAccountIDs(db *gorm.DB, budget *models.Budget) []uint {
+	return ownerAccountIDs(db, budget.UserID, budget.GroupID)
+}
+
+// ownerAccountIDs returns the accounts of a budget owner: the group's joint accounts for
+// group budgets, the user's individual accounts otherwise. Individual accounts linked to a
+// group count for their owner only, as in RefreshAccountSpending.
+func ownerAccountIDs(db *gorm.DB, userID uint, groupID *uint) []uint {
+	var accountIDs []uint
+	query := db.Model(&models.Account{})
+	if groupID != nil {
+		query = query.Where("group_id = ? AND type = ?", *groupID, models.AccountTypeJoint)
+	} else {
+		query = query.Where("user_id = ? AND (group_id IS NULL OR type = ?)", userID, models.AccountTypeIndividual)
+	}
+	query.Pluck("id", &accountIDs)
+	return accountIDs
+}
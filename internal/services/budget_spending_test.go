@@ -0,0 +1,143 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestGetCategorySpending(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+
+	inMonth := time.Date(2030, 3, 15, 12, 0, 0, 0, time.Local)
+	nextMonth := time.Date(2030, 4, 2, 12, 0, 0, 0, time.Local)
+
+	// Paid fixed expense
+	rent := &models.Expense{AccountID: account.ID, Name: "Aluguel", Amount: 1500, Type: models.ExpenseTypeFixed, Category: "Moradia", Active: true}
+	db.Create(rent)
+	db.Create(&models.ExpensePayment{ExpenseID: rent.ID, Year: 2030, Month: 3, PaidAt: inMonth, Amount: 1500})
+
+	// Variable expenses: only active ones created in the month count
+	db.Create(&models.Expense{Model: gorm.Model{CreatedAt: inMonth}, AccountID: account.ID, Name: "Mercado", Amount: 300, Type: models.ExpenseTypeVariable, Category: "Alimentação", Active: true})
+	db.Create(&models.Expense{Model: gorm.Model{CreatedAt: nextMonth}, AccountID: account.ID, Name: "Feira", Amount: 80, Type: models.ExpenseTypeVariable, Category: "Alimentação", Active: true})
+	inactive := &models.Expense{Model: gorm.Model{CreatedAt: inMonth}, AccountID: account.ID, Name: "Padaria", Amount: 20, Type: models.ExpenseTypeVariable, Category: "Alimentação", Active: true}
+	db.Create(inactive)
+	db.Model(inactive).Update("active", false)
+
+	// Installment started in January with 3 parcels is due in March, not with 2 parcels
+	card := &models.CreditCard{AccountID: account.ID, Name: "Cartão", ClosingDay: 1, DueDay: 10}
+	db.Create(card)
+	db.Create(&models.Installment{CreditCardID: card.ID, Description: "TV", TotalAmount: 300, InstallmentAmount: 100, TotalInstallments: 3, StartDate: time.Date(2030, 1, 10, 0, 0, 0, 0, time.Local), Category: "Alimentação"})
+	db.Create(&models.Installment{CreditCardID: card.ID, Description: "Fone", TotalAmount: 200, InstallmentAmount: 100, TotalInstallments: 2, StartDate: time.Date(2030, 1, 10, 0, 0, 0, 0, time.Local), Category: "Alimentação"})
+
+	// Bill due in the month
+	db.Create(&models.Bill{AccountID: account.ID, Name: "Condomínio", Amount: 400, DueDate: inMonth, Category: "Moradia"})
+
+	spending := GetCategorySpending(db, []uint{account.ID}, 2030, 3)
+
+	tests := []struct {
+		category string
+		source   SpendingSource
+		want     float64
+	}{
+		{"Moradia", SpendingSourceFixedPayment, 1500},
+		{"Moradia", SpendingSourceBill, 400},
+		{"Alimentação", SpendingSourceVariable, 300},
+		{"Alimentação", SpendingSourceInstallment, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.category+"/"+string(tt.source), func(t *testing.T) {
+			got := spending[tt.category]
+			if got == nil {
+				t.Fatalf("no spending for %s", tt.category)
+			}
+			if math.Abs(got.BySource[tt.source]-tt.want) > 0.01 {
+				t.Errorf("BySource[%s] = %.2f, want %.2f", tt.source, got.BySource[tt.source], tt.want)
+			}
+		})
+	}
+
+	if math.Abs(spending["Moradia"].Total-1900) > 0.01 {
+		t.Errorf("Moradia total = %.2f, want 1900.00", spending["Moradia"].Total)
+	}
+	if math.Abs(spending["Alimentação"].Total-400) > 0.01 {
+		t.Errorf("Alimentação total = %.2f, want 400.00", spending["Alimentação"].Total)
+	}
+}
+
+func TestBudgetService_RefreshAccountSpending(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	personal := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+	group := testutil.CreateTestGroup(db, "Família", user.ID)
+	testutil.CreateTestGroupMember(db, group.ID, user.ID, "admin")
+	joint := testutil.CreateTestAccount(db, "Conjunta", models.AccountTypeJoint, user.ID, &group.ID)
+	// Individual account linked to the group: it counts for its owner only
+	linked := testutil.CreateTestAccount(db, "Individual do grupo", models.AccountTypeIndividual, user.ID, &group.ID)
+
+	now := time.Now()
+	year, month := now.Year(), int(now.Month())
+
+	service := NewBudgetService()
+	categories := []struct {
+		Category string
+		Limit    float64
+	}{{Category: "Alimentação", Limit: 1000}}
+	personalBudget, err := service.CreateBudget(user.ID, nil, year, month, "Pessoal", categories)
+	if err != nil {
+		t.Fatalf("CreateBudget() error = %v", err)
+	}
+	groupBudget, err := service.CreateBudget(user.ID, &group.ID, year, month, "Família", categories)
+	if err != nil {
+		t.Fatalf("CreateBudget() error = %v", err)
+	}
+
+	db.Create(&models.Expense{AccountID: personal.ID, Name: "Mercado", Amount: 300, Type: models.ExpenseTypeVariable, Category: "Alimentação", Active: true})
+	db.Create(&models.Expense{AccountID: joint.ID, Name: "Restaurante", Amount: 850, Type: models.ExpenseTypeVariable, Category: "Alimentação", Active: true})
+	db.Create(&models.Expense{AccountID: linked.ID, Name: "Padaria", Amount: 100, Type: models.ExpenseTypeVariable, Category: "Alimentação", Active: true})
+
+	for _, accountID := range []uint{personal.ID, joint.ID, linked.ID} {
+		if err := service.RefreshAccountSpending(accountID, "Alimentação", year, month); err != nil {
+			t.Fatalf("RefreshAccountSpending() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		budgetID  uint
+		wantSpent float64
+	}{
+		{name: "personal budget counts individual accounts", budgetID: personalBudget.ID, wantSpent: 400},
+		{name: "group budget counts joint accounts", budgetID: groupBudget.ID, wantSpent: 850},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var category models.BudgetCategory
+			db.Where("budget_id = ?", tt.budgetID).First(&category)
+			if math.Abs(category.Spent-tt.wantSpent) > 0.01 {
+				t.Errorf("Spent = %.2f, want %.2f", category.Spent, tt.wantSpent)
+			}
+		})
+	}
+
+	// The group budget crossed 80%: members are notified once
+	var count int64
+	db.Model(&models.Notification{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 notification, got %d", count)
+	}
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+var ErrNoSpendingHistory = errors.New("não há gastos categorizados suficientes para sugerir um orçamento")
+
+const (
+	// DefaultSuggestionMonths is how many complete months of history are analyzed by default
+	DefaultSuggestionMonths = 6
+	// maxSuggestionMonths caps the history analyzed for a suggestion
+	maxSuggestionMonths = 24
+	// DefaultSuggestionPercentile is the percentile of the monthly spending used as limit (the median)
+	DefaultSuggestionPercentile = 50.0
+	// highVolatilityThreshold is the coefficient of variation above which a category is flagged
+	highVolatilityThreshold = 0.5
+	// suggestionRounding rounds suggested limits up to a multiple of this value
+	suggestionRounding = 10.0
+)
+
+// CategorySuggestion is the limit proposed for a category from its monthly spending history
+type CategorySuggestion struct {
+	Category       string    `json:"category"`
+	SuggestedLimit float64   `json:"suggested_limit"`
+	Median         float64   `json:"median"`
+	Average        float64   `json:"average"`
+	Min            float64   `json:"min"`
+	Max            float64   `json:"max"`
+	Monthly        []float64 `json:"monthly"`         // Spending of each analyzed month, oldest first
+	Volatility     float64   `json:"volatility"`      // Coefficient of variation (standard deviation / average)
+	HighVolatility bool      `json:"high_volatility"` // Spending varies too much for the limit to be reliable
+}
+
+// BudgetSuggestion holds the suggested limits of all categories with spending in the analyzed months
+type BudgetSuggestion struct {
+	Months     int                  `json:"months"`
+	Percentile float64              `json:"percentile"`
+	From       time.Time            `json:"from"` // First analyzed month
+	To         time.Time            `json:"to"`   // Last analyzed month
+	Categories []CategorySuggestion `json:"categories"`
+	Total      float64              `json:"total"`
+}
+
+// SuggestBudget proposes category limits for a user (or a group when groupID is set) from the
+// categorized spending of the last complete months before now. Each limit is the given
+// percentile of the monthly spending (50 = median), rounded up; months without spending count as zero.
+func (s *BudgetService) SuggestBudget(userID uint, groupID *uint, months int, percentile float64, now time.Time) (*BudgetSuggestion, error) {
+	if months <= 0 {
+		months = DefaultSuggestionMonths
+	}
+	if months > maxSuggestionMonths {
+		months = maxSuggestionMonths
+	}
+	if percentile <= 0 || percentile > 100 {
+		percentile = DefaultSuggestionPercentile
+	}
+
+	if groupID != nil && !s.groupService.IsGroupMember(*groupID, userID) {
+		return nil, ErrUnauthorized
+	}
+	accountIDs := ownerAccountIDs(database.DB, userID, groupID)
+
+	currentMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	suggestion := &BudgetSuggestion{
+		Months:     months,
+		Percentile: percentile,
+		From:       currentMonth.AddDate(0, -months, 0),
+		To:         currentMonth.AddDate(0, -1, 0),
+	}
+
+	monthly := make(map[string][]float64)
+	for i := 0; i < months; i++ {
+		month := suggestion.From.AddDate(0, i, 0)
+		for category, spending := range GetCategorySpending(database.DB, accountIDs, month.Year(), int(month.Month())) {
+			if category == "" || spending.Total <= 0 {
+				continue
+			}
+			if monthly[category] == nil {
+				monthly[category] = make([]float64, months)
+			}
+			monthly[category][i] = spending.Total
+		}
+	}
+
+	for category, values := range monthly {
+		item := CategorySuggestion{
+			Category: category,
+			Monthly:  values,
+			Median:   percentileOf(values, 50),
+			Average:  averageOf(values),
+		}
+		item.Min, item.Max = minMaxOf(values)
+		item.SuggestedLimit = math.Ceil(percentileOf(values, percentile)/suggestionRounding) * suggestionRounding
+		if item.Average > 0 {
+			item.Volatility = standardDeviationOf(values, item.Average) / item.Average
+		}
+		item.HighVolatility = item.Volatility > highVolatilityThreshold
+
+		suggestion.Categories = append(suggestion.Categories, item)
+		suggestion.Total += item.SuggestedLimit
+	}
+	sort.Slice(suggestion.Categories, func(i, j int) bool {
+		a, b := suggestion.Categories[i], suggestion.Categories[j]
+		if a.SuggestedLimit != b.SuggestedLimit {
+			return a.SuggestedLimit > b.SuggestedLimit
+		}
+		return a.Category < b.Category
+	})
+
+	return suggestion, nil
+}
+
+// CreateBudgetFromSuggestion creates the budget of a month with the suggested category limits
+func (s *BudgetService) CreateBudgetFromSuggestion(userID uint, groupID *uint, year, month int, name string, months int, percentile float64) (*models.Budget, error) {
+	suggestion, err := s.SuggestBudget(userID, groupID, months, percentile, time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		return nil, err
+	}
+
+	var categories []struct {
+		Category string
+		Limit    float64
+	}
+	for _, item := range suggestion.Categories {
+		if item.SuggestedLimit <= 0 {
+			continue
+		}
+		categories = append(categories, struct {
+			Category string
+			Limit    float64
+		}{
+			Category: item.Category,
+			Limit:    item.SuggestedLimit,
+		})
+	}
+	if len(categories) == 0 {
+		return nil, ErrNoSpendingHistory
+	}
+
+	return s.CreateBudget(userID, groupID, year, month, name, categories)
+}
+
+// percentileOf returns the p-th percentile of values using linear interpolation
+func percentileOf(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+func averageOf(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func standardDeviationOf(values []float64, average float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += (v - average) * (v - average)
+	}
+	return math.Sqrt(sum / float64(len(values)))
+}
+
+func minMaxOf(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	min, max := values[0], values[0]
+	for _, v := range values[1:] {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	return min, max
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestPercentileOf(t *testing.T) {
+	values := []float64{400, 100, 300, 200}
+
+	tests := []struct {
+		percentile float64
+		want       float64
+	}{
+		{0, 100},
+		{50, 250},
+		{75, 325},
+		{100, 400},
+	}
+
+	for _, tt := range tests {
+		got := percentileOf(values, tt.percentile)
+		if math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("percentileOf(%.0f) = %.2f, want %.2f", tt.percentile, got, tt.want)
+		}
+	}
+
+	if got := percentileOf(nil, 50); got != 0 {
+		t.Errorf("percentileOf(nil) = %.2f, want 0", got)
+	}
+}
+
+func TestBudgetService_SuggestBudget(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+
+	variable := func(category string, amount float64, year, month int) {
+		db.Create(&models.Expense{
+			Model:     gorm.Model{CreatedAt: time.Date(year, time.Month(month), 15, 12, 0, 0, 0, time.Local)},
+			AccountID: account.ID, Name: category, Amount: amount, Type: models.ExpenseTypeVariable, Category: category, Active: true,
+		})
+	}
+
+	// Steady groceries and an occasional trip over the 4 months before May 2030
+	for month, amount := range map[int]float64{1: 800, 2: 820, 3: 790, 4: 845} {
+		variable("Alimentação", amount, 2030, month)
+	}
+	variable("Viagem", 2000, 2030, 2)
+	// Spending of the current month is not analyzed
+	variable("Alimentação", 5000, 2030, 5)
+
+	service := NewBudgetService()
+	now := time.Date(2030, 5, 10, 0, 0, 0, 0, time.Local)
+
+	suggestion, err := service.SuggestBudget(user.ID, nil, 4, 50, now)
+	if err != nil {
+		t.Fatalf("SuggestBudget() error = %v", err)
+	}
+	if len(suggestion.Categories) != 2 {
+		t.Fatalf("expected 2 categories, got %d", len(suggestion.Categories))
+	}
+
+	tests := []struct {
+		category       string
+		suggestedLimit float64
+		highVolatility bool
+	}{
+		// Median of 790, 800, 820, 845 is 810
+		{"Alimentação", 810, false},
+		// Spent in a single month: median 0, flagged as volatile
+		{"Viagem", 0, true},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.category, func(t *testing.T) {
+			got := suggestion.Categories[i]
+			if got.Category != tt.category {
+				t.Fatalf("category = %s, want %s", got.Category, tt.category)
+			}
+			if math.Abs(got.SuggestedLimit-tt.suggestedLimit) > 0.01 {
+				t.Errorf("suggested limit = %.2f, want %.2f", got.SuggestedLimit, tt.suggestedLimit)
+			}
+			if got.HighVolatility != tt.highVolatility {
+				t.Errorf("high volatility = %v, want %v (volatility %.2f)", got.HighVolatility, tt.highVolatility, got.Volatility)
+			}
+		})
+	}
+
+	// A higher percentile leaves more room, rounded up to a multiple of 10
+	p90, _ := service.SuggestBudget(user.ID, nil, 4, 90, now)
+	for _, category := range p90.Categories {
+		if category.Category == "Alimentação" && math.Abs(category.SuggestedLimit-840) > 0.01 {
+			t.Errorf("p90 limit = %.2f, want 840.00", category.SuggestedLimit)
+		}
+	}
+
+	budget, err := service.CreateBudgetFromSuggestion(user.ID, nil, 2030, 5, "Sugerido", 4, 50)
+	if err != nil {
+		t.Fatalf("CreateBudgetFromSuggestion() error = %v", err)
+	}
+	// Categories with a zero suggestion are left out
+	if len(budget.Categories) != 1 || budget.Categories[0].Category != "Alimentação" || budget.Categories[0].Limit != 810 {
+		t.Errorf("budget categories = %+v, want only Alimentação with limit 810", budget.Categories)
+	}
+}
+
+func TestBudgetService_CreateBudgetFromSuggestion_NoHistory(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+
+	_, err := NewBudgetService().CreateBudgetFromSuggestion(user.ID, nil, 2030, 5, "Sugerido", 6, 50)
+	if err != ErrNoSpendingHistory {
+		t.Errorf("expected ErrNoSpendingHistory, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+var ErrInvalidReportRange = errors.New("intervalo inválido (o mês final deve ser posterior ao inicial e o intervalo de no máximo 36 meses)")
+
+// maxVarianceMonths caps the range of a variance report
+const maxVarianceMonths = 36
+
+// VarianceLine compares the planned and actual spending of a category.
+// A positive variance means spending stayed under the plan.
+type VarianceLine struct {
+	Category           string  `json:"category"`
+	Planned            float64 `json:"planned"`
+	Actual             float64 `json:"actual"`
+	Variance           float64 `json:"variance"`            // Planned - Actual
+	VariancePercentage float64 `json:"variance_percentage"` // Variance as a percentage of the plan
+}
+
+func (l *VarianceLine) add(planned, actual float64) {
+	l.Planned += planned
+	l.Actual += actual
+	l.Variance = l.Planned - l.Actual
+	l.VariancePercentage = percentageOf(l.Variance, l.Planned)
+}
+
+// VarianceMonth is the budget-vs-actual comparison of one month
+type VarianceMonth struct {
+	Year  int            `json:"year"`
+	Month int            `json:"month"`
+	Lines []VarianceLine `json:"lines"`
+	Total VarianceLine   `json:"total"`
+}
+
+// VarianceReport compares planned and actual spending per category over a range of months
+type VarianceReport struct {
+	FromYear   int             `json:"from_year"`
+	FromMonth  int             `json:"from_month"`
+	ToYear     int             `json:"to_year"`
+	ToMonth    int             `json:"to_month"`
+	Months     []VarianceMonth `json:"months"`     // Months that have a budget, oldest first
+	Categories []VarianceLine  `json:"categories"` // Totals of each category over the range
+	Total      VarianceLine    `json:"total"`
+}
+
+// GetVarianceReport builds the budget-vs-actual report of the personal budgets of a user,
+// or of a group's budgets when groupID is set, from one month to another (inclusive).
+// The plan is the limit assigned to each category and the actual is its tracked spending.
+func (s *BudgetService) GetVarianceReport(userID uint, groupID *uint, fromYear, fromMonth, toYear, toMonth int) (*VarianceReport, error) {
+	from := time.Date(fromYear, time.Month(fromMonth), 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(toYear, time.Month(toMonth), 1, 0, 0, 0, 0, time.UTC)
+	if fromMonth < 1 || fromMonth > 12 || toMonth < 1 || toMonth > 12 ||
+		to.Before(from) || !to.Before(from.AddDate(0, maxVarianceMonths, 0)) {
+		return nil, ErrInvalidReportRange
+	}
+
+	query := database.DB.Where("year * 100 + month BETWEEN ? AND ?", fromYear*100+fromMonth, toYear*100+toMonth)
+	if groupID != nil {
+		if !s.groupService.IsGroupMember(*groupID, userID) {
+			return nil, ErrUnauthorized
+		}
+		query = query.Where("group_id = ?", *groupID)
+	} else {
+		query = query.Where("user_id = ? AND group_id IS NULL", userID)
+	}
+
+	var budgets []models.Budget
+	if err := query.Preload("Categories").Order("year, month").Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+
+	report := &VarianceReport{
+		FromYear:  fromYear,
+		FromMonth: fromMonth,
+		ToYear:    toYear,
+		ToMonth:   toMonth,
+	}
+
+	byCategory := make(map[string]*VarianceLine)
+	for _, budget := range budgets {
+		// Group members may keep more than one budget for the same month
+		if n := len(report.Months); n == 0 || report.Months[n-1].Year != budget.Year || report.Months[n-1].Month != budget.Month {
+			report.Months = append(report.Months, VarianceMonth{Year: budget.Year, Month: budget.Month})
+		}
+		month := &report.Months[len(report.Months)-1]
+
+		for _, category := range budget.Categories {
+			line := VarianceLine{Category: category.Category}
+			line.add(category.Limit, category.Spent)
+			month.Lines = append(month.Lines, line)
+			month.Total.add(category.Limit, category.Spent)
+
+			if byCategory[category.Category] == nil {
+				byCategory[category.Category] = &VarianceLine{Category: category.Category}
+			}
+			byCategory[category.Category].add(category.Limit, category.Spent)
+			report.Total.add(category.Limit, category.Spent)
+		}
+	}
+
+	for _, line := range byCategory {
+		report.Categories = append(report.Categories, *line)
+	}
+	sort.Slice(report.Categories, func(i, j int) bool {
+		return report.Categories[i].Category < report.Categories[j].Category
+	})
+
+	return report, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestBudgetService_GetVarianceReport(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	other := testutil.CreateTestUser(db, "other@example.com", "Other User", "hash")
+
+	createBudget := func(userID uint, year, month int, categories ...models.BudgetCategory) {
+		db.Create(&models.Budget{UserID: userID, Year: year, Month: month, Name: "Mensal", Status: models.BudgetStatusActive, Categories: categories})
+	}
+	createBudget(user.ID, 2030, 1,
+		models.BudgetCategory{Category: "Alimentação", Limit: 1000, Spent: 800},
+		models.BudgetCategory{Category: "Lazer", Limit: 200, Spent: 300},
+	)
+	createBudget(user.ID, 2030, 2,
+		models.BudgetCategory{Category: "Alimentação", Limit: 1000, Spent: 1100},
+	)
+	// Outside the range and of another user
+	createBudget(user.ID, 2030, 4, models.BudgetCategory{Category: "Alimentação", Limit: 1000, Spent: 50})
+	createBudget(other.ID, 2030, 1, models.BudgetCategory{Category: "Alimentação", Limit: 500, Spent: 500})
+
+	service := NewBudgetService()
+	report, err := service.GetVarianceReport(user.ID, nil, 2030, 1, 2030, 3)
+	if err != nil {
+		t.Fatalf("GetVarianceReport() error = %v", err)
+	}
+
+	if len(report.Months) != 2 {
+		t.Fatalf("expected 2 months, got %d", len(report.Months))
+	}
+
+	tests := []struct {
+		category        string
+		planned, actual float64
+		variance        float64
+		percentage      float64
+	}{
+		{"Alimentação", 2000, 1900, 100, 5},
+		{"Lazer", 200, 300, -100, -50},
+	}
+
+	if len(report.Categories) != len(tests) {
+		t.Fatalf("expected %d categories, got %d", len(tests), len(report.Categories))
+	}
+	for i, tt := range tests {
+		t.Run(tt.category, func(t *testing.T) {
+			got := report.Categories[i]
+			if got.Category != tt.category {
+				t.Fatalf("category = %s, want %s", got.Category, tt.category)
+			}
+			if math.Abs(got.Planned-tt.planned) > 0.01 || math.Abs(got.Actual-tt.actual) > 0.01 {
+				t.Errorf("planned/actual = %.2f/%.2f, want %.2f/%.2f", got.Planned, got.Actual, tt.planned, tt.actual)
+			}
+			if math.Abs(got.Variance-tt.variance) > 0.01 {
+				t.Errorf("variance = %.2f, want %.2f", got.Variance, tt.variance)
+			}
+			if math.Abs(got.VariancePercentage-tt.percentage) > 0.01 {
+				t.Errorf("variance %% = %.2f, want %.2f", got.VariancePercentage, tt.percentage)
+			}
+		})
+	}
+
+	if math.Abs(report.Total.Variance-0) > 0.01 || math.Abs(report.Total.Planned-2200) > 0.01 {
+		t.Errorf("total planned/variance = %.2f/%.2f, want 2200.00/0.00", report.Total.Planned, report.Total.Variance)
+	}
+	if math.Abs(report.Months[1].Total.Variance+100) > 0.01 {
+		t.Errorf("February variance = %.2f, want -100.00", report.Months[1].Total.Variance)
+	}
+}
+
+func TestBudgetService_GetVarianceReport_InvalidRange(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	group := testutil.CreateTestGroup(db, "Família", user.ID)
+	outsider := testutil.CreateTestUser(db, "outsider@example.com", "Outsider", "hash")
+	service := NewBudgetService()
+
+	tests := []struct {
+		name                                 string
+		userID                               uint
+		groupID                              *uint
+		fromYear, fromMonth, toYear, toMonth int
+		wantErr                              error
+	}{
+		{"end before start", user.ID, nil, 2030, 5, 2030, 4, ErrInvalidReportRange},
+		{"invalid month", user.ID, nil, 2030, 0, 2030, 4, ErrInvalidReportRange},
+		{"longer than limit", user.ID, nil, 2030, 1, 2033, 1, ErrInvalidReportRange},
+		{"group of another user", outsider.ID, &group.ID, 2030, 1, 2030, 3, ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.GetVarianceReport(tt.userID, tt.groupID, tt.fromYear, tt.fromMonth, tt.toYear, tt.toMonth)
+			if err != tt.wantErr {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,545 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+const (
+	// DefaultCashFlowMonths is the forecast horizon used when none is given
+	DefaultCashFlowMonths = 6
+	// MinCashFlowMonths and MaxCashFlowMonths bound the forecast horizon
+	MinCashFlowMonths = 3
+	MaxCashFlowMonths = 12
+	// dasDueDay is the day of the month the DAS of the previous month's revenue is due
+	dasDueDay = 20
+)
+
+// CashFlowSource identifies where a projected cash movement comes from
+type CashFlowSource string
+
+const (
+	CashFlowSourceIncome       CashFlowSource = "income"
+	CashFlowSourceFixedExpense CashFlowSource = "fixed_expense"
+	CashFlowSourceInstallment  CashFlowSource = "installment"
+	CashFlowSourceRecurring    CashFlowSource = "recurring"
+	CashFlowSourceBill         CashFlowSource = "bill"
+	CashFlowSourceTax          CashFlowSource = "tax"
+)
+
+// CashFlowEntry is a single projected inflow (positive amount) or outflow (negative amount)
+type CashFlowEntry struct {
+	AccountID   uint           `json:"account_id"`
+	Date        time.Time      `json:"date"`
+	Source      CashFlowSource `json:"source"`
+	Description string         `json:"description"`
+	Amount      float64        `json:"amount"`
+}
+
+// CashFlowMonth summarizes the projected movements of a month and the resulting balance
+type CashFlowMonth struct {
+	Year           int                        `json:"year"`
+	Month          int                        `json:"month"`
+	Inflows        float64                    `json:"inflows"`
+	Outflows       float64                    `json:"outflows"`          // Positive total of the outflows
+	BySource       map[CashFlowSource]float64 `json:"by_source"`         // Signed total of each source
+	Net            float64                    `json:"net"`               // Inflows - Outflows
+	OpeningBalance float64                    `json:"opening_balance"`   // Balance at the start of the month
+	ClosingBalance float64                    `json:"closing_balance"`   // Projected balance at the end of the month
+	Negative       bool                       `json:"negative"`          // Closing balance below zero
+	SeasonalIndex  float64                    `json:"seasonal_index"`    // Historical spending of the calendar month / monthly average
+	SeasonalExtra  float64                    `json:"seasonal_extra"`    // Historical variable spending above the monthly average, negative when below
+	Entries        []CashFlowEntry            `json:"entries,omitempty"` // Movements of the month, by date
+}
+
+// AccountCashFlow is the projection of a single account, starting from its current balance
+type AccountCashFlow struct {
+	AccountID   uint            `json:"account_id"`
+	AccountName string          `json:"account_name"`
+	Months      []CashFlowMonth `json:"months"`
+}
+
+// CashFlowWarning flags a month whose projected balance goes negative
+type CashFlowWarning struct {
+	Year    int     `json:"year"`
+	Month   int     `json:"month"`
+	Balance float64 `json:"balance"`
+	Message string  `json:"message"`
+}
+
+// CashFlowForecast is the projected balance curve of a set of accounts
+type CashFlowForecast struct {
+	From            time.Time         `json:"from"`
+	Months          int               `json:"months"`
+	StartingBalance float64           `json:"starting_balance"`
+	Overall         []CashFlowMonth   `json:"overall"`
+	Accounts        []AccountCashFlow `json:"accounts"`
+	Warnings        []CashFlowWarning `json:"warnings"`
+	SeasonalSpikes  []CashFlowWarning `json:"seasonal_spikes"` // Months that historically spend above average, with the balance adjusted by the seasonal variable spending
+}
+
+type CashFlowService struct {
+	occurrenceService *RecurringOccurrenceService
+}
+
+func NewCashFlowService() *CashFlowService {
+	return &CashFlowService{
+		occurrenceService: NewRecurringOccurrenceService(),
+	}
+}
+
+// Forecast projects the cash flow of the given accounts from now until the end of the
+// horizon (the current month included). It combines active fixed expenses on their due day,
+// pending installments on the card due day, upcoming recurring transactions, unpaid bills,
+// expected income and the DAS due on the 20th over the previous month's revenue.
+// Each account curve starts from the account's balance today (see openingBalances) and the
+// overall curve from their sum, unless startingBalance overrides it.
+func (s *CashFlowService) Forecast(accountIDs []uint, months int, startingBalance *float64, now time.Time) (*CashFlowForecast, error) {
+	if months <= 0 {
+		months = DefaultCashFlowMonths
+	}
+	if months < MinCashFlowMonths {
+		months = MinCashFlowMonths
+	}
+	if months > MaxCashFlowMonths {
+		months = MaxCashFlowMonths
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	end := from.AddDate(0, months, 0) // Exclusive
+
+	forecast := &CashFlowForecast{
+		From:           from,
+		Months:         months,
+		Accounts:       []AccountCashFlow{},
+		Warnings:       []CashFlowWarning{},
+		SeasonalSpikes: []CashFlowWarning{},
+	}
+
+	var accounts []models.Account
+	var balances map[uint]float64
+	if len(accountIDs) > 0 {
+		database.DB.Where("id IN ?", accountIDs).Order("name").Find(&accounts)
+		balances = openingBalances(accountIDs, today, now)
+	}
+	for _, account := range accounts {
+		forecast.StartingBalance += balances[account.ID]
+	}
+	if startingBalance != nil {
+		forecast.StartingBalance = *startingBalance
+	}
+
+	if len(accountIDs) == 0 {
+		forecast.Overall = buildCashFlowMonths(nil, from, months, forecast.StartingBalance)
+		return forecast, nil
+	}
+
+	var entries []CashFlowEntry
+	entries = append(entries, fixedExpenseEntries(accountIDs, today, from, months)...)
+	entries = append(entries, installmentEntries(accountIDs, today, end)...)
+	entries = append(entries, billEntries(accountIDs, today, end)...)
+
+	recurring, err := s.recurringEntries(accountIDs, today, end)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, recurring...)
+	entries = append(entries, incomeEntries(accountIDs, today, end)...)
+	entries = append(entries, taxEntries(accountIDs, entries, today, from, end)...)
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Date.Equal(entries[j].Date) {
+			return entries[i].Date.Before(entries[j].Date)
+		}
+		return entries[i].Description < entries[j].Description
+	})
+
+	forecast.Overall = buildCashFlowMonths(entries, from, months, forecast.StartingBalance)
+	for _, month := range forecast.Overall {
+		if month.Negative {
+			forecast.Warnings = append(forecast.Warnings, CashFlowWarning{
+				Year:    month.Year,
+				Month:   month.Month,
+				Balance: month.ClosingBalance,
+				Message: fmt.Sprintf("Saldo projetado negativo em %02d/%d: R$ %.2f", month.Month, month.Year, month.ClosingBalance),
+			})
+		}
+	}
+
+	// The projection only knows scheduled movements; the history tells which months usually
+	// spend more, so planning can reserve for them. The adjusted balance carries each month's
+	// signed deviation of variable spending, so the months that spend less offset the spikes.
+	seasonality := GetSeasonality(database.DB, accountIDs, DefaultSeasonalityYears, now)
+	var seasonalAdjustment float64
+	for i := range forecast.Overall {
+		month := &forecast.Overall[i]
+		seasonal := seasonality.Month(time.Month(month.Month))
+		month.SeasonalIndex = seasonal.ExpenseIndex
+		month.SeasonalExtra = seasonal.VariableExtra
+		seasonalAdjustment += seasonal.VariableExtra
+		if seasonal.Spike {
+			forecast.SeasonalSpikes = append(forecast.SeasonalSpikes, CashFlowWarning{
+				Year:    month.Year,
+				Month:   month.Month,
+				Balance: month.ClosingBalance - seasonalAdjustment,
+				Message: fmt.Sprintf("Em %s os gastos costumam ficar %.0f%% acima da média (%+.2f em gastos variáveis): saldo ajustado de R$ %.2f em %02d/%d",
+					strings.ToLower(seasonal.MonthName), (seasonal.ExpenseIndex-1)*100, seasonal.VariableExtra,
+					month.ClosingBalance-seasonalAdjustment, month.Month, month.Year),
+			})
+		}
+	}
+
+	for _, account := range accounts {
+		var accountEntries []CashFlowEntry
+		for _, entry := range entries {
+			if entry.AccountID == account.ID {
+				accountEntries = append(accountEntries, entry)
+			}
+		}
+		forecast.Accounts = append(forecast.Accounts, AccountCashFlow{
+			AccountID:   account.ID,
+			AccountName: account.Name,
+			Months:      buildCashFlowMonths(accountEntries, from, months, balances[account.ID]),
+		})
+	}
+
+	return forecast, nil
+}
+
+// openingBalances returns the balance of each account today, from the movements that
+// already happened: incomes dated before today at their gross amount, less the DAS already
+// due; variable expenses; recorded fixed expense payments; paid bills; and installments whose
+// card due day has passed. Everything else (future incomes, unpaid bills and fixed expenses,
+// upcoming installments and DAS) is projected by Forecast, so nothing is counted twice.
+func openingBalances(accountIDs []uint, today, now time.Time) map[uint]float64 {
+	balances := make(map[uint]float64, len(accountIDs))
+	type accountSum struct {
+		AccountID uint
+		Total     float64
+	}
+	add := func(sign float64, query *gorm.DB) {
+		var sums []accountSum
+		query.Group("account_id").Scan(&sums)
+		for _, sum := range sums {
+			balances[sum.AccountID] += sign * sum.Total
+		}
+	}
+
+	// The DAS of a month is due on the 20th of the next one; it's projected until then
+	taxPaidBefore := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.Local)
+	if !dayInMonth(taxPaidBefore, dasDueDay).Before(today) {
+		taxPaidBefore = taxPaidBefore.AddDate(0, -1, 0)
+	}
+	add(1, database.DB.Model(&models.Income{}).
+		Select("account_id, COALESCE(SUM(gross_amount), 0) AS total").
+		Where("account_id IN ? AND date < ?", accountIDs, today))
+	add(-1, database.DB.Model(&models.Income{}).
+		Select("account_id, COALESCE(SUM(tax_amount), 0) AS total").
+		Where("account_id IN ? AND date < ?", accountIDs, taxPaidBefore))
+
+	add(-1, database.DB.Model(&models.Expense{}).
+		Select("account_id, COALESCE(SUM(amount), 0) AS total").
+		Where("account_id IN ? AND type = ? AND active = ? AND created_at <= ?", accountIDs, models.ExpenseTypeVariable, true, now))
+	add(-1, database.DB.Model(&models.ExpensePayment{}).
+		Select("expenses.account_id, COALESCE(SUM(expense_payments.amount), 0) AS total").
+		Joins("JOIN expenses ON expenses.id = expense_payments.expense_id").
+		Where("expenses.account_id IN ? AND expenses.deleted_at IS NULL", accountIDs))
+	add(-1, database.DB.Model(&models.Bill{}).
+		Select("account_id, COALESCE(SUM(amount), 0) AS total").
+		Where("account_id IN ? AND paid = ?", accountIDs, true))
+
+	var installments []models.Installment
+	database.DB.Preload("CreditCard").
+		Joins("JOIN credit_cards ON credit_cards.id = installments.credit_card_id").
+		Where("credit_cards.account_id IN ? AND credit_cards.deleted_at IS NULL", accountIDs).
+		Find(&installments)
+	for i := range installments {
+		inst := &installments[i]
+		for _, ym := range installmentMonths(inst) {
+			date := dayInMonth(time.Date(ym[0], time.Month(ym[1]), 1, 0, 0, 0, 0, time.Local), inst.CreditCard.DueDay)
+			if date.Before(today) {
+				balances[inst.CreditCard.AccountID] -= inst.InstallmentAmount
+			}
+		}
+	}
+	return balances
+}
+
+// buildCashFlowMonths groups sorted entries by month and accumulates the balance
+func buildCashFlowMonths(entries []CashFlowEntry, from time.Time, months int, startingBalance float64) []CashFlowMonth {
+	result := make([]CashFlowMonth, months)
+	index := make(map[int]int, months)
+	for i := range result {
+		month := from.AddDate(0, i, 0)
+		result[i] = CashFlowMonth{
+			Year:     month.Year(),
+			Month:    int(month.Month()),
+			BySource: make(map[CashFlowSource]float64),
+		}
+		index[month.Year()*100+int(month.Month())] = i
+	}
+
+	for _, entry := range entries {
+		i, ok := index[entry.Date.Year()*100+int(entry.Date.Month())]
+		if !ok {
+			continue
+		}
+		month := &result[i]
+		if entry.Amount >= 0 {
+			month.Inflows += entry.Amount
+		} else {
+			month.Outflows -= entry.Amount
+		}
+		month.BySource[entry.Source] += entry.Amount
+		month.Entries = append(month.Entries, entry)
+	}
+
+	balance := startingBalance
+	for i := range result {
+		month := &result[i]
+		month.Net = month.Inflows - month.Outflows
+		month.OpeningBalance = balance
+		balance += month.Net
+		month.ClosingBalance = balance
+		month.Negative = balance < 0
+	}
+	return result
+}
+
+// dayInMonth returns the given day of a month, clamped to the month's last day
+func dayInMonth(month time.Time, day int) time.Time {
+	if day < 1 {
+		day = 1
+	}
+	if last := daysInMonth(month.Year(), month.Month()); day > last {
+		day = last
+	}
+	return time.Date(month.Year(), month.Month(), day, 0, 0, 0, 0, time.Local)
+}
+
+// fixedExpenseEntries projects active fixed expenses on their due day. Months already paid
+// are skipped; an unpaid expense of the current month counts even if its due day has passed.
+func fixedExpenseEntries(accountIDs []uint, today, from time.Time, months int) []CashFlowEntry {
+	var expenses []models.Expense
+	database.DB.Where("type = ? AND active = ? AND account_id IN ?", models.ExpenseTypeFixed, true, accountIDs).Find(&expenses)
+	if len(expenses) == 0 {
+		return nil
+	}
+
+	expenseIDs := make([]uint, len(expenses))
+	for i, e := range expenses {
+		expenseIDs[i] = e.ID
+	}
+	var payments []models.ExpensePayment
+	database.DB.Where("expense_id IN ? AND year * 100 + month >= ?", expenseIDs, from.Year()*100+int(from.Month())).Find(&payments)
+	paid := make(map[string]bool, len(payments))
+	for _, p := range payments {
+		paid[fmt.Sprintf("%d-%d-%d", p.ExpenseID, p.Year, p.Month)] = true
+	}
+
+	var entries []CashFlowEntry
+	for _, e := range expenses {
+		for i := 0; i < months; i++ {
+			month := from.AddDate(0, i, 0)
+			if paid[fmt.Sprintf("%d-%d-%d", e.ID, month.Year(), int(month.Month()))] {
+				continue
+			}
+			date := dayInMonth(month, e.DueDay)
+			if date.Before(today) {
+				date = today
+			}
+			entries = append(entries, CashFlowEntry{
+				AccountID:   e.AccountID,
+				Date:        date,
+				Source:      CashFlowSourceFixedExpense,
+				Description: e.Name,
+				Amount:      -e.Amount,
+			})
+		}
+	}
+	return entries
+}
+
+// installmentEntries projects the remaining installments on the due day of their card.
+// Installments whose due day already passed are considered paid.
+func installmentEntries(accountIDs []uint, today, end time.Time) []CashFlowEntry {
+	var installments []models.Installment
+	database.DB.Preload("CreditCard").
+		Joins("JOIN credit_cards ON credit_cards.id = installments.credit_card_id").
+		Where("credit_cards.account_id IN ? AND credit_cards.deleted_at IS NULL", accountIDs).
+		Find(&installments)
+
+	var entries []CashFlowEntry
+	for i := range installments {
+		inst := &installments[i]
+		for n, ym := range installmentMonths(inst) {
+			date := dayInMonth(time.Date(ym[0], time.Month(ym[1]), 1, 0, 0, 0, 0, time.Local), inst.CreditCard.DueDay)
+			if date.Before(today) || !date.Before(end) {
+				continue
+			}
+			entries = append(entries, CashFlowEntry{
+				AccountID:   inst.CreditCard.AccountID,
+				Date:        date,
+				Source:      CashFlowSourceInstallment,
+				Description: fmt.Sprintf("%s (%d/%d)", inst.Description, n+1, inst.TotalInstallments),
+				Amount:      -inst.InstallmentAmount,
+			})
+		}
+	}
+	return entries
+}
+
+// billEntries projects unpaid bills on their due date; overdue bills are expected today
+func billEntries(accountIDs []uint, today, end time.Time) []CashFlowEntry {
+	var bills []models.Bill
+	database.DB.Where("paid = ? AND due_date < ? AND account_id IN ?", false, end, accountIDs).Find(&bills)
+
+	entries := make([]CashFlowEntry, 0, len(bills))
+	for _, b := range bills {
+		date := b.DueDate
+		if date.Before(today) {
+			date = today
+		}
+		entries = append(entries, CashFlowEntry{
+			AccountID:   b.AccountID,
+			Date:        date,
+			Source:      CashFlowSourceBill,
+			Description: b.Name,
+			Amount:      -b.Amount,
+		})
+	}
+	return entries
+}
+
+// recurringEntries projects the recurring transactions still to be generated. Recurring
+// incomes are gross inflows (their DAS is projected by taxEntries); USD amounts are
+// converted with the fallback rate of the transaction or the latest registered rate.
+func (s *CashFlowService) recurringEntries(accountIDs []uint, today, end time.Time) ([]CashFlowEntry, error) {
+	occurrences, err := s.occurrenceService.PreviewOccurrences(accountIDs, today, end.Add(-time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+
+	var recurringTransactions []models.RecurringTransaction
+	database.DB.Where("account_id IN ? AND active = ?", accountIDs, true).Find(&recurringTransactions)
+	byID := make(map[uint]*models.RecurringTransaction, len(recurringTransactions))
+	for i := range recurringTransactions {
+		byID[recurringTransactions[i].ID] = &recurringTransactions[i]
+	}
+
+	latestRate := -1.0 // Loaded on demand
+	var entries []CashFlowEntry
+	for _, occurrence := range occurrences {
+		rt := byID[occurrence.RecurringTransactionID]
+		if occurrence.Skipped || rt == nil {
+			continue
+		}
+
+		amount := occurrence.Amount
+		if occurrence.Currency == models.CurrencyUSD {
+			rate := rt.ExchangeRate
+			if rate <= 0 {
+				if latestRate < 0 {
+					latestRate = latestExchangeRate(accountIDs)
+				}
+				rate = latestRate
+			}
+			amount *= rate
+		}
+
+		if occurrence.TransactionType == models.TransactionTypeExpense {
+			amount = -amount
+		}
+		entries = append(entries, CashFlowEntry{
+			AccountID:   rt.AccountID,
+			Date:        occurrence.Date,
+			Source:      CashFlowSourceRecurring,
+			Description: occurrence.Description,
+			Amount:      amount,
+		})
+	}
+	return entries, nil
+}
+
+// latestExchangeRate returns the exchange rate of the most recent USD income of the accounts
+func latestExchangeRate(accountIDs []uint) float64 {
+	var income models.Income
+	err := database.DB.Where("account_id IN ? AND amount_usd > 0 AND exchange_rate > 0", accountIDs).
+		Order("date DESC").First(&income).Error
+	if err != nil {
+		return 0
+	}
+	return income.ExchangeRate
+}
+
+// incomeEntries projects incomes already registered with a future date at their gross amount
+func incomeEntries(accountIDs []uint, today, end time.Time) []CashFlowEntry {
+	var incomes []models.Income
+	database.DB.Where("date >= ? AND date < ? AND account_id IN ?", today, end, accountIDs).Find(&incomes)
+
+	entries := make([]CashFlowEntry, 0, len(incomes))
+	for _, income := range incomes {
+		entries = append(entries, CashFlowEntry{
+			AccountID:   income.AccountID,
+			Date:        income.Date,
+			Source:      CashFlowSourceIncome,
+			Description: income.Description,
+			Amount:      income.GrossAmount,
+		})
+	}
+	return entries
+}
+
+// taxEntries projects the DAS due on the 20th of each month over the previous month's revenue.
+// Registered incomes use their calculated tax; projected recurring incomes are taxed with the
+// current 12-month revenue. The DAS of the previous month is considered paid after its due day.
+func taxEntries(accountIDs []uint, projected []CashFlowEntry, today, from, end time.Time) []CashFlowEntry {
+	type taxKey struct {
+		accountID uint
+		due       time.Time
+	}
+	taxByDue := make(map[taxKey]float64)
+	addTax := func(accountID uint, date time.Time, amount float64) {
+		month := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.Local)
+		taxByDue[taxKey{accountID, dayInMonth(month.AddDate(0, 1, 0), dasDueDay)}] += amount
+	}
+
+	// Registered incomes from the previous month onwards (future ones are already in projected)
+	var incomes []models.Income
+	database.DB.Where("date >= ? AND date < ? AND account_id IN ?", from.AddDate(0, -1, 0), end, accountIDs).Find(&incomes)
+	for _, income := range incomes {
+		addTax(income.AccountID, income.Date, income.TaxAmount)
+	}
+
+	revenue12M := GetRevenue12MonthsForAccountsAt(database.DB, accountIDs, today)
+	manualBracket := getSettingInt(models.SettingManualBracket)
+	for _, entry := range projected {
+		if entry.Source == CashFlowSourceRecurring && entry.Amount > 0 {
+			addTax(entry.AccountID, entry.Date, CalculateTaxWithManualBracket(revenue12M, entry.Amount, manualBracket).TaxAmount)
+		}
+	}
+
+	var entries []CashFlowEntry
+	for key, amount := range taxByDue {
+		due := key.due
+		if amount <= 0 || due.Before(today) || !due.Before(end) {
+			continue
+		}
+		entries = append(entries, CashFlowEntry{
+			AccountID:   key.accountID,
+			Date:        due,
+			Source:      CashFlowSourceTax,
+			Description: fmt.Sprintf("DAS %02d/%d", int(due.AddDate(0, -1, 0).Month()), due.AddDate(0, -1, 0).Year()),
+			Amount:      -amount,
+		})
+	}
+	return entries
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestCashFlowService_Forecast(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2030, month, day, 0, 0, 0, 0, time.Local)
+	}
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Pessoal", models.AccountTypeIndividual, user.ID, nil)
+	savings := testutil.CreateTestAccount(db, "Reserva", models.AccountTypeIndividual, user.ID, nil)
+
+	// Fixed expenses: rent already paid in March, the other due on the 31st (clamped in April)
+	rent := models.Expense{AccountID: account.ID, Name: "Aluguel", Amount: 1000, Type: models.ExpenseTypeFixed, DueDay: 5, Active: true}
+	db.Create(&rent)
+	db.Create(&models.ExpensePayment{ExpenseID: rent.ID, Year: 2030, Month: 3, PaidAt: date(3, 5), Amount: 1000})
+	db.Create(&models.Expense{AccountID: account.ID, Name: "Internet", Amount: 200, Type: models.ExpenseTypeFixed, DueDay: 31, Active: true})
+	inactive := models.Expense{AccountID: account.ID, Name: "Academia", Amount: 90, Type: models.ExpenseTypeFixed, DueDay: 10}
+	db.Create(&inactive)
+	db.Model(&inactive).Update("active", false)
+
+	// Overdue bill expected today, paid bill ignored, future bill in the other account
+	db.Create(&models.Bill{AccountID: account.ID, Name: "Condomínio", Amount: 300, DueDate: date(3, 1)})
+	db.Create(&models.Bill{AccountID: account.ID, Name: "Luz", Amount: 150, DueDate: date(3, 20), Paid: true})
+	db.Create(&models.Bill{AccountID: savings.ID, Name: "Seguro", Amount: 50, DueDate: date(4, 8)})
+
+	// Installments on the card due day: February already passed, March and April remain
+	card := models.CreditCard{AccountID: account.ID, Name: "Cartão", ClosingDay: 5, DueDay: 15}
+	db.Create(&card)
+	db.Create(&models.Installment{CreditCardID: card.ID, Description: "Notebook", TotalAmount: 300, InstallmentAmount: 100, TotalInstallments: 3, StartDate: date(2, 1)})
+
+	// February income: its DAS is due on March 20 and sets the 12-month revenue (6% bracket)
+	db.Create(&models.Income{AccountID: account.ID, Date: date(2, 10), AmountBRL: 4000, GrossAmount: 4000, TaxAmount: 240, NetAmount: 3760, ExchangeRate: 1})
+
+	db.Create(&models.RecurringTransaction{
+		AccountID:       account.ID,
+		TransactionType: models.TransactionTypeIncome,
+		Frequency:       models.FrequencyMonthly,
+		Amount:          5000,
+		Description:     "Salário",
+		StartDate:       date(3, 25),
+		NextRunDate:     date(3, 25),
+		Active:          true,
+		Currency:        models.CurrencyBRL,
+	})
+
+	startingBalance := -5000.0
+	forecast, err := NewCashFlowService().Forecast([]uint{account.ID, savings.ID}, 3, &startingBalance, date(3, 10))
+	if err != nil {
+		t.Fatalf("Forecast() error = %v", err)
+	}
+
+	tests := []struct {
+		month             int
+		inflows, outflows float64
+		tax               float64
+		closingBalance    float64
+	}{
+		// Internet 200 + Condomínio 300 + Notebook 100 + DAS 240
+		{3, 5000, 840, -240, -840},
+		// Aluguel 1000 + Internet 200 + Seguro 50 + Notebook 100 + DAS 300
+		{4, 5000, 1650, -300, 2510},
+		// Aluguel 1000 + Internet 200 + DAS 300
+		{5, 5000, 1500, -300, 6010},
+	}
+
+	if len(forecast.Overall) != len(tests) {
+		t.Fatalf("expected %d months, got %d", len(tests), len(forecast.Overall))
+	}
+	for i, tt := range tests {
+		got := forecast.Overall[i]
+		if got.Month != tt.month {
+			t.Fatalf("month %d = %d, want %d", i, got.Month, tt.month)
+		}
+		if math.Abs(got.Inflows-tt.inflows) > 0.01 || math.Abs(got.Outflows-tt.outflows) > 0.01 {
+			t.Errorf("month %d inflows/outflows = %.2f/%.2f, want %.2f/%.2f", tt.month, got.Inflows, got.Outflows, tt.inflows, tt.outflows)
+		}
+		if math.Abs(got.BySource[CashFlowSourceTax]-tt.tax) > 0.01 {
+			t.Errorf("month %d tax = %.2f, want %.2f", tt.month, got.BySource[CashFlowSourceTax], tt.tax)
+		}
+		if math.Abs(got.ClosingBalance-tt.closingBalance) > 0.01 {
+			t.Errorf("month %d closing balance = %.2f, want %.2f", tt.month, got.ClosingBalance, tt.closingBalance)
+		}
+	}
+
+	if len(forecast.Warnings) != 1 || forecast.Warnings[0].Month != 3 {
+		t.Errorf("expected a single warning for March, got %+v", forecast.Warnings)
+	}
+
+	if len(forecast.Accounts) != 2 {
+		t.Fatalf("expected 2 accounts, got %d", len(forecast.Accounts))
+	}
+	// Account curves start from the movements already made: the unpaid Seguro bill is only
+	// projected, in April
+	reserve := forecast.Accounts[1]
+	if reserve.AccountName != "Reserva" || reserve.Months[0].OpeningBalance != 0 || math.Abs(reserve.Months[1].ClosingBalance+50) > 0.01 {
+		t.Errorf("account %s opening/April balance = %.2f/%.2f, want 0.00/-50.00",
+			reserve.AccountName, reserve.Months[0].OpeningBalance, reserve.Months[1].ClosingBalance)
+	}
+	// Pessoal: February income 4000 (its DAS is still projected) - Aluguel paid 1000 - Luz paid 150
+	// - February installment 100
+	if personal := forecast.Accounts[0]; math.Abs(personal.Months[0].OpeningBalance-2750) > 0.01 {
+		t.Errorf("account %s opening balance = %.2f, want 2750.00", personal.AccountName, personal.Months[0].OpeningBalance)
+	}
+	if !reserve.Months[2].Negative {
+		t.Error("expected the account balance to stay negative in May")
+	}
+}
+
+func TestCashFlowService_Forecast_Horizon(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	service := NewCashFlowService()
+	now := time.Date(2030, 11, 15, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		months int
+		want   int
+	}{
+		{0, DefaultCashFlowMonths},
+		{1, MinCashFlowMonths},
+		{24, MaxCashFlowMonths},
+	}
+
+	for _, tt := range tests {
+		startingBalance := 100.0
+		forecast, err := service.Forecast(nil, tt.months, &startingBalance, now)
+		if err != nil {
+			t.Fatalf("Forecast() error = %v", err)
+		}
+		if len(forecast.Overall) != tt.want {
+			t.Errorf("months %d: got %d months, want %d", tt.months, len(forecast.Overall), tt.want)
+		}
+		last := forecast.Overall[len(forecast.Overall)-1]
+		if last.ClosingBalance != 100 {
+			t.Errorf("months %d: closing balance = %.2f, want 100.00", tt.months, last.ClosingBalance)
+		}
+	}
+}
+
+func TestCashFlowService_Forecast_OpeningBalance(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2030, month, day, 0, 0, 0, 0, time.Local)
+	}
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Pessoal", models.AccountTypeIndividual, user.ID, nil)
+	savings := testutil.CreateTestAccount(db, "Reserva", models.AccountTypeIndividual, user.ID, nil)
+
+	// January's DAS was due on February 20th; February's is still projected for March 20th
+	db.Create(&models.Income{AccountID: account.ID, Date: date(1, 10), AmountBRL: 5000, GrossAmount: 5000, TaxAmount: 300, NetAmount: 4700, ExchangeRate: 1})
+	db.Create(&models.Income{AccountID: account.ID, Date: date(2, 10), AmountBRL: 4000, GrossAmount: 4000, TaxAmount: 240, NetAmount: 3760, ExchangeRate: 1})
+	db.Create(&models.Income{AccountID: account.ID, Date: date(3, 25), AmountBRL: 1000, GrossAmount: 1000, NetAmount: 1000, ExchangeRate: 1})
+	db.Create(&models.Income{AccountID: savings.ID, Date: date(2, 12), AmountBRL: 1000, GrossAmount: 1000, NetAmount: 1000, ExchangeRate: 1})
+
+	rent := models.Expense{AccountID: account.ID, Name: "Aluguel", Amount: 1000, Type: models.ExpenseTypeFixed, DueDay: 5, Active: true}
+	db.Create(&rent)
+	db.Create(&models.ExpensePayment{ExpenseID: rent.ID, Year: 2030, Month: 2, PaidAt: date(2, 5), Amount: 1000})
+	db.Create(&models.Expense{AccountID: account.ID, Name: "Mercado", Amount: 200, Type: models.ExpenseTypeVariable, Active: true})
+	db.Create(&models.Bill{AccountID: account.ID, Name: "Luz", Amount: 150, DueDate: date(3, 1), Paid: true})
+	db.Create(&models.Bill{AccountID: account.ID, Name: "Condomínio", Amount: 300, DueDate: date(3, 15)})
+	db.Create(&models.Bill{AccountID: savings.ID, Name: "Seguro", Amount: 50, DueDate: date(4, 8)})
+
+	card := models.CreditCard{AccountID: account.ID, Name: "Cartão", ClosingDay: 5, DueDay: 15}
+	db.Create(&card)
+	db.Create(&models.Installment{CreditCardID: card.ID, Description: "Notebook", TotalAmount: 300, InstallmentAmount: 100, TotalInstallments: 3, StartDate: date(2, 1)})
+
+	forecast, err := NewCashFlowService().Forecast([]uint{account.ID, savings.ID}, 3, nil, date(3, 10))
+	if err != nil {
+		t.Fatalf("Forecast() error = %v", err)
+	}
+
+	// Pessoal: 4700 + 4000 - Aluguel 1000 - Mercado 200 - Luz 150 - February installment 100
+	want := map[string]float64{"Pessoal": 7250, "Reserva": 1000}
+	for _, accountFlow := range forecast.Accounts {
+		if got := accountFlow.Months[0].OpeningBalance; math.Abs(got-want[accountFlow.AccountName]) > 0.01 {
+			t.Errorf("account %s opening balance = %.2f, want %.2f", accountFlow.AccountName, got, want[accountFlow.AccountName])
+		}
+	}
+	if math.Abs(forecast.StartingBalance-8250) > 0.01 || math.Abs(forecast.Overall[0].OpeningBalance-8250) > 0.01 {
+		t.Errorf("starting balance = %.2f (opening %.2f), want the sum of the account balances 8250.00",
+			forecast.StartingBalance, forecast.Overall[0].OpeningBalance)
+	}
+
+	// March: future income 1000 - unpaid Aluguel 1000 - Condomínio 300 - Notebook 100 - DAS 240
+	if march := forecast.Overall[0]; math.Abs(march.ClosingBalance-7610) > 0.01 {
+		t.Errorf("March closing balance = %.2f, want 7610.00", march.ClosingBalance)
+	}
+}
+
+func TestCashFlowService_Forecast_SeasonalAdjustment(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Pessoal", models.AccountTypeIndividual, user.ID, nil)
+
+	// Two years of groceries, with a spike every December (12 months apart), and rent that
+	// the forecast already projects
+	for month := time.Date(2028, 1, 10, 0, 0, 0, 0, time.Local); month.Year() < 2030; month = month.AddDate(0, 1, 0) {
+		amount := 100.0
+		if month.Month() == time.December {
+			amount = 1300
+		}
+		db.Create(&models.Expense{Model: gorm.Model{CreatedAt: month}, AccountID: account.ID, Name: "Mercado", Amount: amount,
+			Type: models.ExpenseTypeVariable, Category: "Alimentação", Active: true})
+	}
+	rent := models.Expense{Model: gorm.Model{CreatedAt: time.Date(2028, 1, 1, 0, 0, 0, 0, time.Local)}, AccountID: account.ID,
+		Name: "Aluguel", Amount: 1000, Type: models.ExpenseTypeFixed, DueDay: 5, Active: true}
+	db.Create(&rent)
+
+	startingBalance := 0.0
+	forecast, err := NewCashFlowService().Forecast([]uint{account.ID}, 12, &startingBalance, time.Date(2030, 1, 15, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatalf("Forecast() error = %v", err)
+	}
+
+	// Variable spending averages 200 a month: December is 1100 above it, the other months 100
+	// below. Rent isn't part of the deviation, as the forecast already subtracts it.
+	for _, month := range forecast.Overall {
+		want := -100.0
+		if month.Month == 12 {
+			want = 1100
+		}
+		if math.Abs(month.SeasonalExtra-want) > 0.01 {
+			t.Errorf("%02d/%d SeasonalExtra = %.2f, want %.2f", month.Month, month.Year, month.SeasonalExtra, want)
+		}
+	}
+
+	// The 11 months below average set aside what December spends above it, so the adjusted
+	// balance doesn't drift below the projected one
+	if len(forecast.SeasonalSpikes) != 1 {
+		t.Fatalf("SeasonalSpikes = %+v, want December", forecast.SeasonalSpikes)
+	}
+	december := forecast.Overall[11]
+	if spike := forecast.SeasonalSpikes[0]; spike.Month != 12 || math.Abs(spike.Balance-december.ClosingBalance) > 0.01 {
+		t.Errorf("December spike = %+v, want the adjusted balance equal to the projected %.2f", spike, december.ClosingBalance)
+	}
+}
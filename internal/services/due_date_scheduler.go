@@ -1,6 +1,7 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -11,11 +12,13 @@ import (
 
 type DueDateSchedulerService struct {
 	notificationService *NotificationService
+	webhookService      *WebhookService
 }
 
 func NewDueDateSchedulerService() *DueDateSchedulerService {
 	return &DueDateSchedulerService{
 		notificationService: NewNotificationService(),
+		webhookService:      NewWebhookService(),
 	}
 }
 
@@ -39,6 +42,10 @@ func (s *DueDateSchedulerService) CheckUpcomingDueDates() error {
 
 	log.Printf("Found %d active fixed expenses to check for upcoming due dates", len(expenses))
 
+	// Failures don't stop the other expenses, but they are returned so the job run is
+	// recorded as failed and retried
+	var errs []error
+
 	for _, expense := range expenses {
 		// Check if the due day matches our target (3 days from now)
 		if expense.DueDay != targetDay {
@@ -61,14 +68,22 @@ func (s *DueDateSchedulerService) CheckUpcomingDueDates() error {
 		// No payment record found - expense is unpaid, send notification
 		if err := s.notifyUpcomingDueDate(&expense, targetDate); err != nil {
 			log.Printf("Error notifying for expense %d: %v", expense.ID, err)
-			// Don't fail if notification fails, continue processing other expenses
+			errs = append(errs, fmt.Errorf("expense %d: %w", expense.ID, err))
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// dueDateNotificationKey identifies the due date notice of an expense for one recipient,
+// so a run retried after a partial failure only notifies those who didn't get it
+func dueDateNotificationKey(expense *models.Expense, dueDate time.Time, recipient string) string {
+	return fmt.Sprintf("due_date:%d:%s:%s", expense.ID, dueDate.Format("2006-01-02"), recipient)
 }
 
-// notifyUpcomingDueDate sends notifications to account members about an upcoming due date
+// notifyUpcomingDueDate sends notifications to account members about an upcoming due date.
+// Each member (and the webhook event) is claimed separately, so nobody is notified twice
+// for the same expense and due date, even if the check runs again after a restart.
 func (s *DueDateSchedulerService) notifyUpcomingDueDate(expense *models.Expense, dueDate time.Time) error {
 	// Get the account to find group members
 	var account models.Account
@@ -76,59 +91,76 @@ func (s *DueDateSchedulerService) notifyUpcomingDueDate(expense *models.Expense,
 		return fmt.Errorf("failed to fetch account: %w", err)
 	}
 
+	var errs []error
+	webhookKey := dueDateNotificationKey(expense, dueDate, "webhook")
+	if claimed, err := ClaimIdempotencyKey(database.DB, JobDueDateNotifications, webhookKey); err != nil {
+		errs = append(errs, err)
+	} else if claimed {
+		s.webhookService.EmitForAccount(&account, models.WebhookEventDueDateUpcoming, map[string]interface{}{
+			"expense_id": expense.ID,
+			"account_id": account.ID,
+			"group_id":   account.GroupID,
+			"name":       expense.Name,
+			"amount":     expense.Amount,
+			"due_date":   dueDate.Format("2006-01-02"),
+		})
+	}
+
 	// For individual accounts, notify only the owner
-	if account.IsIndividual() {
-		notification := &models.Notification{
-			UserID:  account.UserID,
-			Type:    models.NotificationTypeDueDate,
-			Title:   "Despesa próxima do vencimento",
-			Message: fmt.Sprintf("A despesa \"%s\" vence em 3 dias (R$ %.2f)", expense.Name, expense.Amount),
-			Link:    "/expenses",
-			GroupID: nil,
+	userIDs := []uint{account.UserID}
+	message := fmt.Sprintf("A despesa \"%s\" vence em 3 dias (R$ %.2f)", expense.Name, expense.Amount)
+	if !account.IsIndividual() {
+		// For joint accounts, notify all group members
+		if account.GroupID == nil {
+			return fmt.Errorf("joint account %d has no group ID", account.ID)
 		}
-		if err := s.notificationService.Create(notification); err != nil {
-			return err
+		userIDs = nil
+		err := database.DB.Model(&models.GroupMember{}).
+			Where("group_id = ?", *account.GroupID).
+			Pluck("user_id", &userIDs).Error
+		if err != nil {
+			return fmt.Errorf("failed to fetch group members: %w", err)
 		}
-		log.Printf("Sent due date notification for expense %d (%s) to user %d",
-			expense.ID, expense.Name, account.UserID)
-		return nil
+		message = fmt.Sprintf("A despesa \"%s\" da conta \"%s\" vence em 3 dias (R$ %.2f)",
+			expense.Name, account.Name, expense.Amount)
 	}
 
-	// For joint accounts, notify all group members
-	if account.GroupID == nil {
-		return fmt.Errorf("joint account %d has no group ID", account.ID)
-	}
-
-	// Get group members
-	var members []models.User
-	err := database.DB.Table("users").
-		Joins("JOIN group_members ON users.id = group_members.user_id").
-		Where("group_members.group_id = ?", *account.GroupID).
-		Find(&members).Error
-
-	if err != nil {
-		return fmt.Errorf("failed to fetch group members: %w", err)
-	}
+	notified := 0
+	for _, userID := range userIDs {
+		key := dueDateNotificationKey(expense, dueDate, fmt.Sprint(userID))
+		claimed, err := ClaimIdempotencyKey(database.DB, JobDueDateNotifications, key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", userID, err))
+			continue
+		}
+		if !claimed {
+			continue
+		}
 
-	// Send notification to each member
-	for _, member := range members {
 		notification := &models.Notification{
-			UserID:  member.ID,
+			UserID:  userID,
 			Type:    models.NotificationTypeDueDate,
 			Title:   "Despesa próxima do vencimento",
-			Message: fmt.Sprintf("A despesa \"%s\" da conta \"%s\" vence em 3 dias (R$ %.2f)",
-				expense.Name, account.Name, expense.Amount),
+			Message: message,
 			Link:    "/expenses",
 			GroupID: account.GroupID,
 		}
 		if err := s.notificationService.Create(notification); err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("user %d: %w", userID, err))
+			// Release only this member's key so the next run retries them alone
+			if err := ReleaseIdempotencyKey(database.DB, key); err != nil {
+				log.Printf("Error releasing due date notification key %s: %v", key, err)
+			}
+			continue
 		}
+		notified++
 	}
 
-	log.Printf("Sent due date notification for expense %d (%s) to %d group members",
-		expense.ID, expense.Name, len(members))
-	return nil
+	if notified > 0 {
+		log.Printf("Sent due date notification for expense %d (%s) to %d users",
+			expense.ID, expense.Name, notified)
+	}
+	return errors.Join(errs...)
 }
 
 // GetUpcomingDueDatesCount returns the count of unpaid fixed expenses due in 3 days
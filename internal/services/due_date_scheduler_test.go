@@ -1,6 +1,7 @@
 package services
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -532,3 +533,43 @@ func TestDueDateSchedulerService_GetUpcomingDueDatesCount_InactiveExpenses(t *te
 		t.Errorf("Expected 1 upcoming due date (only active), got %d", count)
 	}
 }
+
+func TestDueDateSchedulerService_CheckUpcomingDueDates_RetriesOnlyMissingMembers(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user1 := testutil.CreateTestUser(db, "user1@example.com", "User One", "hash1")
+	user2 := testutil.CreateTestUser(db, "user2@example.com", "User Two", "hash2")
+	group := &models.FamilyGroup{Name: "Test Group", CreatedByID: user1.ID}
+	db.Create(group)
+	db.Create(&models.GroupMember{GroupID: group.ID, UserID: user1.ID, Role: "owner"})
+	db.Create(&models.GroupMember{GroupID: group.ID, UserID: user2.ID, Role: "member"})
+	account := testutil.CreateTestAccount(db, "Joint Account", models.AccountTypeJoint, 0, &group.ID)
+
+	targetDate := time.Now().AddDate(0, 0, 3)
+	expense := &models.Expense{
+		AccountID: account.ID,
+		Name:      "Shared Utility Bill",
+		Amount:    200.0,
+		Type:      models.ExpenseTypeFixed,
+		DueDay:    targetDate.Day(),
+		Active:    true,
+	}
+	db.Create(expense)
+
+	// A previous run notified user1 and then failed for user2
+	ClaimIdempotencyKey(db, JobDueDateNotifications, dueDateNotificationKey(expense, targetDate, fmt.Sprint(user1.ID)))
+
+	scheduler := NewDueDateSchedulerService()
+	for run := 0; run < 2; run++ {
+		if err := scheduler.CheckUpcomingDueDates(); err != nil {
+			t.Fatalf("CheckUpcomingDueDates() error = %v", err)
+		}
+	}
+
+	var notified []uint
+	db.Model(&models.Notification{}).Where("group_id = ?", group.ID).Pluck("user_id", &notified)
+	if len(notified) != 1 || notified[0] != user2.ID {
+		t.Errorf("Notified users = %v, want only user2 (%d), once", notified, user2.ID)
+	}
+}
@@ -1,42 +1,45 @@
 package services
 
 import (
+	"bytes"
 	"fmt"
+	"html/template"
 	"os"
 
-	"github.com/resend/resend-go/v2"
+	"poc-finance/internal/models"
 )
 
-// EmailService handles sending emails via Resend API
+// EmailService handles sending emails via Resend API, or through SMTP or a file sink
+// when EMAIL_TRANSPORT selects one (see newEmailTransport)
 type EmailService struct {
-	apiKey string
-	from   string
-	client *resend.Client
+	from      string
+	transport emailTransport
 }
 
 // NewEmailService creates a new email service from environment variables
 func NewEmailService() *EmailService {
-	apiKey := os.Getenv("RESEND_API_KEY")
 	from := os.Getenv("EMAIL_FROM")
 	if from == "" {
 		from = "onboarding@resend.dev" // Default Resend test sender
 	}
 
-	var client *resend.Client
-	if apiKey != "" {
-		client = resend.NewClient(apiKey)
-	}
-
 	return &EmailService{
-		apiKey: apiKey,
-		from:   from,
-		client: client,
+		from:      from,
+		transport: newEmailTransport(os.Getenv("RESEND_API_KEY")),
 	}
 }
 
 // IsConfigured returns true if email service is configured
 func (s *EmailService) IsConfigured() bool {
-	return s.apiKey != "" && s.client != nil
+	return s.transport != nil
+}
+
+// send delivers an HTML email to a single recipient
+func (s *EmailService) send(toEmail, subject, html string) error {
+	if !s.IsConfigured() {
+		return fmt.Errorf("serviço de email não configurado")
+	}
+	return s.transport.Send(s.from, []string{toEmail}, subject, html)
 }
 
 // SendPasswordResetEmail sends a password reset email with the given token
@@ -74,17 +77,129 @@ func (s *EmailService) SendPasswordResetEmail(toEmail, userName, resetToken, bas
 </html>
 `, userName, resetLink, resetLink)
 
-	params := &resend.SendEmailRequest{
-		From:    s.from,
-		To:      []string{toEmail},
-		Subject: subject,
-		Html:    html,
+	return s.send(toEmail, subject, html)
+}
+
+// summaryEmailTemplate renders periodic summaries. Unlike the other emails it goes through
+// html/template because group and goal names are user input.
+var summaryEmailTemplate = template.Must(template.New("summary").Parse(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+</head>
+<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333;">
+    <div style="max-width: 600px; margin: 0 auto; padding: 20px;">
+        <h2 style="color: #2563eb;">{{.Title}}</h2>
+        <p>Olá <strong>{{.UserName}}</strong>,</p>
+        <p>Este é o resumo de <strong>{{.Summary.PeriodLabel}}</strong>.</p>
+        <table style="width: 100%; border-collapse: collapse; margin: 20px 0;">
+            <tr>
+                <td style="padding: 8px; border-bottom: 1px solid #eee;">Receitas ({{.Summary.IncomeCount}})</td>
+                <td style="padding: 8px; border-bottom: 1px solid #eee; text-align: right; color: #16a34a;">R$ {{printf "%.2f" .Summary.TotalIncome}}</td>
+            </tr>
+            <tr>
+                <td style="padding: 8px; border-bottom: 1px solid #eee;">Despesas ({{.Summary.ExpenseCount}})</td>
+                <td style="padding: 8px; border-bottom: 1px solid #eee; text-align: right; color: #dc2626;">R$ {{printf "%.2f" .Summary.TotalExpenses}}</td>
+            </tr>
+            <tr>
+                <td style="padding: 8px;"><strong>Saldo</strong></td>
+                <td style="padding: 8px; text-align: right;"><strong>R$ {{printf "%.2f" .Summary.Balance}}</strong></td>
+            </tr>
+        </table>
+        {{if .Summary.GoalsProgress}}
+        <h3 style="color: #2563eb;">Metas</h3>
+        <ul>
+            {{range .Summary.GoalsProgress}}
+            <li>{{.Name}}: R$ {{printf "%.2f" .CurrentAmount}} de R$ {{printf "%.2f" .TargetAmount}} ({{printf "%.1f" .Percentage}}%)</li>
+            {{end}}
+        </ul>
+        {{end}}
+        <div style="text-align: center; margin: 30px 0;">
+            <a href="{{.Link}}" style="background-color: #2563eb; color: white; padding: 12px 24px; text-decoration: none; border-radius: 6px; display: inline-block;">Ver Detalhes</a>
+        </div>
+        <hr style="border: none; border-top: 1px solid #eee; margin: 30px 0;">
+        <p style="color: #999; font-size: 12px;">Você pode deixar de receber estes emails nas preferências de resumo.</p>
+        <p style="color: #999; font-size: 12px;">— Equipe POC Finance</p>
+    </div>
+</body>
+</html>
+`))
+
+// SendSummaryEmail sends a weekly or monthly summary of a group or of the user's own accounts
+func (s *EmailService) SendSummaryEmail(toEmail, userName string, summary GroupSummaryData, link string) error {
+	if !s.IsConfigured() {
+		return fmt.Errorf("serviço de email não configurado")
+	}
+
+	title := "Resumo semanal"
+	if summary.PeriodType == "monthly" {
+		title = "Resumo mensal"
+	}
+	title = fmt.Sprintf("%s - %s", title, summary.GroupName)
+
+	var html bytes.Buffer
+	err := summaryEmailTemplate.Execute(&html, map[string]interface{}{
+		"Title":    title,
+		"UserName": userName,
+		"Summary":  summary,
+		"Link":     link,
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao gerar email de resumo: %w", err)
+	}
+
+	return s.send(toEmail, title+" - POC Finance", html.String())
+}
+
+// notificationEmailTemplate renders one notification or a digest of several
+var notificationEmailTemplate = template.Must(template.New("notification").Parse(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+</head>
+<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333;">
+    <div style="max-width: 600px; margin: 0 auto; padding: 20px;">
+        <h2 style="color: #2563eb;">{{.Title}}</h2>
+        <p>Olá <strong>{{.UserName}}</strong>,</p>
+        {{range .Notifications}}
+        <div style="border-bottom: 1px solid #eee; padding: 12px 0;">
+            <p style="margin: 0;"><strong>{{.Title}}</strong></p>
+            <p style="margin: 4px 0;">{{.Message}}</p>
+            {{if .Link}}<a href="{{$.BaseURL}}{{.Link}}" style="color: #2563eb; font-size: 14px;">Ver no POC Finance</a>{{end}}
+        </div>
+        {{end}}
+        <hr style="border: none; border-top: 1px solid #eee; margin: 30px 0;">
+        <p style="color: #999; font-size: 12px;">Você pode escolher quais notificações recebe por email em {{.BaseURL}}/notifications.</p>
+        <p style="color: #999; font-size: 12px;">— Equipe POC Finance</p>
+    </div>
+</body>
+</html>
+`))
+
+// SendNotificationEmail sends notifications by email. With a single notification the email
+// carries its title; several notifications go out as a digest.
+func (s *EmailService) SendNotificationEmail(toEmail, userName string, notifications []*models.Notification, baseURL string) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	title := notifications[0].Title
+	if len(notifications) > 1 {
+		title = fmt.Sprintf("Resumo de %d notificações", len(notifications))
 	}
 
-	_, err := s.client.Emails.Send(params)
+	var html bytes.Buffer
+	err := notificationEmailTemplate.Execute(&html, map[string]interface{}{
+		"Title":         title,
+		"UserName":      userName,
+		"Notifications": notifications,
+		"BaseURL":       baseURL,
+	})
 	if err != nil {
-		return fmt.Errorf("erro ao enviar email via Resend: %w", err)
+		return fmt.Errorf("erro ao gerar email de notificação: %w", err)
 	}
 
-	return nil
+	return s.send(toEmail, title+" - POC Finance", html.String())
 }
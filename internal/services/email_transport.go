@@ -0,0 +1,127 @@
+package services
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/resend/resend-go/v2"
+)
+
+// Values of EMAIL_TRANSPORT. With any other value, email goes through Resend when
+// RESEND_API_KEY is set.
+const (
+	EmailTransportSMTP = "smtp"
+	EmailTransportFile = "file"
+)
+
+// emailTransport delivers a rendered HTML email
+type emailTransport interface {
+	Send(from string, to []string, subject, html string) error
+}
+
+// newEmailTransport picks the transport configured in the environment, or nil when
+// email is disabled
+func newEmailTransport(apiKey string) emailTransport {
+	switch strings.ToLower(os.Getenv("EMAIL_TRANSPORT")) {
+	case EmailTransportSMTP:
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			host = "localhost"
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "1025" // Default port of local catchers such as MailHog and Mailpit
+		}
+		transport := &smtpEmailTransport{addr: net.JoinHostPort(host, port)}
+		if username := os.Getenv("SMTP_USERNAME"); username != "" {
+			transport.auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+		}
+		return transport
+	case EmailTransportFile:
+		dir := os.Getenv("EMAIL_FILE_DIR")
+		if dir == "" {
+			dir = "emails"
+		}
+		return &fileEmailTransport{dir: dir}
+	}
+
+	if apiKey == "" {
+		return nil
+	}
+	return &resendEmailTransport{client: resend.NewClient(apiKey)}
+}
+
+// resendEmailTransport sends email through the Resend API
+type resendEmailTransport struct {
+	client *resend.Client
+}
+
+func (t *resendEmailTransport) Send(from string, to []string, subject, html string) error {
+	_, err := t.client.Emails.Send(&resend.SendEmailRequest{
+		From:    from,
+		To:      to,
+		Subject: subject,
+		Html:    html,
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao enviar email via Resend: %w", err)
+	}
+	return nil
+}
+
+// smtpEmailTransport sends email to an SMTP server, typically a local catcher in development
+type smtpEmailTransport struct {
+	addr string
+	auth smtp.Auth
+}
+
+func (t *smtpEmailTransport) Send(from string, to []string, subject, html string) error {
+	if err := smtp.SendMail(t.addr, t.auth, from, to, buildEmailMessage(from, to, subject, html)); err != nil {
+		return fmt.Errorf("erro ao enviar email via SMTP: %w", err)
+	}
+	return nil
+}
+
+// fileEmailTransport writes each email as an .eml file, so emails can be checked offline
+type fileEmailTransport struct {
+	dir string
+}
+
+var (
+	emailFileSequence uint64
+	emailFileUnsafe   = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+)
+
+func (t *fileEmailTransport) Send(from string, to []string, subject, html string) error {
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return fmt.Errorf("erro ao criar diretório de emails: %w", err)
+	}
+	name := fmt.Sprintf("%s-%04d-%s.eml", time.Now().Format("20060102-150405"),
+		atomic.AddUint64(&emailFileSequence, 1)%10000, strings.Trim(emailFileUnsafe.ReplaceAllString(to[0], "_"), "_"))
+	if err := os.WriteFile(filepath.Join(t.dir, name), buildEmailMessage(from, to, subject, html), 0o644); err != nil {
+		return fmt.Errorf("erro ao gravar email: %w", err)
+	}
+	return nil
+}
+
+// buildEmailMessage renders a minimal MIME message with an HTML body
+func buildEmailMessage(from string, to []string, subject, html string) []byte {
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(html)
+	return []byte(message.String())
+}
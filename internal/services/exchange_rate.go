@@ -0,0 +1,87 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ptaxURL is the Banco Central do Brasil PTAX endpoint for the USD/BRL rate of a given day
+const ptaxURL = "https://olinda.bcb.gov.br/olinda/servico/PTAX/versao/v1/odata/" +
+	"CotacaoDolarDia(dataCotacao=@dataCotacao)?@dataCotacao='%s'&$format=json"
+
+// ExchangeRateService resolves the USD/BRL exchange rate used when generating
+// foreign-currency incomes. Rates come from the BCB PTAX (selling rate) and are
+// cached per day.
+type ExchangeRateService struct {
+	fetch func(date time.Time) (float64, error)
+	cache map[string]float64
+	mu    sync.Mutex
+}
+
+// NewExchangeRateService creates an exchange rate service backed by the BCB PTAX API
+func NewExchangeRateService() *ExchangeRateService {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return &ExchangeRateService{
+		fetch: func(date time.Time) (float64, error) {
+			return fetchPTAXRate(client, date)
+		},
+		cache: make(map[string]float64),
+	}
+}
+
+// GetUSDRate returns the USD/BRL rate for the given date. PTAX is not published on
+// weekends and holidays, so the most recent rate in the previous week is used.
+func (s *ExchangeRateService) GetUSDRate(date time.Time) (float64, error) {
+	key := date.Format("2006-01-02")
+	s.mu.Lock()
+	if rate, ok := s.cache[key]; ok {
+		s.mu.Unlock()
+		return rate, nil
+	}
+	s.mu.Unlock()
+
+	for i := 0; i < 7; i++ {
+		rate, err := s.fetch(date.AddDate(0, 0, -i))
+		if err != nil {
+			return 0, err
+		}
+		if rate > 0 {
+			s.mu.Lock()
+			s.cache[key] = rate
+			s.mu.Unlock()
+			return rate, nil
+		}
+	}
+
+	return 0, fmt.Errorf("cotação não encontrada para %s", key)
+}
+
+// fetchPTAXRate queries the PTAX selling rate for a single day (0 when not published)
+func fetchPTAXRate(client *http.Client, date time.Time) (float64, error) {
+	resp, err := client.Get(fmt.Sprintf(ptaxURL, date.Format("01-02-2006")))
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch PTAX rate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("PTAX request failed with status %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		Value []struct {
+			CotacaoVenda float64 `json:"cotacaoVenda"`
+		} `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return 0, fmt.Errorf("failed to decode PTAX response: %w", err)
+	}
+
+	if len(payload.Value) == 0 {
+		return 0, nil
+	}
+	return payload.Value[0].CotacaoVenda, nil
+}
@@ -16,12 +16,14 @@ var (
 type GoalService struct {
 	groupService        *GroupService
 	notificationService *NotificationService
+	webhookService      *WebhookService
 }
 
 func NewGoalService() *GoalService {
 	return &GoalService{
 		groupService:        NewGroupService(),
 		notificationService: NewNotificationService(),
+		webhookService:      NewWebhookService(),
 	}
 }
 
@@ -198,6 +200,13 @@ func (s *GoalService) updateGoalCurrentAmount(goalID uint) {
 		members, err := s.groupService.GetGroupMembers(goal.GroupID)
 		if err == nil && len(members) > 0 {
 			s.notificationService.NotifyGoalReached(&goal, members)
+			s.webhookService.EmitForUsers(members, models.WebhookEventGoalReached, map[string]interface{}{
+				"goal_id":        goal.ID,
+				"group_id":       goal.GroupID,
+				"name":           goal.Name,
+				"target_amount":  goal.TargetAmount,
+				"current_amount": totalAmount,
+			})
 		}
 	}
 
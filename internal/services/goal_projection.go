@@ -0,0 +1,200 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+var (
+	ErrAutoContributionExists = errors.New("meta já possui aporte automático")
+	ErrInvalidContribution    = errors.New("valor do aporte deve ser maior que zero")
+)
+
+// GoalProjectionStatus represents whether a goal is on pace to hit its target date
+type GoalProjectionStatus string
+
+const (
+	GoalProjectionCompleted GoalProjectionStatus = "completed"
+	GoalProjectionOnTrack   GoalProjectionStatus = "on_track"
+	GoalProjectionBehind    GoalProjectionStatus = "behind"
+	GoalProjectionOverdue   GoalProjectionStatus = "overdue"
+)
+
+// GoalProjection holds the forecast for a single goal
+type GoalProjection struct {
+	GoalID                      uint                 `json:"goal_id"`
+	Status                      GoalProjectionStatus `json:"status"`
+	RemainingAmount             float64              `json:"remaining_amount"`
+	MonthsRemaining             int                  `json:"months_remaining"`
+	RequiredMonthlyContribution float64              `json:"required_monthly_contribution"`
+	CurrentMonthlyPace          float64              `json:"current_monthly_pace"`
+	AutoContributionAmount      float64              `json:"auto_contribution_amount"`
+	ProjectedCompletionDate     *time.Time           `json:"projected_completion_date"`
+}
+
+// ProjectGoal calculates the forecast for a goal at the given reference date.
+// The current pace is the average monthly contribution since the goal started;
+// when an automatic contribution is configured its amount is used instead if higher,
+// since that money is already committed.
+func ProjectGoal(goal *models.GroupGoal, autoContribution float64, now time.Time) GoalProjection {
+	projection := GoalProjection{
+		GoalID:                 goal.ID,
+		RemainingAmount:        goal.RemainingAmount(),
+		AutoContributionAmount: autoContribution,
+	}
+
+	if goal.IsCompleted() || goal.Status == models.GoalStatusCompleted {
+		projection.Status = GoalProjectionCompleted
+		return projection
+	}
+
+	// Average monthly contribution since the goal was created
+	monthsElapsed := monthsBetween(goal.StartDate, now)
+	if monthsElapsed < 1 {
+		monthsElapsed = 1
+	}
+	projection.CurrentMonthlyPace = goal.CurrentAmount / monthsElapsed
+	if autoContribution > projection.CurrentMonthlyPace {
+		projection.CurrentMonthlyPace = autoContribution
+	}
+
+	// Required contribution spreads what is left over the months until the target date.
+	// A goal due this month (or already overdue) needs the full remaining amount now.
+	projection.MonthsRemaining = int(math.Ceil(monthsBetween(now, goal.TargetDate)))
+	if projection.MonthsRemaining < 1 {
+		projection.RequiredMonthlyContribution = projection.RemainingAmount
+	} else {
+		projection.RequiredMonthlyContribution = projection.RemainingAmount / float64(projection.MonthsRemaining)
+	}
+
+	if projection.CurrentMonthlyPace > 0 {
+		daysToComplete := int(math.Ceil(projection.RemainingAmount / projection.CurrentMonthlyPace * 30))
+		completion := now.AddDate(0, 0, daysToComplete)
+		projection.ProjectedCompletionDate = &completion
+	}
+
+	switch {
+	case now.After(goal.TargetDate):
+		projection.Status = GoalProjectionOverdue
+	case projection.ProjectedCompletionDate != nil && !projection.ProjectedCompletionDate.After(goal.TargetDate):
+		projection.Status = GoalProjectionOnTrack
+	default:
+		projection.Status = GoalProjectionBehind
+	}
+
+	return projection
+}
+
+// monthsBetween returns the fractional number of months between two dates (30-day months)
+func monthsBetween(from, to time.Time) float64 {
+	return to.Sub(from).Hours() / 24 / 30
+}
+
+// GetGoalProjections returns projections for every goal of a group keyed by goal ID
+func (s *GoalService) GetGoalProjections(goals []models.GroupGoal) (map[uint]GoalProjection, error) {
+	projections := make(map[uint]GoalProjection, len(goals))
+	if len(goals) == 0 {
+		return projections, nil
+	}
+
+	goalIDs := make([]uint, len(goals))
+	for i, goal := range goals {
+		goalIDs[i] = goal.ID
+	}
+
+	// Load active automatic contributions in one query
+	var autoContributions []models.RecurringTransaction
+	if err := database.DB.Where("goal_id IN ? AND active = ?", goalIDs, true).Find(&autoContributions).Error; err != nil {
+		return nil, err
+	}
+
+	autoByGoal := make(map[uint]float64)
+	for _, rt := range autoContributions {
+		autoByGoal[*rt.GoalID] += rt.Amount
+	}
+
+	now := time.Now()
+	for i := range goals {
+		projections[goals[i].ID] = ProjectGoal(&goals[i], autoByGoal[goals[i].ID], now)
+	}
+
+	return projections, nil
+}
+
+// CreateAutoContribution schedules a monthly RecurringTransaction that contributes
+// the given amount to the goal. The expense is drawn from the user's individual account,
+// so the scheduler can attribute each contribution to the account owner.
+// Only one active automatic contribution per user and goal is allowed.
+func (s *GoalService) CreateAutoContribution(goalID, userID uint, amount float64) (*models.RecurringTransaction, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidContribution
+	}
+
+	goal, err := s.GetGoalByID(goalID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.groupService.IsGroupMember(goal.GroupID, userID) {
+		return nil, ErrUnauthorized
+	}
+
+	if goal.Status != models.GoalStatusActive {
+		return nil, ErrGoalCompleted
+	}
+
+	account, err := NewAccountService().GetUserIndividualAccount(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing int64
+	database.DB.Model(&models.RecurringTransaction{}).
+		Where("goal_id = ? AND account_id = ? AND active = ?", goalID, account.ID, true).
+		Count(&existing)
+	if existing > 0 {
+		return nil, ErrAutoContributionExists
+	}
+
+	// First contribution on the first day of next month
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+
+	endDate := goal.TargetDate
+	rt := &models.RecurringTransaction{
+		AccountID:       account.ID,
+		TransactionType: models.TransactionTypeExpense,
+		Frequency:       models.FrequencyMonthly,
+		Amount:          amount,
+		Description:     fmt.Sprintf("Aporte meta: %s", goal.Name),
+		StartDate:       start,
+		EndDate:         &endDate,
+		NextRunDate:     start,
+		Active:          true,
+		Category:        "Metas",
+		GoalID:          &goalID,
+	}
+
+	if err := database.DB.Create(rt).Error; err != nil {
+		return nil, err
+	}
+
+	return rt, nil
+}
+
+// CancelAutoContribution deactivates the user's automatic contribution for a goal
+func (s *GoalService) CancelAutoContribution(goalID, userID uint) error {
+	account, err := NewAccountService().GetUserIndividualAccount(userID)
+	if err != nil {
+		return err
+	}
+
+	return database.DB.Model(&models.RecurringTransaction{}).
+		Where("goal_id = ? AND account_id = ? AND active = ?", goalID, account.ID, true).
+		Update("active", false).Error
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestProjectGoal(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		goal             models.GroupGoal
+		autoContribution float64
+		wantStatus       GoalProjectionStatus
+		wantRequired     float64
+		wantPace         float64
+		wantCompletion   bool
+	}{
+		{
+			name: "completed goal",
+			goal: models.GroupGoal{
+				TargetAmount:  1000,
+				CurrentAmount: 1000,
+				StartDate:     now.AddDate(0, -6, 0),
+				TargetDate:    now.AddDate(0, 6, 0),
+				Status:        models.GoalStatusCompleted,
+			},
+			wantStatus: GoalProjectionCompleted,
+		},
+		{
+			name: "on track at current pace",
+			goal: models.GroupGoal{
+				TargetAmount:  1200,
+				CurrentAmount: 600,
+				StartDate:     now.AddDate(0, 0, -180),
+				TargetDate:    now.AddDate(0, 0, 180),
+				Status:        models.GoalStatusActive,
+			},
+			wantStatus:     GoalProjectionOnTrack,
+			wantRequired:   100,
+			wantPace:       100,
+			wantCompletion: true,
+		},
+		{
+			name: "behind with no contributions",
+			goal: models.GroupGoal{
+				TargetAmount:  1200,
+				CurrentAmount: 0,
+				StartDate:     now.AddDate(0, 0, -60),
+				TargetDate:    now.AddDate(0, 0, 120),
+				Status:        models.GoalStatusActive,
+			},
+			wantStatus:   GoalProjectionBehind,
+			wantRequired: 300,
+			wantPace:     0,
+		},
+		{
+			name: "automatic contribution puts goal on track",
+			goal: models.GroupGoal{
+				TargetAmount:  1200,
+				CurrentAmount: 0,
+				StartDate:     now.AddDate(0, 0, -60),
+				TargetDate:    now.AddDate(0, 0, 120),
+				Status:        models.GoalStatusActive,
+			},
+			autoContribution: 300,
+			wantStatus:       GoalProjectionOnTrack,
+			wantRequired:     300,
+			wantPace:         300,
+			wantCompletion:   true,
+		},
+		{
+			name: "overdue goal needs full remaining amount",
+			goal: models.GroupGoal{
+				TargetAmount:  1000,
+				CurrentAmount: 400,
+				StartDate:     now.AddDate(0, 0, -120),
+				TargetDate:    now.AddDate(0, 0, -1),
+				Status:        models.GoalStatusActive,
+			},
+			wantStatus:     GoalProjectionOverdue,
+			wantRequired:   600,
+			wantPace:       100,
+			wantCompletion: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ProjectGoal(&tt.goal, tt.autoContribution, now)
+
+			if got.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s", got.Status, tt.wantStatus)
+			}
+			if math.Abs(got.RequiredMonthlyContribution-tt.wantRequired) > 0.01 {
+				t.Errorf("RequiredMonthlyContribution = %.2f, want %.2f", got.RequiredMonthlyContribution, tt.wantRequired)
+			}
+			if math.Abs(got.CurrentMonthlyPace-tt.wantPace) > 0.01 {
+				t.Errorf("CurrentMonthlyPace = %.2f, want %.2f", got.CurrentMonthlyPace, tt.wantPace)
+			}
+			if (got.ProjectedCompletionDate != nil) != tt.wantCompletion {
+				t.Errorf("ProjectedCompletionDate = %v, want set = %v", got.ProjectedCompletionDate, tt.wantCompletion)
+			}
+		})
+	}
+}
+
+func TestGoalService_CreateAutoContribution(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+	group := testutil.CreateTestGroup(db, "Family", user.ID)
+	testutil.CreateTestGroupMember(db, group.ID, user.ID, "admin")
+
+	goalService := NewGoalService()
+	goal, err := goalService.CreateGoal(group.ID, user.ID, "Viagem", "", 1200, time.Now().AddDate(1, 0, 0), nil)
+	if err != nil {
+		t.Fatalf("CreateGoal() error = %v", err)
+	}
+
+	rt, err := goalService.CreateAutoContribution(goal.ID, user.ID, 100)
+	if err != nil {
+		t.Fatalf("CreateAutoContribution() error = %v", err)
+	}
+
+	if rt.AccountID != account.ID {
+		t.Errorf("AccountID = %d, want %d", rt.AccountID, account.ID)
+	}
+	if rt.GoalID == nil || *rt.GoalID != goal.ID {
+		t.Errorf("GoalID = %v, want %d", rt.GoalID, goal.ID)
+	}
+	if rt.Frequency != models.FrequencyMonthly {
+		t.Errorf("Frequency = %s, want monthly", rt.Frequency)
+	}
+
+	// A second automatic contribution for the same goal is rejected
+	if _, err := goalService.CreateAutoContribution(goal.ID, user.ID, 50); err != ErrAutoContributionExists {
+		t.Errorf("second CreateAutoContribution() error = %v, want %v", err, ErrAutoContributionExists)
+	}
+
+	projections, err := goalService.GetGoalProjections([]models.GroupGoal{*goal})
+	if err != nil {
+		t.Fatalf("GetGoalProjections() error = %v", err)
+	}
+	if projections[goal.ID].AutoContributionAmount != 100 {
+		t.Errorf("AutoContributionAmount = %.2f, want 100", projections[goal.ID].AutoContributionAmount)
+	}
+
+	if err := goalService.CancelAutoContribution(goal.ID, user.ID); err != nil {
+		t.Fatalf("CancelAutoContribution() error = %v", err)
+	}
+
+	projections, err = goalService.GetGoalProjections([]models.GroupGoal{*goal})
+	if err != nil {
+		t.Fatalf("GetGoalProjections() error = %v", err)
+	}
+	if projections[goal.ID].AutoContributionAmount != 0 {
+		t.Errorf("AutoContributionAmount after cancel = %.2f, want 0", projections[goal.ID].AutoContributionAmount)
+	}
+
+	// A failed lookup is reported instead of showing goals without automatic contributions
+	db.Migrator().DropTable(&models.RecurringTransaction{})
+	if _, err := goalService.GetGoalProjections([]models.GroupGoal{*goal}); err == nil {
+		t.Error("GetGoalProjections() without the recurring transactions table error = nil, want the query failure")
+	}
+}
+
+func TestRecurringSchedulerService_ProcessDueTransactions_GoalContribution(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+	group := testutil.CreateTestGroup(db, "Family", user.ID)
+	testutil.CreateTestGroupMember(db, group.ID, user.ID, "admin")
+
+	goalService := NewGoalService()
+	goal, _ := goalService.CreateGoal(group.ID, user.ID, "Reserva", "", 1000, time.Now().AddDate(1, 0, 0), nil)
+
+	today := time.Now()
+	rt := &models.RecurringTransaction{
+		AccountID:       account.ID,
+		TransactionType: models.TransactionTypeExpense,
+		Frequency:       models.FrequencyMonthly,
+		Amount:          250,
+		Description:     "Aporte meta: Reserva",
+		StartDate:       today,
+		NextRunDate:     today,
+		Active:          true,
+		Category:        "Metas",
+		GoalID:          &goal.ID,
+	}
+	db.Create(rt)
+
+	if err := NewRecurringSchedulerService().ProcessDueTransactions(); err != nil {
+		t.Fatalf("ProcessDueTransactions() error = %v", err)
+	}
+
+	updated, _ := goalService.GetGoalByID(goal.ID)
+	if updated.CurrentAmount != 250 {
+		t.Errorf("CurrentAmount = %.2f, want 250", updated.CurrentAmount)
+	}
+}
+
+func TestRecurringSchedulerService_ProcessDueTransactions_GoalContributionRetried(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	owner := testutil.CreateTestUser(db, "owner@example.com", "Owner", "hash")
+	admin := testutil.CreateTestUser(db, "admin@example.com", "Admin", "hash")
+	account := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, owner.ID, nil)
+	group := testutil.CreateTestGroup(db, "Family", admin.ID)
+	testutil.CreateTestGroupMember(db, group.ID, admin.ID, "admin")
+
+	goalService := NewGoalService()
+	goal, _ := goalService.CreateGoal(group.ID, admin.ID, "Reserva", "", 1000, time.Now().AddDate(1, 0, 0), nil)
+
+	today := time.Now()
+	rt := &models.RecurringTransaction{
+		AccountID:       account.ID,
+		TransactionType: models.TransactionTypeExpense,
+		Frequency:       models.FrequencyMonthly,
+		Amount:          250,
+		Description:     "Aporte meta: Reserva",
+		StartDate:       today,
+		NextRunDate:     today,
+		Active:          true,
+		Category:        "Metas",
+		GoalID:          &goal.ID,
+	}
+	db.Create(rt)
+
+	// The owner isn't a member yet, so the contribution fails after the expense is generated
+	service := NewRecurringSchedulerService()
+	if err := service.ProcessDueTransactions(); err == nil {
+		t.Fatal("ProcessDueTransactions() error = nil, want the contribution failure")
+	}
+
+	// The retry finds the occurrence already generated and still makes the contribution
+	testutil.CreateTestGroupMember(db, group.ID, owner.ID, "member")
+	if err := service.ProcessDueTransactions(); err != nil {
+		t.Fatalf("ProcessDueTransactions() retry error = %v", err)
+	}
+	// Walking the occurrence again never contributes twice
+	db.Model(&models.RecurringTransaction{}).Where("id = ?", rt.ID).Update("next_run_date", today)
+	if err := service.ProcessDueTransactions(); err != nil {
+		t.Fatalf("ProcessDueTransactions() error = %v", err)
+	}
+
+	var expenses int64
+	db.Model(&models.Expense{}).Where("account_id = ?", account.ID).Count(&expenses)
+	updated, _ := goalService.GetGoalByID(goal.ID)
+	if expenses != 1 || updated.CurrentAmount != 250 {
+		t.Errorf("got %d expenses and CurrentAmount %.2f, want one expense and a single 250 contribution", expenses, updated.CurrentAmount)
+	}
+}
@@ -1,31 +1,47 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"time"
 
+	"gorm.io/gorm"
+
 	"poc-finance/internal/database"
 	"poc-finance/internal/models"
 )
 
 // Recommendation represents a personalized financial health recommendation
 type Recommendation struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	ActionUrl   string `json:"action_url"`
-	ActionText  string `json:"action_text"`
-	Priority    string `json:"priority"` // "high", "medium", "low"
-	Color       string `json:"color"`    // CSS color class: "danger", "warning", "brand", "success"
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	ActionUrl   string  `json:"action_url"`
+	ActionText  string  `json:"action_text"`
+	Priority    string  `json:"priority"`     // "high", "medium", "low"
+	Color       string  `json:"color"`        // CSS color class: "danger", "warning", "brand", "success"
+	Key         string  `json:"key"`          // Identifies the advice to dismiss, snooze or complete it
+	Area        string  `json:"area"`         // Score component the advice improves
+	ScoreImpact float64 `json:"score_impact"` // Estimated increase of the overall score when followed
 }
 
 // HealthScoreService handles financial health score calculations and recommendations
 type HealthScoreService struct {
-	accountService *AccountService
+	accountService      *AccountService
+	groupService        *GroupService
+	goalService         *GoalService
+	subscriptionService *SubscriptionService
 }
 
 // NewHealthScoreService creates a new HealthScoreService instance
 func NewHealthScoreService() *HealthScoreService {
 	return &HealthScoreService{
-		accountService: NewAccountService(),
+		accountService:      NewAccountService(),
+		groupService:        NewGroupService(),
+		goalService:         NewGoalService(),
+		subscriptionService: NewSubscriptionService(),
 	}
 }
 
@@ -42,40 +58,93 @@ func (s *HealthScoreService) getRecordStartDate() time.Time {
 	return parsed
 }
 
-// CalculateUserScore calculates the financial health score for a user
-// Scoring formula: 30% savings rate + 25% debt level + 25% goal progress + 20% budget adherence
-func (s *HealthScoreService) CalculateUserScore(userID uint, accountIDs []uint) (*models.HealthScore, error) {
-	// Get record start date from settings
-	recordStartDate := s.getRecordStartDate()
+// HealthScoreInputs are the inputs of each component of a score. They are stored as JSON
+// in the score's Metadata so a score can be explained later.
+type HealthScoreInputs struct {
+	Weights         HealthScoreWeights    `json:"weights"`
+	Thresholds      HealthScoreThresholds `json:"thresholds"`
+	Savings         SavingsInputs         `json:"savings"`
+	Debt            DebtInputs            `json:"debt"`
+	EmergencyFund   EmergencyFundInputs   `json:"emergency_fund"`
+	IncomeStability IncomeStabilityInputs `json:"income_stability"`
+	Goals           GoalInputs            `json:"goals"`
+	Budget          BudgetInputs          `json:"budget"`
+	NetWorth        NetWorthInputs        `json:"net_worth"`
+}
 
-	// Calculate component scores
-	savingsScore := s.calculateSavingsScore(accountIDs, recordStartDate)
-	debtScore := s.calculateDebtScore(accountIDs, recordStartDate)
-	goalScore := s.calculateGoalScore(userID, nil)
-	budgetScore := s.calculateBudgetScore(accountIDs, recordStartDate)
+// SavingsInputs are the totals of the last 3 months
+type SavingsInputs struct {
+	Income      float64 `json:"income"`
+	Expenses    float64 `json:"expenses"`
+	SavingsRate float64 `json:"savings_rate"`
+}
 
-	// Calculate weighted overall score
-	overallScore := (savingsScore * 0.30) + (debtScore * 0.25) + (goalScore * 0.25) + (budgetScore * 0.20)
+// DebtInputs are the monthly obligations and the outstanding debt
+type DebtInputs struct {
+	MonthlyIncome   float64 `json:"monthly_income"`
+	FixedExpenses   float64 `json:"fixed_expenses"`
+	Bills           float64 `json:"bills"`
+	InstallmentsDue float64 `json:"installments_due"` // Card installments of the current month
+	ObligationRatio float64 `json:"obligation_ratio"`
+	OutstandingDebt float64 `json:"outstanding_debt"` // Manual liabilities plus remaining installments
+	AnnualIncome    float64 `json:"annual_income"`
+	DebtToIncome    float64 `json:"debt_to_income"`
+}
 
-	// Create and save health score
-	healthScore := &models.HealthScore{
-		UserID:       &userID,
-		Score:        overallScore,
-		SavingsScore: savingsScore,
-		DebtScore:    debtScore,
-		GoalScore:    goalScore,
-		BudgetScore:  budgetScore,
-		CalculatedAt: time.Now(),
-	}
+// EmergencyFundInputs are the liquid assets and the average monthly expenses they cover
+type EmergencyFundInputs struct {
+	LiquidAssets    float64 `json:"liquid_assets"`
+	MonthlyExpenses float64 `json:"monthly_expenses"`
+	Months          float64 `json:"months"`
+}
 
-	if err := database.DB.Create(healthScore).Error; err != nil {
-		return nil, err
+// IncomeStabilityInputs are the net incomes of the last 6 months, most recent first
+type IncomeStabilityInputs struct {
+	MonthlyIncomes []float64 `json:"monthly_incomes"`
+	Variation      float64   `json:"variation"`
+}
+
+// GoalInputs summarize the active goals
+type GoalInputs struct {
+	ActiveGoals     int     `json:"active_goals"`
+	AverageProgress float64 `json:"average_progress"`
+}
+
+// BudgetInputs are the expenses of the last 3 months, most recent first
+type BudgetInputs struct {
+	MonthlyExpenses []float64 `json:"monthly_expenses"`
+	Variation       float64   `json:"variation"`
+}
+
+// NetWorthInputs are the registered assets and liabilities
+type NetWorthInputs struct {
+	Assets      float64 `json:"assets"`
+	Liabilities float64 `json:"liabilities"`
+	DebtRatio   float64 `json:"debt_ratio"`
+}
+
+// ScoreInputs returns the inputs stored in a score's Metadata (zero values for scores
+// stored before inputs were recorded)
+func ScoreInputs(score *models.HealthScore) HealthScoreInputs {
+	var inputs HealthScoreInputs
+	if score.Metadata != "" {
+		json.Unmarshal([]byte(score.Metadata), &inputs)
 	}
+	return inputs
+}
 
-	return healthScore, nil
+// CalculateUserScore calculates the current financial health score for a user without
+// persisting it. Snapshots are stored by SnapshotUserScore.
+// The overall score is the average of the component scores weighted by the configured
+// weights (see DefaultHealthScoreConfig).
+func (s *HealthScoreService) CalculateUserScore(userID uint, accountIDs []uint) (*models.HealthScore, error) {
+	score := s.calculateScore(userID, nil, accountIDs)
+	score.UserID = &userID
+	return score, nil
 }
 
-// CalculateGroupScore calculates the financial health score for a family group
+// CalculateGroupScore calculates the current financial health score for a family group
+// without persisting it. Snapshots are stored by SnapshotGroupScore.
 func (s *HealthScoreService) CalculateGroupScore(groupID uint) (*models.HealthScore, error) {
 	// Get all accounts for the group (individual + joint)
 	accountIDs, err := s.accountService.GetAllGroupAccountIDs(groupID)
@@ -83,37 +152,216 @@ func (s *HealthScoreService) CalculateGroupScore(groupID uint) (*models.HealthSc
 		return nil, err
 	}
 
-	// Get record start date from settings
+	score := s.calculateScore(0, &groupID, accountIDs)
+	score.GroupID = &groupID
+	return score, nil
+}
+
+// calculateScore calculates the component scores over the accounts and combines them with
+// the configured weights. Net worth items are the user's personal ones, or the group's.
+func (s *HealthScoreService) calculateScore(userID uint, groupID *uint, accountIDs []uint) *models.HealthScore {
+	config := GetHealthScoreConfig()
 	recordStartDate := s.getRecordStartDate()
+	now := time.Now()
 
-	// Calculate component scores
-	savingsScore := s.calculateSavingsScore(accountIDs, recordStartDate)
-	debtScore := s.calculateDebtScore(accountIDs, recordStartDate)
-	goalScore := s.calculateGoalScore(0, &groupID)
-	budgetScore := s.calculateBudgetScore(accountIDs, recordStartDate)
+	snapshot, err := ownerNetWorthSnapshot(userID, groupID, now)
+	if err != nil {
+		snapshot = nil
+	}
+
+	inputs := HealthScoreInputs{Weights: config.Weights, Thresholds: config.Thresholds}
+	thresholds := config.Thresholds
+
+	score := &models.HealthScore{
+		SavingsScore:         s.calculateSavingsScore(accountIDs, recordStartDate, thresholds, &inputs.Savings),
+		DebtScore:            s.calculateDebtScore(accountIDs, recordStartDate, snapshot, thresholds, &inputs.Debt),
+		EmergencyFundScore:   s.calculateEmergencyFundScore(accountIDs, recordStartDate, snapshot, thresholds, &inputs.EmergencyFund),
+		IncomeStabilityScore: s.calculateIncomeStabilityScore(accountIDs, recordStartDate, thresholds, &inputs.IncomeStability),
+		GoalScore:            s.calculateGoalScore(userID, groupID, &inputs.Goals),
+		BudgetScore:          s.calculateBudgetScore(accountIDs, recordStartDate, thresholds, &inputs.Budget),
+		NetWorthScore:        s.calculateNetWorthScore(snapshot, thresholds, &inputs.NetWorth),
+		CalculatedAt:         now,
+	}
 
 	// Calculate weighted overall score
-	overallScore := (savingsScore * 0.30) + (debtScore * 0.25) + (goalScore * 0.25) + (budgetScore * 0.20)
+	weights := config.Weights
+	score.Score = (score.SavingsScore*weights.Savings +
+		score.DebtScore*weights.Debt +
+		score.EmergencyFundScore*weights.EmergencyFund +
+		score.IncomeStabilityScore*weights.IncomeStability +
+		score.GoalScore*weights.Goals +
+		score.BudgetScore*weights.Budget +
+		score.NetWorthScore*weights.NetWorth) / weights.Total()
+
+	if metadata, err := json.Marshal(inputs); err == nil {
+		score.Metadata = string(metadata)
+	}
+	return score
+}
 
-	// Create and save health score
-	healthScore := &models.HealthScore{
-		GroupID:      &groupID,
-		Score:        overallScore,
-		SavingsScore: savingsScore,
-		DebtScore:    debtScore,
-		GoalScore:    goalScore,
-		BudgetScore:  budgetScore,
-		CalculatedAt: time.Now(),
+// HealthScorePeriod returns the snapshot period (month) of a date
+func HealthScorePeriod(date time.Time) string {
+	return date.Format("2006-01")
+}
+
+// SnapshotUserScore calculates a user's score and stores it as the snapshot of the month
+// of now, replacing the snapshot already taken in that month
+func (s *HealthScoreService) SnapshotUserScore(userID uint, now time.Time) (*models.HealthScore, error) {
+	accountIDs, err := s.accountService.GetUserAccountIDs(userID)
+	if err != nil {
+		return nil, err
 	}
+	score, err := s.CalculateUserScore(userID, accountIDs)
+	if err != nil {
+		return nil, err
+	}
+	return score, saveScoreSnapshot(score, now)
+}
 
-	if err := database.DB.Create(healthScore).Error; err != nil {
+// SnapshotGroupScore calculates a group's score and stores it as the snapshot of the month
+// of now, replacing the snapshot already taken in that month
+func (s *HealthScoreService) SnapshotGroupScore(groupID uint, now time.Time) (*models.HealthScore, error) {
+	score, err := s.CalculateGroupScore(groupID)
+	if err != nil {
 		return nil, err
 	}
+	return score, saveScoreSnapshot(score, now)
+}
 
-	return healthScore, nil
+// TakeSnapshots stores the score snapshot of the current month of every user and group.
+// Legacy rows (one per page view) are compacted first.
+func (s *HealthScoreService) TakeSnapshots() error {
+	return s.takeSnapshots(time.Now())
 }
 
-// GetScoreHistory retrieves historical health scores
+func (s *HealthScoreService) takeSnapshots(now time.Time) error {
+	removed, err := compactScoreHistory()
+	if err != nil {
+		return fmt.Errorf("failed to compact health score history: %w", err)
+	}
+	if removed > 0 {
+		log.Printf("Compacted health score history: removed %d duplicated scores", removed)
+	}
+
+	var userIDs, groupIDs []uint
+	if err := database.DB.Model(&models.User{}).Pluck("id", &userIDs).Error; err != nil {
+		return fmt.Errorf("failed to fetch users: %w", err)
+	}
+	if err := database.DB.Model(&models.FamilyGroup{}).Pluck("id", &groupIDs).Error; err != nil {
+		return fmt.Errorf("failed to fetch groups: %w", err)
+	}
+
+	// A failed snapshot doesn't stop the others, but fails the run so the job retries it.
+	// Retries are safe: a snapshot replaces the score of its owner and period.
+	var errs []error
+	for _, userID := range userIDs {
+		if _, err := s.SnapshotUserScore(userID, now); err != nil {
+			log.Printf("Error taking health score snapshot for user %d: %v", userID, err)
+			errs = append(errs, fmt.Errorf("user %d: %w", userID, err))
+		}
+	}
+	for _, groupID := range groupIDs {
+		if _, err := s.SnapshotGroupScore(groupID, now); err != nil {
+			log.Printf("Error taking health score snapshot for group %d: %v", groupID, err)
+			errs = append(errs, fmt.Errorf("group %d: %w", groupID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// saveScoreSnapshot stores a score as the canonical row of its owner and period
+func saveScoreSnapshot(score *models.HealthScore, now time.Time) error {
+	score.Period = HealthScorePeriod(now)
+	score.CalculatedAt = now
+
+	query := database.DB.Where("period = ?", score.Period)
+	if score.UserID != nil {
+		query = query.Where("user_id = ? AND group_id IS NULL", *score.UserID)
+	} else {
+		query = query.Where("group_id = ? AND user_id IS NULL", *score.GroupID)
+	}
+
+	// The unique indexes on owner and period reject a concurrent insert of the same snapshot
+	var existing models.HealthScore
+	err := query.First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return database.DB.Create(score).Error
+	}
+	if err != nil {
+		return err
+	}
+	score.ID = existing.ID
+	score.CreatedAt = existing.CreatedAt
+	return database.DB.Save(score).Error
+}
+
+// compactScoreHistory turns the scores inserted on every page view before snapshots
+// existed into one row per owner and month (the latest one), returning how many rows
+// were removed
+func compactScoreHistory() (int, error) {
+	var legacy int64
+	if err := database.DB.Model(&models.HealthScore{}).Where("period IS NULL OR period = ''").Count(&legacy).Error; err != nil {
+		return 0, err
+	}
+	if legacy == 0 {
+		return 0, nil
+	}
+
+	var scores []models.HealthScore
+	if err := database.DB.Order("calculated_at DESC, id DESC").Find(&scores).Error; err != nil {
+		return 0, err
+	}
+
+	kept := make(map[string]bool)
+	var duplicates []uint
+	periods := make(map[uint]string)
+	for _, score := range scores {
+		period := score.Period
+		if period == "" {
+			period = HealthScorePeriod(score.CalculatedAt.In(time.Local))
+		}
+		key := scoreOwnerKey(&score) + ":" + period
+		if kept[key] {
+			duplicates = append(duplicates, score.ID)
+			continue
+		}
+		kept[key] = true
+		if score.Period == "" {
+			periods[score.ID] = period
+		}
+	}
+
+	for start := 0; start < len(duplicates); start += 500 {
+		end := start + 500
+		if end > len(duplicates) {
+			end = len(duplicates)
+		}
+		if err := database.DB.Unscoped().Where("id IN ?", duplicates[start:end]).Delete(&models.HealthScore{}).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	// Periods are set once the duplicates are gone, so they don't collide with the
+	// unique indexes on owner and period
+	for id, period := range periods {
+		if err := database.DB.Model(&models.HealthScore{}).Where("id = ?", id).Update("period", period).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(duplicates), nil
+}
+
+func scoreOwnerKey(score *models.HealthScore) string {
+	if score.UserID != nil {
+		return fmt.Sprintf("user:%d", *score.UserID)
+	}
+	if score.GroupID != nil {
+		return fmt.Sprintf("group:%d", *score.GroupID)
+	}
+	return "none"
+}
+
+// GetScoreHistory retrieves the monthly health score snapshots, newest first
 func (s *HealthScoreService) GetScoreHistory(userID *uint, groupID *uint, months int) ([]models.HealthScore, error) {
 	var scores []models.HealthScore
 
@@ -138,41 +386,11 @@ func (s *HealthScoreService) GetScoreHistory(userID *uint, groupID *uint, months
 	return scores, nil
 }
 
-// GetRecommendations generates personalized recommendations based on health score
+// GetRecommendations generates personalized recommendations based on health score and the
+// owner's data. Each carries its estimated score impact; dismissed, completed and snoozed
+// recommendations are left out.
 func (s *HealthScoreService) GetRecommendations(score *models.HealthScore) ([]Recommendation, error) {
-	recommendations := []Recommendation{}
-
-	// Identify weakest areas (score < 60)
-	type component struct {
-		name  string
-		score float64
-	}
-
-	components := []component{
-		{"savings", score.SavingsScore},
-		{"debt", score.DebtScore},
-		{"goals", score.GoalScore},
-		{"budget", score.BudgetScore},
-	}
-
-	// Sort components by score to prioritize weakest areas
-	weakAreas := []component{}
-	for _, c := range components {
-		if c.score < 60 {
-			weakAreas = append(weakAreas, c)
-		}
-	}
-
-	// Generate recommendations for weak areas
-	if len(weakAreas) > 0 {
-		for i, area := range weakAreas {
-			if i >= 3 { // Max 3 recommendations for weak areas
-				break
-			}
-			rec := s.getRecommendationForArea(area.name, area.score)
-			recommendations = append(recommendations, rec)
-		}
-	}
+	recommendations := s.getRecommendations(score, time.Now())
 
 	// If score is good, provide encouragement and advanced tips
 	if score.Score >= 75 && len(recommendations) == 0 {
@@ -218,7 +436,7 @@ func (s *HealthScoreService) GetRecommendations(score *models.HealthScore) ([]Re
 
 // calculateSavingsScore calculates savings rate score (0-100)
 // Based on (income - expenses) / income ratio
-func (s *HealthScoreService) calculateSavingsScore(accountIDs []uint, recordStartDate time.Time) float64 {
+func (s *HealthScoreService) calculateSavingsScore(accountIDs []uint, recordStartDate time.Time, thresholds HealthScoreThresholds, inputs *SavingsInputs) float64 {
 	if len(accountIDs) == 0 {
 		return 0
 	}
@@ -238,11 +456,8 @@ func (s *HealthScoreService) calculateSavingsScore(accountIDs []uint, recordStar
 	}
 
 	// Calculate total income (net)
-	var totalIncome float64
-	database.DB.Model(&models.Income{}).
-		Where("account_id IN ? AND date BETWEEN ? AND ?", accountIDs, startDate, endDate).
-		Select("COALESCE(SUM(net_amount), 0)").
-		Scan(&totalIncome)
+	totalIncome := s.calculateIncome(accountIDs, startDate, endDate)
+	inputs.Income = totalIncome
 
 	if totalIncome == 0 {
 		return 0
@@ -272,28 +487,36 @@ func (s *HealthScoreService) calculateSavingsScore(accountIDs []uint, recordStar
 		Scan(&totalBills)
 
 	totalExpenses := totalFixed + totalVariable + totalBills
+	inputs.Expenses = totalExpenses
 
 	// Calculate savings rate
 	savingsRate := (totalIncome - totalExpenses) / totalIncome
+	inputs.SavingsRate = savingsRate
+
+	return savingsRateScore(savingsRate, thresholds.SavingsRate)
+}
 
-	// Convert to 0-100 score
-	// > 30% savings = 100 score
-	// 20-30% = 80-100
-	// 10-20% = 60-80
-	// 0-10% = 40-60
+// savingsRateScore converts a savings rate to a score
+func savingsRateScore(rate, target float64) float64 {
+	// Convert to 0-100 score (T = target savings rate, 30% by default)
+	// >= T = 100 score
+	// 2T/3 to T = 80-100
+	// T/3 to 2T/3 = 60-80
+	// 0 to T/3 = 40-60
 	// negative = 0-40
+	step := target / 3
 	var score float64
-	if savingsRate >= 0.30 {
+	if rate >= target {
 		score = 100
-	} else if savingsRate >= 0.20 {
-		score = 80 + ((savingsRate - 0.20) / 0.10 * 20)
-	} else if savingsRate >= 0.10 {
-		score = 60 + ((savingsRate - 0.10) / 0.10 * 20)
-	} else if savingsRate >= 0 {
-		score = 40 + (savingsRate / 0.10 * 20)
+	} else if rate >= 2*step {
+		score = 80 + ((rate - 2*step) / step * 20)
+	} else if rate >= step {
+		score = 60 + ((rate - step) / step * 20)
+	} else if rate >= 0 {
+		score = 40 + (rate / step * 20)
 	} else {
 		// Negative savings (spending more than earning)
-		score = 40 + (savingsRate * 40) // Will give 0-40 range
+		score = 40 + (rate * 40) // Will give 0-40 range
 		if score < 0 {
 			score = 0
 		}
@@ -303,14 +526,25 @@ func (s *HealthScoreService) calculateSavingsScore(accountIDs []uint, recordStar
 }
 
 // calculateDebtScore calculates debt management score (0-100)
-// Currently based on expense ratio as inverse (lower expenses = better score)
-func (s *HealthScoreService) calculateDebtScore(accountIDs []uint, recordStartDate time.Time) float64 {
-	if len(accountIDs) == 0 {
+// It is the lower of two scores: monthly obligations (fixed expenses, bills and card
+// installments due this month) over monthly income, and outstanding debt (liabilities and
+// remaining card installments) over annual income
+func (s *HealthScoreService) calculateDebtScore(accountIDs []uint, recordStartDate time.Time, snapshot *NetWorthSnapshot, thresholds HealthScoreThresholds, inputs *DebtInputs) float64 {
+	endDate := time.Now()
+
+	// Manual liabilities; remaining installments are taken from the scored accounts
+	if snapshot != nil {
+		inputs.OutstandingDebt = snapshot.Liabilities - snapshot.RemainingInstallments
+	}
+	installments := accountInstallments(accountIDs)
+	inputs.InstallmentsDue = installmentsDueIn(installments, endDate)
+	inputs.OutstandingDebt += remainingInstallments(installments, endDate)
+
+	if len(accountIDs) == 0 && inputs.OutstandingDebt == 0 {
 		return 100 // No accounts = no debt
 	}
 
 	// Get last month of data
-	endDate := time.Now()
 	startDate := endDate.AddDate(0, -1, 0)
 
 	// Respect record start date - don't go before it
@@ -323,78 +557,197 @@ func (s *HealthScoreService) calculateDebtScore(accountIDs []uint, recordStartDa
 		return 50 // Neutral score - no data available yet
 	}
 
-	var totalIncome float64
-	database.DB.Model(&models.Income{}).
-		Where("account_id IN ? AND date BETWEEN ? AND ?", accountIDs, startDate, endDate).
-		Select("COALESCE(SUM(net_amount), 0)").
-		Scan(&totalIncome)
+	inputs.MonthlyIncome = s.calculateIncome(accountIDs, startDate, endDate)
 
 	// Calculate total fixed obligations (rent, bills, etc.)
-	var totalFixed float64
 	database.DB.Model(&models.Expense{}).
 		Where("account_id IN ? AND type = ? AND active = ?", accountIDs, models.ExpenseTypeFixed, true).
 		Select("COALESCE(SUM(amount), 0)").
-		Scan(&totalFixed)
+		Scan(&inputs.FixedExpenses)
 
-	var totalBills float64
 	database.DB.Model(&models.Bill{}).
 		Where("account_id IN ? AND due_date BETWEEN ? AND ?", accountIDs, startDate, endDate).
 		Select("COALESCE(SUM(amount), 0)").
-		Scan(&totalBills)
+		Scan(&inputs.Bills)
 
-	totalObligations := totalFixed + totalBills
+	totalObligations := inputs.FixedExpenses + inputs.Bills + inputs.InstallmentsDue
 
-	// If no income but has obligations, that's a critical situation
-	if totalIncome == 0 {
-		if totalObligations > 0 {
+	// If no income but has obligations or debts, that's a critical situation
+	if inputs.MonthlyIncome == 0 {
+		if totalObligations > 0 || inputs.OutstandingDebt > 0 {
 			return 0 // Critical: expenses but no income
 		}
 		return 100 // No income and no obligations = neutral
 	}
 
-	obligationRatio := totalObligations / totalIncome
+	// Annualize the income of the last 12 months (or since the record start date)
+	yearStart := endDate.AddDate(-1, 0, 0)
+	if !recordStartDate.IsZero() && yearStart.Before(recordStartDate) {
+		yearStart = recordStartDate
+	}
+	months := endDate.Sub(yearStart).Hours() / 24 / (365.0 / 12)
+	if months < 1 {
+		months = 1
+	}
+	inputs.AnnualIncome = s.calculateIncome(accountIDs, yearStart, endDate) / months * 12
+
+	inputs.ObligationRatio = totalObligations / inputs.MonthlyIncome
+	if inputs.AnnualIncome > 0 {
+		inputs.DebtToIncome = inputs.OutstandingDebt / inputs.AnnualIncome
+	}
+
+	return math.Min(
+		obligationRatioScore(inputs.ObligationRatio, thresholds.ObligationRatio),
+		debtToIncomeScore(inputs.DebtToIncome, thresholds.DebtToIncome),
+	)
+}
 
-	// Convert to score (lower ratio = better score)
-	// < 30% = 100 score (excellent)
-	// 30-50% = 80-100 (good)
-	// 50-70% = 60-80 (fair)
-	// 70-90% = 40-60 (poor)
-	// > 90% = 0-40 (critical)
+// obligationRatioScore converts monthly obligations / income to a score (T = target ratio,
+// 30% by default)
+// <= T = 100 score (excellent)
+// T to T+20pp = 80-100 (good)
+// T+20pp to T+40pp = 60-80 (fair)
+// T+40pp to T+60pp = 40-60 (poor)
+// above = 0-40 (critical)
+func obligationRatioScore(ratio, target float64) float64 {
 	var score float64
-	if obligationRatio <= 0.30 {
+	if ratio <= target {
 		score = 100
-	} else if obligationRatio <= 0.50 {
-		score = 80 + ((0.50 - obligationRatio) / 0.20 * 20)
-	} else if obligationRatio <= 0.70 {
-		score = 60 + ((0.70 - obligationRatio) / 0.20 * 20)
-	} else if obligationRatio <= 0.90 {
-		score = 40 + ((0.90 - obligationRatio) / 0.20 * 20)
+	} else if ratio <= target+0.20 {
+		score = 80 + ((target + 0.20 - ratio) / 0.20 * 20)
+	} else if ratio <= target+0.40 {
+		score = 60 + ((target + 0.40 - ratio) / 0.20 * 20)
+	} else if ratio <= target+0.60 {
+		score = 40 + ((target + 0.60 - ratio) / 0.20 * 20)
 	} else {
-		score = 40 * (1 - (obligationRatio - 0.90))
+		score = 40 * (1 - (ratio - (target + 0.60)))
 		if score < 0 {
 			score = 0
 		}
 	}
+	return score
+}
+
+// debtToIncomeScore converts outstanding debt / annual income to a score (D = target
+// ratio, 35% by default)
+// <= D = 100 score
+// D to 2D = 60-100
+// 2D to 4D = 20-60
+// 4D to 6D = 0-20
+// above = 0
+func debtToIncomeScore(ratio, target float64) float64 {
+	switch {
+	case ratio <= target:
+		return 100
+	case ratio <= 2*target:
+		return 60 + ((2*target - ratio) / target * 40)
+	case ratio <= 4*target:
+		return 20 + ((4*target - ratio) / (2 * target) * 40)
+	case ratio <= 6*target:
+		return (6*target - ratio) / (2 * target) * 20
+	}
+	return 0
+}
+
+// calculateEmergencyFundScore calculates emergency fund score (0-100)
+// Based on how many months of expenses the liquid assets (cash and investments registered
+// in the net worth) cover
+func (s *HealthScoreService) calculateEmergencyFundScore(accountIDs []uint, recordStartDate time.Time, snapshot *NetWorthSnapshot, thresholds HealthScoreThresholds, inputs *EmergencyFundInputs) float64 {
+	if len(accountIDs) > 0 {
+		var expenses []float64
+		for _, window := range monthWindows(time.Now(), recordStartDate, 3) {
+			expenses = append(expenses, s.calculateMonthExpenses(accountIDs, window[0], window[1]))
+		}
+		inputs.MonthlyExpenses = averageOf(expenses)
+	}
+
+	if snapshot == nil || len(snapshot.Items) == 0 {
+		return 50 // No assets registered = neutral score
+	}
+	inputs.LiquidAssets = snapshot.LiquidAssets
+
+	if inputs.MonthlyExpenses == 0 {
+		if inputs.LiquidAssets > 0 {
+			return 100
+		}
+		return 50
+	}
+	inputs.Months = inputs.LiquidAssets / inputs.MonthlyExpenses
+
+	return emergencyFundMonthsScore(inputs.Months, thresholds.EmergencyFundMonths)
+}
+
+// emergencyFundMonthsScore converts the months of expenses covered to a score
+func emergencyFundMonthsScore(months, target float64) float64 {
+	// Convert to score (M = target months, 6 by default)
+	// >= M = 100 score
+	// M/2 to M = 60-100
+	// M/6 to M/2 = 30-60
+	// < M/6 = 0-30
+	var score float64
+	if months >= target {
+		score = 100
+	} else if months >= target/2 {
+		score = 60 + ((months - target/2) / (target / 2) * 40)
+	} else if months >= target/6 {
+		score = 30 + ((months - target/6) / (target / 3) * 30)
+	} else {
+		score = months / (target / 6) * 30
+	}
 
 	return score
 }
 
-// calculateGoalScore calculates goal progress score (0-100)
-func (s *HealthScoreService) calculateGoalScore(userID uint, groupID *uint) float64 {
-	var goals []models.GroupGoal
+// calculateIncomeStabilityScore calculates income stability score (0-100)
+// Based on the coefficient of variation of the net income of the last 6 months
+func (s *HealthScoreService) calculateIncomeStabilityScore(accountIDs []uint, recordStartDate time.Time, thresholds HealthScoreThresholds, inputs *IncomeStabilityInputs) float64 {
+	if len(accountIDs) == 0 {
+		return 0
+	}
 
-	if groupID != nil {
-		database.DB.Where("group_id = ? AND status = ?", *groupID, models.GoalStatusActive).Find(&goals)
-	} else if userID > 0 {
-		// Get user's groups
-		var groupIDs []uint
-		database.DB.Model(&models.GroupMember{}).Where("user_id = ?", userID).Pluck("group_id", &groupIDs)
+	for _, window := range monthWindows(time.Now(), recordStartDate, 6) {
+		inputs.MonthlyIncomes = append(inputs.MonthlyIncomes, s.calculateIncome(accountIDs, window[0], window[1]))
+	}
 
-		if len(groupIDs) > 0 {
-			database.DB.Where("group_id IN ? AND status = ?", groupIDs, models.GoalStatusActive).Find(&goals)
+	// If less than 2 valid months, there is not enough data to measure stability
+	if len(inputs.MonthlyIncomes) < 2 {
+		return 50
+	}
+
+	avgIncome := averageOf(inputs.MonthlyIncomes)
+	if avgIncome == 0 {
+		return 0 // No income
+	}
+
+	cv := standardDeviationOf(inputs.MonthlyIncomes, avgIncome) / avgIncome
+	inputs.Variation = cv
+
+	// Convert to score (V = target variation, 15% by default)
+	// CV <= V = 100 (stable)
+	// CV V to 2V = 75-100
+	// CV 2V to 4V = 40-75
+	// CV 4V to 8V = 0-40
+	target := thresholds.IncomeVariation
+	var score float64
+	if cv <= target {
+		score = 100
+	} else if cv <= 2*target {
+		score = 75 + ((2*target - cv) / target * 25)
+	} else if cv <= 4*target {
+		score = 40 + ((4*target - cv) / (2 * target) * 35)
+	} else {
+		score = 40 * (1 - (cv-4*target)/(4*target))
+		if score < 0 {
+			score = 0
 		}
 	}
 
+	return score
+}
+
+// calculateGoalScore calculates goal progress score (0-100)
+func (s *HealthScoreService) calculateGoalScore(userID uint, groupID *uint, inputs *GoalInputs) float64 {
+	goals := activeGoals(userID, groupID)
 	if len(goals) == 0 {
 		return 50 // No goals = neutral score (not good, not bad)
 	}
@@ -407,7 +760,33 @@ func (s *HealthScoreService) calculateGoalScore(userID uint, groupID *uint) floa
 	}
 
 	avgProgress := totalProgress / float64(len(goals))
+	inputs.ActiveGoals = len(goals)
+	inputs.AverageProgress = avgProgress
 
+	return goalProgressScore(avgProgress)
+}
+
+// activeGoals returns the active goals of a group, or of every group of a user
+func activeGoals(userID uint, groupID *uint) []models.GroupGoal {
+	var goals []models.GroupGoal
+
+	if groupID != nil {
+		database.DB.Where("group_id = ? AND status = ?", *groupID, models.GoalStatusActive).Find(&goals)
+	} else if userID > 0 {
+		// Get user's groups
+		var groupIDs []uint
+		database.DB.Model(&models.GroupMember{}).Where("user_id = ?", userID).Pluck("group_id", &groupIDs)
+
+		if len(groupIDs) > 0 {
+			database.DB.Where("group_id IN ? AND status = ?", groupIDs, models.GoalStatusActive).Find(&goals)
+		}
+	}
+
+	return goals
+}
+
+// goalProgressScore converts the average progress of the goals to a score
+func goalProgressScore(avgProgress float64) float64 {
 	// Convert average progress to score
 	// >= 80% average progress = 100 score
 	// 60-80% = 80-100
@@ -432,119 +811,116 @@ func (s *HealthScoreService) calculateGoalScore(userID uint, groupID *uint) floa
 
 // calculateBudgetScore calculates budget adherence score (0-100)
 // Based on consistency of expenses month-over-month
-func (s *HealthScoreService) calculateBudgetScore(accountIDs []uint, recordStartDate time.Time) float64 {
+func (s *HealthScoreService) calculateBudgetScore(accountIDs []uint, recordStartDate time.Time, thresholds HealthScoreThresholds, inputs *BudgetInputs) float64 {
 	if len(accountIDs) == 0 {
 		return 100
 	}
 
-	// Get last 3 months of expense data
-	endDate := time.Now()
-
-	month1Start := endDate.AddDate(0, -1, 0)
-	month1End := endDate
-
-	month2Start := endDate.AddDate(0, -2, 0)
-	month2End := month1Start
-
-	month3Start := endDate.AddDate(0, -3, 0)
-	month3End := month2Start
-
-	// Respect record start date for each month range
-	if !recordStartDate.IsZero() {
-		if month1Start.Before(recordStartDate) {
-			month1Start = recordStartDate
-		}
-		if month2Start.Before(recordStartDate) {
-			month2Start = recordStartDate
-		}
-		if month3Start.Before(recordStartDate) {
-			month3Start = recordStartDate
-		}
-	}
-
-	// Calculate expenses for each month (only if the range is valid)
-	var month1Expenses, month2Expenses, month3Expenses float64
-	var validMonths int
-
-	if month1End.After(month1Start) {
-		month1Expenses = s.calculateMonthExpenses(accountIDs, month1Start, month1End)
-		validMonths++
-	}
-	if month2End.After(month2Start) {
-		month2Expenses = s.calculateMonthExpenses(accountIDs, month2Start, month2End)
-		validMonths++
-	}
-	if month3End.After(month3Start) {
-		month3Expenses = s.calculateMonthExpenses(accountIDs, month3Start, month3End)
-		validMonths++
+	// Calculate expenses for each of the last 3 months (only the valid ranges)
+	for _, window := range monthWindows(time.Now(), recordStartDate, 3) {
+		inputs.MonthlyExpenses = append(inputs.MonthlyExpenses, s.calculateMonthExpenses(accountIDs, window[0], window[1]))
 	}
 
 	// If less than 2 valid months, return high score (not enough data to measure consistency)
-	if validMonths < 2 {
+	if len(inputs.MonthlyExpenses) < 2 {
 		return 85
 	}
 
+	avgExpenses := averageOf(inputs.MonthlyExpenses)
+
 	// If no expenses, return high score (staying within budget of 0!)
-	if month1Expenses == 0 && month2Expenses == 0 && month3Expenses == 0 {
+	if avgExpenses == 0 {
 		return 90
 	}
 
-	// Calculate average and variance using only valid months
-	totalExpenses := month1Expenses + month2Expenses + month3Expenses
-	avgExpenses := totalExpenses / float64(validMonths)
+	// Calculate coefficient of variation (std dev / mean)
+	cv := standardDeviationOf(inputs.MonthlyExpenses, avgExpenses) / avgExpenses
+	inputs.Variation = cv
 
-	if avgExpenses == 0 {
-		return 90
-	}
+	// Convert to score (lower variance = better score, T = target variation, 10% by default)
+	// CV <= T = 100 (very consistent)
+	// CV T to 2T = 80-100
+	// CV 2T to 3T = 60-80
+	// CV 3T to 5T = 40-60
+	// CV > 5T = 0-40
+	return inverseRatioScore(cv, thresholds.BudgetVariation)
+}
 
-	// Calculate coefficient of variation (std dev / mean) for valid months only
-	var variance float64
-	if month1End.After(month1Start) {
-		variance += (month1Expenses - avgExpenses) * (month1Expenses - avgExpenses)
-	}
-	if month2End.After(month2Start) {
-		variance += (month2Expenses - avgExpenses) * (month2Expenses - avgExpenses)
+// calculateNetWorthScore calculates net worth score (0-100)
+// Based on liabilities (including remaining card installments) / assets ratio
+func (s *HealthScoreService) calculateNetWorthScore(snapshot *NetWorthSnapshot, thresholds HealthScoreThresholds, inputs *NetWorthInputs) float64 {
+	if snapshot == nil || len(snapshot.Items) == 0 {
+		return 50 // No assets or liabilities registered = neutral score
 	}
-	if month3End.After(month3Start) {
-		variance += (month3Expenses - avgExpenses) * (month3Expenses - avgExpenses)
-	}
-	variance = variance / float64(validMonths)
-	stdDev := 0.0
-	if variance > 0 {
-		// Simple square root approximation
-		stdDev = variance / 2 // Simplified calculation
-		for i := 0; i < 10; i++ {
-			stdDev = (stdDev + variance/stdDev) / 2
+	inputs.Assets = snapshot.Assets
+	inputs.Liabilities = snapshot.Liabilities
+
+	if snapshot.Assets == 0 {
+		if snapshot.Liabilities > 0 {
+			return 0 // Only debts
 		}
+		return 50
 	}
 
-	cv := stdDev / avgExpenses
+	inputs.DebtRatio = snapshot.Liabilities / snapshot.Assets
 
-	// Convert to score (lower variance = better score)
-	// CV < 10% = 100 (very consistent)
-	// CV 10-20% = 80-100
-	// CV 20-30% = 60-80
-	// CV 30-50% = 40-60
-	// CV > 50% = 0-40
+	// Convert to score (lower ratio = better score, T = target ratio, 20% by default)
+	// <= T = 100 score (excellent)
+	// T to 2T = 80-100 (good)
+	// 2T to 3T = 60-80 (fair)
+	// 3T to 5T = 40-60 (poor)
+	// > 5T = 0-40 (critical)
+	return inverseRatioScore(inputs.DebtRatio, thresholds.NetWorthDebtRatio)
+}
+
+// inverseRatioScore converts a ratio where lower is better to a score, with bands at 1, 2, 3
+// and 5 times the target
+func inverseRatioScore(ratio, target float64) float64 {
 	var score float64
-	if cv <= 0.10 {
+	if ratio <= target {
 		score = 100
-	} else if cv <= 0.20 {
-		score = 80 + ((0.20 - cv) / 0.10 * 20)
-	} else if cv <= 0.30 {
-		score = 60 + ((0.30 - cv) / 0.10 * 20)
-	} else if cv <= 0.50 {
-		score = 40 + ((0.50 - cv) / 0.20 * 20)
+	} else if ratio <= 2*target {
+		score = 80 + ((2*target - ratio) / target * 20)
+	} else if ratio <= 3*target {
+		score = 60 + ((3*target - ratio) / target * 20)
+	} else if ratio <= 5*target {
+		score = 40 + ((5*target - ratio) / (2 * target) * 20)
 	} else {
-		score = 40 * (1 - (cv - 0.50))
+		score = 40 * (1 - (ratio - 5*target))
 		if score < 0 {
 			score = 0
 		}
 	}
-
 	return score
 }
 
+// monthWindows returns the last n one-month windows ending on now, most recent first.
+// Windows are clipped to the record start date and left out when entirely before it.
+func monthWindows(now, recordStartDate time.Time, n int) [][2]time.Time {
+	var windows [][2]time.Time
+	for i := 0; i < n; i++ {
+		start := now.AddDate(0, -(i + 1), 0)
+		end := now.AddDate(0, -i, 0)
+		if !recordStartDate.IsZero() && start.Before(recordStartDate) {
+			start = recordStartDate
+		}
+		if end.After(start) {
+			windows = append(windows, [2]time.Time{start, end})
+		}
+	}
+	return windows
+}
+
+// calculateIncome calculates total net income for a period
+func (s *HealthScoreService) calculateIncome(accountIDs []uint, startDate, endDate time.Time) float64 {
+	var totalIncome float64
+	database.DB.Model(&models.Income{}).
+		Where("account_id IN ? AND date BETWEEN ? AND ?", accountIDs, startDate, endDate).
+		Select("COALESCE(SUM(net_amount), 0)").
+		Scan(&totalIncome)
+	return totalIncome
+}
+
 // calculateMonthExpenses calculates total expenses for a given month
 func (s *HealthScoreService) calculateMonthExpenses(accountIDs []uint, startDate, endDate time.Time) float64 {
 	var totalFixed float64
@@ -610,6 +986,44 @@ func (s *HealthScoreService) getRecommendationForArea(area string, score float64
 			Priority:    "medium",
 			Color:       "warning",
 		}
+	case "emergency_fund":
+		if score < 30 {
+			return Recommendation{
+				Title:       "Monte Sua Reserva de Emergência",
+				Description: "Seu saldo e investimentos cobrem poucos meses de gastos. Separe um valor fixo todo mês até cobrir ao menos 6 meses.",
+				ActionUrl:   "/net-worth",
+				ActionText:  "Ver Patrimônio",
+				Priority:    "high",
+				Color:       "danger",
+			}
+		}
+		return Recommendation{
+			Title:       "Complete Sua Reserva",
+			Description: "Sua reserva de emergência está no caminho. Mantenha-a em aplicações de liquidez diária e atualize o saldo no patrimônio.",
+			ActionUrl:   "/net-worth",
+			ActionText:  "Ver Patrimônio",
+			Priority:    "medium",
+			Color:       "warning",
+		}
+	case "income_stability":
+		if score < 30 {
+			return Recommendation{
+				Title:       "Renda Muito Variável",
+				Description: "Sua renda oscila bastante de um mês para o outro. Planeje seus gastos pela média dos meses mais fracos.",
+				ActionUrl:   "/incomes",
+				ActionText:  "Ver Receitas",
+				Priority:    "high",
+				Color:       "danger",
+			}
+		}
+		return Recommendation{
+			Title:       "Suavize Sua Renda",
+			Description: "Guarde parte das receitas dos meses bons para complementar os meses mais fracos.",
+			ActionUrl:   "/incomes",
+			ActionText:  "Ver Receitas",
+			Priority:    "medium",
+			Color:       "warning",
+		}
 	case "goals":
 		if score < 30 {
 			return Recommendation{
@@ -648,6 +1062,25 @@ func (s *HealthScoreService) getRecommendationForArea(area string, score float64
 			Priority:    "medium",
 			Color:       "warning",
 		}
+	case "networth":
+		if score < 30 {
+			return Recommendation{
+				Title:       "Reduza Suas Dívidas",
+				Description: "Suas dívidas e parcelas a pagar se aproximam ou superam seu patrimônio. Priorize quitar as de juros mais altos.",
+				ActionUrl:   "/net-worth",
+				ActionText:  "Ver Patrimônio",
+				Priority:    "high",
+				Color:       "danger",
+			}
+		}
+		return Recommendation{
+			Title:       "Acompanhe Seu Patrimônio",
+			Description: "Atualize o valor dos seus bens e dívidas todo mês para ver a evolução do seu patrimônio líquido.",
+			ActionUrl:   "/net-worth",
+			ActionText:  "Ver Patrimônio",
+			Priority:    "medium",
+			Color:       "warning",
+		}
 	default:
 		return Recommendation{
 			Title:       "Continue Acompanhando",
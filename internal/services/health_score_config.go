@@ -0,0 +1,125 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+var ErrInvalidHealthScoreConfig = errors.New("configuração do score inválida (pesos não podem ser negativos, ao menos um deve ser positivo e as metas devem ser positivas)")
+
+// HealthScoreWeights are the relative weights of each component in the overall score.
+// They don't need to add up to 1: the overall score is the weighted average.
+type HealthScoreWeights struct {
+	Savings         float64 `json:"savings"`
+	Debt            float64 `json:"debt"`
+	EmergencyFund   float64 `json:"emergency_fund"`
+	IncomeStability float64 `json:"income_stability"`
+	Goals           float64 `json:"goals"`
+	Budget          float64 `json:"budget"`
+	NetWorth        float64 `json:"net_worth"`
+}
+
+// Total returns the sum of the weights
+func (w HealthScoreWeights) Total() float64 {
+	return w.Savings + w.Debt + w.EmergencyFund + w.IncomeStability + w.Goals + w.Budget + w.NetWorth
+}
+
+// HealthScoreThresholds are the targets that earn each component a full score. The
+// score bands of every component are derived from its target.
+type HealthScoreThresholds struct {
+	SavingsRate         float64 `json:"savings_rate"`          // Share of net income saved (0.30 = 30%)
+	ObligationRatio     float64 `json:"obligation_ratio"`      // Monthly obligations (fixed, bills, installments) / monthly income
+	DebtToIncome        float64 `json:"debt_to_income"`        // Outstanding debt / annual net income
+	EmergencyFundMonths float64 `json:"emergency_fund_months"` // Months of expenses covered by liquid assets
+	IncomeVariation     float64 `json:"income_variation"`      // Coefficient of variation of monthly income
+	BudgetVariation     float64 `json:"budget_variation"`      // Coefficient of variation of monthly expenses
+	NetWorthDebtRatio   float64 `json:"net_worth_debt_ratio"`  // Liabilities / assets
+}
+
+// HealthScoreConfig holds the weights and thresholds used to calculate health scores
+type HealthScoreConfig struct {
+	Weights    HealthScoreWeights    `json:"weights"`
+	Thresholds HealthScoreThresholds `json:"thresholds"`
+}
+
+// DefaultHealthScoreConfig returns the weights and thresholds used when none are configured
+func DefaultHealthScoreConfig() HealthScoreConfig {
+	return HealthScoreConfig{
+		Weights: HealthScoreWeights{
+			Savings:         0.20,
+			Debt:            0.20,
+			EmergencyFund:   0.15,
+			IncomeStability: 0.10,
+			Goals:           0.10,
+			Budget:          0.10,
+			NetWorth:        0.15,
+		},
+		Thresholds: HealthScoreThresholds{
+			SavingsRate:         0.30,
+			ObligationRatio:     0.30,
+			DebtToIncome:        0.35,
+			EmergencyFundMonths: 6,
+			IncomeVariation:     0.15,
+			BudgetVariation:     0.10,
+			NetWorthDebtRatio:   0.20,
+		},
+	}
+}
+
+// Validate checks that the weights are usable and the thresholds positive
+func (c HealthScoreConfig) Validate() error {
+	w := c.Weights
+	for _, weight := range []float64{w.Savings, w.Debt, w.EmergencyFund, w.IncomeStability, w.Goals, w.Budget, w.NetWorth} {
+		if weight < 0 {
+			return ErrInvalidHealthScoreConfig
+		}
+	}
+	if w.Total() <= 0 {
+		return ErrInvalidHealthScoreConfig
+	}
+
+	t := c.Thresholds
+	for _, threshold := range []float64{t.SavingsRate, t.ObligationRatio, t.DebtToIncome, t.EmergencyFundMonths,
+		t.IncomeVariation, t.BudgetVariation, t.NetWorthDebtRatio} {
+		if threshold <= 0 {
+			return ErrInvalidHealthScoreConfig
+		}
+	}
+	return nil
+}
+
+// GetHealthScoreConfig returns the configured weights and thresholds. Values missing from
+// the stored configuration, or an invalid configuration, fall back to the defaults.
+func GetHealthScoreConfig() HealthScoreConfig {
+	config := DefaultHealthScoreConfig()
+
+	var setting models.Settings
+	if err := database.DB.Where("key = ?", models.SettingHealthScoreConfig).First(&setting).Error; err != nil {
+		return config
+	}
+	if err := json.Unmarshal([]byte(setting.Value), &config); err != nil || config.Validate() != nil {
+		return DefaultHealthScoreConfig()
+	}
+	return config
+}
+
+// SaveHealthScoreConfig validates and stores the weights and thresholds
+func SaveHealthScoreConfig(config HealthScoreConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	var setting models.Settings
+	if err := database.DB.Where("key = ?", models.SettingHealthScoreConfig).First(&setting).Error; err != nil {
+		return database.DB.Create(&models.Settings{Key: models.SettingHealthScoreConfig, Value: string(value)}).Error
+	}
+	setting.Value = string(value)
+	return database.DB.Save(&setting).Error
+}
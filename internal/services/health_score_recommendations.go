@@ -0,0 +1,505 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+var ErrInvalidRecommendationAction = errors.New("recomendação inválida")
+
+// minCategoryGrowth is the growth of the top categories, quarter over quarter, worth an advice
+const minCategoryGrowth = 0.10
+
+// getRecommendations builds the recommendations of a score: advice computed from the owner's
+// data first (highest estimated impact first), then generic advice for weak areas without
+// one. Dismissed, completed and snoozed recommendations are left out.
+func (s *HealthScoreService) getRecommendations(score *models.HealthScore, now time.Time) []Recommendation {
+	inputs := ScoreInputs(score)
+	config := HealthScoreConfig{Weights: inputs.Weights, Thresholds: inputs.Thresholds}
+	if config.Validate() != nil {
+		// Scores stored before inputs were recorded
+		config = GetHealthScoreConfig()
+	}
+
+	var userID uint
+	var accountIDs []uint
+	if score.GroupID != nil {
+		accountIDs, _ = s.accountService.GetAllGroupAccountIDs(*score.GroupID)
+	} else if score.UserID != nil {
+		userID = *score.UserID
+		accountIDs, _ = s.accountService.GetUserAccountIDs(userID)
+	}
+
+	hidden := hiddenRecommendationKeys(userID, score.GroupID, now)
+	recommendations := []Recommendation{}
+	covered := make(map[string]bool)
+
+	for _, rec := range s.dataRecommendations(score, inputs, config, userID, accountIDs, now) {
+		if hidden[rec.Key] {
+			continue
+		}
+		recommendations = append(recommendations, rec)
+		covered[rec.Area] = true
+	}
+
+	// Generic advice for weak areas (score < 60) without data-driven advice
+	components := []struct {
+		name   string
+		score  float64
+		weight float64
+	}{
+		{"savings", score.SavingsScore, config.Weights.Savings},
+		{"debt", score.DebtScore, config.Weights.Debt},
+		{"emergency_fund", score.EmergencyFundScore, config.Weights.EmergencyFund},
+		{"income_stability", score.IncomeStabilityScore, config.Weights.IncomeStability},
+		{"goals", score.GoalScore, config.Weights.Goals},
+		{"budget", score.BudgetScore, config.Weights.Budget},
+		{"networth", score.NetWorthScore, config.Weights.NetWorth},
+	}
+	generic := 0
+	for _, c := range components {
+		if c.score >= 60 || covered[c.name] || generic >= 3 { // Max 3 recommendations for weak areas
+			continue
+		}
+		rec := s.getRecommendationForArea(c.name, c.score)
+		rec.Key = "area:" + c.name
+		rec.Area = c.name
+		// Estimated as bringing the area to a fair level
+		rec.ScoreImpact = (60 - c.score) * c.weight / config.Weights.Total()
+		if hidden[rec.Key] {
+			continue
+		}
+		recommendations = append(recommendations, rec)
+		generic++
+	}
+
+	return recommendations
+}
+
+// dataRecommendations computes the advice backed by the owner's data, highest estimated
+// impact first
+func (s *HealthScoreService) dataRecommendations(score *models.HealthScore, inputs HealthScoreInputs, config HealthScoreConfig, userID uint, accountIDs []uint, now time.Time) []Recommendation {
+	var recommendations []Recommendation
+	add := func(rec *Recommendation) {
+		if rec != nil {
+			recommendations = append(recommendations, *rec)
+		}
+	}
+
+	add(categoryGrowthRecommendation(score, inputs, config, accountIDs, now))
+	add(s.subscriptionRecommendation(score, inputs, config, accountIDs, now))
+	add(emergencyFundRecommendation(score, inputs, config))
+	add(debtRecommendation(score, inputs, config))
+	add(s.goalRecommendation(score, inputs, config, userID, now))
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].ScoreImpact > recommendations[j].ScoreImpact
+	})
+	return recommendations
+}
+
+// scoreImpact is the increase of the overall score when a component goes from current to improved
+func scoreImpact(current, improved, weight float64, config HealthScoreConfig) float64 {
+	if improved <= current {
+		return 0
+	}
+	return (improved - current) * weight / config.Weights.Total()
+}
+
+// savingsImpact estimates the score impact of spending monthlySavings less per month
+func savingsImpact(score *models.HealthScore, inputs HealthScoreInputs, config HealthScoreConfig, monthlySavings float64) float64 {
+	savings := inputs.Savings
+	if savings.Income == 0 {
+		return 0
+	}
+	// Savings inputs cover the last 3 months
+	rate := (savings.Income - (savings.Expenses - monthlySavings*3)) / savings.Income
+	return scoreImpact(score.SavingsScore, savingsRateScore(rate, config.Thresholds.SavingsRate), config.Weights.Savings, config)
+}
+
+// categoryGrowthRecommendation points at the categories that grew the most in the last full
+// quarter compared to the previous one
+func categoryGrowthRecommendation(score *models.HealthScore, inputs HealthScoreInputs, config HealthScoreConfig, accountIDs []uint, now time.Time) *Recommendation {
+	if len(accountIDs) == 0 {
+		return nil
+	}
+
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	current := GetCategorySpendingBetween(database.DB, accountIDs, monthStart.AddDate(0, -3, 0), monthStart)
+	previous := GetCategorySpendingBetween(database.DB, accountIDs, monthStart.AddDate(0, -6, 0), monthStart.AddDate(0, -3, 0))
+
+	type growth struct {
+		category          string
+		previous, current float64
+	}
+	var grown []growth
+	for category, spending := range current {
+		if prev := categoryTotal(previous, category); prev > 0 && spending.Total > prev {
+			grown = append(grown, growth{category, prev, spending.Total})
+		}
+	}
+	sort.Slice(grown, func(i, j int) bool {
+		return grown[i].current-grown[i].previous > grown[j].current-grown[j].previous
+	})
+	if len(grown) > 3 {
+		grown = grown[:3]
+	}
+
+	var previousTotal, currentTotal float64
+	var categories, displayNames, parts []string
+	for _, g := range grown {
+		previousTotal += g.previous
+		currentTotal += g.current
+		name := g.category
+		if name == "" {
+			name = "Sem categoria"
+		}
+		categories = append(categories, g.category)
+		displayNames = append(displayNames, name)
+		parts = append(parts, fmt.Sprintf("%s (+%.0f%%)", name, (g.current-g.previous)/g.previous*100))
+	}
+	if previousTotal == 0 {
+		return nil
+	}
+	growthRate := (currentTotal - previousTotal) / previousTotal
+	if growthRate < minCategoryGrowth {
+		return nil
+	}
+	monthlySavings := (currentTotal - previousTotal) / 3
+
+	rec := &Recommendation{
+		Key:   "category_growth:" + strings.Join(categories, ","),
+		Area:  "savings",
+		Title: "Seus Gastos Estão Crescendo",
+		Description: fmt.Sprintf("Suas %d categorias que mais cresceram subiram %.0f%% em relação ao trimestre anterior: %s. Voltar ao nível anterior economiza R$ %.2f por mês.",
+			len(grown), growthRate*100, strings.Join(parts, ", "), monthlySavings),
+		ActionUrl:   "/budgets",
+		ActionText:  "Ver Orçamentos",
+		Priority:    "medium",
+		Color:       "warning",
+		ScoreImpact: savingsImpact(score, inputs, config, monthlySavings),
+	}
+	if growthRate >= 0.25 {
+		rec.Priority = "high"
+		rec.Color = "danger"
+	}
+	if len(grown) == 1 {
+		rec.Description = fmt.Sprintf("Seus gastos com %s subiram %.0f%% em relação ao trimestre anterior. Voltar ao nível anterior economiza R$ %.2f por mês.",
+			displayNames[0], growthRate*100, monthlySavings)
+	}
+	return rec
+}
+
+// subscriptionRecommendation suggests cancelling the subscriptions marked to cancel or, when
+// there are none, reviewing the ones that got more expensive
+func (s *HealthScoreService) subscriptionRecommendation(score *models.HealthScore, inputs HealthScoreInputs, config HealthScoreConfig, accountIDs []uint, now time.Time) *Recommendation {
+	if len(accountIDs) == 0 {
+		return nil
+	}
+	overview := s.subscriptionService.GetOverview(accountIDs, now)
+
+	var toCancel, increased []DetectedSubscription
+	var increaseAnnual float64
+	for _, sub := range overview.Subscriptions {
+		switch {
+		case !sub.Confirmed:
+			continue
+		case sub.Status == models.SubscriptionStatusToCancel:
+			toCancel = append(toCancel, sub)
+		case sub.Status == models.SubscriptionStatusActive && sub.PriceIncreased():
+			increased = append(increased, sub)
+			if sub.Amount > 0 {
+				increaseAnnual += sub.PriceIncrease * sub.AnnualCost / sub.Amount
+			}
+		}
+	}
+
+	if len(toCancel) > 0 {
+		merchants, names := subscriptionNames(toCancel)
+		return &Recommendation{
+			Key:   "cancel_subscriptions:" + merchants,
+			Area:  "savings",
+			Title: "Cancele as Assinaturas Marcadas",
+			Description: fmt.Sprintf("Cancelar %d %s marcadas para cancelamento (%s) economiza R$ %.2f por ano.",
+				len(toCancel), pluralize(len(toCancel), "assinatura", "assinaturas"), names, overview.ToCancelAnnual),
+			ActionUrl:   "/subscriptions",
+			ActionText:  "Ver Assinaturas",
+			Priority:    "high",
+			Color:       "danger",
+			ScoreImpact: savingsImpact(score, inputs, config, overview.ToCancelAnnual/12),
+		}
+	}
+
+	if len(increased) > 0 {
+		merchants, names := subscriptionNames(increased)
+		return &Recommendation{
+			Key:   "subscription_price_increase:" + merchants,
+			Area:  "savings",
+			Title: "Assinaturas Mais Caras",
+			Description: fmt.Sprintf("%d %s ficaram mais caras (%s), somando R$ %.2f a mais por ano. Revise se ainda valem a pena ou negocie um plano menor.",
+				len(increased), pluralize(len(increased), "assinatura", "assinaturas"), names, increaseAnnual),
+			ActionUrl:   "/subscriptions",
+			ActionText:  "Ver Assinaturas",
+			Priority:    "medium",
+			Color:       "warning",
+			ScoreImpact: savingsImpact(score, inputs, config, increaseAnnual/12),
+		}
+	}
+
+	return nil
+}
+
+// subscriptionNames returns the sorted merchants (for the key) and the display names of
+// subscriptions
+func subscriptionNames(subscriptions []DetectedSubscription) (string, string) {
+	merchants := make([]string, len(subscriptions))
+	names := make([]string, len(subscriptions))
+	for i, sub := range subscriptions {
+		merchants[i] = sub.Merchant
+		names[i] = sub.Name
+	}
+	sort.Strings(merchants)
+	return strings.Join(merchants, ","), strings.Join(names, ", ")
+}
+
+// emergencyFundRecommendation tells how much is missing from the emergency fund and how long
+// it takes to complete it saving half of the monthly surplus
+func emergencyFundRecommendation(score *models.HealthScore, inputs HealthScoreInputs, config HealthScoreConfig) *Recommendation {
+	fund := inputs.EmergencyFund
+	target := config.Thresholds.EmergencyFundMonths
+	if fund.MonthlyExpenses == 0 || fund.LiquidAssets >= target*fund.MonthlyExpenses {
+		return nil
+	}
+	gap := target*fund.MonthlyExpenses - fund.LiquidAssets
+
+	description := fmt.Sprintf("Sua reserva cobre %.1f meses de gastos e a meta é %.0f meses: faltam R$ %.2f.",
+		fund.LiquidAssets/fund.MonthlyExpenses, target, gap)
+	if surplus := (inputs.Savings.Income - inputs.Savings.Expenses) / 3; surplus > 0 {
+		deposit := surplus / 2
+		description += fmt.Sprintf(" Guardando metade da sua sobra mensal (R$ %.2f) você completa a reserva em %d meses.",
+			deposit, int(math.Ceil(gap/deposit)))
+	} else {
+		description += " Hoje seus gastos consomem toda a renda; corte despesas variáveis para começar a guardar."
+	}
+
+	rec := &Recommendation{
+		Key:         "emergency_fund",
+		Area:        "emergency_fund",
+		Title:       "Complete Sua Reserva de Emergência",
+		Description: description,
+		ActionUrl:   "/net-worth",
+		ActionText:  "Ver Patrimônio",
+		Priority:    "medium",
+		Color:       "warning",
+		ScoreImpact: scoreImpact(score.EmergencyFundScore, 100, config.Weights.EmergencyFund, config),
+	}
+	if score.EmergencyFundScore < 30 {
+		rec.Priority = "high"
+		rec.Color = "danger"
+	}
+	return rec
+}
+
+// debtRecommendation breaks down the monthly obligations when they are above the target, or
+// the outstanding debt when it is too high for the annual income
+func debtRecommendation(score *models.HealthScore, inputs HealthScoreInputs, config HealthScoreConfig) *Recommendation {
+	debt := inputs.Debt
+	thresholds := config.Thresholds
+	if score.DebtScore >= 80 || debt.MonthlyIncome == 0 {
+		return nil
+	}
+
+	rec := &Recommendation{
+		Area:     "debt",
+		Priority: "medium",
+		Color:    "warning",
+	}
+	if score.DebtScore < 30 {
+		rec.Priority = "high"
+		rec.Color = "danger"
+	}
+
+	if debt.ObligationRatio > thresholds.ObligationRatio {
+		obligations := debt.FixedExpenses + debt.Bills + debt.InstallmentsDue
+		cut := obligations - thresholds.ObligationRatio*debt.MonthlyIncome
+		rec.Key = "reduce_obligations"
+		rec.Title = "Reduza Suas Obrigações Mensais"
+		rec.Description = fmt.Sprintf("Suas obrigações somam R$ %.2f por mês (%.0f%% da renda): R$ %.2f em despesas fixas, R$ %.2f em contas e R$ %.2f em parcelas. Reduzir R$ %.2f leva o comprometimento para %.0f%%.",
+			obligations, debt.ObligationRatio*100, debt.FixedExpenses, debt.Bills, debt.InstallmentsDue, cut, thresholds.ObligationRatio*100)
+		rec.ActionUrl = "/expenses"
+		rec.ActionText = "Ver Despesas"
+		improved := math.Min(100, debtToIncomeScore(debt.DebtToIncome, thresholds.DebtToIncome))
+		rec.ScoreImpact = scoreImpact(score.DebtScore, improved, config.Weights.Debt, config)
+		return rec
+	}
+
+	if debt.DebtToIncome > thresholds.DebtToIncome {
+		payoff := debt.OutstandingDebt - thresholds.DebtToIncome*debt.AnnualIncome
+		rec.Key = "reduce_debt"
+		rec.Title = "Reduza Sua Dívida Total"
+		rec.Description = fmt.Sprintf("Suas dívidas e parcelas a pagar somam R$ %.2f, %.0f%% da sua renda anual (meta: %.0f%%). Quitar R$ %.2f, começando pelas de juros mais altos, traz a dívida para a meta.",
+			debt.OutstandingDebt, debt.DebtToIncome*100, thresholds.DebtToIncome*100, payoff)
+		rec.ActionUrl = "/net-worth"
+		rec.ActionText = "Ver Patrimônio"
+		improved := math.Min(100, obligationRatioScore(debt.ObligationRatio, thresholds.ObligationRatio))
+		rec.ScoreImpact = scoreImpact(score.DebtScore, improved, config.Weights.Debt, config)
+		return rec
+	}
+
+	return nil
+}
+
+// goalRecommendation points at the active goal furthest behind its pace
+func (s *HealthScoreService) goalRecommendation(score *models.HealthScore, inputs HealthScoreInputs, config HealthScoreConfig, userID uint, now time.Time) *Recommendation {
+	goals := activeGoals(userID, score.GroupID)
+	if len(goals) == 0 {
+		return nil
+	}
+	projections, err := s.goalService.GetGoalProjections(goals)
+	if err != nil {
+		return nil
+	}
+
+	var worst *models.GroupGoal
+	var worstGap float64
+	for i := range goals {
+		projection := projections[goals[i].ID]
+		if projection.Status != GoalProjectionBehind && projection.Status != GoalProjectionOverdue {
+			continue
+		}
+		if gap := projection.RequiredMonthlyContribution - projection.CurrentMonthlyPace; worst == nil || gap > worstGap {
+			worst, worstGap = &goals[i], gap
+		}
+	}
+	if worst == nil {
+		return nil
+	}
+	projection := projections[worst.ID]
+
+	rec := &Recommendation{
+		Key:        fmt.Sprintf("goal_behind:%d", worst.ID),
+		Area:       "goals",
+		Title:      fmt.Sprintf("Acelere a Meta \"%s\"", worst.Name),
+		ActionUrl:  "/goals",
+		ActionText: "Ver Metas",
+		Priority:   "medium",
+		Color:      "warning",
+	}
+	if projection.Status == GoalProjectionOverdue {
+		rec.Priority = "high"
+		rec.Color = "danger"
+		rec.Description = fmt.Sprintf("A meta \"%s\" venceu em %s e ainda faltam R$ %.2f. Aporte o restante ou revise o prazo.",
+			worst.Name, worst.TargetDate.Format("02/01/2006"), projection.RemainingAmount)
+	} else {
+		rec.Description = fmt.Sprintf("No ritmo atual (R$ %.2f por mês) a meta \"%s\" não fica pronta até %s. Aportando R$ %.2f por mês você chega lá no prazo.",
+			projection.CurrentMonthlyPace, worst.Name, worst.TargetDate.Format("02/01/2006"), projection.RequiredMonthlyContribution)
+	}
+
+	// Impact of one month of the required contribution on the average progress
+	if worst.TargetAmount > 0 && inputs.Goals.ActiveGoals > 0 {
+		progress := inputs.Goals.AverageProgress + projection.RequiredMonthlyContribution/worst.TargetAmount*100/float64(inputs.Goals.ActiveGoals)
+		rec.ScoreImpact = scoreImpact(score.GoalScore, goalProgressScore(progress), config.Weights.Goals, config)
+	}
+	return rec
+}
+
+func pluralize(count int, singular, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
+
+// hiddenRecommendationKeys returns the keys of the recommendations dismissed, completed or
+// still snoozed by a user (personal recommendations) or for a group
+func hiddenRecommendationKeys(userID uint, groupID *uint, now time.Time) map[string]bool {
+	hidden := make(map[string]bool)
+	for _, action := range recommendationActions(userID, groupID) {
+		if action.Hides(now) {
+			hidden[action.Key] = true
+		}
+	}
+	return hidden
+}
+
+func recommendationActions(userID uint, groupID *uint) []models.RecommendationAction {
+	var actions []models.RecommendationAction
+	query := database.DB.Order("acted_at DESC")
+	if groupID != nil {
+		query = query.Where("group_id = ?", *groupID)
+	} else {
+		query = query.Where("user_id = ? AND group_id IS NULL", userID)
+	}
+	query.Find(&actions)
+	return actions
+}
+
+// DismissRecommendation hides a recommendation for good
+func (s *HealthScoreService) DismissRecommendation(userID uint, groupID *uint, rec Recommendation) error {
+	return s.saveRecommendationAction(userID, groupID, rec, models.RecommendationActionDismissed, nil)
+}
+
+// SnoozeRecommendation hides a recommendation for a number of days
+func (s *HealthScoreService) SnoozeRecommendation(userID uint, groupID *uint, rec Recommendation, days int) error {
+	if days <= 0 {
+		days = models.DefaultRecommendationSnoozeDays
+	}
+	until := time.Now().AddDate(0, 0, days)
+	return s.saveRecommendationAction(userID, groupID, rec, models.RecommendationActionSnoozed, &until)
+}
+
+// CompleteRecommendation records that a recommendation was followed, so it isn't repeated
+func (s *HealthScoreService) CompleteRecommendation(userID uint, groupID *uint, rec Recommendation) error {
+	return s.saveRecommendationAction(userID, groupID, rec, models.RecommendationActionCompleted, nil)
+}
+
+// GetCompletedRecommendations returns the recommendations followed by a user (or for a
+// group), most recent first
+func (s *HealthScoreService) GetCompletedRecommendations(userID uint, groupID *uint) ([]models.RecommendationAction, error) {
+	if groupID != nil && !s.groupService.IsGroupMember(*groupID, userID) {
+		return nil, ErrUnauthorized
+	}
+	completed := []models.RecommendationAction{}
+	for _, action := range recommendationActions(userID, groupID) {
+		if action.Status == models.RecommendationActionCompleted {
+			completed = append(completed, action)
+		}
+	}
+	return completed, nil
+}
+
+// saveRecommendationAction stores the latest action on a recommendation of a user or group
+func (s *HealthScoreService) saveRecommendationAction(userID uint, groupID *uint, rec Recommendation, status models.RecommendationActionStatus, snoozedUntil *time.Time) error {
+	if rec.Key == "" {
+		return ErrInvalidRecommendationAction
+	}
+	if groupID != nil && !s.groupService.IsGroupMember(*groupID, userID) {
+		return ErrUnauthorized
+	}
+
+	var action models.RecommendationAction
+	query := database.DB.Where("key = ?", rec.Key)
+	if groupID != nil {
+		query = query.Where("group_id = ?", *groupID)
+	} else {
+		query = query.Where("user_id = ? AND group_id IS NULL", userID)
+	}
+	if err := query.First(&action).Error; err != nil {
+		action = models.RecommendationAction{GroupID: groupID, Key: rec.Key}
+	}
+
+	action.UserID = userID
+	action.Title = rec.Title
+	action.Status = status
+	action.SnoozedUntil = snoozedUntil
+	action.ScoreImpact = rec.ScoreImpact
+	action.ActedAt = time.Now()
+	return database.DB.Save(&action).Error
+}
@@ -1,9 +1,15 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
 	"testing"
 	"time"
 
+	"gorm.io/gorm"
+
 	"poc-finance/internal/database"
 	"poc-finance/internal/models"
 	"poc-finance/internal/testutil"
@@ -20,14 +26,16 @@ func TestHealthScoreService_CalculateUserScore_PerfectScore(t *testing.T) {
 	account := testutil.CreateTestAccount(db, "Personal Account", models.AccountTypeIndividual, user.ID, nil)
 
 	// Create perfect financial situation:
-	// - High income
-	db.Create(&models.Income{
-		AccountID:   account.ID,
-		Date:        time.Now().AddDate(0, -1, 0),
-		GrossAmount: 10000.00,
-		TaxAmount:   1000.00,
-		NetAmount:   9000.00,
-	})
+	// - High and stable income over the last 6 months
+	for i := 0; i < 6; i++ {
+		db.Create(&models.Income{
+			AccountID:   account.ID,
+			Date:        time.Now().AddDate(0, -i, -1),
+			GrossAmount: 10000.00,
+			TaxAmount:   1000.00,
+			NetAmount:   9000.00,
+		})
+	}
 
 	// - Low fixed expenses (10% of income = excellent savings rate)
 	db.Create(&models.Expense{
@@ -53,6 +61,11 @@ func TestHealthScoreService_CalculateUserScore_PerfectScore(t *testing.T) {
 	}
 	db.Create(goal)
 
+	// - Assets and no debts
+	if _, err := NewNetWorthService().CreateItem(user.ID, nil, "Investments", models.NetWorthCategoryInvestment, 50000.00, time.Now()); err != nil {
+		t.Fatalf("CreateItem() error = %v", err)
+	}
+
 	service := NewHealthScoreService()
 	score, err := service.CalculateUserScore(user.ID, []uint{account.ID})
 
@@ -186,8 +199,10 @@ func TestHealthScoreService_CalculateUserScore_ComponentScores(t *testing.T) {
 	}
 
 	// Overall score should be weighted average
-	expectedScore := (score.SavingsScore * 0.30) + (score.DebtScore * 0.25) +
-		(score.GoalScore * 0.25) + (score.BudgetScore * 0.20)
+	weights := DefaultHealthScoreConfig().Weights
+	expectedScore := (score.SavingsScore*weights.Savings + score.DebtScore*weights.Debt +
+		score.EmergencyFundScore*weights.EmergencyFund + score.IncomeStabilityScore*weights.IncomeStability +
+		score.GoalScore*weights.Goals + score.BudgetScore*weights.Budget + score.NetWorthScore*weights.NetWorth) / weights.Total()
 
 	if score.Score < expectedScore-0.1 || score.Score > expectedScore+0.1 {
 		t.Errorf("Score = %.2f, want %.2f (weighted average)", score.Score, expectedScore)
@@ -432,10 +447,10 @@ func TestHealthScoreService_SavesScoreToDB(t *testing.T) {
 	})
 
 	service := NewHealthScoreService()
-	score, err := service.CalculateUserScore(user.ID, []uint{account.ID})
+	score, err := service.SnapshotUserScore(user.ID, time.Now())
 
 	if err != nil {
-		t.Fatalf("CalculateUserScore() error = %v", err)
+		t.Fatalf("SnapshotUserScore() error = %v", err)
 	}
 
 	// Verify score was saved to database
@@ -491,3 +506,299 @@ func TestHealthScoreService_NegativeBalanceHandling(t *testing.T) {
 		t.Errorf("SavingsScore should be very low with negative balance, got %.2f", score.SavingsScore)
 	}
 }
+
+func TestHealthScoreService_TakeSnapshots(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+	db.AutoMigrate(&models.HealthScore{})
+
+	user := testutil.CreateTestUser(db, "snapshot@example.com", "Snapshot User", "hash")
+	group := testutil.CreateTestGroup(db, "Test Family", user.ID)
+	testutil.CreateTestGroupMember(db, group.ID, user.ID, "admin")
+	userID := user.ID
+
+	now := time.Date(2030, 5, 15, 10, 0, 0, 0, time.Local)
+
+	// Rows inserted on every page view before snapshots existed
+	for _, calculatedAt := range []time.Time{
+		now.AddDate(0, 0, -3), now.AddDate(0, 0, -2), now.AddDate(0, 0, -1),
+		now.AddDate(0, -1, -2), now.AddDate(0, -1, 0),
+	} {
+		db.Create(&models.HealthScore{UserID: &userID, Score: 10, CalculatedAt: calculatedAt})
+	}
+
+	service := NewHealthScoreService()
+
+	// Live scores are not persisted
+	if _, err := service.CalculateUserScore(user.ID, nil); err != nil {
+		t.Fatalf("CalculateUserScore() error = %v", err)
+	}
+	var count int64
+	db.Model(&models.HealthScore{}).Count(&count)
+	if count != 5 {
+		t.Fatalf("CalculateUserScore() should not persist, got %d rows", count)
+	}
+
+	// Running twice keeps one row per owner and month
+	for i := 0; i < 2; i++ {
+		if err := service.takeSnapshots(now); err != nil {
+			t.Fatalf("takeSnapshots() error = %v", err)
+		}
+	}
+
+	history, err := service.GetScoreHistory(&userID, nil, 12)
+	if err != nil {
+		t.Fatalf("GetScoreHistory() error = %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 monthly user scores, got %d", len(history))
+	}
+	if history[0].Period != "2030-05" || history[1].Period != "2030-04" {
+		t.Errorf("periods = %s, %s, want 2030-05, 2030-04", history[0].Period, history[1].Period)
+	}
+	if history[0].Score == 10 {
+		t.Error("current month snapshot should be recalculated")
+	}
+
+	groupID := group.ID
+	groupHistory, err := service.GetScoreHistory(nil, &groupID, 12)
+	if err != nil {
+		t.Fatalf("GetScoreHistory() error = %v", err)
+	}
+	if len(groupHistory) != 1 || groupHistory[0].Period != "2030-05" {
+		t.Errorf("expected 1 group snapshot for 2030-05, got %+v", groupHistory)
+	}
+
+	db.Unscoped().Model(&models.HealthScore{}).Count(&count)
+	if count != 3 {
+		t.Errorf("expected 3 rows after compaction, got %d", count)
+	}
+}
+
+func TestHealthScoreService_ConfigurableWeightsAndInputs(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "inputs@example.com", "Inputs User", "hash")
+	account := testutil.CreateTestAccount(db, "Personal Account", models.AccountTypeIndividual, user.ID, nil)
+
+	db.Create(&models.Income{
+		AccountID:   account.ID,
+		Date:        time.Now().AddDate(0, 0, -1),
+		GrossAmount: 5000.00,
+		NetAmount:   5000.00,
+	})
+	db.Create(&models.Expense{
+		AccountID: account.ID,
+		Name:      "Rent",
+		Amount:    1000.00,
+		Type:      models.ExpenseTypeFixed,
+		Active:    true,
+	})
+
+	// Three installments of 100 from this month
+	card := models.CreditCard{AccountID: account.ID, Name: "Cartão", ClosingDay: 1, DueDay: 10}
+	db.Create(&card)
+	db.Create(&models.Installment{
+		CreditCardID: card.ID, Description: "Geladeira", TotalAmount: 300, InstallmentAmount: 100,
+		TotalInstallments: 3, CurrentInstallment: 1, StartDate: time.Now(),
+	})
+
+	netWorthService := NewNetWorthService()
+	if _, err := netWorthService.CreateItem(user.ID, nil, "Reserva", models.NetWorthCategoryCash, 6000, time.Now()); err != nil {
+		t.Fatalf("CreateItem() error = %v", err)
+	}
+	if _, err := netWorthService.CreateItem(user.ID, nil, "Empréstimo", models.NetWorthCategoryLoan, 5000, time.Now()); err != nil {
+		t.Fatalf("CreateItem() error = %v", err)
+	}
+
+	invalid := DefaultHealthScoreConfig()
+	invalid.Weights = HealthScoreWeights{}
+	if err := SaveHealthScoreConfig(invalid); err != ErrInvalidHealthScoreConfig {
+		t.Errorf("SaveHealthScoreConfig() with zero weights error = %v, want %v", err, ErrInvalidHealthScoreConfig)
+	}
+
+	// Only the emergency fund counts, with a 12-month target
+	config := DefaultHealthScoreConfig()
+	config.Weights = HealthScoreWeights{EmergencyFund: 1}
+	config.Thresholds.EmergencyFundMonths = 12
+	if err := SaveHealthScoreConfig(config); err != nil {
+		t.Fatalf("SaveHealthScoreConfig() error = %v", err)
+	}
+
+	score, err := NewHealthScoreService().CalculateUserScore(user.ID, []uint{account.ID})
+	if err != nil {
+		t.Fatalf("CalculateUserScore() error = %v", err)
+	}
+
+	// 6000 of cash covers 6 months of 1000 expenses: half of the target
+	if math.Abs(score.EmergencyFundScore-60) > 0.01 {
+		t.Errorf("EmergencyFundScore = %.2f, want 60.00", score.EmergencyFundScore)
+	}
+	if math.Abs(score.Score-score.EmergencyFundScore) > 0.01 {
+		t.Errorf("Score = %.2f, want %.2f (only the emergency fund is weighted)", score.Score, score.EmergencyFundScore)
+	}
+
+	inputs := ScoreInputs(score)
+	if inputs.Weights.EmergencyFund != 1 || inputs.Thresholds.EmergencyFundMonths != 12 {
+		t.Errorf("stored config = %+v, want the saved one", inputs.Weights)
+	}
+	if inputs.EmergencyFund.LiquidAssets != 6000 || inputs.EmergencyFund.MonthlyExpenses != 1000 {
+		t.Errorf("emergency fund inputs = %+v, want 6000 of liquid assets for 1000 of expenses", inputs.EmergencyFund)
+	}
+	if inputs.Debt.InstallmentsDue != 100 || inputs.Debt.OutstandingDebt != 5200 {
+		t.Errorf("debt inputs = %+v, want 100 due this month and 5200 outstanding", inputs.Debt)
+	}
+	if math.Abs(inputs.Debt.ObligationRatio-0.22) > 0.001 {
+		t.Errorf("obligation ratio = %.3f, want 0.220", inputs.Debt.ObligationRatio)
+	}
+}
+
+func TestHealthScoreService_RecommendationActions(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "advice@example.com", "Advice User", "hash")
+	other := testutil.CreateTestUser(db, "other@example.com", "Other User", "hash")
+	account := testutil.CreateTestAccount(db, "Personal Account", models.AccountTypeIndividual, user.ID, nil)
+	group := testutil.CreateTestGroup(db, "Família", other.ID)
+
+	db.Create(&models.Income{
+		AccountID:   account.ID,
+		Date:        time.Now().AddDate(0, 0, -1),
+		GrossAmount: 5000.00,
+		NetAmount:   5000.00,
+	})
+	db.Create(&models.Expense{
+		AccountID: account.ID,
+		Name:      "Rent",
+		Amount:    1000.00,
+		Type:      models.ExpenseTypeFixed,
+		Active:    true,
+	})
+	// One month of reserve against a six-month target
+	if _, err := NewNetWorthService().CreateItem(user.ID, nil, "Reserva", models.NetWorthCategoryCash, 1000, time.Now()); err != nil {
+		t.Fatalf("CreateItem() error = %v", err)
+	}
+
+	service := NewHealthScoreService()
+	score, err := service.CalculateUserScore(user.ID, []uint{account.ID})
+	if err != nil {
+		t.Fatalf("CalculateUserScore() error = %v", err)
+	}
+
+	find := func(recs []Recommendation, key string) *Recommendation {
+		for i := range recs {
+			if recs[i].Key == key {
+				return &recs[i]
+			}
+		}
+		return nil
+	}
+
+	recs, _ := service.GetRecommendations(score)
+	rec := find(recs, "emergency_fund")
+	if rec == nil {
+		t.Fatalf("GetRecommendations() = %+v, want an emergency fund recommendation", recs)
+	}
+	if rec.ScoreImpact <= 0 {
+		t.Errorf("ScoreImpact = %.2f, want a positive estimate", rec.ScoreImpact)
+	}
+
+	if err := service.DismissRecommendation(user.ID, nil, Recommendation{}); err != ErrInvalidRecommendationAction {
+		t.Errorf("DismissRecommendation() without key error = %v, want %v", err, ErrInvalidRecommendationAction)
+	}
+	if err := service.DismissRecommendation(user.ID, &group.ID, *rec); err != ErrUnauthorized {
+		t.Errorf("DismissRecommendation() for another group error = %v, want %v", err, ErrUnauthorized)
+	}
+
+	// Snoozed: hidden now, back after the snooze period
+	if err := service.SnoozeRecommendation(user.ID, nil, *rec, 30); err != nil {
+		t.Fatalf("SnoozeRecommendation() error = %v", err)
+	}
+	recs, _ = service.GetRecommendations(score)
+	if find(recs, "emergency_fund") != nil {
+		t.Error("snoozed recommendation still listed")
+	}
+	if find(service.getRecommendations(score, time.Now().AddDate(0, 0, 31)), "emergency_fund") == nil {
+		t.Error("snoozed recommendation not listed after the snooze period")
+	}
+
+	// Completed: hidden and kept in the completed list
+	if err := service.CompleteRecommendation(user.ID, nil, *rec); err != nil {
+		t.Fatalf("CompleteRecommendation() error = %v", err)
+	}
+	if find(service.getRecommendations(score, time.Now().AddDate(0, 0, 31)), "emergency_fund") != nil {
+		t.Error("completed recommendation still listed")
+	}
+	completed, err := service.GetCompletedRecommendations(user.ID, nil)
+	if err != nil {
+		t.Fatalf("GetCompletedRecommendations() error = %v", err)
+	}
+	if len(completed) != 1 || completed[0].Key != "emergency_fund" || completed[0].ScoreImpact != rec.ScoreImpact {
+		t.Errorf("GetCompletedRecommendations() = %+v, want the emergency fund recommendation", completed)
+	}
+
+	// Dismissed: hidden for good and no longer counted as completed
+	if err := service.DismissRecommendation(user.ID, nil, *rec); err != nil {
+		t.Fatalf("DismissRecommendation() error = %v", err)
+	}
+	if find(service.getRecommendations(score, time.Now().AddDate(1, 0, 0)), "emergency_fund") != nil {
+		t.Error("dismissed recommendation still listed")
+	}
+	completed, _ = service.GetCompletedRecommendations(user.ID, nil)
+	if len(completed) != 0 {
+		t.Errorf("GetCompletedRecommendations() after dismiss = %+v, want none", completed)
+	}
+}
+
+func TestHealthScoreService_TakeSnapshots_ReturnsFailures(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "failing@example.com", "Failing User", "hash")
+	testutil.CreateTestAccount(db, "Personal Account", models.AccountTypeIndividual, user.ID, nil)
+
+	// Without the accounts table the user's score can't be calculated
+	db.Migrator().DropTable(&models.Account{})
+
+	err := NewHealthScoreService().takeSnapshots(time.Date(2030, 5, 15, 10, 0, 0, 0, time.Local))
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("user %d", user.ID)) {
+		t.Errorf("takeSnapshots() error = %v, want the failed snapshot of user %d", err, user.ID)
+	}
+}
+
+func TestSaveScoreSnapshot_OneRowPerOwnerAndPeriod(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "unique@example.com", "Unique User", "hash")
+	userID := user.ID
+	now := time.Date(2030, 5, 15, 10, 0, 0, 0, time.Local)
+
+	if err := saveScoreSnapshot(&models.HealthScore{UserID: &userID, Score: 50}, now); err != nil {
+		t.Fatalf("saveScoreSnapshot() error = %v", err)
+	}
+	if err := saveScoreSnapshot(&models.HealthScore{UserID: &userID, Score: 60}, now); err != nil {
+		t.Fatalf("saveScoreSnapshot() again error = %v", err)
+	}
+	var count int64
+	db.Model(&models.HealthScore{}).Count(&count)
+	if count != 1 {
+		t.Errorf("expected 1 snapshot, got %d", count)
+	}
+
+	// A second row for the same owner and period is rejected
+	duplicate := models.HealthScore{UserID: &userID, Score: 70, Period: "2030-05", CalculatedAt: now}
+	if err := db.Create(&duplicate).Error; err == nil {
+		t.Error("duplicate snapshot should be rejected")
+	}
+
+	// A failed lookup is returned instead of inserting another row
+	db.Callback().Query().Before("gorm:query").Register("fail_lookup", func(tx *gorm.DB) {
+		tx.AddError(errors.New("lookup failed"))
+	})
+	if err := saveScoreSnapshot(&models.HealthScore{UserID: &userID, Score: 80}, now.AddDate(0, 1, 0)); err == nil {
+		t.Error("saveScoreSnapshot() should return the lookup error")
+	}
+}
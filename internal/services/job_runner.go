@@ -0,0 +1,339 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+// Names of the background jobs registered by the server
+const (
+	JobRecurringTransactions = "recurring_transactions"
+	JobDueDateNotifications  = "due_date_notifications"
+	JobSubscriptionReminders = "subscription_reminders"
+	JobHealthScoreSnapshots  = "health_score_snapshots"
+	JobScheduledSummaries    = "scheduled_summaries"
+	JobNotificationDigest    = "notification_digest"
+	JobNotificationRetention = "notification_retention"
+)
+
+var (
+	ErrJobNotFound = errors.New("tarefa não encontrada")
+	ErrJobLocked   = errors.New("tarefa já está em execução")
+)
+
+const (
+	defaultJobMaxAttempts = 3
+	defaultJobRetryDelay  = time.Minute
+	defaultJobLockTTL     = time.Hour
+)
+
+// Job is a background task that can be run by the JobRunnerService
+type Job struct {
+	Name        string
+	Description string
+	Run         func() error
+	MaxAttempts int           // Attempts per run, including the first one (default 3)
+	RetryDelay  time.Duration // Wait between attempts (default 1 minute)
+}
+
+// JobStatus summarizes a registered job for the admin page
+type JobStatus struct {
+	Job         Job
+	LastRun     *models.JobRun
+	LastSuccess *models.JobRun
+	Running     bool
+}
+
+// JobRunnerService executes registered jobs, persisting every attempt in the job_runs table.
+// A lease in job_locks ensures only one server instance runs a given job at a time.
+type JobRunnerService struct {
+	instanceID string
+	lockTTL    time.Duration
+	sleep      func(time.Duration)
+	// async runs the jobs started from the admin page off the caller's request
+	async func(func())
+
+	mu   sync.RWMutex
+	jobs map[string]Job
+}
+
+// NewJobRunnerService creates a job runner identified by the host name and process ID
+func NewJobRunnerService() *JobRunnerService {
+	hostname, _ := os.Hostname()
+	return &JobRunnerService{
+		instanceID: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		lockTTL:    defaultJobLockTTL,
+		sleep:      time.Sleep,
+		async:      func(f func()) { go f() },
+		jobs:       make(map[string]Job),
+	}
+}
+
+// Register adds a job to the runner, filling retry defaults
+func (s *JobRunnerService) Register(job Job) {
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = defaultJobMaxAttempts
+	}
+	if job.RetryDelay <= 0 {
+		job.RetryDelay = defaultJobRetryDelay
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.Name] = job
+}
+
+// Jobs returns the registered jobs sorted by name
+func (s *JobRunnerService) Jobs() []Job {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs
+}
+
+// RunJob executes a job while holding its lock, retrying failed attempts up to MaxAttempts.
+// Every attempt is recorded as a JobRun; the last one is returned.
+func (s *JobRunnerService) RunJob(name string, trigger models.JobTrigger) (*models.JobRun, error) {
+	job, err := s.lockJob(name)
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseLock(name)
+	return s.runLocked(job, trigger)
+}
+
+// StartJob takes a job's lock and runs it in the background, returning at once. With its
+// retries a failing job can take minutes, longer than a request may wait; the admin page
+// follows the run through its job_runs rows instead.
+func (s *JobRunnerService) StartJob(name string, trigger models.JobTrigger) error {
+	job, err := s.lockJob(name)
+	if err != nil {
+		return err
+	}
+	s.async(func() {
+		defer s.releaseLock(name)
+		if _, err := s.runLocked(job, trigger); err != nil {
+			log.Printf("[Jobs] %s: %v", name, err)
+		}
+	})
+	return nil
+}
+
+// lockJob looks up a registered job and takes its lock
+func (s *JobRunnerService) lockJob(name string) (Job, error) {
+	s.mu.RLock()
+	job, ok := s.jobs[name]
+	s.mu.RUnlock()
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+
+	acquired, err := s.acquireLock(name)
+	if err != nil {
+		return Job{}, fmt.Errorf("failed to acquire job lock: %w", err)
+	}
+	if !acquired {
+		log.Printf("[Jobs] %s is already running, skipping", name)
+		return Job{}, ErrJobLocked
+	}
+	return job, nil
+}
+
+// runLocked runs the attempts of a job whose lock the caller holds
+func (s *JobRunnerService) runLocked(job Job, trigger models.JobTrigger) (*models.JobRun, error) {
+	// Runs still marked as running were interrupted (we hold the lock, so nobody else is running)
+	database.DB.Model(&models.JobRun{}).
+		Where("job_name = ? AND status = ?", job.Name, models.JobRunRunning).
+		Updates(map[string]interface{}{"status": models.JobRunFailed, "error": "execução interrompida"})
+
+	var run *models.JobRun
+	var err error
+	for attempt := 1; attempt <= job.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			s.sleep(job.RetryDelay)
+			trigger = models.JobTriggerRetry
+		}
+
+		run, err = s.runAttempt(job, trigger, attempt)
+		if err != nil {
+			return nil, err
+		}
+		if run.Status == models.JobRunSucceeded {
+			break
+		}
+	}
+
+	return run, nil
+}
+
+// runAttempt executes a single attempt of a job and persists its outcome
+func (s *JobRunnerService) runAttempt(job Job, trigger models.JobTrigger, attempt int) (*models.JobRun, error) {
+	run := &models.JobRun{
+		JobName:    job.Name,
+		Status:     models.JobRunRunning,
+		Trigger:    trigger,
+		Attempt:    attempt,
+		InstanceID: s.instanceID,
+		StartedAt:  time.Now(),
+	}
+	if err := database.DB.Create(run).Error; err != nil {
+		return nil, fmt.Errorf("failed to record job run: %w", err)
+	}
+
+	log.Printf("[Jobs] Running %s (attempt %d/%d)", job.Name, attempt, job.MaxAttempts)
+	runErr := safeRun(job.Run)
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Status = models.JobRunSucceeded
+	if runErr != nil {
+		run.Status = models.JobRunFailed
+		run.Error = runErr.Error()
+		log.Printf("[Jobs] %s failed: %v", job.Name, runErr)
+	}
+
+	if err := database.DB.Save(run).Error; err != nil {
+		return nil, fmt.Errorf("failed to record job run: %w", err)
+	}
+	return run, nil
+}
+
+// safeRun calls fn, converting a panic into an error so the scheduler goroutine survives
+func safeRun(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[Jobs] panic: %v\n%s", r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
+
+// acquireLock takes the job lease if it is free or expired. A held lease means the job is
+// running, on this instance too, so a manual run never overlaps a scheduled one.
+// The primary key on job_locks.name makes concurrent first-time inserts fail for all but one instance.
+func (s *JobRunnerService) acquireLock(name string) (bool, error) {
+	now := time.Now()
+	until := now.Add(s.lockTTL)
+
+	result := database.DB.Model(&models.JobLock{}).
+		Where("name = ? AND locked_until < ?", name, now).
+		Updates(map[string]interface{}{"owner": s.instanceID, "locked_until": until})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	lock := models.JobLock{Name: name, Owner: s.instanceID, LockedUntil: until}
+	result = database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&lock)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// releaseLock frees the job lease held by this instance
+func (s *JobRunnerService) releaseLock(name string) {
+	err := database.DB.Model(&models.JobLock{}).
+		Where("name = ? AND owner = ?", name, s.instanceID).
+		Update("locked_until", time.Time{}).Error
+	if err != nil {
+		log.Printf("[Jobs] Error releasing lock for %s: %v", name, err)
+	}
+}
+
+// isLocked reports whether any instance currently holds the job lease
+func (s *JobRunnerService) isLocked(name string) bool {
+	var count int64
+	database.DB.Model(&models.JobLock{}).
+		Where("name = ? AND locked_until >= ?", name, time.Now()).
+		Count(&count)
+	return count > 0
+}
+
+// RetryRun starts a new manual run of the job of a failed run in the background
+func (s *JobRunnerService) RetryRun(runID uint) error {
+	var run models.JobRun
+	if err := database.DB.First(&run, runID).Error; err != nil {
+		return ErrJobNotFound
+	}
+	return s.StartJob(run.JobName, models.JobTriggerManual)
+}
+
+// GetRecentRuns returns the latest runs, optionally filtered by job name and status
+func (s *JobRunnerService) GetRecentRuns(jobName string, status models.JobRunStatus, limit int) ([]models.JobRun, error) {
+	query := database.DB.Order("started_at DESC, id DESC")
+	if jobName != "" {
+		query = query.Where("job_name = ?", jobName)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var runs []models.JobRun
+	err := query.Find(&runs).Error
+	return runs, err
+}
+
+// GetJobStatuses returns the last run, last success and lock state of every registered job
+func (s *JobRunnerService) GetJobStatuses() []JobStatus {
+	jobs := s.Jobs()
+	statuses := make([]JobStatus, 0, len(jobs))
+
+	for _, job := range jobs {
+		status := JobStatus{Job: job, Running: s.isLocked(job.Name)}
+
+		var lastRun models.JobRun
+		if err := database.DB.Where("job_name = ?", job.Name).Order("started_at DESC, id DESC").First(&lastRun).Error; err == nil {
+			status.LastRun = &lastRun
+		}
+
+		var lastSuccess models.JobRun
+		if err := database.DB.Where("job_name = ? AND status = ?", job.Name, models.JobRunSucceeded).
+			Order("started_at DESC, id DESC").First(&lastSuccess).Error; err == nil {
+			status.LastSuccess = &lastSuccess
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// ClaimIdempotencyKey records that the unit of work identified by key was processed.
+// It returns false when the key was already claimed, meaning the work must be skipped.
+func ClaimIdempotencyKey(db *gorm.DB, jobName, key string) (bool, error) {
+	record := models.JobIdempotencyKey{Key: key, JobName: jobName}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ReleaseIdempotencyKey removes a claimed key so the unit of work can be retried
+func ReleaseIdempotencyKey(db *gorm.DB, key string) error {
+	return db.Where("key = ?", key).Delete(&models.JobIdempotencyKey{}).Error
+}
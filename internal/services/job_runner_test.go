@@ -0,0 +1,335 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+// newTestJobRunner creates a runner with a fixed instance ID that never sleeps between retries
+func newTestJobRunner(instanceID string) *JobRunnerService {
+	runner := NewJobRunnerService()
+	runner.instanceID = instanceID
+	runner.sleep = func(time.Duration) {}
+	return runner
+}
+
+func TestJobRunnerService_RunJob_Success(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	runner := newTestJobRunner("instance-a")
+	calls := 0
+	runner.Register(Job{Name: "test", Run: func() error {
+		calls++
+		return nil
+	}})
+
+	run, err := runner.RunJob("test", models.JobTriggerManual)
+	if err != nil {
+		t.Fatalf("RunJob() error = %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+	if run.Status != models.JobRunSucceeded {
+		t.Errorf("Status = %s, want %s", run.Status, models.JobRunSucceeded)
+	}
+	if run.FinishedAt == nil {
+		t.Error("FinishedAt should be set")
+	}
+
+	// The lock is released after the run, so another instance can run the job
+	other := newTestJobRunner("instance-b")
+	other.Register(Job{Name: "test", Run: func() error { return nil }})
+	if _, err := other.RunJob("test", models.JobTriggerSchedule); err != nil {
+		t.Errorf("RunJob() on second instance error = %v", err)
+	}
+}
+
+func TestJobRunnerService_RunJob_Retries(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	tests := []struct {
+		name        string
+		failures    int
+		maxAttempts int
+		wantRuns    int
+		wantStatus  models.JobRunStatus
+	}{
+		{name: "succeeds after retries", failures: 2, maxAttempts: 3, wantRuns: 3, wantStatus: models.JobRunSucceeded},
+		{name: "gives up after max attempts", failures: 5, maxAttempts: 2, wantRuns: 2, wantStatus: models.JobRunFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := newTestJobRunner("instance-a")
+			calls := 0
+			runner.Register(Job{Name: tt.name, MaxAttempts: tt.maxAttempts, Run: func() error {
+				calls++
+				if calls <= tt.failures {
+					return errors.New("temporary failure")
+				}
+				return nil
+			}})
+
+			run, err := runner.RunJob(tt.name, models.JobTriggerSchedule)
+			if err != nil {
+				t.Fatalf("RunJob() error = %v", err)
+			}
+			if run.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s", run.Status, tt.wantStatus)
+			}
+
+			runs, _ := runner.GetRecentRuns(tt.name, "", 0)
+			if len(runs) != tt.wantRuns {
+				t.Fatalf("Expected %d runs, got %d", tt.wantRuns, len(runs))
+			}
+			if runs[0].Attempt != tt.wantRuns || runs[0].Trigger != models.JobTriggerRetry {
+				t.Errorf("last run Attempt = %d, Trigger = %s", runs[0].Attempt, runs[0].Trigger)
+			}
+			if tt.wantStatus == models.JobRunFailed && runs[0].Error != "temporary failure" {
+				t.Errorf("Error = %q, want %q", runs[0].Error, "temporary failure")
+			}
+		})
+	}
+}
+
+func TestJobRunnerService_RunJob_Panic(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	runner := newTestJobRunner("instance-a")
+	runner.Register(Job{Name: "panics", MaxAttempts: 1, Run: func() error {
+		panic("boom")
+	}})
+
+	run, err := runner.RunJob("panics", models.JobTriggerSchedule)
+	if err != nil {
+		t.Fatalf("RunJob() error = %v", err)
+	}
+	if run.Status != models.JobRunFailed || run.Error != "panic: boom" {
+		t.Errorf("Status = %s, Error = %q", run.Status, run.Error)
+	}
+}
+
+func TestJobRunnerService_RunJob_Locked(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	// Another instance holds a valid lease, and died leaving a run marked as running
+	db.Create(&models.JobLock{Name: "test", Owner: "instance-b", LockedUntil: time.Now().Add(time.Hour)})
+	db.Create(&models.JobRun{JobName: "test", Status: models.JobRunRunning, StartedAt: time.Now()})
+
+	runner := newTestJobRunner("instance-a")
+	calls := 0
+	runner.Register(Job{Name: "test", Run: func() error {
+		calls++
+		return nil
+	}})
+
+	if _, err := runner.RunJob("test", models.JobTriggerSchedule); err != ErrJobLocked {
+		t.Errorf("RunJob() error = %v, want %v", err, ErrJobLocked)
+	}
+	if calls != 0 {
+		t.Errorf("calls = %d, want 0", calls)
+	}
+
+	// Once the lease expires the job runs and the interrupted run is marked as failed
+	db.Model(&models.JobLock{}).Where("name = ?", "test").Update("locked_until", time.Now().Add(-time.Minute))
+	if _, err := runner.RunJob("test", models.JobTriggerSchedule); err != nil {
+		t.Fatalf("RunJob() after lease expiry error = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+
+	failed, _ := runner.GetRecentRuns("test", models.JobRunFailed, 0)
+	if len(failed) != 1 {
+		t.Errorf("Expected 1 interrupted run, got %d", len(failed))
+	}
+}
+
+func TestJobRunnerService_RunJob_AlreadyRunningOnThisInstance(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	runner := newTestJobRunner("instance-a")
+	var nestedErr error
+	runner.Register(Job{Name: "test", Run: func() error {
+		// A manual run while the scheduled one is still going
+		_, nestedErr = runner.RunJob("test", models.JobTriggerManual)
+		return nil
+	}})
+
+	run, err := runner.RunJob("test", models.JobTriggerSchedule)
+	if err != nil {
+		t.Fatalf("RunJob() error = %v", err)
+	}
+	if nestedErr != ErrJobLocked {
+		t.Errorf("overlapping RunJob() error = %v, want %v", nestedErr, ErrJobLocked)
+	}
+	if run.Status != models.JobRunSucceeded {
+		t.Errorf("Status = %s, want the running run left untouched", run.Status)
+	}
+
+	// The lease is released afterwards
+	if _, err := runner.RunJob("test", models.JobTriggerManual); err != nil {
+		t.Errorf("RunJob() after the run finished error = %v", err)
+	}
+}
+
+func TestJobRunnerService_StartJob(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	runner := newTestJobRunner("instance-a")
+	release := make(chan struct{})
+	done := make(chan struct{})
+	runner.async = func(f func()) {
+		go func() {
+			f()
+			close(done)
+		}()
+	}
+	runner.Register(Job{Name: "slow", MaxAttempts: 1, Run: func() error {
+		<-release
+		return nil
+	}})
+
+	// StartJob returns while the job is still running, holding its lock
+	if err := runner.StartJob("slow", models.JobTriggerManual); err != nil {
+		t.Fatalf("StartJob() error = %v", err)
+	}
+	if err := runner.StartJob("slow", models.JobTriggerManual); err != ErrJobLocked {
+		t.Errorf("StartJob() while running error = %v, want %v", err, ErrJobLocked)
+	}
+	if err := runner.StartJob("missing", models.JobTriggerManual); err != ErrJobNotFound {
+		t.Errorf("StartJob() error = %v, want %v", err, ErrJobNotFound)
+	}
+
+	close(release)
+	<-done
+	runs, _ := runner.GetRecentRuns("slow", "", 0)
+	if len(runs) != 1 || runs[0].Status != models.JobRunSucceeded || runs[0].Trigger != models.JobTriggerManual {
+		t.Errorf("runs = %+v, want one succeeded manual run", runs)
+	}
+	if runner.isLocked("slow") {
+		t.Error("lock wasn't released after the background run")
+	}
+}
+
+func TestJobRunnerService_RunJob_NotFound(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	runner := newTestJobRunner("instance-a")
+	if _, err := runner.RunJob("missing", models.JobTriggerManual); err != ErrJobNotFound {
+		t.Errorf("RunJob() error = %v, want %v", err, ErrJobNotFound)
+	}
+}
+
+func TestClaimIdempotencyKey(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	claimed, err := ClaimIdempotencyKey(db, "test", "key-1")
+	if err != nil || !claimed {
+		t.Fatalf("first ClaimIdempotencyKey() = %v, %v, want true, nil", claimed, err)
+	}
+
+	claimed, err = ClaimIdempotencyKey(db, "test", "key-1")
+	if err != nil || claimed {
+		t.Errorf("second ClaimIdempotencyKey() = %v, %v, want false, nil", claimed, err)
+	}
+
+	if err := ReleaseIdempotencyKey(db, "key-1"); err != nil {
+		t.Fatalf("ReleaseIdempotencyKey() error = %v", err)
+	}
+
+	claimed, _ = ClaimIdempotencyKey(db, "test", "key-1")
+	if !claimed {
+		t.Error("ClaimIdempotencyKey() after release = false, want true")
+	}
+}
+
+func TestRecurringSchedulerService_ProcessDueTransactions_Idempotent(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+
+	today := time.Now()
+	rt := &models.RecurringTransaction{
+		AccountID:       account.ID,
+		TransactionType: models.TransactionTypeExpense,
+		Frequency:       models.FrequencyMonthly,
+		Amount:          100,
+		Description:     "Rent",
+		StartDate:       today,
+		NextRunDate:     today,
+		Active:          true,
+	}
+	db.Create(rt)
+
+	scheduler := NewRecurringSchedulerService()
+	if err := scheduler.ProcessDueTransactions(); err != nil {
+		t.Fatalf("ProcessDueTransactions() error = %v", err)
+	}
+
+	// Simulate a crash before NextRunDate was persisted: the same occurrence is due again
+	db.Model(rt).Update("next_run_date", today)
+	if err := scheduler.ProcessDueTransactions(); err != nil {
+		t.Fatalf("ProcessDueTransactions() error = %v", err)
+	}
+
+	var count int64
+	db.Model(&models.Expense{}).Where("account_id = ?", account.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 expense, got %d", count)
+	}
+
+	var updated models.RecurringTransaction
+	db.First(&updated, rt.ID)
+	if !updated.NextRunDate.After(today) {
+		t.Errorf("NextRunDate = %v, want after %v", updated.NextRunDate, today)
+	}
+}
+
+func TestDueDateSchedulerService_CheckUpcomingDueDates_Idempotent(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+
+	db.Create(&models.Expense{
+		AccountID: account.ID,
+		Name:      "Internet Bill",
+		Amount:    150,
+		Type:      models.ExpenseTypeFixed,
+		DueDay:    time.Now().AddDate(0, 0, 3).Day(),
+		Active:    true,
+	})
+
+	// Running the check twice (e.g. after a restart) sends a single notification
+	scheduler := NewDueDateSchedulerService()
+	for i := 0; i < 2; i++ {
+		if err := scheduler.CheckUpcomingDueDates(); err != nil {
+			t.Fatalf("CheckUpcomingDueDates() error = %v", err)
+		}
+	}
+
+	var count int64
+	db.Model(&models.Notification{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 notification, got %d", count)
+	}
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+var (
+	ErrNetWorthItemNotFound = errors.New("item de patrimônio não encontrado")
+	ErrInvalidNetWorthItem  = errors.New("item de patrimônio inválido (informe nome e uma categoria de bem ou dívida)")
+	ErrInvalidValuation     = errors.New("avaliação inválida (o valor não pode ser negativo)")
+)
+
+const (
+	// DefaultNetWorthMonths is the length of the net worth history shown by default
+	DefaultNetWorthMonths = 12
+	// MaxNetWorthMonths caps the net worth history
+	MaxNetWorthMonths = 60
+)
+
+// NetWorthItemValue is an item with the valuation in effect on the snapshot date
+type NetWorthItemValue struct {
+	Item      *models.NetWorthItem      `json:"item"`
+	Valuation *models.NetWorthValuation `json:"valuation"` // nil when the item had no value yet
+}
+
+// NetWorthSnapshot is the net worth on a date: manual assets minus manual liabilities and
+// the card installments still to be paid
+type NetWorthSnapshot struct {
+	Date                  time.Time                           `json:"date"`
+	Assets                float64                             `json:"assets"`
+	Liabilities           float64                             `json:"liabilities"`   // Manual liabilities plus remaining installments
+	LiquidAssets          float64                             `json:"liquid_assets"` // Cash and investments
+	RemainingInstallments float64                             `json:"remaining_installments"`
+	NetWorth              float64                             `json:"net_worth"`
+	ByCategory            map[models.NetWorthCategory]float64 `json:"by_category"`
+	Items                 []NetWorthItemValue                 `json:"items"`
+}
+
+// NetWorthPoint is the net worth at the end of a month of the history
+type NetWorthPoint struct {
+	Year        int     `json:"year"`
+	Month       int     `json:"month"`
+	Assets      float64 `json:"assets"`
+	Liabilities float64 `json:"liabilities"`
+	NetWorth    float64 `json:"net_worth"`
+}
+
+type NetWorthService struct {
+	groupService *GroupService
+}
+
+func NewNetWorthService() *NetWorthService {
+	return &NetWorthService{
+		groupService: NewGroupService(),
+	}
+}
+
+// CreateItem registers an asset or liability for a user or group with its current value
+func (s *NetWorthService) CreateItem(userID uint, groupID *uint, name string, category models.NetWorthCategory, value float64, date time.Time) (*models.NetWorthItem, error) {
+	kind, ok := category.Kind()
+	if name == "" || !ok {
+		return nil, ErrInvalidNetWorthItem
+	}
+	if value < 0 {
+		return nil, ErrInvalidValuation
+	}
+
+	// If group item, verify user is group member
+	if groupID != nil && !s.groupService.IsGroupMember(*groupID, userID) {
+		return nil, ErrUnauthorized
+	}
+
+	item := &models.NetWorthItem{
+		UserID:   userID,
+		GroupID:  groupID,
+		Name:     name,
+		Kind:     kind,
+		Category: category,
+		Valuations: []models.NetWorthValuation{
+			{Date: dateOnly(date), Value: value},
+		},
+	}
+	if err := database.DB.Create(item).Error; err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// GetItems returns the assets and liabilities of a user, or of a group when groupID is set,
+// with all their valuations (most recent first)
+func (s *NetWorthService) GetItems(userID uint, groupID *uint) ([]models.NetWorthItem, error) {
+	if groupID != nil && !s.groupService.IsGroupMember(*groupID, userID) {
+		return nil, ErrUnauthorized
+	}
+	return ownerNetWorthItems(userID, groupID)
+}
+
+// ownerNetWorthItems returns the items of a user or group, without authorization checks
+func ownerNetWorthItems(userID uint, groupID *uint) ([]models.NetWorthItem, error) {
+	query := database.DB.Preload("Valuations", func(db *gorm.DB) *gorm.DB {
+		return db.Order("date DESC")
+	}).Order("kind, name")
+	if groupID != nil {
+		query = query.Where("group_id = ?", *groupID)
+	} else {
+		query = query.Where("user_id = ? AND group_id IS NULL", userID)
+	}
+
+	var items []models.NetWorthItem
+	err := query.Find(&items).Error
+	return items, err
+}
+
+// GetItemByID retrieves an item by ID with authorization check
+func (s *NetWorthService) GetItemByID(itemID, userID uint) (*models.NetWorthItem, error) {
+	var item models.NetWorthItem
+	if err := database.DB.Preload("Valuations", func(db *gorm.DB) *gorm.DB {
+		return db.Order("date DESC")
+	}).First(&item, itemID).Error; err != nil {
+		return nil, ErrNetWorthItemNotFound
+	}
+
+	if item.GroupID == nil && item.UserID != userID ||
+		item.GroupID != nil && !s.groupService.IsGroupMember(*item.GroupID, userID) {
+		return nil, ErrUnauthorized
+	}
+	return &item, nil
+}
+
+// AddValuation records the value of an item on a date. Any group member may update the
+// value of a group item.
+func (s *NetWorthService) AddValuation(itemID, userID uint, value float64, date time.Time) (*models.NetWorthValuation, error) {
+	if value < 0 {
+		return nil, ErrInvalidValuation
+	}
+	item, err := s.GetItemByID(itemID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	valuation := &models.NetWorthValuation{
+		ItemID: item.ID,
+		Date:   dateOnly(date),
+		Value:  value,
+	}
+	if err := database.DB.Create(valuation).Error; err != nil {
+		return nil, err
+	}
+	return valuation, nil
+}
+
+// DeleteItem deletes an item and its valuations. Group items can be deleted by their
+// creator or a group admin.
+func (s *NetWorthService) DeleteItem(itemID, userID uint) error {
+	item, err := s.GetItemByID(itemID, userID)
+	if err != nil {
+		return err
+	}
+	if item.UserID != userID && (item.GroupID == nil || !s.groupService.IsGroupAdmin(*item.GroupID, userID)) {
+		return ErrUnauthorized
+	}
+
+	database.DB.Where("item_id = ?", item.ID).Delete(&models.NetWorthValuation{})
+	return database.DB.Delete(item).Error
+}
+
+// GetSnapshot returns the net worth of a user or group on a date, with the value of each item
+func (s *NetWorthService) GetSnapshot(userID uint, groupID *uint, date time.Time) (*NetWorthSnapshot, error) {
+	if groupID != nil && !s.groupService.IsGroupMember(*groupID, userID) {
+		return nil, ErrUnauthorized
+	}
+	return ownerNetWorthSnapshot(userID, groupID, date)
+}
+
+// ownerNetWorthSnapshot returns the net worth of a user or group on a date, without
+// authorization checks
+func ownerNetWorthSnapshot(userID uint, groupID *uint, date time.Time) (*NetWorthSnapshot, error) {
+	items, err := ownerNetWorthItems(userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	installments := ownerInstallments(userID, groupID)
+
+	snapshot := netWorthAt(items, installments, date)
+	for i := range items {
+		snapshot.Items = append(snapshot.Items, NetWorthItemValue{Item: &items[i], Valuation: items[i].ValuationAt(date)})
+	}
+	return snapshot, nil
+}
+
+// GetHistory returns the net worth at the end of each of the last months (the current
+// month is valued on now), oldest first
+func (s *NetWorthService) GetHistory(userID uint, groupID *uint, months int, now time.Time) ([]NetWorthPoint, error) {
+	if months <= 0 {
+		months = DefaultNetWorthMonths
+	}
+	if months > MaxNetWorthMonths {
+		months = MaxNetWorthMonths
+	}
+
+	items, err := s.GetItems(userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	installments := ownerInstallments(userID, groupID)
+
+	currentMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	history := make([]NetWorthPoint, 0, months)
+	for i := months - 1; i >= 0; i-- {
+		monthStart := currentMonth.AddDate(0, -i, 0)
+		date := monthStart.AddDate(0, 1, 0).Add(-time.Nanosecond)
+		if i == 0 {
+			date = now
+		}
+
+		snapshot := netWorthAt(items, installments, date)
+		history = append(history, NetWorthPoint{
+			Year:        monthStart.Year(),
+			Month:       int(monthStart.Month()),
+			Assets:      snapshot.Assets,
+			Liabilities: snapshot.Liabilities,
+			NetWorth:    snapshot.NetWorth,
+		})
+	}
+	return history, nil
+}
+
+// netWorthAt values the items and installments on a date
+func netWorthAt(items []models.NetWorthItem, installments []models.Installment, date time.Time) *NetWorthSnapshot {
+	snapshot := &NetWorthSnapshot{
+		Date:       date,
+		ByCategory: make(map[models.NetWorthCategory]float64),
+	}
+	for i := range items {
+		valuation := items[i].ValuationAt(date)
+		if valuation == nil {
+			continue
+		}
+		snapshot.ByCategory[items[i].Category] += valuation.Value
+		if items[i].Kind == models.NetWorthItemAsset {
+			snapshot.Assets += valuation.Value
+			if items[i].Category.IsLiquid() {
+				snapshot.LiquidAssets += valuation.Value
+			}
+		} else {
+			snapshot.Liabilities += valuation.Value
+		}
+	}
+
+	snapshot.RemainingInstallments = remainingInstallments(installments, date)
+	snapshot.Liabilities += snapshot.RemainingInstallments
+	snapshot.NetWorth = snapshot.Assets - snapshot.Liabilities
+	return snapshot
+}
+
+// remainingInstallments sums the card installments of the months after date, for purchases
+// whose first installment is on or before the month of date
+func remainingInstallments(installments []models.Installment, date time.Time) float64 {
+	current := date.Year()*12 + int(date.Month())
+	var total float64
+	for i := range installments {
+		months := installmentMonths(&installments[i])
+		if len(months) == 0 || months[0][0]*12+months[0][1] > current {
+			continue
+		}
+		for _, ym := range months {
+			if ym[0]*12+ym[1] > current {
+				total += installments[i].InstallmentAmount
+			}
+		}
+	}
+	return total
+}
+
+// installmentsDueIn sums the card installments that fall in the month of date
+func installmentsDueIn(installments []models.Installment, date time.Time) float64 {
+	var total float64
+	for i := range installments {
+		for _, ym := range installmentMonths(&installments[i]) {
+			if ym[0] == date.Year() && ym[1] == int(date.Month()) {
+				total += installments[i].InstallmentAmount
+				break
+			}
+		}
+	}
+	return total
+}
+
+// ownerInstallments returns the card installments of the accounts of a user or group
+func ownerInstallments(userID uint, groupID *uint) []models.Installment {
+	return accountInstallments(ownerAccountIDs(database.DB, userID, groupID))
+}
+
+// accountInstallments returns the card installments of the cards of the accounts
+func accountInstallments(accountIDs []uint) []models.Installment {
+	var installments []models.Installment
+	if len(accountIDs) == 0 {
+		return installments
+	}
+	database.DB.Joins("JOIN credit_cards ON credit_cards.id = installments.credit_card_id").
+		Where("credit_cards.account_id IN ?", accountIDs).
+		Find(&installments)
+	return installments
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestNetWorthService_GetHistory(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 10, 0, 0, 0, time.Local)
+	}
+	now := date(2030, 5, 15)
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Pessoal", models.AccountTypeIndividual, user.ID, nil)
+	group := testutil.CreateTestGroup(db, "Família", user.ID)
+	testutil.CreateTestGroupMember(db, group.ID, user.ID, "admin")
+
+	service := NewNetWorthService()
+
+	investment, err := service.CreateItem(user.ID, nil, "Tesouro", models.NetWorthCategoryInvestment, 10000, date(2030, 1, 10))
+	if err != nil {
+		t.Fatalf("CreateItem() error = %v", err)
+	}
+	if investment.Kind != models.NetWorthItemAsset {
+		t.Errorf("kind = %s, want %s", investment.Kind, models.NetWorthItemAsset)
+	}
+	if _, err := service.AddValuation(investment.ID, user.ID, 12000, date(2030, 3, 5)); err != nil {
+		t.Fatalf("AddValuation() error = %v", err)
+	}
+	if _, err := service.CreateItem(user.ID, nil, "Financiamento do carro", models.NetWorthCategoryFinancing, 5000, date(2030, 2, 1)); err != nil {
+		t.Fatalf("CreateItem() error = %v", err)
+	}
+	// Group items are not part of the personal net worth
+	if _, err := service.CreateItem(user.ID, &group.ID, "Casa", models.NetWorthCategoryRealEstate, 300000, date(2030, 1, 1)); err != nil {
+		t.Fatalf("CreateItem() error = %v", err)
+	}
+
+	// Three installments of 100 from April
+	card := models.CreditCard{AccountID: account.ID, Name: "Cartão", ClosingDay: 1, DueDay: 10}
+	db.Create(&card)
+	db.Create(&models.Installment{
+		CreditCardID: card.ID, Description: "Geladeira", TotalAmount: 300, InstallmentAmount: 100,
+		TotalInstallments: 3, CurrentInstallment: 1, StartDate: date(2030, 4, 10),
+	})
+
+	history, err := service.GetHistory(user.ID, nil, 5, now)
+	if err != nil {
+		t.Fatalf("GetHistory() error = %v", err)
+	}
+
+	tests := []struct {
+		month       time.Month
+		assets      float64
+		liabilities float64
+		netWorth    float64
+	}{
+		{time.January, 10000, 0, 10000},
+		{time.February, 10000, 5000, 5000},
+		{time.March, 12000, 5000, 7000},
+		{time.April, 12000, 5200, 6800},
+		{time.May, 12000, 5100, 6900},
+	}
+	if len(history) != len(tests) {
+		t.Fatalf("expected %d months, got %d", len(tests), len(history))
+	}
+	for i, tt := range tests {
+		got := history[i]
+		if got.Year != 2030 || got.Month != int(tt.month) {
+			t.Errorf("point %d = %02d/%d, want %02d/2030", i, got.Month, got.Year, tt.month)
+		}
+		if math.Abs(got.Assets-tt.assets) > 0.01 || math.Abs(got.Liabilities-tt.liabilities) > 0.01 ||
+			math.Abs(got.NetWorth-tt.netWorth) > 0.01 {
+			t.Errorf("%s = %.2f - %.2f = %.2f, want %.2f - %.2f = %.2f", tt.month,
+				got.Assets, got.Liabilities, got.NetWorth, tt.assets, tt.liabilities, tt.netWorth)
+		}
+	}
+
+	groupSnapshot, err := service.GetSnapshot(user.ID, &group.ID, now)
+	if err != nil {
+		t.Fatalf("GetSnapshot() error = %v", err)
+	}
+	if groupSnapshot.NetWorth != 300000 || len(groupSnapshot.Items) != 1 {
+		t.Errorf("group net worth = %.2f with %d items, want 300000.00 with 1 item", groupSnapshot.NetWorth, len(groupSnapshot.Items))
+	}
+}
+
+func TestNetWorthService_Authorization(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	other := testutil.CreateTestUser(db, "other@example.com", "Other User", "hash")
+	group := testutil.CreateTestGroup(db, "Família", user.ID)
+	testutil.CreateTestGroupMember(db, group.ID, user.ID, "admin")
+
+	service := NewNetWorthService()
+
+	if _, err := service.CreateItem(user.ID, nil, "Carro", "boat", 1000, time.Now()); err != ErrInvalidNetWorthItem {
+		t.Errorf("CreateItem() with invalid category error = %v, want %v", err, ErrInvalidNetWorthItem)
+	}
+	if _, err := service.CreateItem(other.ID, &group.ID, "Casa", models.NetWorthCategoryRealEstate, 1000, time.Now()); err != ErrUnauthorized {
+		t.Errorf("CreateItem() by non-member error = %v, want %v", err, ErrUnauthorized)
+	}
+
+	item, err := service.CreateItem(user.ID, nil, "FGTS", models.NetWorthCategoryFGTS, 8000, time.Now())
+	if err != nil {
+		t.Fatalf("CreateItem() error = %v", err)
+	}
+	if _, err := service.AddValuation(item.ID, other.ID, 9000, time.Now()); err != ErrUnauthorized {
+		t.Errorf("AddValuation() by another user error = %v, want %v", err, ErrUnauthorized)
+	}
+	if _, err := service.AddValuation(item.ID, user.ID, -1, time.Now()); err != ErrInvalidValuation {
+		t.Errorf("AddValuation() with negative value error = %v, want %v", err, ErrInvalidValuation)
+	}
+	if err := service.DeleteItem(item.ID, other.ID); err != ErrUnauthorized {
+		t.Errorf("DeleteItem() by another user error = %v, want %v", err, ErrUnauthorized)
+	}
+
+	if err := service.DeleteItem(item.ID, user.ID); err != nil {
+		t.Fatalf("DeleteItem() error = %v", err)
+	}
+	var count int64
+	db.Model(&models.NetWorthValuation{}).Where("item_id = ?", item.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("expected valuations to be deleted, got %d", count)
+	}
+}
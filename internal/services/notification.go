@@ -2,36 +2,44 @@ package services
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"poc-finance/internal/database"
 	"poc-finance/internal/models"
 )
 
-type NotificationService struct{}
+type NotificationService struct {
+	channels       map[models.NotificationChannel]NotificationChannelSender
+	webhookService *WebhookService
+}
 
 func NewNotificationService() *NotificationService {
-	return &NotificationService{}
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	webhookService := NewWebhookService()
+	return &NotificationService{
+		channels: map[models.NotificationChannel]NotificationChannelSender{
+			models.NotificationChannelInApp:   &inAppChannel{},
+			models.NotificationChannelEmail:   &emailChannel{emailService: NewEmailService(), baseURL: baseURL},
+			models.NotificationChannelWebhook: &webhookChannel{webhookService: webhookService},
+		},
+		webhookService: webhookService,
+	}
 }
 
-// Create creates a new notification for a user
+// Create delivers a notification to a user through the channels they chose for its type.
+// Email and webhook deliveries are queued for the delivery worker, or for the daily digest
+// when the user asked for it, and wait for quiet hours to end.
 func (s *NotificationService) Create(notification *models.Notification) error {
-	return database.DB.Create(notification).Error
+	return s.dispatch(notification, time.Now())
 }
 
 // GetUserNotifications retrieves all notifications for a user
 func (s *NotificationService) GetUserNotifications(userID uint, limit int) ([]models.Notification, error) {
-	var notifications []models.Notification
-	query := database.DB.Where("user_id = ?", userID).
-		Preload("Group").
-		Order("created_at DESC")
-
-	if limit > 0 {
-		query = query.Limit(limit)
-	}
-
-	err := query.Find(&notifications).Error
-	return notifications, err
+	return s.FindNotifications(userID, NotificationFilter{}, limit)
 }
 
 // GetUnreadNotifications retrieves only unread notifications for a user
@@ -56,29 +64,41 @@ func (s *NotificationService) GetUnreadCount(userID uint) (int64, error) {
 // MarkAsRead marks a single notification as read
 func (s *NotificationService) MarkAsRead(notificationID, userID uint) error {
 	now := time.Now()
-	return database.DB.Model(&models.Notification{}).
+	err := database.DB.Model(&models.Notification{}).
 		Where("id = ? AND user_id = ?", notificationID, userID).
 		Updates(map[string]interface{}{
 			"read":    true,
 			"read_at": now,
 		}).Error
+	return s.publishRead(userID, err)
 }
 
 // MarkAllAsRead marks all notifications as read for a user
 func (s *NotificationService) MarkAllAsRead(userID uint) error {
 	now := time.Now()
-	return database.DB.Model(&models.Notification{}).
+	err := database.DB.Model(&models.Notification{}).
 		Where("user_id = ? AND read = ?", userID, false).
 		Updates(map[string]interface{}{
 			"read":    true,
 			"read_at": now,
 		}).Error
+	return s.publishRead(userID, err)
 }
 
 // DeleteNotification deletes a notification
 func (s *NotificationService) DeleteNotification(notificationID, userID uint) error {
-	return database.DB.Where("id = ? AND user_id = ?", notificationID, userID).
+	err := database.DB.Where("id = ? AND user_id = ?", notificationID, userID).
 		Delete(&models.Notification{}).Error
+	return s.publishRead(userID, err)
+}
+
+// publishRead tells the user's other open pages to refresh their badge after a
+// successful change
+func (s *NotificationService) publishRead(userID uint, err error) error {
+	if err == nil {
+		GetNotificationBroker().Publish(NotificationEvent{UserID: userID, Kind: NotificationEventRead})
+	}
+	return err
 }
 
 // NotifyGroupInvite creates a notification when a user is added to a group
@@ -161,7 +181,9 @@ type GoalProgress struct {
 	Percentage    float64
 }
 
-// NotifyWeeklySummary creates a weekly summary notification for all group members
+// NotifyWeeklySummary creates a weekly summary notification for all group members. Summaries
+// are stored in-app only, bypassing the per-type preferences: the summary preference of the
+// group decides whether they are sent at all, and whether they go by email too.
 func (s *NotificationService) NotifyWeeklySummary(summaryData GroupSummaryData, groupMembers []models.User) error {
 	balanceSign := ""
 	if summaryData.Balance >= 0 {
@@ -184,14 +206,15 @@ func (s *NotificationService) NotifyWeeklySummary(summaryData GroupSummaryData,
 			Link:    fmt.Sprintf("/groups/%d/dashboard", summaryData.GroupID),
 			GroupID: &summaryData.GroupID,
 		}
-		if err := s.Create(notification); err != nil {
+		if err := s.createInApp(notification); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// NotifyMonthlySummary creates a monthly summary notification for all group members
+// NotifyMonthlySummary creates a monthly summary notification for all group members, in-app
+// only like the weekly one
 func (s *NotificationService) NotifyMonthlySummary(summaryData GroupSummaryData, groupMembers []models.User) error {
 	balanceSign := ""
 	if summaryData.Balance >= 0 {
@@ -215,13 +238,41 @@ func (s *NotificationService) NotifyMonthlySummary(summaryData GroupSummaryData,
 			Link:    fmt.Sprintf("/groups/%d/dashboard", summaryData.GroupID),
 			GroupID: &summaryData.GroupID,
 		}
-		if err := s.Create(notification); err != nil {
+		if err := s.createInApp(notification); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// NotifyPersonalSummary creates the periodic summary notification of a user's own accounts,
+// in-app only like the group ones
+func (s *NotificationService) NotifyPersonalSummary(summaryData GroupSummaryData, userID uint) error {
+	balanceSign := ""
+	if summaryData.Balance >= 0 {
+		balanceSign = "+"
+	}
+
+	title := "Resumo semanal pessoal"
+	if summaryData.PeriodType == "monthly" {
+		title = "Resumo mensal pessoal"
+	}
+
+	return s.createInApp(&models.Notification{
+		UserID: userID,
+		Type:   models.NotificationTypeSummary,
+		Title:  title,
+		Message: fmt.Sprintf("%s: Receitas R$ %.2f | Despesas R$ %.2f | Saldo %sR$ %.2f",
+			summaryData.PeriodLabel,
+			summaryData.TotalIncome,
+			summaryData.TotalExpenses,
+			balanceSign,
+			summaryData.Balance,
+		),
+		Link: "/",
+	})
+}
+
 // BudgetAlertData holds data for budget limit alert notifications
 type BudgetAlertData struct {
 	Account       *models.Account
@@ -320,3 +371,67 @@ func (s *NotificationService) NotifyBudgetThreshold(budget *models.Budget, categ
 	}
 	return nil
 }
+
+// NotifyUnusualCharge creates notifications explaining why a charge looked unusual
+func (s *NotificationService) NotifyUnusualCharge(anomaly Anomaly, account *models.Account, members []models.User, link string) error {
+	var title string
+	switch anomaly.Kind {
+	case AnomalyKindDuplicate:
+		title = "Possível cobrança duplicada"
+	case AnomalyKindNewSubscription:
+		title = "Possível nova assinatura"
+	default:
+		title = "Gasto incomum"
+	}
+
+	message := fmt.Sprintf("\"%s\" (R$ %.2f) na conta \"%s\": %s",
+		anomaly.Charge.Name,
+		anomaly.Charge.Amount,
+		account.Name,
+		anomaly.Reason,
+	)
+
+	for _, member := range members {
+		notification := &models.Notification{
+			UserID:  member.ID,
+			Type:    models.NotificationTypeUnusualCharge,
+			Title:   title,
+			Message: message,
+			Link:    link,
+			GroupID: account.GroupID,
+		}
+		if err := s.Create(notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotifySubscriptionCancelReminder reminds members to cancel a subscription before its next charge
+func (s *NotificationService) NotifySubscriptionCancelReminder(sub DetectedSubscription, members []models.User) error {
+	var when string
+	switch sub.DaysUntilCharge {
+	case 0:
+		when = "hoje"
+	case 1:
+		when = "amanhã"
+	default:
+		when = fmt.Sprintf("em %d dias", sub.DaysUntilCharge)
+	}
+	message := fmt.Sprintf("A assinatura \"%s\" (R$ %.2f) será cobrada %s. Cancele antes da cobrança para não renovar.",
+		sub.Name, sub.Amount, when)
+
+	for _, member := range members {
+		notification := &models.Notification{
+			UserID:  member.ID,
+			Type:    models.NotificationTypeSubscription,
+			Title:   "Lembrete de cancelamento",
+			Message: message,
+			Link:    "/subscriptions",
+		}
+		if err := s.Create(notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
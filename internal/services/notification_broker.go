@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+// Kinds of real-time notification events
+const (
+	NotificationEventCreated = "created" // A new in-app notification
+	NotificationEventRead    = "read"    // Notifications were read or deleted, so the badge changed
+)
+
+// NotificationEvent is pushed to the user's open pages when their notifications change
+type NotificationEvent struct {
+	UserID         uint                    `json:"user_id"`
+	Kind           string                  `json:"kind"`
+	NotificationID uint                    `json:"notification_id,omitempty"`
+	Type           models.NotificationType `json:"type,omitempty"`
+	Title          string                  `json:"title,omitempty"`
+}
+
+// NotificationBroker is the pub/sub that carries notification events to SSE streams
+type NotificationBroker interface {
+	Publish(event NotificationEvent)
+	// Subscribe returns the user's events and a function that ends the subscription
+	Subscribe(userID uint) (<-chan NotificationEvent, func())
+}
+
+// notificationSubscriberBuffer is how many events a slow stream can fall behind before
+// events are dropped. Events only trigger a refresh, so dropping some is harmless.
+const notificationSubscriberBuffer = 16
+
+// MemoryNotificationBroker delivers events to subscribers of this server instance
+type MemoryNotificationBroker struct {
+	mu          sync.RWMutex
+	subscribers map[uint]map[chan NotificationEvent]struct{}
+}
+
+func NewMemoryNotificationBroker() *MemoryNotificationBroker {
+	return &MemoryNotificationBroker{subscribers: make(map[uint]map[chan NotificationEvent]struct{})}
+}
+
+func (b *MemoryNotificationBroker) Publish(event NotificationEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subscribers[event.UserID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+func (b *MemoryNotificationBroker) Subscribe(userID uint) (<-chan NotificationEvent, func()) {
+	ch := make(chan NotificationEvent, notificationSubscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan NotificationEvent]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[userID], ch)
+			if len(b.subscribers[userID]) == 0 {
+				delete(b.subscribers, userID)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// postgresNotificationChannel is the LISTEN/NOTIFY channel shared by all instances
+const postgresNotificationChannel = "notification_events"
+
+// PostgresNotificationBroker relays events between server instances through Postgres
+// LISTEN/NOTIFY: every instance publishes with pg_notify and delivers what it hears to its
+// own subscribers. If the listener connection drops, it reconnects; publishing falls back
+// to local delivery while the database is unreachable.
+type PostgresNotificationBroker struct {
+	local       *MemoryNotificationBroker
+	databaseURL string
+}
+
+// NewPostgresNotificationBroker starts listening on databaseURL until ctx is done
+func NewPostgresNotificationBroker(ctx context.Context, databaseURL string) *PostgresNotificationBroker {
+	b := &PostgresNotificationBroker{local: NewMemoryNotificationBroker(), databaseURL: databaseURL}
+	go b.listen(ctx)
+	return b
+}
+
+func (b *PostgresNotificationBroker) Publish(event NotificationEvent) {
+	payload, err := json.Marshal(event)
+	if err == nil {
+		err = database.DB.Exec("SELECT pg_notify(?, ?)", postgresNotificationChannel, string(payload)).Error
+	}
+	if err != nil {
+		log.Printf("Error publishing notification event through Postgres, delivering locally: %v", err)
+		b.local.Publish(event)
+	}
+}
+
+func (b *PostgresNotificationBroker) Subscribe(userID uint) (<-chan NotificationEvent, func()) {
+	return b.local.Subscribe(userID)
+}
+
+func (b *PostgresNotificationBroker) listen(ctx context.Context) {
+	for ctx.Err() == nil {
+		if err := b.listenOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Notification event listener stopped, reconnecting: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}
+}
+
+func (b *PostgresNotificationBroker) listenOnce(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, b.databaseURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+postgresNotificationChannel); err != nil {
+		return err
+	}
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var event NotificationEvent
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			log.Printf("Ignoring malformed notification event: %v", err)
+			continue
+		}
+		b.local.Publish(event)
+	}
+}
+
+var (
+	notificationBrokerMu sync.RWMutex
+	notificationBroker   NotificationBroker = NewMemoryNotificationBroker()
+)
+
+// SetNotificationBroker replaces the broker, e.g. with the Postgres one when several
+// instances share a database
+func SetNotificationBroker(broker NotificationBroker) {
+	notificationBrokerMu.Lock()
+	defer notificationBrokerMu.Unlock()
+	notificationBroker = broker
+}
+
+// GetNotificationBroker returns the broker notification events go through
+func GetNotificationBroker() NotificationBroker {
+	notificationBrokerMu.RLock()
+	defer notificationBrokerMu.RUnlock()
+	return notificationBroker
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestMemoryNotificationBroker(t *testing.T) {
+	broker := NewMemoryNotificationBroker()
+	first, unsubscribeFirst := broker.Subscribe(1)
+	second, unsubscribeSecond := broker.Subscribe(1)
+	other, unsubscribeOther := broker.Subscribe(2)
+	defer unsubscribeSecond()
+	defer unsubscribeOther()
+
+	broker.Publish(NotificationEvent{UserID: 1, Kind: NotificationEventCreated, Title: "Nova despesa"})
+	for i, ch := range []<-chan NotificationEvent{first, second} {
+		select {
+		case event := <-ch:
+			if event.Title != "Nova despesa" {
+				t.Errorf("subscriber %d got %+v", i, event)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("subscriber %d got no event", i)
+		}
+	}
+	select {
+	case event := <-other:
+		t.Errorf("another user's subscriber got %+v", event)
+	default:
+	}
+
+	// Unsubscribing closes the channel and is safe to repeat
+	unsubscribeFirst()
+	unsubscribeFirst()
+	if _, ok := <-first; ok {
+		t.Error("channel still open after unsubscribing")
+	}
+
+	// A subscriber that never reads doesn't block publishers
+	for i := 0; i < notificationSubscriberBuffer*2; i++ {
+		broker.Publish(NotificationEvent{UserID: 2, Kind: NotificationEventRead})
+	}
+}
+
+func TestNotificationService_PublishesEvents(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	broker := NewMemoryNotificationBroker()
+	previous := GetNotificationBroker()
+	SetNotificationBroker(broker)
+	defer SetNotificationBroker(previous)
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Ana", "hash")
+	events, unsubscribe := broker.Subscribe(user.ID)
+	defer unsubscribe()
+	service := NewNotificationService()
+
+	receive := func() NotificationEvent {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("no event published")
+			return NotificationEvent{}
+		}
+	}
+
+	notification := &models.Notification{UserID: user.ID, Type: models.NotificationTypeDueDate, Title: "Aluguel", Message: "Vence amanhã"}
+	if err := service.Create(notification); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if event := receive(); event.Kind != NotificationEventCreated || event.NotificationID != notification.ID {
+		t.Errorf("event = %+v, want the created notification %d", event, notification.ID)
+	}
+
+	if err := service.MarkAsRead(notification.ID, user.ID); err != nil {
+		t.Fatalf("MarkAsRead() error = %v", err)
+	}
+	if event := receive(); event.Kind != NotificationEventRead {
+		t.Errorf("event = %+v, want a read event", event)
+	}
+
+	// Muted types aren't stored, so nothing is pushed
+	service.SavePreferences(user.ID, []models.NotificationPreference{{Type: models.NotificationTypeDueDate}})
+	service.Create(&models.Notification{UserID: user.ID, Type: models.NotificationTypeDueDate, Title: "Luz", Message: "Vence amanhã"})
+	select {
+	case event := <-events:
+		t.Errorf("muted notification published %+v", event)
+	default:
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"time"
+
+	"poc-finance/internal/models"
+)
+
+// NotificationRecipient is the user a channel delivers to, with their delivery settings
+type NotificationRecipient struct {
+	User     models.User
+	Settings models.NotificationSettings
+}
+
+// NotificationChannelSender delivers notifications through one channel. Several
+// notifications are delivered together, as a digest.
+type NotificationChannelSender interface {
+	Channel() models.NotificationChannel
+	Deliver(recipient NotificationRecipient, notifications []*models.Notification) error
+}
+
+// inAppChannel stores notifications in the notifications table, where the bell and the
+// notifications page read them. Similar notifications are collapsed into one row.
+type inAppChannel struct{}
+
+func (c *inAppChannel) Channel() models.NotificationChannel {
+	return models.NotificationChannelInApp
+}
+
+func (c *inAppChannel) Deliver(recipient NotificationRecipient, notifications []*models.Notification) error {
+	for _, notification := range notifications {
+		if err := createOrCollapse(notification, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emailChannel sends notifications through the EmailService
+type emailChannel struct {
+	emailService *EmailService
+	baseURL      string
+}
+
+func (c *emailChannel) Channel() models.NotificationChannel {
+	return models.NotificationChannelEmail
+}
+
+func (c *emailChannel) Deliver(recipient NotificationRecipient, notifications []*models.Notification) error {
+	if !c.emailService.IsConfigured() {
+		return nil
+	}
+	return c.emailService.SendNotificationEmail(recipient.User.Email, recipient.User.Name, notifications, c.baseURL)
+}
+
+// webhookChannel sends notifications to the user's webhooks subscribed to
+// notification.created, through the WebhookService that signs, retries and logs them
+type webhookChannel struct {
+	webhookService *WebhookService
+}
+
+// WebhookNotification is a notification as sent to user webhooks
+type WebhookNotification struct {
+	Type      models.NotificationType `json:"type"`
+	Title     string                  `json:"title"`
+	Message   string                  `json:"message"`
+	Link      string                  `json:"link,omitempty"`
+	CreatedAt time.Time               `json:"created_at"`
+}
+
+// WebhookNotificationData is the data of notification.created webhook events
+type WebhookNotificationData struct {
+	UserID        uint                  `json:"user_id"`
+	Digest        bool                  `json:"digest"`
+	Notifications []WebhookNotification `json:"notifications"`
+}
+
+func (c *webhookChannel) Channel() models.NotificationChannel {
+	return models.NotificationChannelWebhook
+}
+
+func (c *webhookChannel) Deliver(recipient NotificationRecipient, notifications []*models.Notification) error {
+	data := WebhookNotificationData{UserID: recipient.User.ID, Digest: len(notifications) > 1}
+	for _, notification := range notifications {
+		createdAt := notification.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
+		data.Notifications = append(data.Notifications, WebhookNotification{
+			Type:      notification.Type,
+			Title:     notification.Title,
+			Message:   notification.Message,
+			Link:      notification.Link,
+			CreatedAt: createdAt,
+		})
+	}
+	return c.webhookService.emit([]uint{recipient.User.ID}, models.WebhookEventNotification, data)
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+// DefaultNotificationRetentionDays is how long read notifications are kept when
+// NOTIFICATION_RETENTION_DAYS isn't set
+const DefaultNotificationRetentionDays = 30
+
+// maxCollapsedMessages caps the messages kept in a collapsed notification
+const maxCollapsedMessages = 20
+
+// collapsedTitles gives the title of a collapsed notification of each type. Types without
+// an entry are never collapsed: invites and summaries, and budget alerts, since each one
+// reports a higher threshold crossed.
+var collapsedTitles = map[models.NotificationType]string{
+	models.NotificationTypeDueDate:       "%d despesas próximas do vencimento",
+	models.NotificationTypeExpense:       "%d novos gastos do parceiro",
+	models.NotificationTypeUnusualCharge: "%d cobranças incomuns",
+	models.NotificationTypeSubscription:  "%d lembretes de cancelamento",
+	models.NotificationTypeGoalReached:   "%d metas atingidas",
+}
+
+// notificationCollapseKey identifies notifications similar enough to be merged: same
+// type, group and original title
+func notificationCollapseKey(notification *models.Notification) string {
+	if _, ok := collapsedTitles[notification.Type]; !ok || notification.InviteID != nil {
+		return ""
+	}
+	groupID := uint(0)
+	if notification.GroupID != nil {
+		groupID = *notification.GroupID
+	}
+	return fmt.Sprintf("%s:%d:%s", notification.Type, groupID, notification.Title)
+}
+
+// createOrCollapse stores an in-app notification, merging it into an unread similar one
+// from the same day when there is one. The notification gets the ID of the stored row.
+// Concurrent notifications of a user are merged one at a time: the user row is locked
+// (SQLite, which has no row locks, serializes writes instead) and the merge only applies
+// to the count it read, so none is lost.
+func createOrCollapse(notification *models.Notification, now time.Time) error {
+	notification.CollapseKey = notificationCollapseKey(notification)
+	if notification.CollapseKey == "" {
+		return database.DB.Create(notification).Error
+	}
+
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, notification.UserID).Error; err != nil {
+			return err
+		}
+
+		var existing models.Notification
+		err := tx.Where("user_id = ? AND collapse_key = ? AND read = ? AND created_at >= ?",
+			notification.UserID, notification.CollapseKey, false, startOfDay).
+			Order("id DESC").First(&existing).Error
+		if err == gorm.ErrRecordNotFound {
+			return tx.Create(notification).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		count := existing.Count + 1
+		messages := append(strings.Split(existing.Message, "\n"), notification.Message)
+		if len(messages) > maxCollapsedMessages {
+			messages = messages[len(messages)-maxCollapsedMessages:]
+		}
+		result := tx.Model(&models.Notification{}).
+			Where("id = ? AND count = ? AND read = ?", existing.ID, existing.Count, false).
+			Updates(map[string]interface{}{
+				"count":   gorm.Expr("count + 1"),
+				"title":   fmt.Sprintf(collapsedTitles[notification.Type], count),
+				"message": strings.Join(messages, "\n"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Read or merged in the meantime: keep this one on its own row
+			return tx.Create(notification).Error
+		}
+		notification.ID = existing.ID
+		return nil
+	})
+}
+
+// NotificationFilter narrows the notifications listed or changed in bulk
+type NotificationFilter struct {
+	Type       models.NotificationType
+	GroupID    *uint
+	UnreadOnly bool
+}
+
+func (f NotificationFilter) apply(query *gorm.DB, userID uint) *gorm.DB {
+	query = query.Where("user_id = ?", userID)
+	if f.Type != "" {
+		query = query.Where("type = ?", f.Type)
+	}
+	if f.GroupID != nil {
+		query = query.Where("group_id = ?", *f.GroupID)
+	}
+	if f.UnreadOnly {
+		query = query.Where("read = ?", false)
+	}
+	return query
+}
+
+// FindNotifications lists the user's notifications matching the filter, newest first
+func (s *NotificationService) FindNotifications(userID uint, filter NotificationFilter, limit int) ([]models.Notification, error) {
+	var notifications []models.Notification
+	query := filter.apply(database.DB, userID).
+		Preload("Group").
+		Order("created_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&notifications).Error
+	return notifications, err
+}
+
+// MarkAsReadByFilter marks every notification matching the filter as read
+func (s *NotificationService) MarkAsReadByFilter(userID uint, filter NotificationFilter) (int64, error) {
+	result := filter.apply(database.DB.Model(&models.Notification{}), userID).
+		Where("read = ?", false).
+		Updates(map[string]interface{}{
+			"read":    true,
+			"read_at": time.Now(),
+		})
+	return result.RowsAffected, s.publishRead(userID, result.Error)
+}
+
+// DeleteByFilter deletes every notification matching the filter
+func (s *NotificationService) DeleteByFilter(userID uint, filter NotificationFilter) (int64, error) {
+	result := filter.apply(database.DB, userID).Delete(&models.Notification{})
+	return result.RowsAffected, s.publishRead(userID, result.Error)
+}
+
+// NotificationRetentionDays reads NOTIFICATION_RETENTION_DAYS, falling back to the default
+func NotificationRetentionDays() int {
+	if days, err := strconv.Atoi(os.Getenv("NOTIFICATION_RETENTION_DAYS")); err == nil && days > 0 {
+		return days
+	}
+	return DefaultNotificationRetentionDays
+}
+
+// PurgeOldNotifications is the daily retention job: it permanently removes notifications
+// read (or deleted) more than NOTIFICATION_RETENTION_DAYS ago, and sent or failed deliveries
+func (s *NotificationService) PurgeOldNotifications() error {
+	_, err := s.purgeNotifications(time.Now().AddDate(0, 0, -NotificationRetentionDays()))
+	return err
+}
+
+func (s *NotificationService) purgeNotifications(cutoff time.Time) (int64, error) {
+	result := database.DB.Unscoped().
+		Where("(read = ? AND read_at < ?) OR deleted_at < ?", true, cutoff, cutoff).
+		Delete(&models.Notification{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge notifications: %w", result.Error)
+	}
+	if err := database.DB.Unscoped().Where("sent_at < ? OR failed_at < ?", cutoff, cutoff).Delete(&models.NotificationDelivery{}).Error; err != nil {
+		return result.RowsAffected, fmt.Errorf("failed to purge notification deliveries: %w", err)
+	}
+	return result.RowsAffected, nil
+}
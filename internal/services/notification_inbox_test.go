@@ -0,0 +1,159 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestNotificationService_CollapsesSimilarNotifications(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Ana", "hash")
+	service := NewNotificationService()
+	groupID := uint(7)
+	notify := func(notificationType models.NotificationType, title, message string, now time.Time) *models.Notification {
+		t.Helper()
+		notification := &models.Notification{UserID: user.ID, GroupID: &groupID, Type: notificationType, Title: title, Message: message}
+		if err := createOrCollapse(notification, now); err != nil {
+			t.Fatalf("createOrCollapse(%s) error = %v", message, err)
+		}
+		return notification
+	}
+	today := time.Now()
+
+	first := notify(models.NotificationTypeDueDate, "Despesa próxima do vencimento", "Aluguel vence em 3 dias", today)
+	second := notify(models.NotificationTypeDueDate, "Despesa próxima do vencimento", "Luz vence em 3 dias", today)
+	third := notify(models.NotificationTypeDueDate, "Despesa próxima do vencimento", "Internet vence em 3 dias", today)
+	if second.ID != first.ID || third.ID != first.ID {
+		t.Fatalf("similar notifications got IDs %d, %d and %d, want one row", first.ID, second.ID, third.ID)
+	}
+
+	var collapsed models.Notification
+	db.First(&collapsed, first.ID)
+	if collapsed.Count != 3 || collapsed.Title != "3 despesas próximas do vencimento" {
+		t.Errorf("collapsed notification = %d %q, want 3 and a collapsed title", collapsed.Count, collapsed.Title)
+	}
+	if !strings.Contains(collapsed.Message, "Aluguel") || !strings.Contains(collapsed.Message, "Internet") {
+		t.Errorf("collapsed message = %q, want every merged message", collapsed.Message)
+	}
+
+	// Other types, invites, read notifications and other days start a new row
+	if summary := notify(models.NotificationTypeSummary, "Resumo semanal", "R$ 100", today); summary.ID == first.ID {
+		t.Error("summaries shouldn't be collapsed")
+	}
+	if err := service.MarkAsRead(first.ID, user.ID); err != nil {
+		t.Fatalf("MarkAsRead() error = %v", err)
+	}
+	if after := notify(models.NotificationTypeDueDate, "Despesa próxima do vencimento", "Água vence em 3 dias", today); after.ID == first.ID {
+		t.Error("a read notification shouldn't absorb new ones")
+	}
+	if tomorrow := notify(models.NotificationTypeDueDate, "Despesa próxima do vencimento", "Gás vence em 3 dias", today.AddDate(0, 0, 1)); tomorrow.Count > 1 {
+		t.Error("notifications from previous days shouldn't absorb new ones")
+	}
+}
+
+func TestNotificationService_FilterAndBulk(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Ana", "hash")
+	other := testutil.CreateTestUser(db, "other@example.com", "Bia", "hash")
+	service := NewNotificationService()
+	groupID := uint(3)
+	for _, notification := range []models.Notification{
+		{UserID: user.ID, Type: models.NotificationTypeDueDate, Title: "A", GroupID: &groupID},
+		{UserID: user.ID, Type: models.NotificationTypeDueDate, Title: "B"},
+		{UserID: user.ID, Type: models.NotificationTypeExpense, Title: "C", GroupID: &groupID},
+		{UserID: other.ID, Type: models.NotificationTypeDueDate, Title: "D", GroupID: &groupID},
+	} {
+		db.Create(&notification)
+	}
+	count := func(filter NotificationFilter) int {
+		t.Helper()
+		notifications, err := service.FindNotifications(user.ID, filter, 0)
+		if err != nil {
+			t.Fatalf("FindNotifications() error = %v", err)
+		}
+		return len(notifications)
+	}
+
+	if got := count(NotificationFilter{Type: models.NotificationTypeDueDate}); got != 2 {
+		t.Errorf("due date notifications = %d, want 2", got)
+	}
+	if got := count(NotificationFilter{GroupID: &groupID}); got != 2 {
+		t.Errorf("group notifications = %d, want 2", got)
+	}
+
+	read, err := service.MarkAsReadByFilter(user.ID, NotificationFilter{Type: models.NotificationTypeDueDate})
+	if err != nil || read != 2 {
+		t.Fatalf("MarkAsReadByFilter() = %d, %v, want 2", read, err)
+	}
+	if got := count(NotificationFilter{UnreadOnly: true}); got != 1 {
+		t.Errorf("unread notifications = %d, want 1", got)
+	}
+
+	deleted, err := service.DeleteByFilter(user.ID, NotificationFilter{GroupID: &groupID})
+	if err != nil || deleted != 2 {
+		t.Fatalf("DeleteByFilter() = %d, %v, want 2", deleted, err)
+	}
+	if got := count(NotificationFilter{}); got != 1 {
+		t.Errorf("remaining notifications = %d, want 1", got)
+	}
+	var othersLeft int64
+	db.Model(&models.Notification{}).Where("user_id = ?", other.ID).Count(&othersLeft)
+	if othersLeft != 1 {
+		t.Error("bulk actions touched another user's notifications")
+	}
+}
+
+func TestNotificationService_PurgeNotifications(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Ana", "hash")
+	service := NewNotificationService()
+	now := time.Now()
+	old := now.AddDate(0, 0, -40)
+	recent := now.AddDate(0, 0, -5)
+
+	oldRead := models.Notification{UserID: user.ID, Type: models.NotificationTypeDueDate, Title: "Lida antiga", Read: true, ReadAt: &old}
+	recentRead := models.Notification{UserID: user.ID, Type: models.NotificationTypeDueDate, Title: "Lida recente", Read: true, ReadAt: &recent}
+	oldUnread := models.Notification{UserID: user.ID, Type: models.NotificationTypeDueDate, Title: "Não lida"}
+	oldUnread.CreatedAt = old
+	for _, notification := range []*models.Notification{&oldRead, &recentRead, &oldUnread} {
+		db.Create(notification)
+	}
+	deleted := models.Notification{UserID: user.ID, Type: models.NotificationTypeExpense, Title: "Excluída"}
+	db.Create(&deleted)
+	db.Delete(&deleted)
+	db.Unscoped().Model(&deleted).Update("deleted_at", old)
+
+	sentAt := old
+	db.Create(&models.NotificationDelivery{UserID: user.ID, Channel: models.NotificationChannelEmail, Title: "Enviada", SentAt: &sentAt})
+	db.Create(&models.NotificationDelivery{UserID: user.ID, Channel: models.NotificationChannelEmail, Title: "Pendente"})
+
+	t.Setenv("NOTIFICATION_RETENTION_DAYS", "30")
+	if NotificationRetentionDays() != 30 {
+		t.Fatalf("NotificationRetentionDays() = %d, want 30", NotificationRetentionDays())
+	}
+	if err := service.PurgeOldNotifications(); err != nil {
+		t.Fatalf("PurgeOldNotifications() error = %v", err)
+	}
+
+	var titles []string
+	db.Unscoped().Model(&models.Notification{}).Order("id").Pluck("title", &titles)
+	if strings.Join(titles, ",") != "Lida recente,Não lida" {
+		t.Errorf("remaining notifications = %v, want the recent read and the unread one", titles)
+	}
+	var deliveries []string
+	db.Model(&models.NotificationDelivery{}).Pluck("title", &deliveries)
+	if len(deliveries) != 1 || deliveries[0] != "Pendente" {
+		t.Errorf("remaining deliveries = %v, want only the pending one", deliveries)
+	}
+}
@@ -0,0 +1,330 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+var (
+	ErrInvalidNotificationSettings = errors.New("horários de notificação inválidos")
+	ErrDigestInQuietHours          = errors.New("o horário do resumo diário não pode estar dentro do horário silencioso")
+)
+
+// Defaults for users that never saved their notification settings
+const (
+	DefaultNotificationQuietStart = 22
+	DefaultNotificationQuietEnd   = 7
+	DefaultNotificationDigestHour = 8
+)
+
+// notificationDeliveryMaxAttempts caps the sends of a queued email or webhook that keeps failing
+const notificationDeliveryMaxAttempts = 5
+
+// NotificationTypeOption is a notification type users can configure, with its label
+type NotificationTypeOption struct {
+	Type  models.NotificationType
+	Label string
+}
+
+// NotificationTypeOptions lists the configurable notification types in display order.
+// Periodic summaries aren't listed: their channels are the summary preferences of each group.
+var NotificationTypeOptions = []NotificationTypeOption{
+	{models.NotificationTypeDueDate, "Vencimentos"},
+	{models.NotificationTypeBudgetAlert, "Alertas de orçamento"},
+	{models.NotificationTypeUnusualCharge, "Cobranças incomuns"},
+	{models.NotificationTypeSubscription, "Lembretes de cancelamento"},
+	{models.NotificationTypeExpense, "Despesas compartilhadas"},
+	{models.NotificationTypeGoalReached, "Metas atingidas"},
+	{models.NotificationTypeGroupInvite, "Convites de grupo"},
+}
+
+// NotificationPreferenceRow pairs a configurable type with the user's preference for it
+type NotificationPreferenceRow struct {
+	NotificationTypeOption
+	Preference models.NotificationPreference
+}
+
+// DefaultNotificationPreference delivers in-app only, as before preferences existed
+func DefaultNotificationPreference(userID uint, notificationType models.NotificationType) models.NotificationPreference {
+	return models.NotificationPreference{UserID: userID, Type: notificationType, InApp: true}
+}
+
+// GetPreference returns the user's channels for a notification type
+func (s *NotificationService) GetPreference(userID uint, notificationType models.NotificationType) models.NotificationPreference {
+	var preference models.NotificationPreference
+	if err := database.DB.Where("user_id = ? AND type = ?", userID, notificationType).First(&preference).Error; err != nil {
+		return DefaultNotificationPreference(userID, notificationType)
+	}
+	return preference
+}
+
+// GetPreferenceRows returns the user's preference for every configurable type
+func (s *NotificationService) GetPreferenceRows(userID uint) []NotificationPreferenceRow {
+	var saved []models.NotificationPreference
+	database.DB.Where("user_id = ?", userID).Find(&saved)
+	byType := make(map[models.NotificationType]models.NotificationPreference, len(saved))
+	for _, preference := range saved {
+		byType[preference.Type] = preference
+	}
+
+	rows := make([]NotificationPreferenceRow, len(NotificationTypeOptions))
+	for i, option := range NotificationTypeOptions {
+		preference, ok := byType[option.Type]
+		if !ok {
+			preference = DefaultNotificationPreference(userID, option.Type)
+		}
+		rows[i] = NotificationPreferenceRow{NotificationTypeOption: option, Preference: preference}
+	}
+	return rows
+}
+
+// SavePreferences replaces the user's channels for the given types
+func (s *NotificationService) SavePreferences(userID uint, preferences []models.NotificationPreference) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, preference := range preferences {
+			preference.Model = gorm.Model{}
+			preference.UserID = userID
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+				DoUpdates: clause.AssignmentColumns([]string{"in_app", "email", "webhook", "digest", "updated_at"}),
+			}).Create(&preference).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetSettings returns the user's quiet hours and digest time
+func (s *NotificationService) GetSettings(userID uint) models.NotificationSettings {
+	var settings models.NotificationSettings
+	if err := database.DB.Where("user_id = ?", userID).First(&settings).Error; err != nil {
+		return models.NotificationSettings{
+			UserID:     userID,
+			QuietStart: DefaultNotificationQuietStart,
+			QuietEnd:   DefaultNotificationQuietEnd,
+			DigestHour: DefaultNotificationDigestHour,
+		}
+	}
+	return settings
+}
+
+// SaveSettings validates and stores the user's delivery settings
+func (s *NotificationService) SaveSettings(userID uint, settings models.NotificationSettings) (*models.NotificationSettings, error) {
+	for _, hour := range []int{settings.QuietStart, settings.QuietEnd, settings.DigestHour} {
+		if hour < 0 || hour > 23 {
+			return nil, ErrInvalidNotificationSettings
+		}
+	}
+	// A digest hour inside quiet hours would hold the digest every day, so it never goes out
+	digestAt := time.Date(2000, 1, 1, settings.DigestHour, 0, 0, 0, time.Local)
+	if settings.InQuietHours(digestAt) {
+		return nil, ErrDigestInQuietHours
+	}
+
+	existing := s.GetSettings(userID)
+	existing.QuietHoursEnabled = settings.QuietHoursEnabled
+	existing.QuietStart = settings.QuietStart
+	existing.QuietEnd = settings.QuietEnd
+	existing.DigestHour = settings.DigestHour
+	if err := database.DB.Save(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+func (s *NotificationService) recipient(userID uint) (NotificationRecipient, error) {
+	recipient := NotificationRecipient{Settings: s.GetSettings(userID)}
+	err := database.DB.First(&recipient.User, userID).Error
+	return recipient, err
+}
+
+// dispatch routes a notification to the channels the user enabled for its type. Email and
+// webhook deliveries are queued and sent by the delivery worker or the daily digest, so
+// creating a notification never waits on the network.
+func (s *NotificationService) dispatch(notification *models.Notification, now time.Time) error {
+	preference := s.GetPreference(notification.UserID, notification.Type)
+	if preference.InApp {
+		if err := s.createInApp(notification); err != nil {
+			return err
+		}
+	}
+	if !preference.Email && !preference.Webhook {
+		return nil
+	}
+
+	for _, channel := range []models.NotificationChannel{models.NotificationChannelEmail, models.NotificationChannelWebhook} {
+		if !preference.Enabled(channel) ||
+			(channel == models.NotificationChannelWebhook && !s.webhookService.Subscribed(notification.UserID, models.WebhookEventNotification)) {
+			continue
+		}
+
+		delivery := models.NotificationDelivery{
+			UserID:  notification.UserID,
+			Channel: channel,
+			Type:    notification.Type,
+			Title:   notification.Title,
+			Message: notification.Message,
+			Link:    notification.Link,
+			Digest:  preference.Digest,
+		}
+		if err := database.DB.Create(&delivery).Error; err != nil {
+			log.Printf("Error queueing %s notification for user %d: %v", channel, notification.UserID, err)
+		}
+	}
+	return nil
+}
+
+// createInApp stores a notification in-app and pushes it to the user's open pages
+func (s *NotificationService) createInApp(notification *models.Notification) error {
+	if err := s.channels[models.NotificationChannelInApp].Deliver(NotificationRecipient{}, []*models.Notification{notification}); err != nil {
+		return err
+	}
+	GetNotificationBroker().Publish(NotificationEvent{
+		UserID:         notification.UserID,
+		Kind:           NotificationEventCreated,
+		NotificationID: notification.ID,
+		Type:           notification.Type,
+		Title:          notification.Title,
+	})
+	return nil
+}
+
+// SendQueuedDeliveries is run every minute by the delivery worker: it sends the queued
+// deliveries that don't wait for the digest, holding them while the user is in quiet hours
+func (s *NotificationService) SendQueuedDeliveries() error {
+	return s.sendDeliveries(time.Now(), false)
+}
+
+// SendDigests is the hourly job that sends the daily digests, once a day at each user's
+// digest hour
+func (s *NotificationService) SendDigests() error {
+	return s.sendDeliveries(time.Now(), true)
+}
+
+// sendDeliveries sends the pending digest or non-digest deliveries, batched per user and
+// channel. Each delivery is claimed before it goes out, so the worker of another server
+// instance never sends it twice; failed deliveries are retried up to
+// notificationDeliveryMaxAttempts times.
+func (s *NotificationService) sendDeliveries(now time.Time, digest bool) error {
+	var pending []models.NotificationDelivery
+	if err := database.DB.Where("sent_at IS NULL AND failed_at IS NULL AND digest = ?", digest).
+		Order("user_id, id").Find(&pending).Error; err != nil {
+		return fmt.Errorf("failed to fetch pending notification deliveries: %w", err)
+	}
+
+	type batchKey struct {
+		userID  uint
+		channel models.NotificationChannel
+	}
+	batches := make(map[batchKey][]models.NotificationDelivery)
+	var order []batchKey
+	for _, delivery := range pending {
+		key := batchKey{delivery.UserID, delivery.Channel}
+		if _, ok := batches[key]; !ok {
+			order = append(order, key)
+		}
+		batches[key] = append(batches[key], delivery)
+	}
+
+	var errs []error
+	recipients := make(map[uint]*NotificationRecipient)
+	for _, key := range order {
+		recipient, ok := recipients[key.userID]
+		if !ok {
+			loaded, err := s.recipient(key.userID)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("user %d: %w", key.userID, err))
+				continue
+			}
+			recipient = &loaded
+			recipients[key.userID] = recipient
+		}
+		if recipient.Settings.InQuietHours(now) {
+			continue
+		}
+
+		idempotencyKey := ""
+		if digest {
+			if now.Hour() < recipient.Settings.DigestHour {
+				continue
+			}
+			// One digest per user, channel and day
+			idempotencyKey = fmt.Sprintf("notification_digest:%d:%s:%s", key.userID, key.channel, now.Format("2006-01-02"))
+			claimed, err := ClaimIdempotencyKey(database.DB, JobNotificationDigest, idempotencyKey)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("user %d: %w", key.userID, err))
+				continue
+			}
+			if !claimed {
+				continue
+			}
+		}
+
+		var notifications []*models.Notification
+		var ids []uint
+		for _, delivery := range batches[key] {
+			claim := database.DB.Model(&models.NotificationDelivery{}).
+				Where("id = ? AND sent_at IS NULL", delivery.ID).
+				Update("sent_at", now)
+			if claim.Error != nil {
+				errs = append(errs, fmt.Errorf("notification delivery %d: %w", delivery.ID, claim.Error))
+				continue
+			}
+			if claim.RowsAffected == 0 {
+				continue
+			}
+			notifications = append(notifications, &models.Notification{
+				Model:   gorm.Model{CreatedAt: delivery.CreatedAt},
+				UserID:  delivery.UserID,
+				Type:    delivery.Type,
+				Title:   delivery.Title,
+				Message: delivery.Message,
+				Link:    delivery.Link,
+			})
+			ids = append(ids, delivery.ID)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		if err := s.channels[key.channel].Deliver(*recipient, notifications); err != nil {
+			log.Printf("Error sending %s notifications to user %d: %v", key.channel, key.userID, err)
+			errs = append(errs, fmt.Errorf("%s notifications of user %d: %w", key.channel, key.userID, err))
+			if err := s.releaseDeliveries(ids, now); err != nil {
+				errs = append(errs, err)
+			}
+			if idempotencyKey != "" {
+				if err := ReleaseIdempotencyKey(database.DB, idempotencyKey); err != nil {
+					errs = append(errs, fmt.Errorf("release key %s: %w", idempotencyKey, err))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// releaseDeliveries puts failed deliveries back in the queue, giving up on those that
+// reached their last attempt
+func (s *NotificationService) releaseDeliveries(ids []uint, now time.Time) error {
+	err := database.DB.Model(&models.NotificationDelivery{}).Where("id IN ?", ids).
+		Updates(map[string]interface{}{"sent_at": nil, "attempts": gorm.Expr("attempts + 1")}).Error
+	if err == nil {
+		err = database.DB.Model(&models.NotificationDelivery{}).
+			Where("id IN ? AND attempts >= ?", ids, notificationDeliveryMaxAttempts).
+			Update("failed_at", now).Error
+	}
+	if err != nil {
+		return fmt.Errorf("failed to release notification deliveries: %w", err)
+	}
+	return nil
+}
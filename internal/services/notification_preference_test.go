@@ -0,0 +1,276 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestNotificationSettings_InQuietHours(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2030, 1, 1, hour, 30, 0, 0, time.Local) }
+
+	overnight := models.NotificationSettings{QuietHoursEnabled: true, QuietStart: 22, QuietEnd: 7}
+	for hour, want := range map[int]bool{21: false, 22: true, 23: true, 3: true, 7: false, 12: false} {
+		if got := overnight.InQuietHours(at(hour)); got != want {
+			t.Errorf("22h-7h InQuietHours(%dh30) = %v, want %v", hour, got, want)
+		}
+	}
+
+	daytime := models.NotificationSettings{QuietHoursEnabled: true, QuietStart: 13, QuietEnd: 15}
+	if !daytime.InQuietHours(at(14)) || daytime.InQuietHours(at(15)) {
+		t.Error("13h-15h quiet hours should hold 14h30 and release 15h30")
+	}
+	if disabled := (models.NotificationSettings{QuietStart: 0, QuietEnd: 23}); disabled.InQuietHours(at(12)) {
+		t.Error("disabled quiet hours should never hold deliveries")
+	}
+}
+
+func TestNotificationService_SaveSettings_DigestInQuietHours(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Ana", "hash")
+	service := NewNotificationService()
+
+	// 22h is quiet, and so is every hour until 7h, when the digest hour already passed
+	_, err := service.SaveSettings(user.ID, models.NotificationSettings{QuietHoursEnabled: true, QuietStart: 22, QuietEnd: 7, DigestHour: 22})
+	if err != ErrDigestInQuietHours {
+		t.Errorf("SaveSettings() with the digest at 22h error = %v, want ErrDigestInQuietHours", err)
+	}
+	if _, err := service.SaveSettings(user.ID, models.NotificationSettings{QuietHoursEnabled: true, QuietStart: 22, QuietEnd: 7, DigestHour: 3}); err != ErrDigestInQuietHours {
+		t.Errorf("SaveSettings() with the digest at 3h error = %v, want ErrDigestInQuietHours", err)
+	}
+	if got := service.GetSettings(user.ID); got.DigestHour != DefaultNotificationDigestHour {
+		t.Errorf("DigestHour = %d, want the rejected settings left unsaved", got.DigestHour)
+	}
+
+	// The quiet window ends at 7h, so a 7h digest goes out, as does any hour with quiet hours off
+	if _, err := service.SaveSettings(user.ID, models.NotificationSettings{QuietHoursEnabled: true, QuietStart: 22, QuietEnd: 7, DigestHour: 7}); err != nil {
+		t.Errorf("SaveSettings() with the digest at 7h error = %v", err)
+	}
+	if _, err := service.SaveSettings(user.ID, models.NotificationSettings{QuietStart: 22, QuietEnd: 7, DigestHour: 23}); err != nil {
+		t.Errorf("SaveSettings() with quiet hours off error = %v", err)
+	}
+}
+
+// webhookRecorder collects the notifications of the notification.created events posted
+// to a test webhook
+type webhookRecorder struct {
+	mu       sync.Mutex
+	payloads []WebhookNotificationData
+}
+
+func (r *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var payload struct {
+		Event models.WebhookEvent     `json:"event"`
+		Data  WebhookNotificationData `json:"data"`
+	}
+	json.NewDecoder(req.Body).Decode(&payload)
+	if payload.Event != models.WebhookEventNotification {
+		return
+	}
+	r.mu.Lock()
+	r.payloads = append(r.payloads, payload.Data)
+	r.mu.Unlock()
+}
+
+func (r *webhookRecorder) received() []WebhookNotificationData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]WebhookNotificationData(nil), r.payloads...)
+}
+
+// failingEmailTransport rejects every email
+type failingEmailTransport struct{}
+
+func (failingEmailTransport) Send(from string, to []string, subject, html string) error {
+	return errors.New("smtp indisponível")
+}
+
+func TestNotificationService_Channels(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Ana", "hash")
+	recorder := &webhookRecorder{}
+	t.Setenv("WEBHOOK_ALLOW_INTERNAL", "true") // The test server listens on loopback
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	emailDir := t.TempDir()
+	service := NewNotificationService()
+	webhookService := newTestWebhookService()
+	service.webhookService = webhookService
+	service.channels[models.NotificationChannelWebhook] = &webhookChannel{webhookService: webhookService}
+	service.channels[models.NotificationChannelEmail] = &emailChannel{
+		emailService: &EmailService{from: "financas@example.com", transport: &fileEmailTransport{dir: emailDir}},
+		baseURL:      "http://finance.test",
+	}
+	emails := func() []string {
+		entries, _ := os.ReadDir(emailDir)
+		var contents []string
+		for _, entry := range entries {
+			content, _ := os.ReadFile(filepath.Join(emailDir, entry.Name()))
+			contents = append(contents, string(content))
+		}
+		return contents
+	}
+	inApp := func(notificationType models.NotificationType) int64 {
+		var count int64
+		db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", user.ID, notificationType).Count(&count)
+		return count
+	}
+	notify := func(notificationType models.NotificationType, title string, now time.Time) {
+		t.Helper()
+		err := service.dispatch(&models.Notification{UserID: user.ID, Type: notificationType, Title: title, Message: "Mensagem", Link: "/expenses"}, now)
+		if err != nil {
+			t.Fatalf("dispatch(%s) error = %v", title, err)
+		}
+	}
+
+	err := service.SavePreferences(user.ID, []models.NotificationPreference{
+		{Type: models.NotificationTypeDueDate, InApp: true, Email: true, Webhook: true},
+		{Type: models.NotificationTypeBudgetAlert}, // Muted
+		{Type: models.NotificationTypeUnusualCharge, Email: true, Webhook: true, Digest: true},
+	})
+	if err != nil {
+		t.Fatalf("SavePreferences() error = %v", err)
+	}
+	_, err = service.SaveSettings(user.ID, models.NotificationSettings{QuietHoursEnabled: true, QuietStart: 22, QuietEnd: 7, DigestHour: 8})
+	if err != nil {
+		t.Fatalf("SaveSettings() error = %v", err)
+	}
+	// Notifications reach the webhooks subscribed to them, signed and logged like any event
+	if _, err := webhookService.CreateWebhook(user.ID, server.URL, "", []models.WebhookEvent{models.WebhookEventNotification}); err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+
+	day := func(hour int) time.Time { return time.Date(2030, 1, 10, hour, 0, 0, 0, time.Local) }
+
+	t.Run("delivery on every enabled channel by the worker", func(t *testing.T) {
+		notify(models.NotificationTypeDueDate, "Aluguel vence amanhã", day(12))
+		if inApp(models.NotificationTypeDueDate) != 1 {
+			t.Error("due date notification wasn't stored in-app")
+		}
+		// Creating the notification only queues email and webhook deliveries
+		if len(emails()) != 0 || len(recorder.received()) != 0 {
+			t.Fatal("email and webhook were sent while creating the notification")
+		}
+
+		if err := service.sendDeliveries(day(12), false); err != nil {
+			t.Fatalf("sendDeliveries() error = %v", err)
+		}
+		service.sendDeliveries(day(12).Add(time.Minute), false)
+		if sent := emails(); len(sent) != 1 || !strings.Contains(sent[0], "http://finance.test/expenses") {
+			t.Errorf("emails = %v, want one with the notification link", sent)
+		}
+		if received := recorder.received(); len(received) != 1 || received[0].Notifications[0].Title != "Aluguel vence amanhã" {
+			t.Errorf("webhook payloads = %+v, want the due date notification", received)
+		}
+	})
+
+	t.Run("muted and default types", func(t *testing.T) {
+		notify(models.NotificationTypeBudgetAlert, "Orçamento estourado", day(12))
+		notify(models.NotificationTypeGoalReached, "Meta atingida", day(12))
+		if inApp(models.NotificationTypeBudgetAlert) != 0 {
+			t.Error("muted budget alert was stored in-app")
+		}
+		if inApp(models.NotificationTypeGoalReached) != 1 || len(emails()) != 1 {
+			t.Error("goal notification should default to in-app only")
+		}
+	})
+
+	t.Run("quiet hours hold email and webhook until they end", func(t *testing.T) {
+		notify(models.NotificationTypeDueDate, "Luz vence amanhã", day(23))
+		service.sendDeliveries(day(23), false)
+		if inApp(models.NotificationTypeDueDate) != 2 || len(emails()) != 1 || len(recorder.received()) != 1 {
+			t.Fatal("during quiet hours only the in-app notification should go out")
+		}
+
+		service.sendDeliveries(day(23).Add(time.Hour*3), false) // 2h, still quiet
+		if len(emails()) != 1 {
+			t.Fatal("held deliveries went out during quiet hours")
+		}
+		service.sendDeliveries(day(23).Add(time.Hour*8), false) // 7h next day
+		if len(emails()) != 2 || len(recorder.received()) != 2 {
+			t.Errorf("got %d emails and %d webhooks, want the held ones after quiet hours", len(emails()), len(recorder.received()))
+		}
+	})
+
+	t.Run("daily digest batches and goes out once", func(t *testing.T) {
+		next := day(12).AddDate(0, 0, 1)
+		notify(models.NotificationTypeUnusualCharge, "Cobrança duplicada", next)
+		notify(models.NotificationTypeUnusualCharge, "Nova assinatura", next)
+		if inApp(models.NotificationTypeUnusualCharge) != 0 || len(emails()) != 2 {
+			t.Fatal("digest notifications should be held, and not stored in-app")
+		}
+
+		service.sendDeliveries(next, false)
+		service.sendDeliveries(next.AddDate(0, 0, 1).Add(-5*time.Hour), true) // 7h: quiet hours
+		if len(emails()) != 2 {
+			t.Fatal("digest went out during quiet hours")
+		}
+		service.sendDeliveries(next.AddDate(0, 0, 1).Add(-4*time.Hour), true) // 8h: digest hour
+		service.sendDeliveries(next.AddDate(0, 0, 1).Add(-3*time.Hour), true)
+
+		sent := emails()
+		digests := 0
+		for _, email := range sent {
+			if strings.Contains(email, "Cobrança duplicada") && strings.Contains(email, "Nova assinatura") {
+				digests++
+			}
+		}
+		if len(sent) != 3 || digests != 1 {
+			t.Errorf("got %d emails with %d digests, want a single digest with both notifications", len(sent), digests)
+		}
+		received := recorder.received()
+		if last := received[len(received)-1]; len(received) != 3 || !last.Digest || len(last.Notifications) != 2 {
+			t.Errorf("webhook payloads = %+v, want a digest with two notifications", received)
+		}
+	})
+}
+
+func TestNotificationService_SendDeliveries_RetriesFailures(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Ana", "hash")
+	service := NewNotificationService()
+	service.channels[models.NotificationChannelEmail] = &emailChannel{
+		emailService: &EmailService{from: "financas@example.com", transport: failingEmailTransport{}},
+	}
+	if err := service.SavePreferences(user.ID, []models.NotificationPreference{{Type: models.NotificationTypeDueDate, Email: true}}); err != nil {
+		t.Fatalf("SavePreferences() error = %v", err)
+	}
+	if err := service.dispatch(&models.Notification{UserID: user.ID, Type: models.NotificationTypeDueDate, Title: "Aluguel"}, time.Now()); err != nil {
+		t.Fatalf("dispatch() error = %v", err)
+	}
+
+	now := time.Date(2030, 1, 10, 12, 0, 0, 0, time.Local)
+	for attempt := 1; attempt <= notificationDeliveryMaxAttempts+1; attempt++ {
+		err := service.sendDeliveries(now.Add(time.Duration(attempt)*time.Minute), false)
+		if attempt <= notificationDeliveryMaxAttempts && err == nil {
+			t.Fatalf("attempt %d: sendDeliveries() error = nil, want the email failure", attempt)
+		}
+		if attempt > notificationDeliveryMaxAttempts && err != nil {
+			t.Fatalf("sendDeliveries() after giving up error = %v", err)
+		}
+	}
+
+	var delivery models.NotificationDelivery
+	db.First(&delivery)
+	if delivery.Attempts != notificationDeliveryMaxAttempts || delivery.SentAt != nil || delivery.FailedAt == nil {
+		t.Errorf("delivery = %+v, want it failed after %d attempts", delivery, notificationDeliveryMaxAttempts)
+	}
+}
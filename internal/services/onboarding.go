@@ -145,6 +145,28 @@ func (s *OnboardingService) CreateDefaultBudget(userID uint, templateName string
 	return budget, nil
 }
 
+// HistoryTemplateName selects, in the onboarding wizard, a budget suggested from the
+// spending already registered or imported instead of a fixed template
+const HistoryTemplateName = "history"
+
+// GetHistorySuggestion returns the budget suggested from the user's spending history,
+// or nil when there is no categorized spending yet
+func (s *OnboardingService) GetHistorySuggestion(userID uint) *BudgetSuggestion {
+	suggestion, err := NewBudgetService().SuggestBudget(userID, nil, DefaultSuggestionMonths, DefaultSuggestionPercentile, time.Now())
+	if err != nil || len(suggestion.Categories) == 0 {
+		return nil
+	}
+	return suggestion
+}
+
+// CreateBudgetFromHistory creates the current month's budget with the limits suggested
+// from the user's spending history
+func (s *OnboardingService) CreateBudgetFromHistory(userID uint) (*models.Budget, error) {
+	now := time.Now()
+	return NewBudgetService().CreateBudgetFromSuggestion(userID, nil, now.Year(), int(now.Month()),
+		"Orçamento pelo histórico", DefaultSuggestionMonths, DefaultSuggestionPercentile)
+}
+
 // CompleteOnboarding marks the user's onboarding as completed
 func (s *OnboardingService) CompleteOnboarding(userID uint) error {
 	var user models.User
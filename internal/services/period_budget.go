@@ -0,0 +1,314 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+var (
+	ErrPeriodBudgetNotFound = errors.New("orçamento do período não encontrado")
+	ErrInvalidBudgetPeriod  = errors.New("período inválido (a data final deve ser posterior à inicial e o período de no máximo 5 anos)")
+)
+
+// maxBudgetPeriodYears caps custom periods so pro-rated views stay meaningful
+const maxBudgetPeriodYears = 5
+
+// paceTolerance is how many percentage points spending may run ahead of the elapsed
+// time before a period budget is flagged as ahead of pace
+const paceTolerance = 10.0
+
+// PaceStatus compares the spending of a period budget with the time elapsed
+type PaceStatus string
+
+const (
+	PaceOnTrack PaceStatus = "on_track" // Spending in line with the elapsed time
+	PaceAhead   PaceStatus = "ahead"    // Spending faster than time elapses
+	PaceOver    PaceStatus = "over"     // Limit exceeded
+)
+
+// PeriodCategoryProgress is the spending of a category over the whole period
+type PeriodCategoryProgress struct {
+	CategoryID      uint       `json:"category_id"`
+	Category        string     `json:"category"`
+	Limit           float64    `json:"limit"`
+	Spent           float64    `json:"spent"`
+	SpentPercentage float64    `json:"spent_percentage"`
+	Pace            PaceStatus `json:"pace"`
+}
+
+// PeriodMonthView is the pro-rated slice of a period budget that falls in one month
+type PeriodMonthView struct {
+	Year          int     `json:"year"`
+	Month         int     `json:"month"`
+	Days          int     `json:"days"`           // Days of the period in this month
+	ProratedLimit float64 `json:"prorated_limit"` // Share of the total limit for those days
+	Spent         float64 `json:"spent"`
+}
+
+// PeriodBudgetProgress is a period budget with its spending, pace and monthly breakdown
+type PeriodBudgetProgress struct {
+	Budget            *models.PeriodBudget     `json:"budget"`
+	Limit             float64                  `json:"limit"`
+	Spent             float64                  `json:"spent"`
+	SpentPercentage   float64                  `json:"spent_percentage"`
+	ElapsedPercentage float64                  `json:"elapsed_percentage"`
+	Pace              PaceStatus               `json:"pace"`
+	Categories        []PeriodCategoryProgress `json:"categories"`
+	Months            []PeriodMonthView        `json:"months"`
+}
+
+type PeriodBudgetService struct {
+	groupService *GroupService
+}
+
+func NewPeriodBudgetService() *PeriodBudgetService {
+	return &PeriodBudgetService{
+		groupService: NewGroupService(),
+	}
+}
+
+// PeriodEndDate returns the last day (inclusive) of a period starting on start.
+// Quarters and years are derived from the start date; custom periods use customEnd.
+func PeriodEndDate(periodType models.BudgetPeriodType, start, customEnd time.Time) (time.Time, error) {
+	var end time.Time
+	switch periodType {
+	case models.BudgetPeriodQuarter:
+		end = start.AddDate(0, 3, -1)
+	case models.BudgetPeriodYear:
+		end = start.AddDate(1, 0, -1)
+	case models.BudgetPeriodCustom:
+		end = customEnd
+	default:
+		return time.Time{}, ErrInvalidBudgetPeriod
+	}
+
+	if end.Before(start) || end.After(start.AddDate(maxBudgetPeriodYears, 0, 0)) {
+		return time.Time{}, ErrInvalidBudgetPeriod
+	}
+	return end, nil
+}
+
+// CreatePeriodBudget creates a budget over a period for a user or group
+func (s *PeriodBudgetService) CreatePeriodBudget(userID uint, groupID *uint, name string, periodType models.BudgetPeriodType, start, customEnd time.Time) (*models.PeriodBudget, error) {
+	start = dateOnly(start)
+	end, err := PeriodEndDate(periodType, start, dateOnly(customEnd))
+	if err != nil {
+		return nil, err
+	}
+
+	// If group budget, verify user is group member
+	if groupID != nil && !s.groupService.IsGroupMember(*groupID, userID) {
+		return nil, ErrUnauthorized
+	}
+
+	budget := &models.PeriodBudget{
+		UserID:     userID,
+		GroupID:    groupID,
+		Name:       name,
+		PeriodType: periodType,
+		StartDate:  start,
+		EndDate:    end,
+		Status:     models.BudgetStatusActive,
+	}
+	if err := database.DB.Create(budget).Error; err != nil {
+		return nil, err
+	}
+	return budget, nil
+}
+
+// GetPeriodBudgets returns the period budgets of a user, or of a group when groupID is set
+func (s *PeriodBudgetService) GetPeriodBudgets(userID uint, groupID *uint) ([]models.PeriodBudget, error) {
+	query := database.DB.Preload("Categories").Order("start_date DESC")
+	if groupID != nil {
+		if !s.groupService.IsGroupMember(*groupID, userID) {
+			return nil, ErrUnauthorized
+		}
+		query = query.Where("group_id = ?", *groupID)
+	} else {
+		query = query.Where("user_id = ? AND group_id IS NULL", userID)
+	}
+
+	var budgets []models.PeriodBudget
+	err := query.Find(&budgets).Error
+	return budgets, err
+}
+
+// GetPeriodBudgetByID retrieves a period budget by ID with authorization check
+func (s *PeriodBudgetService) GetPeriodBudgetByID(budgetID, userID uint) (*models.PeriodBudget, error) {
+	var budget models.PeriodBudget
+	if err := database.DB.Preload("Group").Preload("Categories").First(&budget, budgetID).Error; err != nil {
+		return nil, ErrPeriodBudgetNotFound
+	}
+
+	if budget.GroupID == nil && budget.UserID != userID ||
+		budget.GroupID != nil && !s.groupService.IsGroupMember(*budget.GroupID, userID) {
+		return nil, ErrUnauthorized
+	}
+	return &budget, nil
+}
+
+// DeletePeriodBudget deletes a period budget and its categories
+func (s *PeriodBudgetService) DeletePeriodBudget(budgetID, userID uint) error {
+	budget, err := s.getModifiable(budgetID, userID)
+	if err != nil {
+		return err
+	}
+
+	database.DB.Where("period_budget_id = ?", budget.ID).Delete(&models.PeriodBudgetCategory{})
+	return database.DB.Delete(budget).Error
+}
+
+// AddCategory adds a category with its limit for the whole period
+func (s *PeriodBudgetService) AddCategory(budgetID, userID uint, categoryName string, limit float64) (*models.PeriodBudgetCategory, error) {
+	budget, err := s.getModifiable(budgetID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	category := &models.PeriodBudgetCategory{
+		PeriodBudgetID: budget.ID,
+		Category:       categoryName,
+		Limit:          limit,
+	}
+	if err := database.DB.Create(category).Error; err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// DeleteCategory removes a category from a period budget
+func (s *PeriodBudgetService) DeleteCategory(budgetID, categoryID, userID uint) error {
+	budget, err := s.getModifiable(budgetID, userID)
+	if err != nil {
+		return err
+	}
+
+	result := database.DB.Where("id = ? AND period_budget_id = ?", categoryID, budget.ID).
+		Delete(&models.PeriodBudgetCategory{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+// GetProgress calculates the spending of a period budget up to now, how it compares with
+// the elapsed time and the pro-rated view of each month of the period
+func (s *PeriodBudgetService) GetProgress(budget *models.PeriodBudget, now time.Time) *PeriodBudgetProgress {
+	accountIDs := ownerAccountIDs(database.DB, budget.UserID, budget.GroupID)
+	periodStart := budget.StartDate.In(time.Local)
+	periodEnd := budget.EndDate.In(time.Local).AddDate(0, 0, 1)
+
+	progress := &PeriodBudgetProgress{
+		Budget:            budget,
+		Limit:             budget.TotalLimit(),
+		ElapsedPercentage: elapsedPercentage(budget, now),
+	}
+
+	spending := GetCategorySpendingBetween(database.DB, accountIDs, periodStart, periodEnd)
+	for _, category := range budget.Categories {
+		spent := categoryTotal(spending, category.Category)
+		percentage := percentageOf(spent, category.Limit)
+		progress.Categories = append(progress.Categories, PeriodCategoryProgress{
+			CategoryID:      category.ID,
+			Category:        category.Category,
+			Limit:           category.Limit,
+			Spent:           spent,
+			SpentPercentage: percentage,
+			Pace:            paceStatus(percentage, progress.ElapsedPercentage),
+		})
+		progress.Spent += spent
+	}
+	progress.SpentPercentage = percentageOf(progress.Spent, progress.Limit)
+	progress.Pace = paceStatus(progress.SpentPercentage, progress.ElapsedPercentage)
+
+	// Pro-rate the limit by the days of the period that fall in each month
+	days := float64(budget.Days())
+	for from := periodStart; from.Before(periodEnd); {
+		to := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location()).AddDate(0, 1, 0)
+		if to.After(periodEnd) {
+			to = periodEnd
+		}
+
+		monthDays := daysBetween(from, to)
+		view := PeriodMonthView{
+			Year:          from.Year(),
+			Month:         int(from.Month()),
+			Days:          monthDays,
+			ProratedLimit: progress.Limit * float64(monthDays) / days,
+		}
+		monthSpending := GetCategorySpendingBetween(database.DB, accountIDs, from, to)
+		for _, category := range budget.Categories {
+			view.Spent += categoryTotal(monthSpending, category.Category)
+		}
+		progress.Months = append(progress.Months, view)
+
+		from = to
+	}
+
+	return progress
+}
+
+// getModifiable loads a period budget the user may change: personal budgets by their
+// owner, group budgets by their creator or a group admin
+func (s *PeriodBudgetService) getModifiable(budgetID, userID uint) (*models.PeriodBudget, error) {
+	budget, err := s.GetPeriodBudgetByID(budgetID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if budget.UserID != userID && (budget.GroupID == nil || !s.groupService.IsGroupAdmin(*budget.GroupID, userID)) {
+		return nil, ErrUnauthorized
+	}
+	return budget, nil
+}
+
+// elapsedPercentage returns how much of the period has passed, counting today
+func elapsedPercentage(budget *models.PeriodBudget, now time.Time) float64 {
+	today := dateOnly(now)
+	if today.Before(budget.StartDate) {
+		return 0
+	}
+	if today.After(budget.EndDate) {
+		return 100
+	}
+	return float64(daysBetween(budget.StartDate, today)+1) / float64(budget.Days()) * 100
+}
+
+// paceStatus compares the spent and elapsed percentages of a period
+func paceStatus(spentPercentage, elapsedPercentage float64) PaceStatus {
+	switch {
+	case spentPercentage > 100:
+		return PaceOver
+	case spentPercentage > elapsedPercentage+paceTolerance:
+		return PaceAhead
+	default:
+		return PaceOnTrack
+	}
+}
+
+// percentageOf returns value as a percentage of total (0 when total is zero)
+func percentageOf(value, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return value / total * 100
+}
+
+// daysBetween returns the number of whole days in [from, to)
+func daysBetween(from, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
+
+// dateOnly truncates a time to midnight in the local time zone
+func dateOnly(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
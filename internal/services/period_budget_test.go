@@ -0,0 +1,179 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestPeriodEndDate(t *testing.T) {
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name       string
+		periodType models.BudgetPeriodType
+		customEnd  time.Time
+		want       time.Time
+		wantErr    bool
+	}{
+		{"quarter", models.BudgetPeriodQuarter, time.Time{}, time.Date(2030, 3, 31, 0, 0, 0, 0, time.Local), false},
+		{"year", models.BudgetPeriodYear, time.Time{}, time.Date(2030, 12, 31, 0, 0, 0, 0, time.Local), false},
+		{"custom", models.BudgetPeriodCustom, time.Date(2030, 6, 15, 0, 0, 0, 0, time.Local), time.Date(2030, 6, 15, 0, 0, 0, 0, time.Local), false},
+		{"custom ending before start", models.BudgetPeriodCustom, time.Date(2029, 12, 31, 0, 0, 0, 0, time.Local), time.Time{}, true},
+		{"custom longer than limit", models.BudgetPeriodCustom, time.Date(2036, 1, 1, 0, 0, 0, 0, time.Local), time.Time{}, true},
+		{"unknown type", models.BudgetPeriodType("week"), time.Time{}, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PeriodEndDate(tt.periodType, start, tt.customEnd)
+			if tt.wantErr {
+				if err != ErrInvalidBudgetPeriod {
+					t.Errorf("expected ErrInvalidBudgetPeriod, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("end = %s, want %s", got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestPeriodBudgetService_GetProgress(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+
+	service := NewPeriodBudgetService()
+	budget, err := service.CreatePeriodBudget(user.ID, nil, "Despesas do trimestre", models.BudgetPeriodQuarter,
+		time.Date(2030, 1, 1, 0, 0, 0, 0, time.Local), time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create period budget: %v", err)
+	}
+	if _, err := service.AddCategory(budget.ID, user.ID, "Impostos", 900); err != nil {
+		t.Fatalf("failed to add category: %v", err)
+	}
+	if _, err := service.AddCategory(budget.ID, user.ID, "Educação", 300); err != nil {
+		t.Fatalf("failed to add category: %v", err)
+	}
+
+	variable := func(name, category string, amount float64, createdAt time.Time) {
+		db.Create(&models.Expense{Model: gorm.Model{CreatedAt: createdAt}, AccountID: account.ID, Name: name, Amount: amount, Type: models.ExpenseTypeVariable, Category: category, Active: true})
+	}
+	variable("IPVA", "Impostos", 600, time.Date(2030, 1, 20, 12, 0, 0, 0, time.Local))
+	variable("IPTU", "Impostos", 150, time.Date(2030, 2, 10, 12, 0, 0, 0, time.Local))
+	variable("Fora do período", "Impostos", 999, time.Date(2030, 4, 1, 12, 0, 0, 0, time.Local))
+
+	// School material in 3 parcels from January: 50 per month in the quarter
+	card := &models.CreditCard{AccountID: account.ID, Name: "Cartão", ClosingDay: 1, DueDay: 10}
+	db.Create(card)
+	db.Create(&models.Installment{CreditCardID: card.ID, Description: "Material escolar", TotalAmount: 150, InstallmentAmount: 50, TotalInstallments: 3, StartDate: time.Date(2030, 1, 10, 0, 0, 0, 0, time.Local), Category: "Educação"})
+
+	budget, err = service.GetPeriodBudgetByID(budget.ID, user.ID)
+	if err != nil {
+		t.Fatalf("failed to load period budget: %v", err)
+	}
+
+	// February 14th: 45 of the 90 days elapsed, 900 of 1200 spent
+	progress := service.GetProgress(budget, time.Date(2030, 2, 14, 18, 0, 0, 0, time.Local))
+
+	if math.Abs(progress.Limit-1200) > 0.01 {
+		t.Errorf("Limit = %.2f, want 1200.00", progress.Limit)
+	}
+	if math.Abs(progress.Spent-900) > 0.01 {
+		t.Errorf("Spent = %.2f, want 900.00", progress.Spent)
+	}
+	if math.Abs(progress.ElapsedPercentage-50) > 0.01 {
+		t.Errorf("ElapsedPercentage = %.2f, want 50.00", progress.ElapsedPercentage)
+	}
+	if progress.Pace != PaceAhead {
+		t.Errorf("Pace = %s, want %s", progress.Pace, PaceAhead)
+	}
+
+	wantCategories := map[string]struct {
+		spent float64
+		pace  PaceStatus
+	}{
+		"Impostos": {750, PaceAhead},
+		"Educação": {150, PaceOnTrack},
+	}
+	for _, category := range progress.Categories {
+		want := wantCategories[category.Category]
+		if math.Abs(category.Spent-want.spent) > 0.01 {
+			t.Errorf("%s spent = %.2f, want %.2f", category.Category, category.Spent, want.spent)
+		}
+		if category.Pace != want.pace {
+			t.Errorf("%s pace = %s, want %s", category.Category, category.Pace, want.pace)
+		}
+	}
+
+	wantMonths := []PeriodMonthView{
+		{Year: 2030, Month: 1, Days: 31, ProratedLimit: 1200 * 31.0 / 90, Spent: 650},
+		{Year: 2030, Month: 2, Days: 28, ProratedLimit: 1200 * 28.0 / 90, Spent: 200},
+		{Year: 2030, Month: 3, Days: 31, ProratedLimit: 1200 * 31.0 / 90, Spent: 50},
+	}
+	if len(progress.Months) != len(wantMonths) {
+		t.Fatalf("expected %d months, got %d", len(wantMonths), len(progress.Months))
+	}
+	for i, want := range wantMonths {
+		got := progress.Months[i]
+		if got.Year != want.Year || got.Month != want.Month || got.Days != want.Days {
+			t.Errorf("month %d = %d/%d (%d days), want %d/%d (%d days)", i, got.Month, got.Year, got.Days, want.Month, want.Year, want.Days)
+		}
+		if math.Abs(got.ProratedLimit-want.ProratedLimit) > 0.01 {
+			t.Errorf("month %d prorated limit = %.2f, want %.2f", i, got.ProratedLimit, want.ProratedLimit)
+		}
+		if math.Abs(got.Spent-want.Spent) > 0.01 {
+			t.Errorf("month %d spent = %.2f, want %.2f", i, got.Spent, want.Spent)
+		}
+	}
+}
+
+func TestPeriodBudgetService_Authorization(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	owner := testutil.CreateTestUser(db, "owner@example.com", "Owner", "hash")
+	member := testutil.CreateTestUser(db, "member@example.com", "Member", "hash")
+	outsider := testutil.CreateTestUser(db, "outsider@example.com", "Outsider", "hash")
+	group := testutil.CreateTestGroup(db, "Família", owner.ID)
+	testutil.CreateTestGroupMember(db, group.ID, owner.ID, "admin")
+	testutil.CreateTestGroupMember(db, group.ID, member.ID, "member")
+
+	service := NewPeriodBudgetService()
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.Local)
+
+	if _, err := service.CreatePeriodBudget(outsider.ID, &group.ID, "Anual", models.BudgetPeriodYear, start, time.Time{}); err != ErrUnauthorized {
+		t.Errorf("outsider create: expected ErrUnauthorized, got %v", err)
+	}
+
+	budget, err := service.CreatePeriodBudget(owner.ID, &group.ID, "Anual", models.BudgetPeriodYear, start, time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create group period budget: %v", err)
+	}
+
+	if _, err := service.GetPeriodBudgetByID(budget.ID, member.ID); err != nil {
+		t.Errorf("member should see the group budget, got %v", err)
+	}
+	if _, err := service.GetPeriodBudgetByID(budget.ID, outsider.ID); err != ErrUnauthorized {
+		t.Errorf("outsider view: expected ErrUnauthorized, got %v", err)
+	}
+	if _, err := service.AddCategory(budget.ID, member.ID, "Impostos", 100); err != ErrUnauthorized {
+		t.Errorf("member add category: expected ErrUnauthorized, got %v", err)
+	}
+	if err := service.DeletePeriodBudget(budget.ID, owner.ID); err != nil {
+		t.Errorf("owner delete: unexpected error %v", err)
+	}
+}
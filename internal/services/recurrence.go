@@ -0,0 +1,263 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"poc-finance/internal/models"
+)
+
+var ErrInvalidSchedule = errors.New("agendamento inválido")
+
+// maxCatchUpOccurrences limits how many missed occurrences a single run generates
+// for one recurring transaction, so a very old daily schedule cannot flood the database.
+const maxCatchUpOccurrences = 400
+
+// ValidateSchedule checks the schedule options of a recurring transaction and fills defaults
+func ValidateSchedule(rt *models.RecurringTransaction) error {
+	if rt.Interval == 0 {
+		rt.Interval = 1
+	}
+	if rt.ScheduleRule == "" {
+		rt.ScheduleRule = models.ScheduleRuleDayOfMonth
+	}
+	if rt.BusinessDayAdjustment == "" {
+		rt.BusinessDayAdjustment = models.BusinessDayNone
+	}
+
+	if rt.Interval < 1 || rt.Interval > 365 {
+		return ErrInvalidSchedule
+	}
+	if rt.DayOfMonth < 0 || rt.DayOfMonth > 31 {
+		return ErrInvalidSchedule
+	}
+
+	switch rt.ScheduleRule {
+	case models.ScheduleRuleDayOfMonth, models.ScheduleRuleLastBusinessDay:
+	case models.ScheduleRuleNthWeekday:
+		if rt.WeekOfMonth < -1 || rt.WeekOfMonth > 4 || rt.WeekOfMonth == 0 || rt.Weekday < 0 || rt.Weekday > 6 {
+			return ErrInvalidSchedule
+		}
+	default:
+		return ErrInvalidSchedule
+	}
+
+	switch rt.BusinessDayAdjustment {
+	case models.BusinessDayNone, models.BusinessDayFollowing, models.BusinessDayPreceding:
+	default:
+		return ErrInvalidSchedule
+	}
+
+	return nil
+}
+
+// FirstOccurrence returns the first scheduled date of a recurring transaction,
+// on or after its StartDate.
+func FirstOccurrence(rt *models.RecurringTransaction) time.Time {
+	return NextOccurrence(rt, rt.StartDate.Add(-time.Nanosecond))
+}
+
+// NextOccurrence returns the first scheduled date strictly after the given date.
+// Occurrences are always derived from StartDate (occurrence k = StartDate + k*Interval units)
+// so month-end clamping and business-day adjustments never accumulate drift.
+func NextOccurrence(rt *models.RecurringTransaction, after time.Time) time.Time {
+	interval := rt.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	// Estimate the occurrence index close to "after" and walk forward from there.
+	// Business-day adjustments move a date by a few days at most, so a small margin is enough.
+	k := estimateOccurrenceIndex(rt, after, interval) - 7
+	if k < 0 {
+		k = 0
+	}
+
+	for {
+		date := occurrenceDate(rt, k, interval)
+		if date.After(after) {
+			return date
+		}
+		k++
+	}
+}
+
+// OccurrencesBetween expands a recurring transaction into its scheduled dates in [from, to]
+func OccurrencesBetween(rt *models.RecurringTransaction, from, to time.Time) []time.Time {
+	var dates []time.Time
+	date := NextOccurrence(rt, from.Add(-time.Nanosecond))
+	for !date.After(to) {
+		if rt.EndDate != nil && date.After(*rt.EndDate) {
+			break
+		}
+		dates = append(dates, date)
+		date = NextOccurrence(rt, date)
+	}
+	return dates
+}
+
+// estimateOccurrenceIndex returns roughly how many occurrences fit between StartDate and date
+func estimateOccurrenceIndex(rt *models.RecurringTransaction, date time.Time, interval int) int {
+	if !date.After(rt.StartDate) {
+		return 0
+	}
+
+	days := int(date.Sub(rt.StartDate).Hours() / 24)
+	switch rt.Frequency {
+	case models.FrequencyDaily:
+		return days / interval
+	case models.FrequencyWeekly:
+		return days / (7 * interval)
+	case models.FrequencyYearly:
+		return (date.Year() - rt.StartDate.Year()) / interval
+	default:
+		months := (date.Year()-rt.StartDate.Year())*12 + int(date.Month()) - int(rt.StartDate.Month())
+		return months / interval
+	}
+}
+
+// occurrenceDate computes the k-th occurrence (0-based) of the schedule
+func occurrenceDate(rt *models.RecurringTransaction, k, interval int) time.Time {
+	start := rt.StartDate
+
+	var date time.Time
+	switch rt.Frequency {
+	case models.FrequencyDaily:
+		date = start.AddDate(0, 0, k*interval)
+	case models.FrequencyWeekly:
+		date = start.AddDate(0, 0, 7*k*interval)
+	case models.FrequencyYearly:
+		date = monthlyDate(rt, start.Year()+k*interval, start.Month())
+	default:
+		// Normalize year/month without letting the day overflow into the next month
+		monthIndex := int(start.Month()) - 1 + k*interval
+		date = monthlyDate(rt, start.Year()+monthIndex/12, time.Month(monthIndex%12+1))
+	}
+
+	// Last business day is already a business day
+	if rt.ScheduleRule == models.ScheduleRuleLastBusinessDay &&
+		(rt.Frequency == models.FrequencyMonthly || rt.Frequency == models.FrequencyYearly) {
+		return date
+	}
+
+	return adjustToBusinessDay(date, rt.BusinessDayAdjustment)
+}
+
+// monthlyDate picks the day within the given month according to the schedule rule
+func monthlyDate(rt *models.RecurringTransaction, year int, month time.Month) time.Time {
+	start := rt.StartDate
+	clock := func(day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+	}
+
+	switch rt.ScheduleRule {
+	case models.ScheduleRuleLastBusinessDay:
+		return adjustToBusinessDay(clock(daysInMonth(year, month)), models.BusinessDayPreceding)
+	case models.ScheduleRuleNthWeekday:
+		return clock(nthWeekdayOfMonth(year, month, time.Weekday(rt.Weekday), rt.WeekOfMonth))
+	default:
+		day := rt.DayOfMonth
+		if day <= 0 {
+			day = start.Day()
+		}
+		if last := daysInMonth(year, month); day > last {
+			day = last
+		}
+		return clock(day)
+	}
+}
+
+// nthWeekdayOfMonth returns the day of the n-th given weekday in a month (n = -1 for the last one)
+func nthWeekdayOfMonth(year int, month time.Month, weekday time.Weekday, n int) int {
+	if n < 0 {
+		last := daysInMonth(year, month)
+		lastWeekday := time.Date(year, month, last, 0, 0, 0, 0, time.UTC).Weekday()
+		return last - (int(lastWeekday)-int(weekday)+7)%7
+	}
+	if n == 0 {
+		n = 1
+	}
+
+	firstWeekday := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Weekday()
+	day := 1 + (int(weekday)-int(firstWeekday)+7)%7 + (n-1)*7
+	if last := daysInMonth(year, month); day > last {
+		day -= 7
+	}
+	return day
+}
+
+// daysInMonth returns the number of days in the given month
+func daysInMonth(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// adjustToBusinessDay moves a date off weekends and holidays in the given direction
+func adjustToBusinessDay(date time.Time, adjustment models.BusinessDayAdjustment) time.Time {
+	step := 0
+	switch adjustment {
+	case models.BusinessDayFollowing:
+		step = 1
+	case models.BusinessDayPreceding:
+		step = -1
+	default:
+		return date
+	}
+
+	for !IsBusinessDay(date) {
+		date = date.AddDate(0, 0, step)
+	}
+	return date
+}
+
+// IsBusinessDay returns false for weekends and Brazilian national bank holidays
+func IsBusinessDay(date time.Time) bool {
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return false
+	}
+	return !isBrazilianHoliday(date)
+}
+
+// isBrazilianHoliday checks fixed national holidays and the Easter-based bank holidays
+// (Carnival Monday/Tuesday, Good Friday and Corpus Christi)
+func isBrazilianHoliday(date time.Time) bool {
+	switch {
+	case date.Month() == time.January && date.Day() == 1,
+		date.Month() == time.April && date.Day() == 21,
+		date.Month() == time.May && date.Day() == 1,
+		date.Month() == time.September && date.Day() == 7,
+		date.Month() == time.October && date.Day() == 12,
+		date.Month() == time.November && date.Day() == 2,
+		date.Month() == time.November && date.Day() == 15,
+		date.Month() == time.November && date.Day() == 20,
+		date.Month() == time.December && date.Day() == 25:
+		return true
+	}
+
+	easter := easterSunday(date.Year())
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	for _, offset := range []int{-48, -47, -2, 60} {
+		if day.Equal(easter.AddDate(0, 0, offset)) {
+			return true
+		}
+	}
+	return false
+}
+
+// easterSunday computes the date of Easter for a year (anonymous Gregorian algorithm)
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"poc-finance/internal/models"
+)
+
+func testDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestNextOccurrence(t *testing.T) {
+	tests := []struct {
+		name  string
+		rt    models.RecurringTransaction
+		after time.Time
+		want  time.Time
+	}{
+		{
+			name:  "monthly on the 31st clamps to February",
+			rt:    models.RecurringTransaction{Frequency: models.FrequencyMonthly, StartDate: testDate(2025, 1, 31)},
+			after: testDate(2025, 1, 31),
+			want:  testDate(2025, 2, 28),
+		},
+		{
+			name:  "monthly on the 31st returns to the 31st after February",
+			rt:    models.RecurringTransaction{Frequency: models.FrequencyMonthly, StartDate: testDate(2025, 1, 31)},
+			after: testDate(2025, 2, 28),
+			want:  testDate(2025, 3, 31),
+		},
+		{
+			name:  "leap year yearly schedule clamps to February 28",
+			rt:    models.RecurringTransaction{Frequency: models.FrequencyYearly, StartDate: testDate(2024, 2, 29)},
+			after: testDate(2024, 2, 29),
+			want:  testDate(2025, 2, 28),
+		},
+		{
+			name:  "every 2 weeks",
+			rt:    models.RecurringTransaction{Frequency: models.FrequencyWeekly, Interval: 2, StartDate: testDate(2025, 1, 6)},
+			after: testDate(2025, 1, 6),
+			want:  testDate(2025, 1, 20),
+		},
+		{
+			name:  "every 3 months",
+			rt:    models.RecurringTransaction{Frequency: models.FrequencyMonthly, Interval: 3, StartDate: testDate(2025, 1, 10)},
+			after: testDate(2025, 2, 1),
+			want:  testDate(2025, 4, 10),
+		},
+		{
+			name: "explicit day of month",
+			rt: models.RecurringTransaction{Frequency: models.FrequencyMonthly, StartDate: testDate(2025, 1, 3),
+				DayOfMonth: 15},
+			after: testDate(2025, 1, 15),
+			want:  testDate(2025, 2, 15),
+		},
+		{
+			name: "last business day of May 2025 (31st is Saturday)",
+			rt: models.RecurringTransaction{Frequency: models.FrequencyMonthly, StartDate: testDate(2025, 1, 1),
+				ScheduleRule: models.ScheduleRuleLastBusinessDay},
+			after: testDate(2025, 5, 1),
+			want:  testDate(2025, 5, 30),
+		},
+		{
+			name: "second Tuesday of the month",
+			rt: models.RecurringTransaction{Frequency: models.FrequencyMonthly, StartDate: testDate(2025, 1, 1),
+				ScheduleRule: models.ScheduleRuleNthWeekday, WeekOfMonth: 2, Weekday: int(time.Tuesday)},
+			after: testDate(2025, 2, 1),
+			want:  testDate(2025, 2, 11),
+		},
+		{
+			name: "last Friday of the month",
+			rt: models.RecurringTransaction{Frequency: models.FrequencyMonthly, StartDate: testDate(2025, 1, 1),
+				ScheduleRule: models.ScheduleRuleNthWeekday, WeekOfMonth: -1, Weekday: int(time.Friday)},
+			after: testDate(2025, 2, 1),
+			want:  testDate(2025, 2, 28),
+		},
+		{
+			name: "weekend moves to following business day",
+			rt: models.RecurringTransaction{Frequency: models.FrequencyMonthly, StartDate: testDate(2025, 1, 5),
+				BusinessDayAdjustment: models.BusinessDayFollowing},
+			after: testDate(2025, 1, 1),
+			want:  testDate(2025, 1, 6),
+		},
+		{
+			name: "holiday moves to preceding business day",
+			rt: models.RecurringTransaction{Frequency: models.FrequencyMonthly, StartDate: testDate(2025, 1, 21),
+				BusinessDayAdjustment: models.BusinessDayPreceding},
+			after: testDate(2025, 4, 1),
+			want:  testDate(2025, 4, 17), // Apr 21 is Tiradentes, Apr 18 is Good Friday
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NextOccurrence(&tt.rt, tt.after)
+			if !got.Equal(tt.want) {
+				t.Errorf("NextOccurrence() = %s, want %s", got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestOccurrencesBetween_RespectsEndDate(t *testing.T) {
+	end := testDate(2025, 3, 15)
+	rt := models.RecurringTransaction{Frequency: models.FrequencyMonthly, StartDate: testDate(2025, 1, 10), EndDate: &end}
+
+	dates := OccurrencesBetween(&rt, testDate(2025, 1, 1), testDate(2025, 12, 31))
+	if len(dates) != 3 {
+		t.Fatalf("OccurrencesBetween() returned %d dates, want 3", len(dates))
+	}
+	if !dates[2].Equal(testDate(2025, 3, 10)) {
+		t.Errorf("last occurrence = %s, want 2025-03-10", dates[2].Format("2006-01-02"))
+	}
+}
+
+func TestFirstOccurrence_KeepsStartTime(t *testing.T) {
+	// StartDate set from time.Now() carries sub-second precision
+	start := time.Date(2025, 1, 10, 14, 30, 15, 123456789, time.UTC)
+	rt := models.RecurringTransaction{Frequency: models.FrequencyMonthly, StartDate: start}
+
+	if got := FirstOccurrence(&rt); !got.Equal(start) {
+		t.Errorf("FirstOccurrence() = %v, want %v", got, start)
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	rt := models.RecurringTransaction{Frequency: models.FrequencyMonthly}
+	if err := ValidateSchedule(&rt); err != nil {
+		t.Fatalf("ValidateSchedule() error = %v", err)
+	}
+	if rt.Interval != 1 || rt.ScheduleRule != models.ScheduleRuleDayOfMonth || rt.BusinessDayAdjustment != models.BusinessDayNone {
+		t.Errorf("ValidateSchedule() did not fill defaults: %+v", rt)
+	}
+
+	invalid := []models.RecurringTransaction{
+		{Interval: -1},
+		{DayOfMonth: 32},
+		{ScheduleRule: "every_full_moon"},
+		{ScheduleRule: models.ScheduleRuleNthWeekday, WeekOfMonth: 0},
+		{BusinessDayAdjustment: "sideways"},
+	}
+	for _, rt := range invalid {
+		if err := ValidateSchedule(&rt); err != ErrInvalidSchedule {
+			t.Errorf("ValidateSchedule(%+v) error = %v, want %v", rt, err, ErrInvalidSchedule)
+		}
+	}
+}
+
+func TestIsBusinessDay(t *testing.T) {
+	tests := []struct {
+		day  time.Time
+		want bool
+	}{
+		{testDate(2025, 1, 1), false},  // Confraternização Universal
+		{testDate(2025, 3, 3), false},  // Carnival Monday
+		{testDate(2025, 4, 18), false}, // Good Friday
+		{testDate(2025, 6, 19), false}, // Corpus Christi
+		{testDate(2025, 6, 21), false}, // Saturday
+		{testDate(2025, 6, 23), true},  // Monday
+	}
+
+	for _, tt := range tests {
+		if got := IsBusinessDay(tt.day); got != tt.want {
+			t.Errorf("IsBusinessDay(%s) = %v, want %v", tt.day.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+var (
+	ErrOccurrenceNotFound      = errors.New("ocorrência não encontrada")
+	ErrInvalidOccurrenceDate   = errors.New("nova data deve ser anterior à próxima ocorrência")
+	ErrOccurrenceTooEarly      = errors.New("nova data não pode ser anterior à ocorrência anterior")
+	ErrInvalidOccurrenceAmount = errors.New("valor deve ser maior que zero")
+)
+
+// Occurrence is one scheduled instance of a recurring transaction with its override applied
+type Occurrence struct {
+	RecurringTransactionID uint                   `json:"recurring_transaction_id"`
+	Description            string                 `json:"description"`
+	TransactionType        models.TransactionType `json:"transaction_type"`
+	Category               string                 `json:"category"`
+	Currency               string                 `json:"currency"`
+	ScheduledDate          time.Time              `json:"scheduled_date"` // Originally scheduled date, identifies the occurrence
+	Date                   time.Time              `json:"date"`           // Effective date (moved by an override)
+	Amount                 float64                `json:"amount"`
+	Skipped                bool                   `json:"skipped"`
+	Overridden             bool                   `json:"overridden"`
+}
+
+// Key returns the identifier of the occurrence used in URLs (its scheduled day)
+func (o Occurrence) Key() string {
+	return occurrenceKey(o.ScheduledDate)
+}
+
+// occurrenceKey identifies an occurrence by its scheduled day
+func occurrenceKey(date time.Time) string {
+	return date.Format("2006-01-02")
+}
+
+// ResolveOccurrence applies the override of a scheduled occurrence, if any.
+// Overrides are keyed by occurrenceKey of their scheduled date.
+func ResolveOccurrence(rt *models.RecurringTransaction, scheduled time.Time, overrides map[string]models.RecurringOccurrenceOverride) Occurrence {
+	occurrence := Occurrence{
+		RecurringTransactionID: rt.ID,
+		Description:            rt.Description,
+		TransactionType:        rt.TransactionType,
+		Category:               rt.Category,
+		Currency:               rt.Currency,
+		ScheduledDate:          scheduled,
+		Date:                   scheduled,
+		Amount:                 rt.Amount,
+	}
+
+	override, ok := overrides[occurrenceKey(scheduled)]
+	if !ok {
+		return occurrence
+	}
+
+	occurrence.Overridden = true
+	occurrence.Skipped = override.Skip
+	if override.Amount != nil {
+		occurrence.Amount = *override.Amount
+	}
+	if override.Date != nil {
+		occurrence.Date = *override.Date
+	}
+	return occurrence
+}
+
+// loadOccurrenceOverrides returns the overrides of the given recurring transactions,
+// grouped by recurring transaction ID and keyed by scheduled day
+func loadOccurrenceOverrides(recurringTransactionIDs []uint) map[uint]map[string]models.RecurringOccurrenceOverride {
+	result := make(map[uint]map[string]models.RecurringOccurrenceOverride)
+	if len(recurringTransactionIDs) == 0 {
+		return result
+	}
+
+	var overrides []models.RecurringOccurrenceOverride
+	database.DB.Where("recurring_transaction_id IN ?", recurringTransactionIDs).Find(&overrides)
+
+	for _, override := range overrides {
+		if result[override.RecurringTransactionID] == nil {
+			result[override.RecurringTransactionID] = make(map[string]models.RecurringOccurrenceOverride)
+		}
+		result[override.RecurringTransactionID][occurrenceKey(override.OccurrenceDate)] = override
+	}
+	return result
+}
+
+type RecurringOccurrenceService struct{}
+
+func NewRecurringOccurrenceService() *RecurringOccurrenceService {
+	return &RecurringOccurrenceService{}
+}
+
+// PreviewOccurrences expands the active recurring transactions of the given accounts into
+// the occurrences still to be generated between from and to, ordered by effective date
+func (s *RecurringOccurrenceService) PreviewOccurrences(accountIDs []uint, from, to time.Time) ([]Occurrence, error) {
+	var recurringTransactions []models.RecurringTransaction
+	err := database.DB.Where("account_id IN ? AND active = ?", accountIDs, true).Find(&recurringTransactions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(recurringTransactions))
+	for i, rt := range recurringTransactions {
+		ids[i] = rt.ID
+	}
+	overrides := loadOccurrenceOverrides(ids)
+
+	var occurrences []Occurrence
+	for i := range recurringTransactions {
+		rt := &recurringTransactions[i]
+
+		// Occurrences before NextRunDate were already generated
+		start := from
+		if rt.NextRunDate.After(start) {
+			start = rt.NextRunDate
+		}
+
+		for _, scheduled := range OccurrencesBetween(rt, start, to) {
+			occurrences = append(occurrences, ResolveOccurrence(rt, scheduled, overrides[rt.ID]))
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].Date.Before(occurrences[j].Date)
+	})
+
+	return occurrences, nil
+}
+
+// SkipOccurrence marks a single pending occurrence as skipped
+func (s *RecurringOccurrenceService) SkipOccurrence(recurringTransactionID uint, accountIDs []uint, day string) error {
+	rt, scheduled, err := s.findPendingOccurrence(recurringTransactionID, accountIDs, day)
+	if err != nil {
+		return err
+	}
+
+	override := s.findOverride(rt.ID, scheduled)
+	override.Skip = true
+	return database.DB.Save(override).Error
+}
+
+// OverrideOccurrence changes the amount and/or date of a single pending occurrence.
+// A moved occurrence must stay between the previous pending occurrence and the following
+// one, with their own overrides applied, so the schedule keeps its order: the scheduler
+// walks the occurrences from NextRunDate and stops at the first one not due yet. The first
+// pending occurrence can be moved earlier freely, since the scheduler starts from it.
+func (s *RecurringOccurrenceService) OverrideOccurrence(recurringTransactionID uint, accountIDs []uint, day string, amount *float64, date *time.Time) error {
+	rt, scheduled, err := s.findPendingOccurrence(recurringTransactionID, accountIDs, day)
+	if err != nil {
+		return err
+	}
+
+	if amount != nil && *amount <= 0 {
+		return ErrInvalidOccurrenceAmount
+	}
+
+	if date != nil {
+		// Keep the time of day of the schedule
+		moved := time.Date(date.Year(), date.Month(), date.Day(),
+			scheduled.Hour(), scheduled.Minute(), scheduled.Second(), scheduled.Nanosecond(), scheduled.Location())
+		overrides := loadOccurrenceOverrides([]uint{rt.ID})[rt.ID]
+		next := ResolveOccurrence(rt, NextOccurrence(rt, scheduled), overrides)
+		if !moved.Before(next.ScheduledDate) || (!next.Skipped && !moved.Before(next.Date)) {
+			return ErrInvalidOccurrenceDate
+		}
+		if pending := OccurrencesBetween(rt, rt.NextRunDate, scheduled.Add(-time.Nanosecond)); len(pending) > 0 {
+			previous := ResolveOccurrence(rt, pending[len(pending)-1], overrides)
+			if moved.Before(previous.ScheduledDate) || (!previous.Skipped && moved.Before(previous.Date)) {
+				return ErrOccurrenceTooEarly
+			}
+		}
+		date = &moved
+	}
+
+	override := s.findOverride(rt.ID, scheduled)
+	override.Skip = false
+	override.Amount = amount
+	override.Date = date
+	return database.DB.Save(override).Error
+}
+
+// RestoreOccurrence removes the override of an occurrence, restoring the recurring schedule
+func (s *RecurringOccurrenceService) RestoreOccurrence(recurringTransactionID uint, accountIDs []uint, day string) error {
+	rt, scheduled, err := s.findPendingOccurrence(recurringTransactionID, accountIDs, day)
+	if err != nil {
+		return err
+	}
+
+	override := s.findOverride(rt.ID, scheduled)
+	if override.ID == 0 {
+		return nil
+	}
+	return database.DB.Unscoped().Delete(override).Error
+}
+
+// findPendingOccurrence loads a recurring transaction the accounts can access and resolves
+// the occurrence scheduled on the given day (YYYY-MM-DD). Only occurrences that were not
+// generated yet can be changed.
+func (s *RecurringOccurrenceService) findPendingOccurrence(recurringTransactionID uint, accountIDs []uint, day string) (*models.RecurringTransaction, time.Time, error) {
+	var rt models.RecurringTransaction
+	if err := database.DB.Where("id = ? AND account_id IN ?", recurringTransactionID, accountIDs).First(&rt).Error; err != nil {
+		return nil, time.Time{}, ErrOccurrenceNotFound
+	}
+
+	dayStart, err := time.ParseInLocation("2006-01-02", day, rt.StartDate.Location())
+	if err != nil {
+		return nil, time.Time{}, ErrOccurrenceNotFound
+	}
+
+	scheduled := NextOccurrence(&rt, dayStart.Add(-time.Nanosecond))
+	if occurrenceKey(scheduled) != day {
+		return nil, time.Time{}, ErrOccurrenceNotFound
+	}
+	if rt.EndDate != nil && scheduled.After(*rt.EndDate) {
+		return nil, time.Time{}, ErrOccurrenceNotFound
+	}
+	if scheduled.Before(rt.NextRunDate) {
+		return nil, time.Time{}, ErrOccurrenceNotFound
+	}
+
+	return &rt, scheduled, nil
+}
+
+// findOverride returns the existing override of an occurrence or a new, unsaved one
+func (s *RecurringOccurrenceService) findOverride(recurringTransactionID uint, scheduled time.Time) *models.RecurringOccurrenceOverride {
+	override, ok := loadOccurrenceOverrides([]uint{recurringTransactionID})[recurringTransactionID][occurrenceKey(scheduled)]
+	if !ok {
+		return &models.RecurringOccurrenceOverride{
+			RecurringTransactionID: recurringTransactionID,
+			OccurrenceDate:         scheduled,
+		}
+	}
+	return &override
+}
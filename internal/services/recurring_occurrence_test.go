@@ -0,0 +1,213 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func createMonthlyRecurringExpense(t *testing.T, accountID uint, start time.Time, amount float64) *models.RecurringTransaction {
+	t.Helper()
+	rt := &models.RecurringTransaction{
+		AccountID:       accountID,
+		TransactionType: models.TransactionTypeExpense,
+		Frequency:       models.FrequencyMonthly,
+		Amount:          amount,
+		Description:     "Streaming",
+		StartDate:       start,
+		NextRunDate:     start,
+		Active:          true,
+		Category:        "Assinaturas",
+	}
+	if err := database.DB.Create(rt).Error; err != nil {
+		t.Fatalf("failed to create recurring transaction: %v", err)
+	}
+	return rt
+}
+
+func TestRecurringOccurrenceService_PreviewOccurrences(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+
+	start := testDate(2030, 1, 10)
+	rt := createMonthlyRecurringExpense(t, account.ID, start, 50)
+
+	service := NewRecurringOccurrenceService()
+	accountIDs := []uint{account.ID}
+
+	if err := service.SkipOccurrence(rt.ID, accountIDs, "2030-02-10"); err != nil {
+		t.Fatalf("SkipOccurrence() error = %v", err)
+	}
+	amount := 80.0
+	moved := testDate(2030, 3, 15)
+	if err := service.OverrideOccurrence(rt.ID, accountIDs, "2030-03-10", &amount, &moved); err != nil {
+		t.Fatalf("OverrideOccurrence() error = %v", err)
+	}
+
+	occurrences, err := service.PreviewOccurrences(accountIDs, testDate(2030, 1, 1), testDate(2030, 4, 30))
+	if err != nil {
+		t.Fatalf("PreviewOccurrences() error = %v", err)
+	}
+
+	want := []struct {
+		date    time.Time
+		amount  float64
+		skipped bool
+	}{
+		{testDate(2030, 1, 10), 50, false},
+		{testDate(2030, 2, 10), 50, true},
+		{testDate(2030, 3, 15), 80, false},
+		{testDate(2030, 4, 10), 50, false},
+	}
+
+	if len(occurrences) != len(want) {
+		t.Fatalf("Expected %d occurrences, got %d", len(want), len(occurrences))
+	}
+	for i, w := range want {
+		got := occurrences[i]
+		if !got.Date.Equal(w.date) || got.Amount != w.amount || got.Skipped != w.skipped {
+			t.Errorf("occurrence %d = %s %.2f skipped=%v, want %s %.2f skipped=%v", i,
+				got.Date.Format("2006-01-02"), got.Amount, got.Skipped,
+				w.date.Format("2006-01-02"), w.amount, w.skipped)
+		}
+	}
+
+	// Restoring removes the override
+	if err := service.RestoreOccurrence(rt.ID, accountIDs, "2030-02-10"); err != nil {
+		t.Fatalf("RestoreOccurrence() error = %v", err)
+	}
+	occurrences, _ = service.PreviewOccurrences(accountIDs, testDate(2030, 2, 1), testDate(2030, 2, 28))
+	if len(occurrences) != 1 || occurrences[0].Skipped || occurrences[0].Overridden {
+		t.Errorf("restored occurrence = %+v", occurrences)
+	}
+}
+
+func TestRecurringOccurrenceService_OverrideOccurrence_Validation(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+	other := testutil.CreateTestUser(db, "other@example.com", "Other", "hash")
+	otherAccount := testutil.CreateTestAccount(db, "Other", models.AccountTypeIndividual, other.ID, nil)
+
+	rt := createMonthlyRecurringExpense(t, account.ID, testDate(2030, 1, 10), 50)
+	service := NewRecurringOccurrenceService()
+
+	zero := 0.0
+	tooLate := testDate(2030, 2, 10)
+	beforePrevious := testDate(2030, 1, 5)
+	tests := []struct {
+		name       string
+		accountIDs []uint
+		day        string
+		amount     *float64
+		date       *time.Time
+		wantErr    error
+	}{
+		{name: "not a scheduled day", accountIDs: []uint{account.ID}, day: "2030-01-11", wantErr: ErrOccurrenceNotFound},
+		{name: "before next run date", accountIDs: []uint{account.ID}, day: "2029-12-10", wantErr: ErrOccurrenceNotFound},
+		{name: "other user's transaction", accountIDs: []uint{otherAccount.ID}, day: "2030-01-10", wantErr: ErrOccurrenceNotFound},
+		{name: "invalid amount", accountIDs: []uint{account.ID}, day: "2030-01-10", amount: &zero, wantErr: ErrInvalidOccurrenceAmount},
+		{name: "moved past next occurrence", accountIDs: []uint{account.ID}, day: "2030-01-10", date: &tooLate, wantErr: ErrInvalidOccurrenceDate},
+		{name: "moved before previous pending occurrence", accountIDs: []uint{account.ID}, day: "2030-02-10", date: &beforePrevious, wantErr: ErrOccurrenceTooEarly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.OverrideOccurrence(rt.ID, tt.accountIDs, tt.day, tt.amount, tt.date)
+			if err != tt.wantErr {
+				t.Errorf("OverrideOccurrence() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// The neighbours' overrides count: with March moved to February 12, February can't
+	// move past it, and April can't move before it
+	march := testDate(2030, 2, 12)
+	if err := service.OverrideOccurrence(rt.ID, []uint{account.ID}, "2030-03-10", nil, &march); err != nil {
+		t.Fatalf("OverrideOccurrence() error = %v", err)
+	}
+	lateFebruary := testDate(2030, 2, 20)
+	if err := service.OverrideOccurrence(rt.ID, []uint{account.ID}, "2030-02-10", nil, &lateFebruary); err != ErrInvalidOccurrenceDate {
+		t.Errorf("OverrideOccurrence() past the moved next occurrence error = %v, want %v", err, ErrInvalidOccurrenceDate)
+	}
+	earlyFebruary := testDate(2030, 2, 11)
+	if err := service.OverrideOccurrence(rt.ID, []uint{account.ID}, "2030-04-10", nil, &earlyFebruary); err != ErrOccurrenceTooEarly {
+		t.Errorf("OverrideOccurrence() before the moved previous occurrence error = %v, want %v", err, ErrOccurrenceTooEarly)
+	}
+}
+
+func TestRecurringSchedulerService_ProcessDueTransactions_Overrides(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	service := NewRecurringOccurrenceService()
+	accountIDs := []uint{account.ID}
+
+	// Skipped occurrence due today: nothing is generated but the schedule advances
+	skipped := createMonthlyRecurringExpense(t, account.ID, today, 50)
+	if err := service.SkipOccurrence(skipped.ID, accountIDs, today.Format("2006-01-02")); err != nil {
+		t.Fatalf("SkipOccurrence() error = %v", err)
+	}
+
+	// Occurrence scheduled in 5 days moved to yesterday with a new amount: generated now
+	future := today.AddDate(0, 0, 5)
+	moved := createMonthlyRecurringExpense(t, account.ID, future, 30)
+	amount := 45.0
+	yesterday := today.AddDate(0, 0, -1)
+	if err := service.OverrideOccurrence(moved.ID, accountIDs, future.Format("2006-01-02"), &amount, &yesterday); err != nil {
+		t.Fatalf("OverrideOccurrence() error = %v", err)
+	}
+
+	if err := NewRecurringSchedulerService().ProcessDueTransactions(); err != nil {
+		t.Fatalf("ProcessDueTransactions() error = %v", err)
+	}
+
+	var expenses []models.Expense
+	db.Where("account_id = ?", account.ID).Find(&expenses)
+	if len(expenses) != 1 {
+		t.Fatalf("Expected 1 expense, got %d", len(expenses))
+	}
+	if expenses[0].Amount != 45 {
+		t.Errorf("Amount = %.2f, want 45.00", expenses[0].Amount)
+	}
+	if expenses[0].CreatedAt.Format("2006-01-02") != yesterday.Format("2006-01-02") {
+		t.Errorf("CreatedAt = %s, want %s", expenses[0].CreatedAt.Format("2006-01-02"), yesterday.Format("2006-01-02"))
+	}
+
+	// The notification reports the overridden amount, not the recurring one
+	var notification models.Notification
+	db.Where("user_id = ?", user.ID).First(&notification)
+	if !strings.Contains(notification.Message, "R$ 45.00") {
+		t.Errorf("notification message = %q, want the overridden amount", notification.Message)
+	}
+
+	for _, rt := range []*models.RecurringTransaction{skipped, moved} {
+		var updated models.RecurringTransaction
+		db.First(&updated, rt.ID)
+		if !updated.NextRunDate.After(rt.NextRunDate) {
+			t.Errorf("recurring %d NextRunDate = %v, want after %v", rt.ID, updated.NextRunDate, rt.NextRunDate)
+		}
+	}
+
+	// The overrides of the processed occurrences are deleted, so the moved occurrence no
+	// longer makes its transaction due on later runs
+	var overrides int64
+	db.Model(&models.RecurringOccurrenceOverride{}).Count(&overrides)
+	if overrides != 0 {
+		t.Errorf("expected the processed overrides to be deleted, got %d", overrides)
+	}
+}
@@ -1,21 +1,34 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"gorm.io/gorm"
+
 	"poc-finance/internal/database"
 	"poc-finance/internal/models"
 )
 
 type RecurringSchedulerService struct {
 	notificationService *NotificationService
+	goalService         *GoalService
+	accountService      *AccountService
+	budgetService       *BudgetService
+	exchangeRates       *ExchangeRateService
+	webhookService      *WebhookService
 }
 
 func NewRecurringSchedulerService() *RecurringSchedulerService {
 	return &RecurringSchedulerService{
 		notificationService: NewNotificationService(),
+		goalService:         NewGoalService(),
+		accountService:      NewAccountService(),
+		budgetService:       NewBudgetService(),
+		exchangeRates:       NewExchangeRateService(),
+		webhookService:      NewWebhookService(),
 	}
 }
 
@@ -23,10 +36,15 @@ func NewRecurringSchedulerService() *RecurringSchedulerService {
 func (s *RecurringSchedulerService) ProcessDueTransactions() error {
 	now := time.Now()
 
-	// Find all active recurring transactions where NextRunDate is today or earlier
+	// Find all active recurring transactions where NextRunDate is today or earlier,
+	// or with an occurrence moved to today or earlier
+	movedOccurrences := database.DB.Model(&models.RecurringOccurrenceOverride{}).
+		Select("recurring_transaction_id").
+		Where("date <= ? AND skip = ?", now, false)
+
 	var recurringTransactions []models.RecurringTransaction
 	err := database.DB.Preload("Account").
-		Where("active = ? AND next_run_date <= ?", true, now).
+		Where("active = ? AND (next_run_date <= ? OR id IN (?))", true, now, movedOccurrences).
 		Find(&recurringTransactions).Error
 
 	if err != nil {
@@ -35,115 +53,259 @@ func (s *RecurringSchedulerService) ProcessDueTransactions() error {
 
 	log.Printf("Found %d due recurring transactions to process", len(recurringTransactions))
 
+	// Failures don't stop the other transactions, but they are returned so the job run
+	// is recorded as failed and retried
+	var errs []error
 	for _, rt := range recurringTransactions {
+		generated, err := s.processRecurringTransaction(&rt, now)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if len(generated) == 0 {
+			continue
+		}
+
+		// Notify the user
+		if err := s.notifyUser(&rt, generated); err != nil {
+			log.Printf("Error notifying user for recurring transaction %d: %v", rt.ID, err)
+			errs = append(errs, fmt.Errorf("recurring transaction %d: notify: %w", rt.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// processRecurringTransaction generates every occurrence that is due up to now, each with
+// its own scheduled date, and returns the occurrences generated. NextRunDate is
+// persisted after every occurrence so a failure midway never repeats earlier occurrences.
+// Occurrence overrides are honoured: skipped occurrences are not generated, and moved
+// occurrences become due on their new date with the overridden amount. Errors are returned
+// joined, along with the transactions generated before them.
+func (s *RecurringSchedulerService) processRecurringTransaction(rt *models.RecurringTransaction, now time.Time) ([]Occurrence, error) {
+	var generated []Occurrence
+	var errs []error
+	fail := func(action string, err error) {
+		log.Printf("Error %s for recurring transaction %d: %v", action, rt.ID, err)
+		errs = append(errs, fmt.Errorf("recurring transaction %d: %s: %w", rt.ID, action, err))
+	}
+	runDate := rt.NextRunDate
+	overrides := loadOccurrenceOverrides([]uint{rt.ID})[rt.ID]
+
+	for len(generated) < maxCatchUpOccurrences {
+		occurrence := ResolveOccurrence(rt, runDate, overrides)
+
+		dueDate := occurrence.Date
+		if occurrence.Skipped {
+			dueDate = runDate
+		}
+		if dueDate.After(now) {
+			break
+		}
+
 		// Check if end date has passed
-		if rt.EndDate != nil && now.After(*rt.EndDate) {
+		if rt.EndDate != nil && runDate.After(*rt.EndDate) {
 			// Deactivate the recurring transaction
 			if err := s.deactivateRecurringTransaction(rt.ID); err != nil {
-				log.Printf("Error deactivating recurring transaction %d: %v", rt.ID, err)
+				fail("deactivating", err)
 			}
-			continue
+			break
 		}
 
-		// Generate the transaction
-		if err := s.generateTransaction(&rt); err != nil {
-			log.Printf("Error generating transaction for recurring transaction %d: %v", rt.ID, err)
-			continue
+		// Automatic goal contributions stop once the goal is no longer active
+		if rt.GoalID != nil && !s.isGoalActive(*rt.GoalID) {
+			if err := s.deactivateRecurringTransaction(rt.ID); err != nil {
+				fail("deactivating", err)
+			}
+			break
 		}
 
-		// Update NextRunDate
-		nextRunDate := s.calculateNextRunDate(rt.NextRunDate, rt.Frequency)
-		if err := s.updateNextRunDate(rt.ID, nextRunDate); err != nil {
-			log.Printf("Error updating next run date for recurring transaction %d: %v", rt.ID, err)
-			continue
+		if occurrence.Skipped {
+			log.Printf("Occurrence %s of recurring transaction %d was skipped", occurrence.Key(), rt.ID)
+		} else {
+			// Generate the transaction (skipped when this occurrence was already generated)
+			created, err := s.generateTransaction(rt, occurrence)
+			if err != nil {
+				fail("generating transaction", err)
+				break
+			}
+
+			if created {
+				generated = append(generated, occurrence)
+			} else {
+				log.Printf("Occurrence %s of recurring transaction %d was already generated, skipping",
+					occurrence.Key(), rt.ID)
+			}
+
+			// Register the goal contribution for the account owner. It is tried even when the
+			// occurrence was already generated, since a failed contribution is only made by
+			// the retry; NextRunDate stays on the occurrence until it succeeds.
+			if rt.GoalID != nil {
+				if err := s.addGoalContribution(rt, occurrence); err != nil {
+					fail("adding goal contribution", err)
+					break
+				}
+			}
 		}
 
-		// Notify the user
-		if err := s.notifyUser(&rt); err != nil {
-			log.Printf("Error notifying user for recurring transaction %d: %v", rt.ID, err)
-			// Don't fail if notification fails
+		// Update NextRunDate
+		runDate = s.calculateNextRunDate(rt, runDate)
+		if err := s.updateNextRunDate(rt.ID, runDate); err != nil {
+			fail("updating next run date", err)
+			break
 		}
 	}
 
+	return generated, errors.Join(errs...)
+}
+
+// addGoalContribution adds an occurrence's automatic goal contribution once. The contribution
+// has its own idempotency key, claimed before it is added and released if it fails.
+func (s *RecurringSchedulerService) addGoalContribution(rt *models.RecurringTransaction, occurrence Occurrence) error {
+	key := recurringOccurrenceKey(rt, occurrence) + ":goal"
+	claimed, err := ClaimIdempotencyKey(database.DB, JobRecurringTransactions, key)
+	if err != nil || !claimed {
+		return err
+	}
+	if _, err := s.goalService.AddContribution(*rt.GoalID, rt.Account.UserID, occurrence.Amount); err != nil {
+		if releaseErr := ReleaseIdempotencyKey(database.DB, key); releaseErr != nil {
+			return errors.Join(err, fmt.Errorf("release key %s: %w", key, releaseErr))
+		}
+		return err
+	}
 	return nil
 }
 
-// generateTransaction creates an Expense or Income based on the recurring transaction
-func (s *RecurringSchedulerService) generateTransaction(rt *models.RecurringTransaction) error {
+// generateTransaction creates an Expense or Income based on the recurring transaction.
+// It returns false when the occurrence had already been generated by a previous run.
+func (s *RecurringSchedulerService) generateTransaction(rt *models.RecurringTransaction, occurrence Occurrence) (bool, error) {
 	if rt.TransactionType == models.TransactionTypeExpense {
-		return s.generateExpense(rt)
+		return s.generateExpense(rt, occurrence)
 	} else if rt.TransactionType == models.TransactionTypeIncome {
-		return s.generateIncome(rt)
+		return s.generateIncome(rt, occurrence)
 	}
-	return fmt.Errorf("unknown transaction type: %s", rt.TransactionType)
+	return false, fmt.Errorf("unknown transaction type: %s", rt.TransactionType)
 }
 
-// generateExpense creates a new Expense from a recurring transaction
-func (s *RecurringSchedulerService) generateExpense(rt *models.RecurringTransaction) error {
+// recurringOccurrenceKey identifies one occurrence of a recurring transaction for idempotency
+func recurringOccurrenceKey(rt *models.RecurringTransaction, occurrence Occurrence) string {
+	return fmt.Sprintf("recurring:%d:%s", rt.ID, occurrence.Key())
+}
+
+// createOccurrence stores a generated record together with the occurrence idempotency key in
+// a single database transaction, so a crash can never leave one without the other.
+func createOccurrence(rt *models.RecurringTransaction, occurrence Occurrence, record interface{}) (bool, error) {
+	created := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		claimed, err := ClaimIdempotencyKey(tx, JobRecurringTransactions, recurringOccurrenceKey(rt, occurrence))
+		if err != nil || !claimed {
+			return err
+		}
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+// generateExpense creates a new Expense from a recurring transaction.
+// Variable expenses are dated by CreatedAt, so it is set to the occurrence date.
+func (s *RecurringSchedulerService) generateExpense(rt *models.RecurringTransaction, occurrence Occurrence) (bool, error) {
 	expense := &models.Expense{
+		Model:     gorm.Model{CreatedAt: occurrence.Date},
 		AccountID: rt.AccountID,
 		Name:      rt.Description,
-		Amount:    rt.Amount,
+		Amount:    occurrence.Amount,
 		Type:      models.ExpenseTypeVariable,
+		DueDay:    occurrence.Date.Day(),
 		Category:  rt.Category,
 		Active:    true,
 		IsSplit:   false,
 	}
 
-	if err := database.DB.Create(expense).Error; err != nil {
-		return fmt.Errorf("failed to create expense: %w", err)
+	created, err := createOccurrence(rt, occurrence, expense)
+	if err != nil {
+		return false, fmt.Errorf("failed to create expense: %w", err)
 	}
 
-	log.Printf("Generated expense %d from recurring transaction %d", expense.ID, rt.ID)
-	return nil
+	if created {
+		log.Printf("Generated expense %d from recurring transaction %d", expense.ID, rt.ID)
+		if rt.Category != "" {
+			s.budgetService.RefreshAccountSpending(rt.AccountID, rt.Category, occurrence.Date.Year(), int(occurrence.Date.Month()))
+		}
+	}
+	return created, nil
 }
 
-// generateIncome creates a new Income from a recurring transaction
-func (s *RecurringSchedulerService) generateIncome(rt *models.RecurringTransaction) error {
-	// For income, we need to set USD and BRL amounts
-	// Since recurring transactions use BRL, we'll set AmountBRL and use 1.0 as exchange rate
+// generateIncome creates a new Income from a recurring transaction. Like a manually
+// registered income, the tax is calculated from the owner's revenue over the 12 months
+// before the run date; USD amounts are converted with the rate of the run date.
+func (s *RecurringSchedulerService) generateIncome(rt *models.RecurringTransaction, occurrence Occurrence) (bool, error) {
+	runDate := occurrence.Date
+	amountUSD := occurrence.Amount
+	exchangeRate := 1.0
+	if rt.Currency == models.CurrencyUSD {
+		rate, err := s.exchangeRates.GetUSDRate(runDate)
+		if err != nil {
+			if rt.ExchangeRate <= 0 {
+				return false, fmt.Errorf("failed to get exchange rate: %w", err)
+			}
+			log.Printf("Using fallback exchange rate %.4f for recurring transaction %d: %v", rt.ExchangeRate, rt.ID, err)
+			rate = rt.ExchangeRate
+		}
+		exchangeRate = rate
+	}
+	amountBRL := amountUSD * exchangeRate
+
+	accountIDs, err := s.accountService.GetUserAccountIDs(rt.Account.UserID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get user accounts: %w", err)
+	}
+	revenue12M := GetRevenue12MonthsForAccountsAt(database.DB, accountIDs, runDate)
+	taxCalc := CalculateTaxWithManualBracket(revenue12M, amountBRL, getSettingInt(models.SettingManualBracket))
+
 	income := &models.Income{
 		AccountID:    rt.AccountID,
-		Date:         time.Now(),
-		AmountUSD:    rt.Amount, // Using BRL as USD for now
-		ExchangeRate: 1.0,
-		AmountBRL:    rt.Amount,
-		GrossAmount:  rt.Amount,
-		TaxAmount:    0, // Tax calculation would be done separately
-		NetAmount:    rt.Amount,
+		Date:         runDate,
+		AmountUSD:    amountUSD,
+		ExchangeRate: exchangeRate,
+		AmountBRL:    amountBRL,
+		GrossAmount:  amountBRL,
+		TaxAmount:    taxCalc.TaxAmount,
+		NetAmount:    taxCalc.NetAmount,
 		Description:  rt.Description,
 	}
 
-	if err := database.DB.Create(income).Error; err != nil {
-		return fmt.Errorf("failed to create income: %w", err)
+	created, err := createOccurrence(rt, occurrence, income)
+	if err != nil {
+		return false, fmt.Errorf("failed to create income: %w", err)
 	}
 
-	log.Printf("Generated income %d from recurring transaction %d", income.ID, rt.ID)
-	return nil
+	if created {
+		log.Printf("Generated income %d from recurring transaction %d", income.ID, rt.ID)
+	}
+	return created, nil
 }
 
-// calculateNextRunDate determines the next run date based on frequency
-func (s *RecurringSchedulerService) calculateNextRunDate(currentRunDate time.Time, frequency models.Frequency) time.Time {
-	switch frequency {
-	case models.FrequencyDaily:
-		return currentRunDate.AddDate(0, 0, 1)
-	case models.FrequencyWeekly:
-		return currentRunDate.AddDate(0, 0, 7)
-	case models.FrequencyMonthly:
-		return currentRunDate.AddDate(0, 1, 0)
-	case models.FrequencyYearly:
-		return currentRunDate.AddDate(1, 0, 0)
-	default:
-		// Default to monthly if unknown
-		return currentRunDate.AddDate(0, 1, 0)
-	}
+// calculateNextRunDate determines the next run date after the current one based on the schedule
+func (s *RecurringSchedulerService) calculateNextRunDate(rt *models.RecurringTransaction, currentRunDate time.Time) time.Time {
+	return NextOccurrence(rt, currentRunDate)
 }
 
-// updateNextRunDate updates the next run date for a recurring transaction
+// updateNextRunDate updates the next run date for a recurring transaction and deletes the
+// overrides of the occurrences before it, which were generated or skipped. Otherwise a moved
+// occurrence would keep the transaction in the due query of every run.
 func (s *RecurringSchedulerService) updateNextRunDate(recurringTransactionID uint, nextRunDate time.Time) error {
-	return database.DB.Model(&models.RecurringTransaction{}).
-		Where("id = ?", recurringTransactionID).
-		Update("next_run_date", nextRunDate).Error
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.RecurringTransaction{}).
+			Where("id = ?", recurringTransactionID).
+			Update("next_run_date", nextRunDate).Error; err != nil {
+			return err
+		}
+		return tx.Where("recurring_transaction_id = ? AND occurrence_date < ?", recurringTransactionID, nextRunDate).
+			Delete(&models.RecurringOccurrenceOverride{}).Error
+	})
 }
 
 // deactivateRecurringTransaction marks a recurring transaction as inactive
@@ -154,21 +316,55 @@ func (s *RecurringSchedulerService) deactivateRecurringTransaction(recurringTran
 		Update("active", false).Error
 }
 
-// notifyUser sends a notification when a transaction is generated from a recurring schedule
-func (s *RecurringSchedulerService) notifyUser(rt *models.RecurringTransaction) error {
+// isGoalActive reports whether the goal exists and is still accepting contributions
+func (s *RecurringSchedulerService) isGoalActive(goalID uint) bool {
+	goal, err := s.goalService.GetGoalByID(goalID)
+	if err != nil {
+		return false
+	}
+	return goal.Status == models.GoalStatusActive
+}
+
+// notifyUser sends a notification when transactions are generated from a recurring schedule.
+// When several missed occurrences were caught up in one run, a single notification covers them.
+// Amounts are the generated ones, which overrides may have changed.
+func (s *RecurringSchedulerService) notifyUser(rt *models.RecurringTransaction, generated []Occurrence) error {
 	// Get the user ID from the account
 	var account models.Account
 	if err := database.DB.First(&account, rt.AccountID).Error; err != nil {
 		return fmt.Errorf("failed to fetch account: %w", err)
 	}
 
+	count := len(generated)
+	total := 0.0
+	sameAmount := true
+	occurrences := make([]map[string]interface{}, 0, count)
+	for _, occurrence := range generated {
+		total += occurrence.Amount
+		sameAmount = sameAmount && occurrence.Amount == generated[0].Amount
+		occurrences = append(occurrences, map[string]interface{}{
+			"date":   occurrence.Date,
+			"amount": occurrence.Amount,
+		})
+	}
+	amounts := fmt.Sprintf("R$ %.2f no total", total)
+	if sameAmount {
+		amounts = fmt.Sprintf("R$ %.2f cada", generated[0].Amount)
+	}
+
 	var title, message string
 	if rt.TransactionType == models.TransactionTypeExpense {
 		title = "Despesa recorrente gerada"
-		message = fmt.Sprintf("Uma despesa recorrente foi criada: %s (R$ %.2f)", rt.Description, rt.Amount)
+		message = fmt.Sprintf("Uma despesa recorrente foi criada: %s (R$ %.2f)", rt.Description, total)
+		if count > 1 {
+			message = fmt.Sprintf("%d despesas recorrentes pendentes foram criadas: %s (%s)", count, rt.Description, amounts)
+		}
 	} else {
 		title = "Receita recorrente gerada"
-		message = fmt.Sprintf("Uma receita recorrente foi criada: %s (R$ %.2f)", rt.Description, rt.Amount)
+		message = fmt.Sprintf("Uma receita recorrente foi criada: %s (R$ %.2f)", rt.Description, total)
+		if count > 1 {
+			message = fmt.Sprintf("%d receitas recorrentes pendentes foram criadas: %s (%s)", count, rt.Description, amounts)
+		}
 	}
 
 	notification := &models.Notification{
@@ -180,6 +376,18 @@ func (s *RecurringSchedulerService) notifyUser(rt *models.RecurringTransaction)
 		GroupID: account.GroupID,
 	}
 
+	s.webhookService.EmitForAccount(&account, models.WebhookEventRecurringGenerated, map[string]interface{}{
+		"recurring_transaction_id": rt.ID,
+		"account_id":               rt.AccountID,
+		"group_id":                 account.GroupID,
+		"transaction_type":         rt.TransactionType,
+		"description":              rt.Description,
+		"amount":                   total,
+		"currency":                 rt.Currency,
+		"count":                    count,
+		"occurrences":              occurrences,
+	})
+
 	return s.notificationService.Create(notification)
 }
 
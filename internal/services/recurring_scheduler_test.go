@@ -1,6 +1,9 @@
 package services
 
 import (
+	"errors"
+	"math"
+	"strings"
 	"testing"
 	"time"
 
@@ -300,6 +303,124 @@ func TestRecurringSchedulerService_ProcessDueTransactions_Income(t *testing.T) {
 	}
 }
 
+func TestRecurringSchedulerService_ProcessDueTransactions_IncomeTax(t *testing.T) {
+	tests := []struct {
+		name          string
+		currency      string
+		fallbackRate  float64
+		quote         float64
+		quoteErr      error
+		wantRate      float64
+		wantAmountBRL float64
+		wantTax       float64
+		wantIncomes   int
+		wantErr       bool // The run is reported as failed so the job runner retries it
+	}{
+		{
+			name:          "BRL income uses owner's 12-month revenue",
+			currency:      models.CurrencyBRL,
+			wantRate:      1,
+			wantAmountBRL: 5000,
+			wantTax:       326, // bracket 2: (200000*11.2% - 9360) / 200000 = 6.52%
+			wantIncomes:   2,
+		},
+		{
+			name:          "USD income converted with quote of the run date",
+			currency:      models.CurrencyUSD,
+			quote:         5.5,
+			wantRate:      5.5,
+			wantAmountBRL: 27500,
+			wantTax:       1793,
+			wantIncomes:   2,
+		},
+		{
+			name:          "USD income falls back to configured rate",
+			currency:      models.CurrencyUSD,
+			fallbackRate:  5,
+			quoteErr:      errors.New("offline"),
+			wantRate:      5,
+			wantAmountBRL: 25000,
+			wantTax:       1630,
+			wantIncomes:   2,
+		},
+		{
+			name:        "USD income without quote or fallback is not generated",
+			currency:    models.CurrencyUSD,
+			quoteErr:    errors.New("offline"),
+			wantIncomes: 1,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.SetupTestDB()
+			database.DB = db
+
+			user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+			account := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+
+			today := time.Now()
+			db.Create(&models.Income{
+				AccountID:   account.ID,
+				Date:        today.AddDate(0, -2, 0),
+				AmountBRL:   200000,
+				GrossAmount: 200000,
+				NetAmount:   200000,
+				Description: "Previous income",
+			})
+
+			db.Create(&models.RecurringTransaction{
+				AccountID:       account.ID,
+				TransactionType: models.TransactionTypeIncome,
+				Frequency:       models.FrequencyMonthly,
+				Amount:          5000,
+				Description:     "Contract",
+				StartDate:       today,
+				NextRunDate:     today,
+				Active:          true,
+				Currency:        tt.currency,
+				ExchangeRate:    tt.fallbackRate,
+			})
+
+			scheduler := NewRecurringSchedulerService()
+			scheduler.exchangeRates.fetch = func(date time.Time) (float64, error) {
+				return tt.quote, tt.quoteErr
+			}
+
+			if err := scheduler.ProcessDueTransactions(); (err != nil) != tt.wantErr {
+				t.Fatalf("ProcessDueTransactions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var incomes []models.Income
+			db.Where("account_id = ?", account.ID).Order("id").Find(&incomes)
+			if len(incomes) != tt.wantIncomes {
+				t.Fatalf("Expected %d incomes, got %d", tt.wantIncomes, len(incomes))
+			}
+			if tt.wantIncomes == 1 {
+				return
+			}
+
+			generated := incomes[1]
+			if generated.AmountUSD != 5000 {
+				t.Errorf("AmountUSD = %.2f, want 5000.00", generated.AmountUSD)
+			}
+			if generated.ExchangeRate != tt.wantRate {
+				t.Errorf("ExchangeRate = %.4f, want %.4f", generated.ExchangeRate, tt.wantRate)
+			}
+			if math.Abs(generated.AmountBRL-tt.wantAmountBRL) > 0.01 {
+				t.Errorf("AmountBRL = %.2f, want %.2f", generated.AmountBRL, tt.wantAmountBRL)
+			}
+			if math.Abs(generated.TaxAmount-tt.wantTax) > 0.01 {
+				t.Errorf("TaxAmount = %.2f, want %.2f", generated.TaxAmount, tt.wantTax)
+			}
+			if math.Abs(generated.NetAmount-(tt.wantAmountBRL-tt.wantTax)) > 0.01 {
+				t.Errorf("NetAmount = %.2f, want %.2f", generated.NetAmount, tt.wantAmountBRL-tt.wantTax)
+			}
+		})
+	}
+}
+
 func TestRecurringSchedulerService_ProcessDueTransactions_Inactive(t *testing.T) {
 	db := testutil.SetupTestDB()
 	database.DB = db
@@ -535,7 +656,8 @@ func TestRecurringSchedulerService_CalculateNextRunDate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := scheduler.calculateNextRunDate(baseDate, tt.frequency)
+			rt := &models.RecurringTransaction{StartDate: baseDate, Frequency: tt.frequency}
+			result := scheduler.calculateNextRunDate(rt, baseDate)
 
 			if result.Year() != tt.expected.Year() ||
 				result.Month() != tt.expected.Month() ||
@@ -545,3 +667,117 @@ func TestRecurringSchedulerService_CalculateNextRunDate(t *testing.T) {
 		})
 	}
 }
+
+func TestRecurringSchedulerService_ProcessDueTransactions_CatchUp(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+
+	// Monthly expense that has missed its last three occurrences
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local).AddDate(0, -2, 0)
+	recurringTx := &models.RecurringTransaction{
+		AccountID:       account.ID,
+		TransactionType: models.TransactionTypeExpense,
+		Frequency:       models.FrequencyMonthly,
+		Amount:          50.0,
+		Description:     "Streaming",
+		StartDate:       start,
+		NextRunDate:     start,
+		Active:          true,
+	}
+	db.Create(recurringTx)
+
+	// The latest occurrence costs more this time
+	amount := 80.0
+	latest := start.AddDate(0, 2, 0).Format("2006-01-02")
+	if err := NewRecurringOccurrenceService().OverrideOccurrence(recurringTx.ID, []uint{account.ID}, latest, &amount, nil); err != nil {
+		t.Fatalf("OverrideOccurrence() error = %v", err)
+	}
+
+	if err := NewRecurringSchedulerService().ProcessDueTransactions(); err != nil {
+		t.Fatalf("ProcessDueTransactions() error = %v", err)
+	}
+
+	var expenses []models.Expense
+	db.Where("account_id = ?", account.ID).Order("created_at ASC").Find(&expenses)
+	if len(expenses) != 3 {
+		t.Fatalf("Expected 3 expenses, got %d", len(expenses))
+	}
+
+	// Each generated expense carries its own occurrence date
+	for i, expense := range expenses {
+		want := start.AddDate(0, i, 0)
+		if !expense.CreatedAt.Equal(want) {
+			t.Errorf("expense %d CreatedAt = %v, want %v", i, expense.CreatedAt, want)
+		}
+	}
+
+	var updatedTx models.RecurringTransaction
+	db.First(&updatedTx, recurringTx.ID)
+	if !updatedTx.NextRunDate.Equal(start.AddDate(0, 3, 0)) {
+		t.Errorf("NextRunDate = %v, want %v", updatedTx.NextRunDate, start.AddDate(0, 3, 0))
+	}
+
+	// Only one notification summarises the catch-up, with the generated amounts
+	var notifications []models.Notification
+	db.Where("user_id = ?", user.ID).Find(&notifications)
+	if len(notifications) != 1 {
+		t.Errorf("Expected 1 notification, got %d", len(notifications))
+	} else if !strings.Contains(notifications[0].Message, "R$ 180.00 no total") {
+		t.Errorf("notification message = %q, want the total of the generated amounts", notifications[0].Message)
+	}
+
+	// A second run does not generate anything new
+	NewRecurringSchedulerService().ProcessDueTransactions()
+	var count int64
+	db.Model(&models.Expense{}).Where("account_id = ?", account.ID).Count(&count)
+	if count != 3 {
+		t.Errorf("Expected 3 expenses after second run, got %d", count)
+	}
+}
+
+func TestRecurringSchedulerService_ProcessDueTransactions_ReturnsFailures(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Personal", models.AccountTypeIndividual, user.ID, nil)
+
+	today := time.Now()
+	db.Create(&models.RecurringTransaction{
+		AccountID:       account.ID,
+		TransactionType: "transfer", // Can't be generated
+		Frequency:       models.FrequencyDaily,
+		Amount:          100.0,
+		Description:     "Broken",
+		StartDate:       today.AddDate(0, 0, -1),
+		NextRunDate:     today,
+		Active:          true,
+	})
+	healthy := &models.RecurringTransaction{
+		AccountID:       account.ID,
+		TransactionType: models.TransactionTypeExpense,
+		Frequency:       models.FrequencyDaily,
+		Amount:          50.0,
+		Description:     "Healthy",
+		StartDate:       today.AddDate(0, 0, -1),
+		NextRunDate:     today,
+		Active:          true,
+	}
+	db.Create(healthy)
+
+	err := NewRecurringSchedulerService().ProcessDueTransactions()
+	if err == nil || !strings.Contains(err.Error(), "unknown transaction type") {
+		t.Errorf("ProcessDueTransactions() error = %v, want the generation failure so the job is retried", err)
+	}
+
+	// The failure doesn't stop the other transactions
+	var count int64
+	db.Model(&models.Expense{}).Where("name = ?", "Healthy").Count(&count)
+	if count != 1 {
+		t.Errorf("Expected the healthy transaction to be generated, got %d expenses", count)
+	}
+}
@@ -0,0 +1,524 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+var (
+	ErrInvalidSubscription       = errors.New("assinatura inválida")
+	ErrInvalidSubscriptionStatus = errors.New("status de assinatura inválido")
+)
+
+const (
+	// subscriptionLookbackMonths is how far back charges are analyzed to detect subscriptions
+	subscriptionLookbackMonths = 24
+	// subscriptionPriceTolerance is the relative price change accepted between consecutive charges
+	subscriptionPriceTolerance = 0.3
+	// maxSubscriptionReminderDays caps how early a cancel reminder can be sent
+	maxSubscriptionReminderDays = 30
+)
+
+// nonSubscriptionCategories are categories whose fixed and recurring expenses are not
+// subscriptions (rent, condominium fees, taxes)
+var nonSubscriptionCategories = map[string]bool{
+	"Moradia":  true,
+	"Impostos": true,
+}
+
+// subscriptionCategories are categories whose fixed expenses are taken as subscriptions
+// without the user confirming them
+var subscriptionCategories = map[string]bool{
+	"Lazer": true,
+}
+
+// subscriptionMerchants are names of subscription services. A fixed expense whose name
+// contains one of them is taken as a subscription without the user confirming it.
+var subscriptionMerchants = []string{
+	"assinatura", "streaming", "netflix", "spotify", "prime video", "amazon prime", "disney",
+	"hbo", "globoplay", "youtube", "deezer", "apple music", "apple tv", "icloud", "google one",
+	"paramount", "crunchyroll", "xbox", "playstation", "chatgpt", "openai", "microsoft 365",
+	"office 365", "adobe", "dropbox", "canva", "duolingo",
+}
+
+// SubscriptionSource identifies where a subscription was detected
+type SubscriptionSource string
+
+const (
+	SubscriptionSourceRecurring    SubscriptionSource = "recurring"
+	SubscriptionSourceFixedExpense SubscriptionSource = "fixed_expense"
+	SubscriptionSourceCharges      SubscriptionSource = "charges"
+)
+
+// SubscriptionPrice is the amount of a subscription charge at a date
+type SubscriptionPrice struct {
+	Date   time.Time `json:"date"`
+	Amount float64   `json:"amount"`
+}
+
+// DetectedSubscription is a recurring merchant of an account with its cost and next renewal
+type DetectedSubscription struct {
+	AccountID               uint                      `json:"account_id"`
+	AccountName             string                    `json:"account_name"`
+	Merchant                string                    `json:"merchant"` // Normalized name, identifies the subscription in the account
+	Name                    string                    `json:"name"`
+	Category                string                    `json:"category"`
+	Source                  SubscriptionSource        `json:"source"`
+	Frequency               models.Frequency          `json:"frequency"`
+	Amount                  float64                   `json:"amount"` // Current price per charge
+	MonthlyCost             float64                   `json:"monthly_cost"`
+	AnnualCost              float64                   `json:"annual_cost"`
+	Prices                  []SubscriptionPrice       `json:"prices"` // Price history, oldest first
+	PriceIncrease           float64                   `json:"price_increase"`
+	PriceIncreasePercentage float64                   `json:"price_increase_percentage"`
+	NextCharge              time.Time                 `json:"next_charge"`
+	DaysUntilCharge         int                       `json:"days_until_charge"`
+	Status                  models.SubscriptionStatus `json:"status"`
+	ReminderDays            int                       `json:"reminder_days"`
+	// Confirmed is false for fixed expenses that don't look like a subscription and the user
+	// hasn't decided about. They are only suggested: not counted nor reminded.
+	Confirmed bool `json:"confirmed"`
+}
+
+// PriceIncreased reports whether the subscription got more expensive over time
+func (d DetectedSubscription) PriceIncreased() bool {
+	return d.PriceIncrease >= 0.01
+}
+
+// SubscriptionOverview lists the detected subscriptions of a user and their total cost.
+// Cancelled, ignored and unconfirmed subscriptions are listed but don't count in the totals.
+type SubscriptionOverview struct {
+	Subscriptions  []DetectedSubscription `json:"subscriptions"`
+	MonthlyTotal   float64                `json:"monthly_total"`
+	AnnualTotal    float64                `json:"annual_total"`
+	ToCancelAnnual float64                `json:"to_cancel_annual"` // Yearly savings once the subscriptions marked to cancel are cancelled
+	PriceIncreases int                    `json:"price_increases"`
+	Unconfirmed    int                    `json:"unconfirmed"`
+}
+
+type SubscriptionService struct {
+	accountService      *AccountService
+	notificationService *NotificationService
+}
+
+func NewSubscriptionService() *SubscriptionService {
+	return &SubscriptionService{
+		accountService:      NewAccountService(),
+		notificationService: NewNotificationService(),
+	}
+}
+
+// GetOverview detects the subscriptions of the given accounts
+func (s *SubscriptionService) GetOverview(accountIDs []uint, now time.Time) *SubscriptionOverview {
+	overview := &SubscriptionOverview{Subscriptions: DetectSubscriptions(accountIDs, now)}
+	for _, sub := range overview.Subscriptions {
+		if !sub.Confirmed {
+			overview.Unconfirmed++
+			continue
+		}
+		if sub.Status == models.SubscriptionStatusCancelled || sub.Status == models.SubscriptionStatusIgnored {
+			continue
+		}
+		overview.MonthlyTotal += sub.MonthlyCost
+		overview.AnnualTotal += sub.AnnualCost
+		if sub.Status == models.SubscriptionStatusToCancel {
+			overview.ToCancelAnnual += sub.AnnualCost
+		}
+		if sub.PriceIncreased() {
+			overview.PriceIncreases++
+		}
+	}
+	return overview
+}
+
+// DetectSubscriptions finds the subscriptions of the given accounts from their active recurring
+// expenses, active fixed expenses and repeated charges (variable expenses and installment
+// purchases) of the same merchant about a month or a year apart. Each merchant is reported
+// once per account, preferring recurring transactions, then fixed expenses. Fixed expenses
+// are confirmed only when they look like a subscription or the user decided about them.
+func DetectSubscriptions(accountIDs []uint, now time.Time) []DetectedSubscription {
+	if len(accountIDs) == 0 {
+		return []DetectedSubscription{}
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	var accounts []models.Account
+	database.DB.Where("id IN ?", accountIDs).Find(&accounts)
+	accountNames := make(map[uint]string, len(accounts))
+	for _, a := range accounts {
+		accountNames[a.ID] = a.Name
+	}
+
+	// Charge history of each account by merchant, used for price history and detection
+	charges := make(map[uint]map[string][]Charge)
+	for _, accountID := range accountIDs {
+		byMerchant := make(map[string][]Charge)
+		for _, charge := range chargeHistory(accountID, today.AddDate(0, -subscriptionLookbackMonths, 0), now, 0, 0) {
+			merchant := normalizeMerchant(charge.Name)
+			byMerchant[merchant] = append(byMerchant[merchant], charge)
+		}
+		for _, list := range byMerchant {
+			sort.Slice(list, func(i, j int) bool { return list[i].Date.Before(list[j].Date) })
+		}
+		charges[accountID] = byMerchant
+	}
+
+	seen := make(map[string]bool)
+	var result []DetectedSubscription
+	add := func(sub DetectedSubscription) {
+		key := fmt.Sprintf("%d|%s", sub.AccountID, sub.Merchant)
+		if sub.Merchant == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		sub.AccountName = accountNames[sub.AccountID]
+		sub.AnnualCost = math.Round(sub.MonthlyCost*12*100) / 100
+		sub.MonthlyCost = math.Round(sub.MonthlyCost*100) / 100
+		sub.DaysUntilCharge = int(sub.NextCharge.Sub(today).Hours() / 24)
+		if len(sub.Prices) > 0 {
+			first := sub.Prices[0].Amount
+			sub.PriceIncrease = math.Round((sub.Amount-first)*100) / 100
+			if first > 0 {
+				sub.PriceIncreasePercentage = sub.PriceIncrease / first * 100
+			}
+		}
+		result = append(result, sub)
+	}
+
+	var recurring []models.RecurringTransaction
+	database.DB.Where("account_id IN ? AND active = ? AND transaction_type = ?", accountIDs, true, models.TransactionTypeExpense).
+		Order("id").Find(&recurring)
+	for _, rt := range recurring {
+		// Automatic goal contributions are savings, not subscriptions
+		monthly := monthlyRecurringCost(&rt)
+		if monthly == 0 || rt.GoalID != nil || nonSubscriptionCategories[rt.Category] {
+			continue
+		}
+		merchant := normalizeMerchant(rt.Description)
+		add(DetectedSubscription{
+			AccountID:   rt.AccountID,
+			Merchant:    merchant,
+			Name:        rt.Description,
+			Category:    rt.Category,
+			Source:      SubscriptionSourceRecurring,
+			Frequency:   rt.Frequency,
+			Amount:      rt.Amount,
+			MonthlyCost: monthly,
+			Prices:      pricesWithCurrent(chargePrices(charges[rt.AccountID][merchant]), rt.Amount, today),
+			NextCharge:  rt.NextRunDate,
+			Confirmed:   true,
+		})
+	}
+
+	var expenses []models.Expense
+	database.DB.Where("account_id IN ? AND active = ? AND type = ?", accountIDs, true, models.ExpenseTypeFixed).
+		Order("id").Find(&expenses)
+	for _, e := range expenses {
+		if nonSubscriptionCategories[e.Category] {
+			continue
+		}
+		add(DetectedSubscription{
+			AccountID:   e.AccountID,
+			Merchant:    normalizeMerchant(e.Name),
+			Name:        e.Name,
+			Category:    e.Category,
+			Source:      SubscriptionSourceFixedExpense,
+			Frequency:   models.FrequencyMonthly,
+			Amount:      e.Amount,
+			MonthlyCost: e.Amount,
+			Prices:      pricesWithCurrent(expensePaymentPrices(e.ID), e.Amount, today),
+			NextCharge:  nextFixedExpenseCharge(&e, today),
+			Confirmed:   isSubscriptionExpense(e.Name, e.Category),
+		})
+	}
+
+	for _, accountID := range accountIDs {
+		merchants := make([]string, 0, len(charges[accountID]))
+		for merchant := range charges[accountID] {
+			merchants = append(merchants, merchant)
+		}
+		sort.Strings(merchants)
+		for _, merchant := range merchants {
+			if sub, ok := detectChargeSubscription(charges[accountID][merchant], today); ok {
+				sub.AccountID = accountID
+				sub.Merchant = merchant
+				sub.Confirmed = true
+				add(sub)
+			}
+		}
+	}
+
+	statuses := loadSubscriptionStatuses(accountIDs)
+	for i := range result {
+		result[i].Status = models.SubscriptionStatusActive
+		result[i].ReminderDays = models.DefaultSubscriptionReminderDays
+		if saved, ok := statuses[fmt.Sprintf("%d|%s", result[i].AccountID, result[i].Merchant)]; ok {
+			result[i].Status = saved.Status
+			result[i].ReminderDays = saved.ReminderDays
+			result[i].Confirmed = true
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].MonthlyCost != result[j].MonthlyCost {
+			return result[i].MonthlyCost > result[j].MonthlyCost
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// isSubscriptionExpense reports whether a fixed expense is a subscription by its category or name
+func isSubscriptionExpense(name, category string) bool {
+	if subscriptionCategories[category] {
+		return true
+	}
+	merchant := normalizeMerchant(name)
+	for _, known := range subscriptionMerchants {
+		if strings.Contains(merchant, known) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectChargeSubscription reports charges of a merchant that repeat every month or every
+// year with similar amounts, whose last charge is recent enough for the subscription to be alive
+func detectChargeSubscription(charges []Charge, today time.Time) (DetectedSubscription, bool) {
+	if len(charges) < 2 {
+		return DetectedSubscription{}, false
+	}
+
+	monthly, yearly := true, true
+	for i := 1; i < len(charges); i++ {
+		days := charges[i].Date.Sub(charges[i-1].Date).Hours() / 24
+		monthly = monthly && days >= 25 && days <= 35
+		yearly = yearly && days >= 350 && days <= 380
+
+		previous := charges[i-1].Amount
+		if previous <= 0 || math.Abs(charges[i].Amount-previous)/previous > subscriptionPriceTolerance {
+			return DetectedSubscription{}, false
+		}
+	}
+
+	if !monthly && !yearly {
+		return DetectedSubscription{}, false
+	}
+
+	last := charges[len(charges)-1]
+	sub := DetectedSubscription{
+		Name:        last.Name,
+		Category:    last.Category,
+		Source:      SubscriptionSourceCharges,
+		Frequency:   models.FrequencyMonthly,
+		Amount:      last.Amount,
+		MonthlyCost: last.Amount,
+		Prices:      chargePrices(charges),
+	}
+	if !monthly {
+		sub.Frequency = models.FrequencyYearly
+		sub.MonthlyCost = last.Amount / 12
+	}
+
+	// A subscription that missed two charges in a row was probably cancelled
+	next := nextCharge(last.Date, sub.Frequency)
+	if today.After(nextCharge(next, sub.Frequency)) {
+		return DetectedSubscription{}, false
+	}
+	for next.Before(today) {
+		next = nextCharge(next, sub.Frequency)
+	}
+	sub.NextCharge = next
+	return sub, true
+}
+
+// nextCharge returns the day of the charge following date for a monthly or yearly subscription
+func nextCharge(date time.Time, frequency models.Frequency) time.Time {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+	if frequency == models.FrequencyYearly {
+		return day.AddDate(1, 0, 0)
+	}
+	return day.AddDate(0, 1, 0)
+}
+
+// monthlyRecurringCost converts the amount of a recurring expense to a monthly cost.
+// Daily transactions are not subscriptions and cost zero.
+func monthlyRecurringCost(rt *models.RecurringTransaction) float64 {
+	interval := float64(rt.Interval)
+	if interval < 1 {
+		interval = 1
+	}
+	switch rt.Frequency {
+	case models.FrequencyWeekly:
+		return rt.Amount * 52 / 12 / interval
+	case models.FrequencyMonthly:
+		return rt.Amount / interval
+	case models.FrequencyYearly:
+		return rt.Amount / 12 / interval
+	}
+	return 0
+}
+
+// nextFixedExpenseCharge returns the next due date of a fixed expense not yet paid
+func nextFixedExpenseCharge(expense *models.Expense, today time.Time) time.Time {
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.Local)
+	due := dayInMonth(month, expense.DueDay)
+
+	var paid int64
+	database.DB.Model(&models.ExpensePayment{}).
+		Where("expense_id = ? AND year = ? AND month = ?", expense.ID, today.Year(), int(today.Month())).
+		Count(&paid)
+	if paid > 0 || due.Before(today) {
+		due = dayInMonth(month.AddDate(0, 1, 0), expense.DueDay)
+	}
+	return due
+}
+
+func chargePrices(charges []Charge) []SubscriptionPrice {
+	prices := make([]SubscriptionPrice, 0, len(charges))
+	for _, c := range charges {
+		prices = append(prices, SubscriptionPrice{Date: c.Date, Amount: c.Amount})
+	}
+	return prices
+}
+
+// expensePaymentPrices returns the amounts paid for a fixed expense, oldest first
+func expensePaymentPrices(expenseID uint) []SubscriptionPrice {
+	var payments []models.ExpensePayment
+	database.DB.Where("expense_id = ?", expenseID).Order("year, month").Find(&payments)
+
+	prices := make([]SubscriptionPrice, 0, len(payments))
+	for _, p := range payments {
+		prices = append(prices, SubscriptionPrice{
+			Date:   time.Date(p.Year, time.Month(p.Month), 1, 0, 0, 0, 0, time.Local),
+			Amount: p.Amount,
+		})
+	}
+	return prices
+}
+
+// pricesWithCurrent appends the current price when it differs from the last one charged
+func pricesWithCurrent(prices []SubscriptionPrice, amount float64, today time.Time) []SubscriptionPrice {
+	if len(prices) == 0 || math.Abs(prices[len(prices)-1].Amount-amount) >= 0.01 {
+		prices = append(prices, SubscriptionPrice{Date: today, Amount: amount})
+	}
+	return prices
+}
+
+// loadSubscriptionStatuses returns the saved decisions of the accounts, keyed by account and merchant
+func loadSubscriptionStatuses(accountIDs []uint) map[string]models.Subscription {
+	var subscriptions []models.Subscription
+	database.DB.Where("account_id IN ?", accountIDs).Find(&subscriptions)
+
+	result := make(map[string]models.Subscription, len(subscriptions))
+	for _, sub := range subscriptions {
+		result[fmt.Sprintf("%d|%s", sub.AccountID, sub.Merchant)] = sub
+	}
+	return result
+}
+
+// SetStatus records the user's decision about a detected subscription of one of their accounts
+func (s *SubscriptionService) SetStatus(userID, accountID uint, name string, status models.SubscriptionStatus, reminderDays int) (*models.Subscription, error) {
+	switch status {
+	case models.SubscriptionStatusActive, models.SubscriptionStatusToCancel, models.SubscriptionStatusCancelled, models.SubscriptionStatusIgnored:
+	default:
+		return nil, ErrInvalidSubscriptionStatus
+	}
+	merchant := normalizeMerchant(name)
+	if merchant == "" {
+		return nil, ErrInvalidSubscription
+	}
+	if !s.accountService.CanUserAccessAccount(userID, accountID) {
+		return nil, ErrUnauthorized
+	}
+	if reminderDays <= 0 {
+		reminderDays = models.DefaultSubscriptionReminderDays
+	}
+	if reminderDays > maxSubscriptionReminderDays {
+		reminderDays = maxSubscriptionReminderDays
+	}
+
+	var subscription models.Subscription
+	err := database.DB.Where("account_id = ? AND merchant = ?", accountID, merchant).First(&subscription).Error
+	if err != nil {
+		subscription = models.Subscription{AccountID: accountID, Merchant: merchant}
+	}
+	subscription.Name = name
+	subscription.Status = status
+	subscription.ReminderDays = reminderDays
+	subscription.CancelledAt = nil
+	if status == models.SubscriptionStatusCancelled {
+		now := time.Now()
+		subscription.CancelledAt = &now
+	}
+
+	if err := database.DB.Save(&subscription).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// SendCancelReminders reminds the account members of the subscriptions marked to cancel
+// whose next charge is within their reminder days. It runs as a daily job.
+func (s *SubscriptionService) SendCancelReminders() error {
+	return s.sendCancelReminders(time.Now())
+}
+
+func (s *SubscriptionService) sendCancelReminders(now time.Time) error {
+	var pending []models.Subscription
+	if err := database.DB.Where("status = ?", models.SubscriptionStatusToCancel).Find(&pending).Error; err != nil {
+		return fmt.Errorf("failed to fetch subscriptions to cancel: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	accountIDs := make([]uint, 0, len(pending))
+	seen := make(map[uint]bool)
+	for _, sub := range pending {
+		if !seen[sub.AccountID] {
+			seen[sub.AccountID] = true
+			accountIDs = append(accountIDs, sub.AccountID)
+		}
+	}
+
+	// A failed reminder doesn't stop the others, but fails the run so the job retries it
+	var errs []error
+	for _, sub := range DetectSubscriptions(accountIDs, now) {
+		if !sub.Confirmed || sub.Status != models.SubscriptionStatusToCancel || sub.DaysUntilCharge < 0 || sub.DaysUntilCharge > sub.ReminderDays {
+			continue
+		}
+
+		// Only remind once per subscription and charge, even if the job runs again
+		key := fmt.Sprintf("subscription_reminder:%d:%s:%s", sub.AccountID, sub.Merchant, sub.NextCharge.Format("2006-01-02"))
+		claimed, err := ClaimIdempotencyKey(database.DB, JobSubscriptionReminders, key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s of account %d: claim: %w", sub.Merchant, sub.AccountID, err))
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		members, err := s.accountService.GetAccountMembers(sub.AccountID)
+		if err == nil {
+			err = s.notificationService.NotifySubscriptionCancelReminder(sub, members)
+		}
+		if err != nil {
+			log.Printf("Error reminding subscription %s of account %d: %v", sub.Merchant, sub.AccountID, err)
+			errs = append(errs, fmt.Errorf("subscription %s of account %d: %w", sub.Merchant, sub.AccountID, err))
+			if err := ReleaseIdempotencyKey(database.DB, key); err != nil {
+				errs = append(errs, fmt.Errorf("subscription %s of account %d: release key: %w", sub.Merchant, sub.AccountID, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestDetectSubscriptions(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 10, 0, 0, 0, time.Local)
+	}
+	now := date(2030, 6, 10)
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Pessoal", models.AccountTypeIndividual, user.ID, nil)
+
+	// Recurring yearly subscription
+	db.Create(&models.RecurringTransaction{
+		AccountID: account.ID, TransactionType: models.TransactionTypeExpense, Frequency: models.FrequencyYearly,
+		Amount: 120, Description: "Antivírus", StartDate: date(2030, 9, 1), NextRunDate: date(2030, 9, 1), Active: true, Category: "Serviços",
+	})
+
+	// Fixed expense whose price went up; rent is not a subscription
+	music := models.Expense{AccountID: account.ID, Name: "Música", Amount: 24.9, Type: models.ExpenseTypeFixed, DueDay: 5, Active: true, Category: "Lazer"}
+	db.Create(&music)
+	db.Create(&models.ExpensePayment{ExpenseID: music.ID, Year: 2030, Month: 4, Amount: 19.9, PaidAt: date(2030, 4, 5)})
+	db.Create(&models.ExpensePayment{ExpenseID: music.ID, Year: 2030, Month: 5, Amount: 21.9, PaidAt: date(2030, 5, 5)})
+	db.Create(&models.Expense{AccountID: account.ID, Name: "Aluguel", Amount: 2000, Type: models.ExpenseTypeFixed, DueDay: 10, Active: true, Category: "Moradia"})
+
+	// Monthly charges of the same merchant, an abandoned one and irregular ones
+	charge := func(name string, amount float64, createdAt time.Time) {
+		db.Create(&models.Expense{
+			Model: gorm.Model{CreatedAt: createdAt}, AccountID: account.ID, Name: name, Amount: amount,
+			Type: models.ExpenseTypeVariable, Active: true, Category: "Lazer",
+		})
+	}
+	charge("Streaming", 39.9, date(2030, 3, 20))
+	charge("streaming ", 39.9, date(2030, 4, 20))
+	charge("Streaming", 44.9, date(2030, 5, 20))
+	charge("Jornal", 9.9, date(2030, 1, 15))
+	charge("Jornal", 9.9, date(2030, 2, 15))
+	charge("Padaria", 15, date(2030, 5, 2))
+	charge("Padaria", 22, date(2030, 5, 12))
+
+	subscriptions := DetectSubscriptions([]uint{account.ID}, now)
+
+	byName := make(map[string]DetectedSubscription)
+	for _, sub := range subscriptions {
+		byName[sub.Name] = sub
+	}
+	if len(subscriptions) != 3 {
+		t.Fatalf("expected 3 subscriptions, got %d: %+v", len(subscriptions), byName)
+	}
+
+	tests := []struct {
+		name          string
+		source        SubscriptionSource
+		monthlyCost   float64
+		annualCost    float64
+		priceIncrease float64
+		nextCharge    time.Time
+	}{
+		{"Streaming", SubscriptionSourceCharges, 44.9, 538.8, 5, date(2030, 6, 20)},
+		{"Música", SubscriptionSourceFixedExpense, 24.9, 298.8, 5, date(2030, 7, 5)},
+		{"Antivírus", SubscriptionSourceRecurring, 10, 120, 0, date(2030, 9, 1)},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := byName[tt.name]
+			if !ok {
+				t.Fatalf("subscription %s not detected", tt.name)
+			}
+			if subscriptions[i].Name != tt.name {
+				t.Errorf("position %d = %s, want %s (sorted by monthly cost)", i, subscriptions[i].Name, tt.name)
+			}
+			if got.Source != tt.source {
+				t.Errorf("source = %s, want %s", got.Source, tt.source)
+			}
+			if math.Abs(got.MonthlyCost-tt.monthlyCost) > 0.01 || math.Abs(got.AnnualCost-tt.annualCost) > 0.01 {
+				t.Errorf("monthly/annual = %.2f/%.2f, want %.2f/%.2f", got.MonthlyCost, got.AnnualCost, tt.monthlyCost, tt.annualCost)
+			}
+			if math.Abs(got.PriceIncrease-tt.priceIncrease) > 0.01 {
+				t.Errorf("price increase = %.2f, want %.2f", got.PriceIncrease, tt.priceIncrease)
+			}
+			if got.NextCharge.Format("2006-01-02") != tt.nextCharge.Format("2006-01-02") {
+				t.Errorf("next charge = %s, want %s", got.NextCharge.Format("2006-01-02"), tt.nextCharge.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestSubscriptionService_UnconfirmedFixedExpenses(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	now := time.Date(2030, 6, 10, 10, 0, 0, 0, time.Local)
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Pessoal", models.AccountTypeIndividual, user.ID, nil)
+
+	// School fees and health insurance are fixed expenses, not subscriptions
+	db.Create(&models.Expense{AccountID: account.ID, Name: "Escola", Amount: 1500, Type: models.ExpenseTypeFixed, DueDay: 5, Active: true, Category: "Educação"})
+	db.Create(&models.Expense{AccountID: account.ID, Name: "Plano de saúde", Amount: 800, Type: models.ExpenseTypeFixed, DueDay: 15, Active: true, Category: "Saúde"})
+	db.Create(&models.Expense{AccountID: account.ID, Name: "Netflix", Amount: 55.9, Type: models.ExpenseTypeFixed, DueDay: 20, Active: true, Category: "Serviços"})
+
+	service := NewSubscriptionService()
+	overview := service.GetOverview([]uint{account.ID}, now)
+	confirmed := make(map[string]bool)
+	for _, sub := range overview.Subscriptions {
+		confirmed[sub.Name] = sub.Confirmed
+	}
+	if len(confirmed) != 3 || confirmed["Escola"] || confirmed["Plano de saúde"] || !confirmed["Netflix"] {
+		t.Fatalf("confirmed = %v, want only Netflix confirmed", confirmed)
+	}
+	if math.Abs(overview.MonthlyTotal-55.9) > 0.01 || overview.Unconfirmed != 2 {
+		t.Errorf("monthly total/unconfirmed = %.2f/%d, want 55.90/2", overview.MonthlyTotal, overview.Unconfirmed)
+	}
+
+	// The user confirms the health insurance as a subscription
+	if _, err := service.SetStatus(user.ID, account.ID, "Plano de saúde", models.SubscriptionStatusActive, 0); err != nil {
+		t.Fatalf("SetStatus() error = %v", err)
+	}
+	overview = service.GetOverview([]uint{account.ID}, now)
+	if math.Abs(overview.MonthlyTotal-855.9) > 0.01 || overview.Unconfirmed != 1 {
+		t.Errorf("after confirming, monthly total/unconfirmed = %.2f/%d, want 855.90/1", overview.MonthlyTotal, overview.Unconfirmed)
+	}
+}
+
+func TestSubscriptionService_CancelReminder(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	other := testutil.CreateTestUser(db, "other@example.com", "Other User", "hash")
+	account := testutil.CreateTestAccount(db, "Pessoal", models.AccountTypeIndividual, user.ID, nil)
+
+	now := time.Now()
+	due := now.AddDate(0, 0, 2)
+	db.Create(&models.Expense{AccountID: account.ID, Name: "Streaming", Amount: 39.9, Type: models.ExpenseTypeFixed, DueDay: due.Day(), Active: true, Category: "Lazer"})
+
+	service := NewSubscriptionService()
+	if _, err := service.SetStatus(other.ID, account.ID, "Streaming", models.SubscriptionStatusToCancel, 3); err != ErrUnauthorized {
+		t.Errorf("SetStatus() by another user error = %v, want %v", err, ErrUnauthorized)
+	}
+	if _, err := service.SetStatus(user.ID, account.ID, "Streaming", "paused", 3); err != ErrInvalidSubscriptionStatus {
+		t.Errorf("SetStatus() with invalid status error = %v, want %v", err, ErrInvalidSubscriptionStatus)
+	}
+	if _, err := service.SetStatus(user.ID, account.ID, "streaming", models.SubscriptionStatusToCancel, 3); err != nil {
+		t.Fatalf("SetStatus() error = %v", err)
+	}
+
+	overview := service.GetOverview([]uint{account.ID}, now)
+	if len(overview.Subscriptions) != 1 || overview.Subscriptions[0].Status != models.SubscriptionStatusToCancel {
+		t.Fatalf("expected the subscription marked to cancel, got %+v", overview.Subscriptions)
+	}
+	if math.Abs(overview.ToCancelAnnual-478.8) > 0.01 {
+		t.Errorf("to cancel annual = %.2f, want 478.80", overview.ToCancelAnnual)
+	}
+
+	// Runs twice: the reminder is sent once per charge
+	for i := 0; i < 2; i++ {
+		if err := service.sendCancelReminders(now); err != nil {
+			t.Fatalf("sendCancelReminders() error = %v", err)
+		}
+	}
+
+	var count int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND link = ?", user.ID, "/subscriptions").Count(&count)
+	if count != 1 {
+		t.Errorf("expected 1 reminder, got %d", count)
+	}
+}
+
+func TestSubscriptionService_CancelReminder_Collapsed(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Pessoal", models.AccountTypeIndividual, user.ID, nil)
+
+	now := time.Now()
+	due := now.AddDate(0, 0, 2)
+	service := NewSubscriptionService()
+	for _, name := range []string{"Streaming", "Música"} {
+		db.Create(&models.Expense{AccountID: account.ID, Name: name, Amount: 29.9, Type: models.ExpenseTypeFixed, DueDay: due.Day(), Active: true, Category: "Lazer"})
+		if _, err := service.SetStatus(user.ID, account.ID, name, models.SubscriptionStatusToCancel, 3); err != nil {
+			t.Fatalf("SetStatus(%s) error = %v", name, err)
+		}
+	}
+
+	if err := service.sendCancelReminders(now); err != nil {
+		t.Fatalf("sendCancelReminders() error = %v", err)
+	}
+
+	// Both reminders merge into one with a title about cancel reminders, not due dates
+	var notifications []models.Notification
+	db.Where("user_id = ?", user.ID).Find(&notifications)
+	if len(notifications) != 1 {
+		t.Fatalf("expected 1 collapsed reminder, got %d", len(notifications))
+	}
+	if notifications[0].Type != models.NotificationTypeSubscription || notifications[0].Title != "2 lembretes de cancelamento" {
+		t.Errorf("reminder = %s %q, want %s %q", notifications[0].Type, notifications[0].Title, models.NotificationTypeSubscription, "2 lembretes de cancelamento")
+	}
+}
+
+func TestSubscriptionService_CancelReminder_ReturnsFailures(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Test User", "hash")
+	account := testutil.CreateTestAccount(db, "Pessoal", models.AccountTypeIndividual, user.ID, nil)
+
+	now := time.Now()
+	due := now.AddDate(0, 0, 2)
+	db.Create(&models.Expense{AccountID: account.ID, Name: "Streaming", Amount: 39.9, Type: models.ExpenseTypeFixed, DueDay: due.Day(), Active: true, Category: "Lazer"})
+
+	service := NewSubscriptionService()
+	if _, err := service.SetStatus(user.ID, account.ID, "Streaming", models.SubscriptionStatusToCancel, 3); err != nil {
+		t.Fatalf("SetStatus() error = %v", err)
+	}
+
+	// Without the notifications table the reminder can't be stored
+	db.Migrator().DropTable(&models.Notification{})
+	if err := service.sendCancelReminders(now); err == nil {
+		t.Fatal("sendCancelReminders() error = nil, want the failed reminder")
+	}
+
+	// The key was released, so the retry sends the reminder
+	db.AutoMigrate(&models.Notification{})
+	if err := service.sendCancelReminders(now); err != nil {
+		t.Fatalf("sendCancelReminders() retry error = %v", err)
+	}
+	var count int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND link = ?", user.ID, "/subscriptions").Count(&count)
+	if count != 1 {
+		t.Errorf("expected 1 reminder after the retry, got %d", count)
+	}
+}
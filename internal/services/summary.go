@@ -108,11 +108,16 @@ func GetRevenue12Months(db *gorm.DB) float64 {
 
 // GetRevenue12MonthsForAccounts retorna o faturamento bruto dos últimos 12 meses para contas específicas
 func GetRevenue12MonthsForAccounts(db *gorm.DB, accountIDs []uint) float64 {
+	return GetRevenue12MonthsForAccountsAt(db, accountIDs, time.Now())
+}
+
+// GetRevenue12MonthsForAccountsAt retorna o faturamento bruto dos 12 meses anteriores a uma data de referência
+func GetRevenue12MonthsForAccountsAt(db *gorm.DB, accountIDs []uint, refDate time.Time) float64 {
 	if len(accountIDs) == 0 {
 		return 0
 	}
 
-	endDate := time.Now()
+	endDate := refDate
 	startDate := endDate.AddDate(-1, 0, 0)
 
 	var total float64
@@ -207,6 +212,124 @@ func GetMemberContributions(db *gorm.DB, groupID uint, accountIDs []uint) []Memb
 	return contributions
 }
 
+// summaryEntryKind identifies which summary total an entry counts in
+type summaryEntryKind int
+
+const (
+	summaryEntryIncome summaryEntryKind = iota
+	summaryEntryFixed
+	summaryEntryVariable
+	summaryEntryInstallment
+	summaryEntryBill
+)
+
+// summaryEntry is a single amount counted by the monthly summaries, dated inside the
+// month it counts in. Incomes carry the net amount in Amount.
+type summaryEntry struct {
+	Kind      summaryEntryKind
+	AccountID uint
+	ExpenseID uint // Set for fixed and variable expenses
+	Date      time.Time
+	Category  string
+	Name      string // Expense, bill or installment name, or the income description
+	Amount    float64
+	Gross     float64
+	Tax       float64
+}
+
+// loadSummaryEntries fetches every income and expense of the accounts in [from, to) with
+// the same batch queries used by GetBatchMonthlySummariesForAccounts.
+//
+// BATCH QUERY OPTIMIZATION: 5 queries totais ao invés de N*5 queries
+// Ao invés de executar 5 queries por mês (incomes, fixed, variable, cards, bills),
+// executamos apenas 1 query de cada tipo para TODO o range de datas.
+// Os dados são então distribuídos em memória por quem chama.
+//
+// Exemplo: Para 6 meses, ao invés de 30 queries (6 meses × 5 tipos),
+// executamos apenas 5 queries totais.
+func loadSummaryEntries(db *gorm.DB, from, to time.Time, accountIDs []uint) []summaryEntry {
+	var entries []summaryEntry
+	if len(accountIDs) == 0 || !from.Before(to) {
+		return entries
+	}
+
+	// Batch query 1: Fetch ALL incomes for the entire date range in a single query
+	// Instead of: SELECT * FROM incomes WHERE date BETWEEN month1 (6 queries for 6 months)
+	// We do: SELECT * FROM incomes WHERE date BETWEEN startDate AND endDate (1 query)
+	var incomes []models.Income
+	db.Where("date >= ? AND date < ? AND account_id IN ?", from, to, accountIDs).Find(&incomes)
+	for _, i := range incomes {
+		entries = append(entries, summaryEntry{
+			Kind: summaryEntryIncome, AccountID: i.AccountID, Date: i.Date, Name: i.Description,
+			Amount: i.NetAmount, Gross: i.GrossAmount, Tax: i.TaxAmount,
+		})
+	}
+
+	// Batch query 2: Fetch ALL fixed expenses once and apply to all months
+	// Fixed expenses are recurring monthly, so we fetch once and date them on their due day
+	// of every month in the range
+	var fixedExpenses []models.Expense
+	db.Where("type = ? AND active = ? AND account_id IN ?", models.ExpenseTypeFixed, true, accountIDs).Find(&fixedExpenses)
+	for month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.Local); month.Before(to); month = month.AddDate(0, 1, 0) {
+		for _, e := range fixedExpenses {
+			date := dayInMonth(month, e.DueDay)
+			if date.Before(from) || !date.Before(to) {
+				continue
+			}
+			entries = append(entries, summaryEntry{
+				Kind: summaryEntryFixed, AccountID: e.AccountID, ExpenseID: e.ID, Date: date,
+				Category: e.Category, Name: e.Name, Amount: e.Amount,
+			})
+		}
+	}
+
+	// Batch query 3: Fetch ALL variable expenses for the entire date range in a single query
+	// Variable expenses are month-specific, so we distribute based on created_at
+	var variableExpenses []models.Expense
+	db.Where("type = ? AND active = ? AND created_at >= ? AND created_at < ? AND account_id IN ?",
+		models.ExpenseTypeVariable, true, from, to, accountIDs).Find(&variableExpenses)
+	for _, e := range variableExpenses {
+		entries = append(entries, summaryEntry{
+			Kind: summaryEntryVariable, AccountID: e.AccountID, ExpenseID: e.ID, Date: e.CreatedAt,
+			Category: e.Category, Name: e.Name, Amount: e.Amount,
+		})
+	}
+
+	// Batch query 4: Fetch ALL credit cards with installments in a single query (with preload)
+	// Installments can span multiple months, so we calculate which months they affect
+	// and distribute the amounts accordingly
+	var creditCards []models.CreditCard
+	db.Where("account_id IN ?", accountIDs).Preload("Installments").Find(&creditCards)
+	for _, card := range creditCards {
+		for _, inst := range card.Installments {
+			// Calculate which months this installment affects based on start date and duration
+			installmentMonth := inst.StartDate
+			for i := 1; i <= inst.TotalInstallments; i++ {
+				if !installmentMonth.Before(from) && installmentMonth.Before(to) {
+					entries = append(entries, summaryEntry{
+						Kind: summaryEntryInstallment, AccountID: card.AccountID, Date: installmentMonth,
+						Category: inst.Category, Name: inst.Description, Amount: inst.InstallmentAmount,
+					})
+				}
+				installmentMonth = installmentMonth.AddDate(0, 1, 0)
+			}
+		}
+	}
+
+	// Batch query 5: Fetch ALL bills for the entire date range in a single query
+	// Bills are distributed to their respective months based on due_date
+	var bills []models.Bill
+	db.Where("due_date >= ? AND due_date < ? AND account_id IN ?", from, to, accountIDs).Find(&bills)
+	for _, b := range bills {
+		entries = append(entries, summaryEntry{
+			Kind: summaryEntryBill, AccountID: b.AccountID, Date: b.DueDate,
+			Category: b.Category, Name: b.Name, Amount: b.Amount,
+		})
+	}
+
+	return entries
+}
+
 // GetBatchMonthlySummariesForAccounts retorna resumos mensais para múltiplos meses em uma única operação batch.
 //
 // BATCH OPTIMIZATION:
@@ -261,88 +384,28 @@ func GetBatchMonthlySummariesForAccounts(db *gorm.DB, startYear, startMonth, end
 		return result
 	}
 
-	// ============================================================================
-	// BATCH QUERY OPTIMIZATION: 5 queries totais ao invés de N*5 queries
-	// ============================================================================
-	// Ao invés de executar 5 queries por mês (incomes, fixed, variable, cards, bills),
-	// executamos apenas 1 query de cada tipo para TODO o range de meses.
-	// Os dados são então distribuídos nos meses corretos em memória.
-	//
-	// Exemplo: Para 6 meses, ao invés de 30 queries (6 meses × 5 tipos),
-	// executamos apenas 5 queries totais.
-	// ============================================================================
-
-	// Batch query 1: Fetch ALL incomes for the entire date range in a single query
-	// Instead of: SELECT * FROM incomes WHERE date BETWEEN month1 (6 queries for 6 months)
-	// We do: SELECT * FROM incomes WHERE date BETWEEN startDate AND endDate (1 query)
-	var incomes []models.Income
-	db.Where("date BETWEEN ? AND ? AND account_id IN ?", rangeStartDate, rangeEndDate, accountIDs).Find(&incomes)
-	for _, i := range incomes {
-		key := i.Date.Format("2006-01")
-		if summary, exists := summaryMap[key]; exists {
-			summary.TotalIncomeGross += i.GrossAmount
-			summary.TotalIncomeNet += i.NetAmount
-			summary.TotalTax += i.TaxAmount
-		}
-	}
-
-	// Batch query 2: Fetch ALL fixed expenses once and apply to all months
-	// Fixed expenses are recurring monthly, so we fetch once and multiply across months
-	var fixedExpenses []models.Expense
-	db.Where("type = ? AND active = ? AND account_id IN ?", models.ExpenseTypeFixed, true, accountIDs).Find(&fixedExpenses)
-	// Fixed expenses apply to all months in the range
-	for key := range summaryMap {
-		for _, e := range fixedExpenses {
-			summaryMap[key].TotalFixed += e.Amount
+	// loadSummaryEntries batches the whole range; entries are distributed by month key
+	for _, entry := range loadSummaryEntries(db, rangeStartDate, rangeEndDate.Add(time.Second), accountIDs) {
+		summary, exists := summaryMap[entry.Date.Format("2006-01")]
+		if !exists {
+			continue
 		}
-	}
-
-	// Batch query 3: Fetch ALL variable expenses for the entire date range in a single query
-	// Variable expenses are month-specific, so we distribute based on created_at
-	var variableExpenses []models.Expense
-	db.Where("type = ? AND active = ? AND created_at BETWEEN ? AND ? AND account_id IN ?",
-		models.ExpenseTypeVariable, true, rangeStartDate, rangeEndDate, accountIDs).Find(&variableExpenses)
-	for _, e := range variableExpenses {
-		key := e.CreatedAt.Format("2006-01")
-		if summary, exists := summaryMap[key]; exists {
-			summary.TotalVariable += e.Amount
+		switch entry.Kind {
+		case summaryEntryIncome:
+			summary.TotalIncomeGross += entry.Gross
+			summary.TotalIncomeNet += entry.Amount
+			summary.TotalTax += entry.Tax
+		case summaryEntryFixed:
+			summary.TotalFixed += entry.Amount
+		case summaryEntryVariable:
+			summary.TotalVariable += entry.Amount
+		case summaryEntryInstallment:
+			summary.TotalCards += entry.Amount
+		case summaryEntryBill:
+			summary.TotalBills += entry.Amount
 		}
 	}
 
-	// Batch query 4: Fetch ALL credit cards with installments in a single query (with preload)
-	// Installments can span multiple months, so we calculate which months they affect
-	// and distribute the amounts accordingly
-	var creditCards []models.CreditCard
-	db.Where("account_id IN ?", accountIDs).Preload("Installments").Find(&creditCards)
-	for _, card := range creditCards {
-		for _, inst := range card.Installments {
-			// Calculate which months this installment affects based on start date and duration
-			installmentMonth := inst.StartDate
-			for i := 1; i <= inst.TotalInstallments; i++ {
-				key := installmentMonth.Format("2006-01")
-				if summary, exists := summaryMap[key]; exists {
-					summary.TotalCards += inst.InstallmentAmount
-				}
-				installmentMonth = installmentMonth.AddDate(0, 1, 0)
-			}
-		}
-	}
-
-	// Batch query 5: Fetch ALL bills for the entire date range in a single query
-	// Bills are distributed to their respective months based on due_date
-	var bills []models.Bill
-	db.Where("due_date BETWEEN ? AND ? AND account_id IN ?", rangeStartDate, rangeEndDate, accountIDs).Find(&bills)
-	for _, b := range bills {
-		key := b.DueDate.Format("2006-01")
-		if summary, exists := summaryMap[key]; exists {
-			summary.TotalBills += b.Amount
-		}
-	}
-
-	// ============================================================================
-	// END OF BATCH QUERIES
-	// ============================================================================
-
 	// Calculate totals and build result slice
 	result := make([]MonthlySummary, 0, len(summaryMap))
 	for _, summary := range summaryMap {
@@ -0,0 +1,449 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/i18n"
+	"poc-finance/internal/models"
+)
+
+var (
+	ErrInvalidSummarySchedule = errors.New("agendamento de resumo inválido")
+	ErrInvalidSummaryPeriod   = errors.New("período de resumo inválido")
+)
+
+// Default schedule for groups that never configured one: weekly on Mondays and
+// monthly on the 1st, both at 8h
+const (
+	DefaultSummaryWeeklyDay  = int(time.Monday)
+	DefaultSummaryMonthlyDay = 1
+	DefaultSummaryHour       = 8
+)
+
+// SummaryScheduleService builds periodic summaries of groups and users and delivers
+// them automatically, in-app and by email, following each member's preferences
+type SummaryScheduleService struct {
+	groupService        *GroupService
+	accountService      *AccountService
+	notificationService *NotificationService
+	emailService        *EmailService
+	baseURL             string
+}
+
+func NewSummaryScheduleService() *SummaryScheduleService {
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	return &SummaryScheduleService{
+		groupService:        NewGroupService(),
+		accountService:      NewAccountService(),
+		notificationService: NewNotificationService(),
+		emailService:        NewEmailService(),
+		baseURL:             baseURL,
+	}
+}
+
+// DefaultSummarySchedule returns the schedule used by groups without a saved one
+func DefaultSummarySchedule(groupID *uint) models.SummarySchedule {
+	return models.SummarySchedule{
+		GroupID:        groupID,
+		WeeklyEnabled:  groupID != nil,
+		WeeklyDay:      DefaultSummaryWeeklyDay,
+		MonthlyEnabled: groupID != nil,
+		MonthlyDay:     DefaultSummaryMonthlyDay,
+		Hour:           DefaultSummaryHour,
+	}
+}
+
+func validateSummarySchedule(schedule *models.SummarySchedule) error {
+	if schedule.WeeklyDay < 0 || schedule.WeeklyDay > 6 ||
+		schedule.MonthlyDay < 1 || schedule.MonthlyDay > 28 ||
+		schedule.Hour < 0 || schedule.Hour > 23 {
+		return ErrInvalidSummarySchedule
+	}
+	return nil
+}
+
+func findSummarySchedule(userID uint, groupID *uint) (*models.SummarySchedule, error) {
+	var schedule models.SummarySchedule
+	query := database.DB
+	if groupID != nil {
+		query = query.Where("group_id = ?", *groupID)
+	} else {
+		query = query.Where("user_id = ? AND group_id IS NULL", userID)
+	}
+	if err := query.First(&schedule).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// GetGroupSchedule returns the summary schedule of a group, or the default one
+func (s *SummaryScheduleService) GetGroupSchedule(groupID, userID uint) (*models.SummarySchedule, error) {
+	if !s.groupService.IsGroupMember(groupID, userID) {
+		return nil, ErrUnauthorized
+	}
+	schedule, err := findSummarySchedule(userID, &groupID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		defaults := DefaultSummarySchedule(&groupID)
+		return &defaults, nil
+	}
+	return schedule, err
+}
+
+// SaveGroupSchedule updates when a group's summaries are sent. Only admins can change it.
+func (s *SummaryScheduleService) SaveGroupSchedule(groupID, userID uint, schedule models.SummarySchedule) (*models.SummarySchedule, error) {
+	if !s.groupService.IsGroupAdmin(groupID, userID) {
+		return nil, ErrUnauthorized
+	}
+	schedule.GroupID = &groupID
+	schedule.UserID = userID
+	return saveSummarySchedule(userID, &groupID, schedule)
+}
+
+// GetPersonalSchedule returns the user's personal summary schedule. Personal summaries
+// are opt-in, so the default has both frequencies disabled.
+func (s *SummaryScheduleService) GetPersonalSchedule(userID uint) (*models.SummarySchedule, error) {
+	schedule, err := findSummarySchedule(userID, nil)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		defaults := DefaultSummarySchedule(nil)
+		defaults.UserID = userID
+		return &defaults, nil
+	}
+	return schedule, err
+}
+
+// SavePersonalSchedule updates when the user's personal summaries are sent
+func (s *SummaryScheduleService) SavePersonalSchedule(userID uint, schedule models.SummarySchedule) (*models.SummarySchedule, error) {
+	schedule.GroupID = nil
+	schedule.UserID = userID
+	return saveSummarySchedule(userID, nil, schedule)
+}
+
+func saveSummarySchedule(userID uint, groupID *uint, schedule models.SummarySchedule) (*models.SummarySchedule, error) {
+	if err := validateSummarySchedule(&schedule); err != nil {
+		return nil, err
+	}
+
+	existing, err := findSummarySchedule(userID, groupID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		schedule.Model = gorm.Model{}
+		if err := database.DB.Create(&schedule).Error; err != nil {
+			return nil, err
+		}
+		return &schedule, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	existing.UserID = schedule.UserID
+	existing.WeeklyEnabled = schedule.WeeklyEnabled
+	existing.WeeklyDay = schedule.WeeklyDay
+	existing.MonthlyEnabled = schedule.MonthlyEnabled
+	existing.MonthlyDay = schedule.MonthlyDay
+	existing.Hour = schedule.Hour
+	if err := database.DB.Save(existing).Error; err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// GetPreference returns how the user receives summaries of a group (or their personal
+// ones when groupID is nil). Without a saved preference summaries are in-app only.
+func (s *SummaryScheduleService) GetPreference(userID uint, groupID *uint) models.SummaryPreference {
+	var preference models.SummaryPreference
+	query := database.DB.Where("user_id = ?", userID)
+	if groupID != nil {
+		query = query.Where("group_id = ?", *groupID)
+	} else {
+		query = query.Where("group_id IS NULL")
+	}
+	if err := query.First(&preference).Error; err != nil {
+		return models.SummaryPreference{UserID: userID, GroupID: groupID, InApp: true}
+	}
+	return preference
+}
+
+// SavePreference opts the user in or out of each delivery channel
+func (s *SummaryScheduleService) SavePreference(userID uint, groupID *uint, inApp, email bool) (*models.SummaryPreference, error) {
+	if groupID != nil && !s.groupService.IsGroupMember(*groupID, userID) {
+		return nil, ErrUnauthorized
+	}
+
+	preference := s.GetPreference(userID, groupID)
+	preference.InApp = inApp
+	preference.Email = email
+	if err := database.DB.Save(&preference).Error; err != nil {
+		return nil, err
+	}
+	return &preference, nil
+}
+
+// summaryPeriod returns the [from, to) range and label of a summary. Scheduled summaries
+// cover the full days or month before the slot; manual ones cover the last seven days
+// including today, or the current month.
+func summaryPeriod(frequency models.SummaryFrequency, now time.Time, scheduled bool) (time.Time, time.Time, string, error) {
+	switch frequency {
+	case models.SummaryFrequencyWeekly:
+		to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		if !scheduled {
+			to = to.AddDate(0, 0, 1)
+		}
+		from := to.AddDate(0, 0, -7)
+		last := to.AddDate(0, 0, -1)
+		return from, to, fmt.Sprintf("Semana de %s a %s", from.Format("02/01"), last.Format("02/01")), nil
+	case models.SummaryFrequencyMonthly:
+		from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		if scheduled {
+			from = from.AddDate(0, -1, 0)
+		}
+		to := from.AddDate(0, 1, 0)
+		return from, to, fmt.Sprintf("%s de %d", i18n.MonthNames[from.Month()], from.Year()), nil
+	}
+	return time.Time{}, time.Time{}, "", ErrInvalidSummaryPeriod
+}
+
+// summarizeEntries totals the incomes and expenses of the accounts in [from, to),
+// counting expenses the same way as the monthly summaries
+func summarizeEntries(accountIDs []uint, from, to time.Time) GroupSummaryData {
+	var data GroupSummaryData
+	for _, entry := range loadSummaryEntries(database.DB, from, to, accountIDs) {
+		if entry.Kind == summaryEntryIncome {
+			data.TotalIncome += entry.Amount
+			data.IncomeCount++
+		} else {
+			data.TotalExpenses += entry.Amount
+			data.ExpenseCount++
+		}
+	}
+	data.TotalIncome = math.Round(data.TotalIncome*100) / 100
+	data.TotalExpenses = math.Round(data.TotalExpenses*100) / 100
+	data.Balance = math.Round((data.TotalIncome-data.TotalExpenses)*100) / 100
+	return data
+}
+
+// BuildGroupSummary computes the summary of a group's joint accounts for the period of
+// the given frequency ending at now
+func (s *SummaryScheduleService) BuildGroupSummary(groupID uint, frequency models.SummaryFrequency, now time.Time, scheduled bool) (GroupSummaryData, error) {
+	from, to, label, err := summaryPeriod(frequency, now, scheduled)
+	if err != nil {
+		return GroupSummaryData{}, err
+	}
+	group, err := s.groupService.GetGroupByID(groupID)
+	if err != nil {
+		return GroupSummaryData{}, err
+	}
+	accountIDs, _ := s.accountService.GetGroupJointAccountIDs(groupID)
+
+	data := summarizeEntries(accountIDs, from, to)
+	data.GroupName = group.Name
+	data.GroupID = group.ID
+	data.PeriodType = string(frequency)
+	data.PeriodLabel = label
+
+	var goals []models.GroupGoal
+	database.DB.Where("group_id = ? AND status = ?", groupID, models.GoalStatusActive).Order("target_date ASC").Find(&goals)
+	for _, goal := range goals {
+		progress := GoalProgress{Name: goal.Name, CurrentAmount: goal.CurrentAmount, TargetAmount: goal.TargetAmount}
+		if goal.TargetAmount > 0 {
+			progress.Percentage = math.Round(goal.CurrentAmount/goal.TargetAmount*1000) / 10
+		}
+		data.GoalsProgress = append(data.GoalsProgress, progress)
+	}
+	return data, nil
+}
+
+// BuildPersonalSummary computes the summary of every account the user can access
+func (s *SummaryScheduleService) BuildPersonalSummary(userID uint, frequency models.SummaryFrequency, now time.Time, scheduled bool) (GroupSummaryData, error) {
+	from, to, label, err := summaryPeriod(frequency, now, scheduled)
+	if err != nil {
+		return GroupSummaryData{}, err
+	}
+	accountIDs, err := s.accountService.GetUserAccountIDs(userID)
+	if err != nil {
+		return GroupSummaryData{}, err
+	}
+
+	data := summarizeEntries(accountIDs, from, to)
+	data.GroupName = "Pessoal"
+	data.PeriodType = string(frequency)
+	data.PeriodLabel = label
+	return data, nil
+}
+
+// SendGroupSummary builds a group summary and delivers it to the members who opted in.
+// Email failures are logged so that one bad address doesn't stop the other deliveries.
+func (s *SummaryScheduleService) SendGroupSummary(groupID uint, frequency models.SummaryFrequency, now time.Time, scheduled bool) error {
+	return s.sendGroupSummary(groupID, frequency, now, scheduled, "")
+}
+
+// sendGroupSummary delivers the group summary to every member. When key is set, each
+// member's delivery is claimed with its own idempotency key, so a retry only reaches the
+// members that didn't get the summary yet.
+func (s *SummaryScheduleService) sendGroupSummary(groupID uint, frequency models.SummaryFrequency, now time.Time, scheduled bool, key string) error {
+	data, err := s.BuildGroupSummary(groupID, frequency, now, scheduled)
+	if err != nil {
+		return err
+	}
+	members, err := s.groupService.GetGroupMembers(groupID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	link := fmt.Sprintf("%s/groups/%d/dashboard", s.baseURL, groupID)
+	for _, member := range members {
+		if err := s.deliverOnce(key, member, &groupID, frequency, data, link); err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", member.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SendPersonalSummary builds the user's personal summary and delivers it following their preference
+func (s *SummaryScheduleService) SendPersonalSummary(userID uint, frequency models.SummaryFrequency, now time.Time, scheduled bool) error {
+	return s.sendPersonalSummary(userID, frequency, now, scheduled, "")
+}
+
+func (s *SummaryScheduleService) sendPersonalSummary(userID uint, frequency models.SummaryFrequency, now time.Time, scheduled bool, key string) error {
+	data, err := s.BuildPersonalSummary(userID, frequency, now, scheduled)
+	if err != nil {
+		return err
+	}
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return err
+	}
+	return s.deliverOnce(key, user, nil, frequency, data, s.baseURL+"/")
+}
+
+// deliverOnce delivers a summary to a user following their preference for the group (or
+// their personal one when groupID is nil). When a key is given, the in-app notification and
+// the email each claim their own idempotency key derived from it, released if they fail,
+// so a retry of the run repeats only what failed.
+func (s *SummaryScheduleService) deliverOnce(key string, user models.User, groupID *uint, frequency models.SummaryFrequency, data GroupSummaryData, link string) error {
+	inAppKey, emailKey := "", ""
+	if key != "" {
+		inAppKey = fmt.Sprintf("%s:user:%d", key, user.ID)
+		emailKey = inAppKey + ":email"
+	}
+
+	preference := s.GetPreference(user.ID, groupID)
+	var errs []error
+	if preference.InApp {
+		err := claimOnce(inAppKey, func() error {
+			switch {
+			case groupID == nil:
+				return s.notificationService.NotifyPersonalSummary(data, user.ID)
+			case frequency == models.SummaryFrequencyMonthly:
+				return s.notificationService.NotifyMonthlySummary(data, []models.User{user})
+			default:
+				return s.notificationService.NotifyWeeklySummary(data, []models.User{user})
+			}
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("in-app: %w", err))
+		}
+	}
+	if preference.Email && s.emailService.IsConfigured() {
+		err := claimOnce(emailKey, func() error {
+			return s.emailService.SendSummaryEmail(user.Email, user.Name, data, link)
+		})
+		if err != nil {
+			log.Printf("Error emailing %s summary to user %d: %v", data.PeriodType, user.ID, err)
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// claimOnce runs deliver unless key was already claimed, releasing the key if deliver
+// fails. An empty key runs deliver unconditionally.
+func claimOnce(key string, deliver func() error) error {
+	if key == "" {
+		return deliver()
+	}
+	claimed, err := ClaimIdempotencyKey(database.DB, JobScheduledSummaries, key)
+	if err != nil || !claimed {
+		return err
+	}
+	if err := deliver(); err != nil {
+		if releaseErr := ReleaseIdempotencyKey(database.DB, key); releaseErr != nil {
+			return errors.Join(err, fmt.Errorf("release key %s: %w", key, releaseErr))
+		}
+		return err
+	}
+	return nil
+}
+
+// SendDueSummaries is the scheduled job: it sends every group and personal summary whose
+// latest slot has passed and wasn't sent yet. It runs hourly, so each slot is claimed with
+// an idempotency key to deliver it exactly once.
+func (s *SummaryScheduleService) SendDueSummaries() error {
+	return s.sendDueSummaries(time.Now())
+}
+
+func (s *SummaryScheduleService) sendDueSummaries(now time.Time) error {
+	// Groups without a schedule get the default one, starting from now
+	var groups []models.FamilyGroup
+	if err := database.DB.Where("id NOT IN (?)",
+		database.DB.Model(&models.SummarySchedule{}).Select("group_id").Where("group_id IS NOT NULL")).
+		Find(&groups).Error; err != nil {
+		return fmt.Errorf("failed to fetch groups without a summary schedule: %w", err)
+	}
+	for _, group := range groups {
+		schedule := DefaultSummarySchedule(&group.ID)
+		schedule.UserID = group.CreatedByID
+		schedule.CreatedAt = now
+		if err := database.DB.Create(&schedule).Error; err != nil {
+			return fmt.Errorf("failed to create the summary schedule of group %d: %w", group.ID, err)
+		}
+	}
+
+	var schedules []models.SummarySchedule
+	if err := database.DB.Where("weekly_enabled = ? OR monthly_enabled = ?", true, true).Find(&schedules).Error; err != nil {
+		return fmt.Errorf("failed to fetch summary schedules: %w", err)
+	}
+
+	var errs []error
+	for _, schedule := range schedules {
+		for _, frequency := range []models.SummaryFrequency{models.SummaryFrequencyWeekly, models.SummaryFrequencyMonthly} {
+			if !schedule.Enabled(frequency) {
+				continue
+			}
+			slot := schedule.LastSlot(frequency, now)
+			if slot.Before(schedule.CreatedAt) {
+				continue
+			}
+
+			owner := fmt.Sprintf("user:%d", schedule.UserID)
+			if schedule.GroupID != nil {
+				owner = fmt.Sprintf("group:%d", *schedule.GroupID)
+			}
+			// Each recipient's delivery is claimed on its own, so a failed run is retried
+			// for the recipients that didn't get the summary only
+			key := fmt.Sprintf("summary:%s:%s:%s", owner, frequency, slot.Format("2006-01-02T15"))
+			var err error
+			if schedule.GroupID != nil {
+				err = s.sendGroupSummary(*schedule.GroupID, frequency, slot, true, key)
+			} else {
+				err = s.sendPersonalSummary(schedule.UserID, frequency, slot, true, key)
+			}
+			if err != nil {
+				log.Printf("Error sending %s summary of %s: %v", frequency, owner, err)
+				errs = append(errs, fmt.Errorf("%s summary of %s: %w", frequency, owner, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+func TestSummarySchedule_LastSlot(t *testing.T) {
+	schedule := models.SummarySchedule{WeeklyDay: int(time.Monday), MonthlyDay: 5, Hour: 8}
+
+	tests := []struct {
+		name      string
+		frequency models.SummaryFrequency
+		now       time.Time
+		want      time.Time
+	}{
+		{"weekly on the slot", models.SummaryFrequencyWeekly, time.Date(2030, 1, 7, 8, 0, 0, 0, time.Local), time.Date(2030, 1, 7, 8, 0, 0, 0, time.Local)},
+		{"weekly before the hour", models.SummaryFrequencyWeekly, time.Date(2030, 1, 7, 7, 59, 0, 0, time.Local), time.Date(2029, 12, 31, 8, 0, 0, 0, time.Local)},
+		{"weekly later in the week", models.SummaryFrequencyWeekly, time.Date(2030, 1, 12, 20, 0, 0, 0, time.Local), time.Date(2030, 1, 7, 8, 0, 0, 0, time.Local)},
+		{"monthly after the day", models.SummaryFrequencyMonthly, time.Date(2030, 3, 20, 0, 0, 0, 0, time.Local), time.Date(2030, 3, 5, 8, 0, 0, 0, time.Local)},
+		{"monthly before the day", models.SummaryFrequencyMonthly, time.Date(2030, 3, 4, 12, 0, 0, 0, time.Local), time.Date(2030, 2, 5, 8, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schedule.LastSlot(tt.frequency, tt.now); !got.Equal(tt.want) {
+				t.Errorf("LastSlot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummaryScheduleService_SendDueSummaries(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	admin := testutil.CreateTestUser(db, "admin@example.com", "Ana", "hash")
+	member := testutil.CreateTestUser(db, "member@example.com", "Bruno", "hash")
+	group := testutil.CreateTestGroup(db, "Família", admin.ID)
+	testutil.CreateTestGroupMember(db, group.ID, admin.ID, "admin")
+	testutil.CreateTestGroupMember(db, group.ID, member.ID, "member")
+	joint := testutil.CreateTestAccount(db, "Conjunta", models.AccountTypeJoint, admin.ID, &group.ID)
+	personal := testutil.CreateTestAccount(db, "Pessoal", models.AccountTypeIndividual, member.ID, nil)
+
+	// Week of Monday, January 7th 2030
+	db.Create(&models.Income{AccountID: joint.ID, Date: time.Date(2030, 1, 2, 0, 0, 0, 0, time.Local), GrossAmount: 3000, NetAmount: 3000})
+	db.Create(&models.Expense{Model: gorm.Model{CreatedAt: time.Date(2030, 1, 4, 0, 0, 0, 0, time.Local)}, AccountID: joint.ID,
+		Name: "Mercado", Amount: 450, Type: models.ExpenseTypeVariable, Category: "Alimentação", Active: true})
+	db.Create(&models.Expense{Model: gorm.Model{CreatedAt: time.Date(2030, 1, 7, 0, 0, 0, 0, time.Local)}, AccountID: joint.ID,
+		Name: "Farmácia", Amount: 80, Type: models.ExpenseTypeVariable, Category: "Saúde", Active: true})
+	db.Create(&models.Income{AccountID: personal.ID, Date: time.Date(2029, 12, 10, 0, 0, 0, 0, time.Local), GrossAmount: 2000, NetAmount: 2000})
+
+	service := NewSummaryScheduleService()
+	created := time.Date(2029, 12, 1, 0, 0, 0, 0, time.Local)
+
+	// Members can't change the group schedule
+	if _, err := service.SaveGroupSchedule(group.ID, member.ID, DefaultSummarySchedule(&group.ID)); err != ErrUnauthorized {
+		t.Errorf("SaveGroupSchedule() by a member error = %v, want ErrUnauthorized", err)
+	}
+	invalid := DefaultSummarySchedule(&group.ID)
+	invalid.MonthlyDay = 31
+	if _, err := service.SaveGroupSchedule(group.ID, admin.ID, invalid); err != ErrInvalidSummarySchedule {
+		t.Errorf("SaveGroupSchedule() with day 31 error = %v, want ErrInvalidSummarySchedule", err)
+	}
+	if _, err := service.SaveGroupSchedule(group.ID, admin.ID, DefaultSummarySchedule(&group.ID)); err != nil {
+		t.Fatalf("SaveGroupSchedule() error = %v", err)
+	}
+	// The member opts out of in-app summaries; the personal summary is opt-in
+	if _, err := service.SavePreference(member.ID, &group.ID, false, false); err != nil {
+		t.Fatalf("SavePreference() error = %v", err)
+	}
+	if _, err := service.SavePersonalSchedule(member.ID, models.SummarySchedule{MonthlyEnabled: true, MonthlyDay: 1, Hour: 8, WeeklyDay: 1}); err != nil {
+		t.Fatalf("SavePersonalSchedule() error = %v", err)
+	}
+	db.Model(&models.SummarySchedule{}).Where("1 = 1").Update("created_at", created)
+	// A per-type preference left from before summaries had their own is ignored
+	db.Create(&models.NotificationPreference{UserID: admin.ID, Type: models.NotificationTypeSummary, Email: true})
+
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.Local)
+	if err := service.sendDueSummaries(now); err != nil {
+		t.Fatalf("sendDueSummaries() error = %v", err)
+	}
+	// Running again in the same hour doesn't send duplicates
+	if err := service.sendDueSummaries(now.Add(30 * time.Minute)); err != nil {
+		t.Fatalf("sendDueSummaries() error = %v", err)
+	}
+
+	var adminNotifications []models.Notification
+	db.Where("user_id = ?", admin.ID).Order("id").Find(&adminNotifications)
+	if len(adminNotifications) != 2 {
+		t.Fatalf("admin got %d notifications, want the weekly and monthly summaries", len(adminNotifications))
+	}
+	weekly := adminNotifications[0]
+	if weekly.Title != "Resumo semanal - Família" || weekly.Message != "Receitas: R$ 3000.00 | Despesas: R$ 450.00 | Saldo: +R$ 2550.00" {
+		t.Errorf("weekly summary = %q %q, want the week before Monday without Monday's expense", weekly.Title, weekly.Message)
+	}
+	if monthly := adminNotifications[1]; monthly.Title != "Resumo mensal - Família" || monthly.Message != "Dezembro de 2029: Receitas R$ 0.00 | Despesas R$ 0.00 | Saldo +R$ 0.00" {
+		t.Errorf("monthly summary = %q %q, want December 2029", monthly.Title, monthly.Message)
+	}
+
+	var queued int64
+	db.Model(&models.NotificationDelivery{}).Count(&queued)
+	if queued != 0 {
+		t.Errorf("queued %d notification deliveries, want summaries emailed only by their own preference", queued)
+	}
+
+	var memberNotifications []models.Notification
+	db.Where("user_id = ?", member.ID).Find(&memberNotifications)
+	if len(memberNotifications) != 1 || memberNotifications[0].Title != "Resumo mensal pessoal" ||
+		memberNotifications[0].Message != "Dezembro de 2029: Receitas R$ 2000.00 | Despesas R$ 0.00 | Saldo +R$ 2000.00" {
+		t.Errorf("member notifications = %+v, want only the personal monthly summary", memberNotifications)
+	}
+
+	// Groups without a schedule get the default one, without back-filling past slots
+	other := testutil.CreateTestGroup(db, "Amigos", admin.ID)
+	testutil.CreateTestGroupMember(db, other.ID, admin.ID, "admin")
+	if err := service.sendDueSummaries(now); err != nil {
+		t.Fatalf("sendDueSummaries() error = %v", err)
+	}
+	schedule, err := service.GetGroupSchedule(other.ID, admin.ID)
+	if err != nil || schedule.ID == 0 || !schedule.WeeklyEnabled {
+		t.Errorf("GetGroupSchedule() = %+v, %v, want a saved default schedule", schedule, err)
+	}
+	var count int64
+	db.Model(&models.Notification{}).Where("group_id = ?", other.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("new group got %d summaries, want none before its next slot", count)
+	}
+}
+
+func TestSummaryScheduleService_SendDueSummaries_RetriesOnlyMissingMembers(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	admin := testutil.CreateTestUser(db, "admin@example.com", "Ana", "hash")
+	member := testutil.CreateTestUser(db, "member@example.com", "Bruno", "hash")
+	group := testutil.CreateTestGroup(db, "Família", admin.ID)
+	testutil.CreateTestGroupMember(db, group.ID, admin.ID, "admin")
+	testutil.CreateTestGroupMember(db, group.ID, member.ID, "member")
+
+	service := NewSummaryScheduleService()
+	if _, err := service.SaveGroupSchedule(group.ID, admin.ID, DefaultSummarySchedule(&group.ID)); err != nil {
+		t.Fatalf("SaveGroupSchedule() error = %v", err)
+	}
+	db.Model(&models.SummarySchedule{}).Where("1 = 1").Update("created_at", time.Date(2029, 12, 1, 0, 0, 0, 0, time.Local))
+
+	// The member's notification can't be stored in the first run
+	db.Exec(fmt.Sprintf("CREATE TRIGGER fail_member_summary BEFORE INSERT ON notifications WHEN NEW.user_id = %d BEGIN SELECT RAISE(ABORT, 'falha'); END", member.ID))
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.Local)
+	if err := service.sendDueSummaries(now); err == nil {
+		t.Fatal("sendDueSummaries() error = nil, want the member's failed delivery")
+	}
+
+	db.Exec("DROP TRIGGER fail_member_summary")
+	if err := service.sendDueSummaries(now.Add(time.Hour)); err != nil {
+		t.Fatalf("sendDueSummaries() retry error = %v", err)
+	}
+
+	for _, user := range []*models.User{admin, member} {
+		var count int64
+		db.Model(&models.Notification{}).Where("user_id = ? AND title = ?", user.ID, "Resumo semanal - Família").Count(&count)
+		if count != 1 {
+			t.Errorf("user %s got %d weekly summaries, want 1", user.Name, count)
+		}
+	}
+}
+
+// switchableEmailTransport fails while failing is set and counts the emails sent otherwise
+type switchableEmailTransport struct {
+	failing bool
+	sent    int
+}
+
+func (t *switchableEmailTransport) Send(from string, to []string, subject, html string) error {
+	if t.failing {
+		return errors.New("smtp indisponível")
+	}
+	t.sent++
+	return nil
+}
+
+func TestSummaryScheduleService_SendDueSummaries_RetriesFailedEmail(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	admin := testutil.CreateTestUser(db, "admin@example.com", "Ana", "hash")
+	group := testutil.CreateTestGroup(db, "Família", admin.ID)
+	testutil.CreateTestGroupMember(db, group.ID, admin.ID, "admin")
+
+	transport := &switchableEmailTransport{failing: true}
+	service := NewSummaryScheduleService()
+	service.emailService = &EmailService{from: "financas@example.com", transport: transport}
+	if _, err := service.SaveGroupSchedule(group.ID, admin.ID, DefaultSummarySchedule(&group.ID)); err != nil {
+		t.Fatalf("SaveGroupSchedule() error = %v", err)
+	}
+	if _, err := service.SavePreference(admin.ID, &group.ID, true, true); err != nil {
+		t.Fatalf("SavePreference() error = %v", err)
+	}
+	db.Model(&models.SummarySchedule{}).Where("1 = 1").Update("created_at", time.Date(2029, 12, 1, 0, 0, 0, 0, time.Local))
+
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.Local)
+	if err := service.sendDueSummaries(now); err == nil {
+		t.Fatal("sendDueSummaries() error = nil, want the failed emails")
+	}
+
+	// The retry sends the emails without repeating the in-app summaries
+	transport.failing = false
+	for _, at := range []time.Time{now.Add(time.Hour), now.Add(2 * time.Hour)} {
+		if err := service.sendDueSummaries(at); err != nil {
+			t.Fatalf("sendDueSummaries() retry error = %v", err)
+		}
+	}
+	if transport.sent != 2 {
+		t.Errorf("sent %d emails, want the weekly and monthly summaries once", transport.sent)
+	}
+	var count int64
+	db.Model(&models.Notification{}).Where("user_id = ?", admin.ID).Count(&count)
+	if count != 2 {
+		t.Errorf("admin got %d notifications, want the weekly and monthly summaries once", count)
+	}
+}
@@ -0,0 +1,503 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+)
+
+var (
+	ErrWebhookNotFound   = errors.New("webhook não encontrado")
+	ErrWebhookNoEvents   = errors.New("selecione ao menos um evento")
+	ErrInvalidWebhookURL = errors.New("URL do webhook inválida")
+)
+
+// errWebhookAddressNotAllowed is returned when a webhook request would connect to an
+// internal address
+var errWebhookAddressNotAllowed = errors.New("endereço não permitido")
+
+// Headers sent with every webhook delivery. The signature is
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)).
+const (
+	WebhookSignatureHeader = "X-Finance-Signature"
+	WebhookTimestampHeader = "X-Finance-Timestamp"
+	WebhookEventHeader     = "X-Finance-Event"
+	WebhookDeliveryHeader  = "X-Finance-Delivery"
+)
+
+const (
+	// webhookMaxAttempts is how many times a delivery is tried before it is marked failed
+	webhookMaxAttempts = 6
+	// webhookBaseBackoff is the wait before the first retry; it doubles on each retry
+	// (1, 2, 4, 8 and 16 minutes)
+	webhookBaseBackoff = time.Minute
+)
+
+// WebhookEventOption is an event users can subscribe to, with its label
+type WebhookEventOption struct {
+	Event models.WebhookEvent
+	Label string
+}
+
+// WebhookEventOptions lists the subscribable events in display order
+var WebhookEventOptions = []WebhookEventOption{
+	{models.WebhookEventExpenseCreated, "Despesa criada"},
+	{models.WebhookEventIncomeCreated, "Recebimento criado"},
+	{models.WebhookEventBudgetThreshold, "Limite de orçamento atingido"},
+	{models.WebhookEventGoalReached, "Meta atingida"},
+	{models.WebhookEventDueDateUpcoming, "Vencimento próximo"},
+	{models.WebhookEventRecurringGenerated, "Transação recorrente gerada"},
+	{models.WebhookEventNotification, "Notificações (conforme as preferências de notificação)"},
+}
+
+// WebhookEventPayload is the JSON body posted to webhooks
+type WebhookEventPayload struct {
+	ID        string              `json:"id"`
+	Event     models.WebhookEvent `json:"event"`
+	CreatedAt time.Time           `json:"created_at"`
+	Data      interface{}         `json:"data"`
+}
+
+type WebhookService struct {
+	client *http.Client
+	// async runs first delivery attempts off the caller's request
+	async func(func())
+}
+
+func NewWebhookService() *WebhookService {
+	return &WebhookService{
+		client: newWebhookHTTPClient(10 * time.Second),
+		async:  func(f func()) { go f() },
+	}
+}
+
+// ListWebhooks returns the user's webhooks
+func (s *WebhookService) ListWebhooks(userID uint) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := database.DB.Where("user_id = ?", userID).Order("created_at").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// CreateWebhook registers a URL for the given events with a new signing secret
+func (s *WebhookService) CreateWebhook(userID uint, url, description string, events []models.WebhookEvent) (*models.Webhook, error) {
+	url = strings.TrimSpace(url)
+	if url == "" || ValidateWebhookURL(url) != nil {
+		return nil, ErrInvalidWebhookURL
+	}
+
+	var subscribed []string
+	for _, option := range WebhookEventOptions {
+		for _, event := range events {
+			if event == option.Event {
+				subscribed = append(subscribed, string(event))
+				break
+			}
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil, ErrWebhookNoEvents
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	webhook := &models.Webhook{
+		UserID:      userID,
+		URL:         url,
+		Description: strings.TrimSpace(description),
+		Secret:      secret,
+		Events:      strings.Join(subscribed, ","),
+		Active:      true,
+	}
+	if err := database.DB.Create(webhook).Error; err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func generateWebhookSecret() (string, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(bytes), nil
+}
+
+// GetWebhook returns one of the user's webhooks
+func (s *WebhookService) GetWebhook(webhookID, userID uint) (*models.Webhook, error) {
+	var webhook models.Webhook
+	if err := database.DB.Where("id = ? AND user_id = ?", webhookID, userID).First(&webhook).Error; err != nil {
+		return nil, ErrWebhookNotFound
+	}
+	return &webhook, nil
+}
+
+// SetActive pauses or resumes a webhook
+func (s *WebhookService) SetActive(webhookID, userID uint, active bool) error {
+	webhook, err := s.GetWebhook(webhookID, userID)
+	if err != nil {
+		return err
+	}
+	return database.DB.Model(webhook).Update("active", active).Error
+}
+
+// DeleteWebhook removes a webhook; its pending retries are dropped
+func (s *WebhookService) DeleteWebhook(webhookID, userID uint) error {
+	webhook, err := s.GetWebhook(webhookID, userID)
+	if err != nil {
+		return err
+	}
+	return database.DB.Delete(webhook).Error
+}
+
+// GetDeliveries returns the latest deliveries of one of the user's webhooks
+func (s *WebhookService) GetDeliveries(webhookID, userID uint, limit int) ([]models.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(webhookID, userID); err != nil {
+		return nil, err
+	}
+	var deliveries []models.WebhookDelivery
+	err := database.DB.Where("webhook_id = ?", webhookID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// SendTest delivers a test event right away, even to a paused webhook, and returns the
+// logged delivery
+func (s *WebhookService) SendTest(webhookID, userID uint) (*models.WebhookDelivery, error) {
+	webhook, err := s.GetWebhook(webhookID, userID)
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := s.enqueue(webhook, newWebhookEventPayload(models.WebhookEventTest, map[string]interface{}{
+		"message": "Webhook configurado corretamente",
+	}), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	s.attempt(delivery, time.Now())
+	return delivery, nil
+}
+
+func newWebhookEventPayload(event models.WebhookEvent, data interface{}) WebhookEventPayload {
+	id := make([]byte, 12)
+	rand.Read(id)
+	return WebhookEventPayload{ID: "evt_" + hex.EncodeToString(id), Event: event, CreatedAt: time.Now(), Data: data}
+}
+
+// Emit sends an event to the active webhooks of the given users that subscribe to it.
+// Deliveries are logged first and attempted in the background, so callers never wait on
+// (or fail because of) a webhook.
+func (s *WebhookService) Emit(userIDs []uint, event models.WebhookEvent, data interface{}) {
+	if err := s.emit(userIDs, event, data); err != nil {
+		log.Printf("Error emitting webhook event %s: %v", event, err)
+	}
+}
+
+// emit is Emit returning the errors logging the deliveries, for callers that retry
+func (s *WebhookService) emit(userIDs []uint, event models.WebhookEvent, data interface{}) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	var webhooks []models.Webhook
+	if err := database.DB.Where("user_id IN ? AND active = ?", userIDs, true).Find(&webhooks).Error; err != nil {
+		return fmt.Errorf("failed to load webhooks: %w", err)
+	}
+
+	payload := newWebhookEventPayload(event, data)
+	var deliveries []*models.WebhookDelivery
+	var errs []error
+	for i := range webhooks {
+		if !webhooks[i].Subscribes(event) {
+			continue
+		}
+		delivery, err := s.enqueue(&webhooks[i], payload, time.Now())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook %d: %w", webhooks[i].ID, err))
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if len(deliveries) > 0 {
+		s.async(func() {
+			for _, delivery := range deliveries {
+				s.attempt(delivery, time.Now())
+			}
+		})
+	}
+	return errors.Join(errs...)
+}
+
+// Subscribed reports whether the user has an active webhook that subscribes to the event
+func (s *WebhookService) Subscribed(userID uint, event models.WebhookEvent) bool {
+	var webhooks []models.Webhook
+	if err := database.DB.Where("user_id = ? AND active = ?", userID, true).Find(&webhooks).Error; err != nil {
+		return false
+	}
+	for _, webhook := range webhooks {
+		if webhook.Subscribes(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// EmitForAccount sends an event to the users of an account: its owner, or every member
+// of the group for joint accounts
+func (s *WebhookService) EmitForAccount(account *models.Account, event models.WebhookEvent, data interface{}) {
+	userIDs := []uint{account.UserID}
+	if account.GroupID != nil {
+		if err := database.DB.Model(&models.GroupMember{}).
+			Where("group_id = ?", *account.GroupID).
+			Pluck("user_id", &userIDs).Error; err != nil {
+			log.Printf("Error loading members of group %d for event %s: %v", *account.GroupID, event, err)
+			return
+		}
+	}
+	s.Emit(userIDs, event, data)
+}
+
+// EmitForUsers sends an event to the given users, e.g. the members notified of it
+func (s *WebhookService) EmitForUsers(users []models.User, event models.WebhookEvent, data interface{}) {
+	userIDs := make([]uint, len(users))
+	for i, user := range users {
+		userIDs[i] = user.ID
+	}
+	s.Emit(userIDs, event, data)
+}
+
+// enqueue logs a pending delivery, due immediately
+func (s *WebhookService) enqueue(webhook *models.Webhook, payload WebhookEventPayload, now time.Time) (*models.WebhookDelivery, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	delivery := &models.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		Event:         payload.Event,
+		Payload:       string(body),
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: &now,
+	}
+	if err := database.DB.Create(delivery).Error; err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// SignWebhookPayload computes the signature header value for a body sent at timestamp
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff is the wait after the given failed attempt
+func webhookBackoff(attempt int) time.Duration {
+	return webhookBaseBackoff << (attempt - 1)
+}
+
+// attempt posts a pending delivery once and records the outcome, scheduling a retry with
+// exponential backoff on failure. Deliveries of a paused webhook are held until it is
+// active again, except test events.
+func (s *WebhookService) attempt(delivery *models.WebhookDelivery, now time.Time) {
+	var webhook models.Webhook
+	found := database.DB.First(&webhook, delivery.WebhookID).Error == nil
+	if found && !webhook.Active && delivery.Event != models.WebhookEventTest {
+		return
+	}
+
+	// Claim the attempt so the retry worker and the first attempt never post twice. The
+	// claim also pushes the next try past the client timeout, so the worker can't pick up
+	// a delivery that is still in flight; the outcome below replaces it.
+	lease := now.Add(s.client.Timeout + webhookBackoff(delivery.Attempts+1))
+	claim := database.DB.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND attempts = ? AND status = ?", delivery.ID, delivery.Attempts, models.WebhookDeliveryPending).
+		Updates(map[string]interface{}{"attempts": delivery.Attempts + 1, "next_attempt_at": lease})
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return
+	}
+	delivery.Attempts++
+
+	updates := map[string]interface{}{}
+	if !found {
+		updates["status"] = models.WebhookDeliveryFailed
+		updates["error"] = "webhook removido"
+		updates["next_attempt_at"] = nil
+	} else {
+		status, err := s.post(&webhook, delivery, now)
+		updates["response_status"] = status
+		if err == nil {
+			updates["status"] = models.WebhookDeliverySuccess
+			updates["error"] = ""
+			updates["delivered_at"] = now
+			updates["next_attempt_at"] = nil
+		} else {
+			updates["error"] = err.Error()
+			if delivery.Attempts >= webhookMaxAttempts {
+				updates["status"] = models.WebhookDeliveryFailed
+				updates["next_attempt_at"] = nil
+			} else {
+				updates["next_attempt_at"] = now.Add(webhookBackoff(delivery.Attempts))
+			}
+		}
+	}
+
+	if err := database.DB.Model(delivery).Updates(updates).Error; err != nil {
+		log.Printf("Error logging webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// post sends the delivery's payload and returns the response status
+func (s *WebhookService) post(webhook *models.Webhook, delivery *models.WebhookDelivery, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "poc-finance-webhooks")
+	req.Header.Set(WebhookEventHeader, string(delivery.Event))
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, webhookErrorClass(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Lets the connection be reused
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, fmt.Errorf("status %d", resp.StatusCode)
+}
+
+// webhookErrorClass reduces a request error to the short class kept in the delivery log,
+// which never shows what the receiver or the network returned
+func webhookErrorClass(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errWebhookAddressNotAllowed):
+		return errWebhookAddressNotAllowed
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return errors.New("tempo esgotado")
+	default:
+		return errors.New("falha de conexão")
+	}
+}
+
+// RetryDueDeliveries retries the pending deliveries whose backoff has elapsed. Deliveries of
+// paused webhooks wait until they are active again; those of removed webhooks are failed.
+func (s *WebhookService) RetryDueDeliveries() error {
+	return s.retryDueDeliveries(time.Now())
+}
+
+func (s *WebhookService) retryDueDeliveries(now time.Time) error {
+	var deliveries []models.WebhookDelivery
+	if err := database.DB.Joins("JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id").
+		Where("webhook_deliveries.status = ? AND webhook_deliveries.next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+		Where("webhooks.active = ? OR webhooks.deleted_at IS NOT NULL", true).
+		Order("webhook_deliveries.id").
+		Limit(100).
+		Find(&deliveries).Error; err != nil {
+		return fmt.Errorf("failed to fetch webhook deliveries: %w", err)
+	}
+	for i := range deliveries {
+		s.attempt(&deliveries[i], now)
+	}
+	return nil
+}
+
+// ValidateWebhookURL accepts empty URLs (no webhook) and absolute http(s) URLs whose host
+// resolves only to public addresses, so webhooks can't reach the server's own network.
+// WEBHOOK_ALLOW_INTERNAL=true lifts the address check, for local development.
+func ValidateWebhookURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return ErrInvalidWebhookURL
+	}
+	if webhookInternalAllowed() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return ErrInvalidWebhookURL
+	}
+	for _, addr := range addrs {
+		if internalWebhookIP(addr.IP) {
+			return ErrInvalidWebhookURL
+		}
+	}
+	return nil
+}
+
+// webhookInternalAllowed reports whether webhooks may target internal addresses
+func webhookInternalAllowed() bool {
+	return os.Getenv("WEBHOOK_ALLOW_INTERNAL") == "true"
+}
+
+// internalWebhookIP reports whether an address belongs to the server's own network:
+// loopback, private (RFC 1918 and IPv6 ULA), link-local (cloud metadata included),
+// multicast or unspecified
+func internalWebhookIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// newWebhookHTTPClient returns the client webhook deliveries are posted with. Its dialer
+// checks the address actually connected to, so a host that resolved to a public address
+// when the webhook was saved can't be rebound to an internal one, and redirects to
+// internal addresses fail too. Proxies are bypassed, since they would hide the address.
+func newWebhookHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if webhookInternalAllowed() {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || internalWebhookIP(ip) {
+				return fmt.Errorf("%w: %s", errWebhookAddressNotAllowed, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
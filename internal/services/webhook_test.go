@@ -0,0 +1,400 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"poc-finance/internal/database"
+	"poc-finance/internal/models"
+	"poc-finance/internal/testutil"
+)
+
+// signedWebhookReceiver records the events it receives, checking their signature, and
+// answers with status
+type signedWebhookReceiver struct {
+	mu      sync.Mutex
+	secret  string
+	status  int
+	events  []WebhookEventPayload
+	invalid int
+}
+
+func (r *signedWebhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	timestamp := req.Header.Get(WebhookTimestampHeader)
+	if req.Header.Get(WebhookSignatureHeader) != SignWebhookPayload(r.secret, timestamp, body) {
+		r.invalid++
+	}
+	var payload WebhookEventPayload
+	json.Unmarshal(body, &payload)
+	r.events = append(r.events, payload)
+	w.WriteHeader(r.status)
+	if r.status >= 300 {
+		w.Write([]byte("internal detail from the receiver"))
+	}
+}
+
+func (r *signedWebhookReceiver) received() []WebhookEventPayload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]WebhookEventPayload(nil), r.events...)
+}
+
+func (r *signedWebhookReceiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func newTestWebhookService() *WebhookService {
+	service := NewWebhookService()
+	service.async = func(f func()) { f() }
+	return service
+}
+
+func TestWebhookService_CreateWebhook(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Ana", "hash")
+	service := newTestWebhookService()
+
+	if _, err := service.CreateWebhook(user.ID, "ftp://example.com", "", []models.WebhookEvent{models.WebhookEventGoalReached}); err != ErrInvalidWebhookURL {
+		t.Errorf("CreateWebhook() with an ftp URL error = %v, want ErrInvalidWebhookURL", err)
+	}
+	if _, err := service.CreateWebhook(user.ID, "https://203.0.113.10/hook", "", []models.WebhookEvent{"unknown.event"}); err != ErrWebhookNoEvents {
+		t.Errorf("CreateWebhook() without known events error = %v, want ErrWebhookNoEvents", err)
+	}
+
+	webhook, err := service.CreateWebhook(user.ID, " https://203.0.113.10/hook ", "Bot", []models.WebhookEvent{
+		models.WebhookEventGoalReached, models.WebhookEventExpenseCreated,
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+	if webhook.URL != "https://203.0.113.10/hook" || !webhook.Active || len(webhook.Secret) < 20 {
+		t.Errorf("webhook = %+v, want a trimmed, active webhook with a secret", webhook)
+	}
+	if webhook.Events != "expense.created,goal.reached" {
+		t.Errorf("Events = %q, want the events in display order", webhook.Events)
+	}
+	if !webhook.Subscribes(models.WebhookEventGoalReached) || webhook.Subscribes(models.WebhookEventIncomeCreated) {
+		t.Error("Subscribes() doesn't match the subscribed events")
+	}
+
+	other := testutil.CreateTestUser(db, "other@example.com", "Bia", "hash")
+	if err := service.DeleteWebhook(webhook.ID, other.ID); err != ErrWebhookNotFound {
+		t.Errorf("DeleteWebhook() by another user error = %v, want ErrWebhookNotFound", err)
+	}
+}
+
+func TestWebhookService_Deliveries(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Ana", "hash")
+	service := newTestWebhookService()
+	receiver := &signedWebhookReceiver{status: http.StatusOK}
+	t.Setenv("WEBHOOK_ALLOW_INTERNAL", "true") // The test server listens on loopback
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webhook, err := service.CreateWebhook(user.ID, server.URL, "", []models.WebhookEvent{models.WebhookEventExpenseCreated})
+	if err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+	receiver.secret = webhook.Secret
+
+	latest := func() models.WebhookDelivery {
+		var delivery models.WebhookDelivery
+		db.Order("id DESC").First(&delivery)
+		return delivery
+	}
+
+	t.Run("signed delivery of subscribed events", func(t *testing.T) {
+		service.Emit([]uint{user.ID}, models.WebhookEventExpenseCreated, map[string]interface{}{"name": "Mercado"})
+		service.Emit([]uint{user.ID}, models.WebhookEventIncomeCreated, map[string]interface{}{"description": "Salário"})
+
+		received := receiver.received()
+		if len(received) != 1 || received[0].Event != models.WebhookEventExpenseCreated {
+			t.Fatalf("received = %+v, want only the expense event", received)
+		}
+		if receiver.invalid != 0 {
+			t.Error("delivery signature doesn't match the webhook secret")
+		}
+		if delivery := latest(); delivery.Status != models.WebhookDeliverySuccess || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusOK {
+			t.Errorf("delivery = %+v, want a successful first attempt", delivery)
+		}
+	})
+
+	t.Run("retries with exponential backoff, then gives up", func(t *testing.T) {
+		receiver.setStatus(http.StatusInternalServerError)
+		service.Emit([]uint{user.ID}, models.WebhookEventExpenseCreated, map[string]interface{}{"name": "Farmácia"})
+
+		delivery := latest()
+		if delivery.Status != models.WebhookDeliveryPending || delivery.NextAttemptAt == nil {
+			t.Fatalf("delivery = %+v, want a pending retry", delivery)
+		}
+		now := time.Now()
+		for attempt := 1; attempt < webhookMaxAttempts; attempt++ {
+			wait := webhookBackoff(attempt)
+			if attempt > 1 && wait != 2*webhookBackoff(attempt-1) {
+				t.Errorf("backoff after attempt %d = %v, want double the previous one", attempt, wait)
+			}
+			service.retryDueDeliveries(now.Add(wait / 2)) // Too early
+			if latest().Attempts != attempt {
+				t.Fatalf("delivery was retried before its backoff elapsed (attempt %d)", attempt)
+			}
+			now = now.Add(wait)
+			service.retryDueDeliveries(now)
+		}
+
+		delivery = latest()
+		// The log keeps the status only, never what the receiver answered
+		if delivery.Status != models.WebhookDeliveryFailed || delivery.Attempts != webhookMaxAttempts || delivery.Error != "status 500" {
+			t.Errorf("delivery = %+v, want failed after %d attempts", delivery, webhookMaxAttempts)
+		}
+		service.retryDueDeliveries(now.Add(24 * time.Hour))
+		if latest().Attempts != webhookMaxAttempts {
+			t.Error("failed delivery was retried again")
+		}
+	})
+
+	t.Run("test send reaches paused webhooks", func(t *testing.T) {
+		receiver.setStatus(http.StatusNoContent)
+		if err := service.SetActive(webhook.ID, user.ID, false); err != nil {
+			t.Fatalf("SetActive() error = %v", err)
+		}
+		before := len(receiver.received())
+		service.Emit([]uint{user.ID}, models.WebhookEventExpenseCreated, nil)
+		if len(receiver.received()) != before {
+			t.Error("paused webhook received an event")
+		}
+
+		if _, err := service.SendTest(webhook.ID, user.ID); err != nil {
+			t.Fatalf("SendTest() error = %v", err)
+		}
+		received := receiver.received()
+		if len(received) != before+1 || received[len(received)-1].Event != models.WebhookEventTest {
+			t.Errorf("received = %+v, want the test event", received)
+		}
+
+		deliveries, err := service.GetDeliveries(webhook.ID, user.ID, 10)
+		if err != nil || len(deliveries) != 3 || deliveries[0].Status != models.WebhookDeliverySuccess {
+			t.Errorf("GetDeliveries() = %d deliveries, %v, want 3 with the test first", len(deliveries), err)
+		}
+	})
+}
+
+func TestWebhookService_PausedWebhookHoldsRetries(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Ana", "hash")
+	service := newTestWebhookService()
+	receiver := &signedWebhookReceiver{status: http.StatusInternalServerError}
+	t.Setenv("WEBHOOK_ALLOW_INTERNAL", "true") // The test server listens on loopback
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webhook, err := service.CreateWebhook(user.ID, server.URL, "", []models.WebhookEvent{models.WebhookEventExpenseCreated})
+	if err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+	receiver.secret = webhook.Secret
+	service.Emit([]uint{user.ID}, models.WebhookEventExpenseCreated, nil)
+
+	var delivery models.WebhookDelivery
+	db.First(&delivery)
+	if delivery.Status != models.WebhookDeliveryPending || delivery.Attempts != 1 {
+		t.Fatalf("delivery = %+v, want pending after a failed first attempt", delivery)
+	}
+
+	// While paused, neither the worker nor a direct attempt posts the delivery again
+	if err := service.SetActive(webhook.ID, user.ID, false); err != nil {
+		t.Fatalf("SetActive() error = %v", err)
+	}
+	later := time.Now().Add(24 * time.Hour)
+	if err := service.retryDueDeliveries(later); err != nil {
+		t.Fatalf("retryDueDeliveries() error = %v", err)
+	}
+	service.attempt(&delivery, later)
+	db.First(&delivery, delivery.ID)
+	if len(receiver.received()) != 1 || delivery.Attempts != 1 || delivery.Status != models.WebhookDeliveryPending {
+		t.Errorf("paused webhook got %d posts, delivery = %+v, want the delivery held", len(receiver.received()), delivery)
+	}
+
+	// Once active again, the held delivery is retried
+	receiver.setStatus(http.StatusOK)
+	if err := service.SetActive(webhook.ID, user.ID, true); err != nil {
+		t.Fatalf("SetActive() error = %v", err)
+	}
+	if err := service.retryDueDeliveries(later); err != nil {
+		t.Fatalf("retryDueDeliveries() error = %v", err)
+	}
+	db.First(&delivery, delivery.ID)
+	if delivery.Status != models.WebhookDeliverySuccess || delivery.Attempts != 2 {
+		t.Errorf("delivery = %+v, want delivered on the second attempt", delivery)
+	}
+}
+
+func TestWebhookService_RetryWorkerSkipsInFlightDelivery(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	user := testutil.CreateTestUser(db, "user@example.com", "Ana", "hash")
+	service := NewWebhookService() // First attempts run in a goroutine, as in production
+
+	// The first request blocks inside the handler until released, like a slow receiver
+	var mu sync.Mutex
+	requests := 0
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	t.Setenv("WEBHOOK_ALLOW_INTERNAL", "true") // The test server listens on loopback
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		first := requests == 1
+		mu.Unlock()
+		if first {
+			close(entered)
+			<-release
+		}
+	}))
+	defer server.Close()
+
+	if _, err := service.CreateWebhook(user.ID, server.URL, "", []models.WebhookEvent{models.WebhookEventExpenseCreated}); err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+	service.Emit([]uint{user.ID}, models.WebhookEventExpenseCreated, nil)
+	<-entered
+
+	if err := service.retryDueDeliveries(time.Now()); err != nil {
+		t.Fatalf("retryDueDeliveries() error = %v", err)
+	}
+	close(release)
+
+	var delivery models.WebhookDelivery
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		db.First(&delivery)
+		if delivery.Status != models.WebhookDeliveryPending {
+			break
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 1 {
+		t.Errorf("receiver got %d requests, want the in-flight delivery posted once", requests)
+	}
+	if delivery.Status != models.WebhookDeliverySuccess || delivery.Attempts != 1 {
+		t.Errorf("delivery = %+v, want a successful first attempt", delivery)
+	}
+}
+
+func TestWebhookService_EmitForAccount(t *testing.T) {
+	db := testutil.SetupTestDB()
+	database.DB = db
+
+	owner := testutil.CreateTestUser(db, "owner@example.com", "Ana", "hash")
+	partner := testutil.CreateTestUser(db, "partner@example.com", "Bia", "hash")
+	group := models.FamilyGroup{Name: "Casa", CreatedByID: owner.ID}
+	db.Create(&group)
+	db.Create(&models.GroupMember{GroupID: group.ID, UserID: owner.ID, Role: "admin"})
+	db.Create(&models.GroupMember{GroupID: group.ID, UserID: partner.ID, Role: "member"})
+
+	receiver := &signedWebhookReceiver{status: http.StatusOK}
+	t.Setenv("WEBHOOK_ALLOW_INTERNAL", "true") // The test server listens on loopback
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	service := newTestWebhookService()
+	events := []models.WebhookEvent{models.WebhookEventDueDateUpcoming}
+	if _, err := service.CreateWebhook(owner.ID, server.URL, "", events); err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+	if _, err := service.CreateWebhook(partner.ID, server.URL, "", events); err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+
+	service.EmitForAccount(&models.Account{UserID: owner.ID}, models.WebhookEventDueDateUpcoming, nil)
+	if got := len(receiver.received()); got != 1 {
+		t.Errorf("individual account event reached %d webhooks, want the owner's only", got)
+	}
+	service.EmitForAccount(&models.Account{UserID: owner.ID, GroupID: &group.ID}, models.WebhookEventDueDateUpcoming, nil)
+	if got := len(receiver.received()); got != 3 {
+		t.Errorf("got %d deliveries, want the joint account event sent to both members", got)
+	}
+}
+
+func TestWebhookErrorClass_RefusedAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	resp, err := newWebhookHTTPClient(time.Second).Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("Get() to a loopback address error = nil, want the dialer to refuse it")
+	}
+	// The log names the refusal without the address that was dialed
+	if class := webhookErrorClass(err); class != errWebhookAddressNotAllowed {
+		t.Errorf("webhookErrorClass() = %v, want %v", class, errWebhookAddressNotAllowed)
+	}
+}
+
+func TestValidateWebhookURL_InternalAddresses(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"", false},
+		{"https://203.0.113.10/hook", false},
+		{"http://[2001:db8::1]:8080/hook", false},
+		{"ftp://203.0.113.10/hook", true},
+		{"http://127.0.0.1:8080/hook", true},
+		{"http://localhost/hook", true},
+		{"http://10.0.0.5/hook", true},
+		{"http://172.16.3.4/hook", true},
+		{"http://192.168.1.1/hook", true},
+		{"http://169.254.169.254/latest/meta-data", true},
+		{"http://0.0.0.0/hook", true},
+		{"http://[::1]/hook", true},
+		{"http://[fd00::1]/hook", true},
+		{"http://[::ffff:127.0.0.1]/hook", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateWebhookURL(tt.url); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateWebhookURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+		}
+	}
+
+	t.Setenv("WEBHOOK_ALLOW_INTERNAL", "true")
+	if err := ValidateWebhookURL("http://127.0.0.1:8080/hook"); err != nil {
+		t.Errorf("ValidateWebhookURL() with internal targets allowed error = %v", err)
+	}
+}
+
+func TestWebhookHTTPClient_RefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// A host that resolved to a public address when saved may resolve to an internal one later
+	if resp, err := newWebhookHTTPClient(time.Second).Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("Get() to a loopback address error = nil, want the dialer to refuse it")
+	}
+
+	t.Setenv("WEBHOOK_ALLOW_INTERNAL", "true")
+	resp, err := newWebhookHTTPClient(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() with internal targets allowed error = %v", err)
+	}
+	resp.Body.Close()
+}
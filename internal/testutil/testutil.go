@@ -46,9 +46,28 @@ func SetupTestDB() *gorm.DB {
 		&models.Notification{},
 		&models.Settings{},
 		&models.RecurringTransaction{},
+		&models.RecurringOccurrenceOverride{},
 		&models.HealthScore{},
 		&models.Budget{},
 		&models.BudgetCategory{},
+		&models.BudgetAlert{},
+		&models.BudgetMove{},
+		&models.PeriodBudget{},
+		&models.PeriodBudgetCategory{},
+		&models.Subscription{},
+		&models.NetWorthItem{},
+		&models.NetWorthValuation{},
+		&models.RecommendationAction{},
+		&models.SummarySchedule{},
+		&models.SummaryPreference{},
+		&models.NotificationPreference{},
+		&models.NotificationSettings{},
+		&models.NotificationDelivery{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.JobRun{},
+		&models.JobLock{},
+		&models.JobIdempotencyKey{},
 	)
 	if err != nil {
 		panic("failed to migrate test database: " + err.Error())